@@ -0,0 +1,37 @@
+// Command fakemusicapi serves the /info contract of the external music API from
+// fixtures, for integration tests and demos. Point API_URL of the song library at it.
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"songlibrary/internal/lib/logger/utils"
+	"songlibrary/internal/musicapi"
+
+	"go.uber.org/zap"
+)
+
+func main() {
+	addr := flag.String("addr", ":8081", "address to listen on")
+	dir := flag.String("fixtures", "", "directory of YAML/JSON fixtures, the demo fixtures when empty")
+	latency := flag.Duration("latency", 0, "delay added to every response")
+	flag.Parse()
+
+	if err := utils.InitLogger(); err != nil {
+		log.Fatalf("Failed to initialize logger: %v", err)
+	}
+	defer utils.Logger.Sync()
+
+	fixtures, err := musicapi.LoadFixtures(*dir)
+	if err != nil {
+		utils.Logger.Fatal("Fixtures load failed", zap.Error(err))
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/info", musicapi.NewFake(fixtures, musicapi.FakeConfig{Latency: *latency}))
+
+	utils.Logger.Info("Fake music API starting", zap.String("address", *addr), zap.String("fixtures", *dir), zap.Int("count", fixtures.Len()))
+	log.Fatal(http.ListenAndServe(*addr, mux))
+}
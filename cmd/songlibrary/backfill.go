@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"songlibrary/config"
+	"songlibrary/internal/lib/normalize"
+	"songlibrary/internal/service"
+)
+
+const backfillKeysUsage = `usage: songlibrary backfill-keys [--dry-run]
+
+sets the identity keys of songs added before they existed and reports the songs
+whose names normalize to the same keys; --dry-run only reports`
+
+func runBackfillKeysCommand(cfg *config.Config, args []string) error {
+	dryRun := false
+	for _, arg := range args {
+		switch arg {
+		case "--dry-run", "-dry-run":
+			dryRun = true
+		default:
+			return fmt.Errorf("unknown argument %q\n%s", arg, backfillKeysUsage)
+		}
+	}
+
+	songStorage, closeStorage, err := initStorage(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeStorage()
+
+	report, err := service.BackfillKeys(context.Background(), songStorage, normalize.New(cfg.Articles), dryRun)
+	if err != nil {
+		return err
+	}
+	printBackfillReport(report, dryRun)
+	return nil
+}
+
+func printBackfillReport(report *service.BackfillReport, dryRun bool) {
+	verb := "updated"
+	if dryRun {
+		verb = "would update"
+	}
+	fmt.Printf("%d songs, %s %d, %d collisions\n", report.Songs, verb, report.Updated, len(report.Collisions))
+	if len(report.Collisions) == 0 {
+		return
+	}
+
+	fmt.Println("\nthe first song of each collision holds the keys, the others have none until merged or renamed:")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tID\tGROUP\tSONG")
+	for _, collision := range report.Collisions {
+		key := collision.GroupKey + " / " + collision.SongKey
+		for _, song := range collision.Songs {
+			fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", key, song.ID, song.GroupName, song.SongName)
+			key = ""
+		}
+	}
+	w.Flush()
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/joho/godotenv"
@@ -12,17 +14,19 @@ import (
 	"go.uber.org/zap"
 
 	"songlibrary/config"
+	"songlibrary/internal/api/handlers/health"
 	"songlibrary/internal/api/handlers/songs"
+	"songlibrary/internal/api/middleware"
 	"songlibrary/internal/lib/logger/utils"
+	"songlibrary/internal/lib/normalize"
+	"songlibrary/internal/migrator"
 	"songlibrary/internal/musicapi"
 	"songlibrary/internal/service"
+	"songlibrary/internal/storage"
+	"songlibrary/internal/storage/memory"
 	"songlibrary/internal/storage/postgres"
+	"songlibrary/internal/storage/sqlite"
 	_ "songlibrary/swagger/docs"
-
-	"github.com/golang-migrate/migrate/v4"
-	_ "github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
-	"github.com/jackc/pgx/v5"
 )
 
 // @title Online Library API
@@ -51,38 +55,76 @@ func main() {
 	}
 	utils.Logger.Debug("Configuration loaded", zap.Any("config", cfg))
 
-	// 3. Подключение к БД и запуск миграций
-	conn, err := pgx.Connect(context.Background(), cfg.DBURL)
-	if err != nil {
-		utils.Logger.Fatal("Database connection failed", zap.Error(err))
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrateCommand(cfg, os.Args[2:]); err != nil {
+			utils.Logger.Fatal("Migrate command failed", zap.Error(err))
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "backfill-keys" {
+		if err := runBackfillKeysCommand(cfg, os.Args[2:]); err != nil {
+			utils.Logger.Fatal("Backfill keys command failed", zap.Error(err))
+		}
 		return
 	}
-	defer conn.Close(context.Background())
-	utils.Logger.Info("Database connected")
 
-	if err := runMigrations(cfg.DBURL); err != nil {
-		utils.Logger.Fatal("Database migration failed", zap.Error(err))
+	// 3. Инициализация хранилища (подключение к БД и запуск миграций)
+	songStorage, closeStorage, err := initStorage(cfg)
+	if err != nil {
+		utils.Logger.Fatal("Storage initialization failed", zap.Error(err))
 		return
 	}
-	utils.Logger.Info("Database migrations completed successfully")
+	defer closeStorage()
 
-	// 4. Инициализация хранилища, music API клиента и сервиса
-	pgStorage := postgres.NewPgStorage(conn)
-	musicAPIClient := musicapi.NewMusicAPIClient(cfg.APIURL)
-	songService := service.NewSongService(pgStorage, musicAPIClient)
+	// 4. Инициализация music API клиента и сервиса
+	musicAPIClient, apiBreaker, err := initMusicAPI(cfg, songStorage)
+	if err != nil {
+		utils.Logger.Fatal("Music API initialization failed", zap.Error(err))
+		return
+	}
+	songService := service.NewSongService(songStorage, musicAPIClient, normalize.New(cfg.Articles), service.Config{
+		BatchWorkers: cfg.BatchWorkers,
+		Enrichment:   cfg.EnrichmentMode,
+	})
+	// Jobs may be left from a previous run in another mode, so the worker runs in every mode.
+	if queue, ok := songStorage.(storage.EnrichmentQueue); ok {
+		worker := service.NewEnrichmentWorker(songStorage, queue, musicAPIClient, service.EnrichmentWorkerConfig{
+			Workers:     cfg.EnrichmentWorkers,
+			MaxAttempts: cfg.EnrichmentMaxAttempts,
+			Backoff:     cfg.EnrichmentBackoff,
+			MaxBackoff:  cfg.EnrichmentMaxBackoff,
+		})
+		go worker.Run(context.Background())
+	}
 
 	// 5. Инициализация обработчиков API
 	songHandlers := songs.NewSongHandlers(songService)
+	statsReporter, _ := songStorage.(storage.StatsReporter)
+	healthHandlers := health.NewHealthHandlers(statsReporter, apiBreaker)
 
 	// 6. Настройка роутера
 	router := mux.NewRouter()
+	if idempotencyStore, ok := songStorage.(storage.IdempotencyStore); ok {
+		router.Use(middleware.Idempotency(idempotencyStore, cfg.IdempotencyTTL))
+		go purgeExpired(context.Background(), "idempotency keys", idempotencyStore.DeleteExpiredIdempotencyKeys, time.Hour)
+	}
 
 	// Регистрация эндпоинтов
 	router.HandleFunc("/health", songHandlers.HealthCheckHandler).Methods("GET")
+	router.HandleFunc("/health/stats", healthHandlers.StatsHandler).Methods("GET")
 	router.HandleFunc("/songs", songHandlers.GetSongsHandler).Methods("GET")
 	router.HandleFunc("/songs", songHandlers.AddSongHandler).Methods("POST")
+	router.HandleFunc("/songs/batch", songHandlers.AddSongsHandler).Methods("POST")
+	router.HandleFunc("/songs/export", songHandlers.ExportSongsHandler).Methods("GET")
+	router.HandleFunc("/songs/import", songHandlers.ImportSongsHandler).Methods("POST")
+	router.HandleFunc("/songs/refresh", songHandlers.RefreshSongsHandler).Methods("POST")
+	router.HandleFunc("/songs/search", songHandlers.SearchSongsHandler).Methods("GET")
 	router.HandleFunc("/songs/{id}/text", songHandlers.GetSongTextHandler).Methods("GET")
+	router.HandleFunc("/songs/{id}/enrichment", songHandlers.GetEnrichmentJobHandler).Methods("GET")
+	router.HandleFunc("/songs/{id}/enrichment", songHandlers.RetryEnrichmentHandler).Methods("POST")
+	router.HandleFunc("/songs/{id}/refresh", songHandlers.RefreshSongHandler).Methods("POST")
 	router.HandleFunc("/songs/{id}", songHandlers.UpdateSongHandler).Methods("PUT")
+	router.HandleFunc("/songs/{id}", songHandlers.PatchSongHandler).Methods("PATCH")
 	router.HandleFunc("/songs/{id}", songHandlers.DeleteSongHandler).Methods("DELETE")
 
 	// Регистрация Swagger UI
@@ -94,20 +136,137 @@ func main() {
 	log.Fatal(http.ListenAndServe(serverAddr, router))
 }
 
-func runMigrations(dbURL string) error {
-	migrationSourceURL := "file://internal/migrations"
-	m, err := migrate.New(migrationSourceURL, dbURL)
-	if err != nil {
-		return fmt.Errorf("failed to initialize migration: %w", err)
+// initMusicAPI chains the providers of song details configured. The breaker of the
+// external API is returned when it is one of them.
+func initMusicAPI(cfg *config.Config, songStorage storage.SongStorage) (musicapi.MusicAPI, musicapi.BreakerReporter, error) {
+	var providers []musicapi.Provider
+	var apiBreaker musicapi.BreakerReporter
+	for _, name := range cfg.Providers {
+		switch name {
+		case musicapi.ProviderAPI:
+			var api musicapi.MusicAPI
+			if cfg.APIURL == "" {
+				fixtures, err := musicapi.LoadFixtures(cfg.FakeFixturesDir)
+				if err != nil {
+					return nil, nil, err
+				}
+				utils.Logger.Info("API_URL is not configured, song details come from the fixtures of the fake external API",
+					zap.String("dir", cfg.FakeFixturesDir), zap.Int("fixtures", fixtures.Len()))
+				api = musicapi.NewFake(fixtures, musicapi.FakeConfig{Latency: cfg.FakeLatency})
+			} else {
+				api = musicapi.NewMusicAPIClient(cfg.APIURL, musicapi.Config{
+					Timeout:         cfg.APITimeout,
+					ConnectTimeout:  cfg.APIConnectTimeout,
+					MaxResponseSize: cfg.APIMaxResponseSize,
+				})
+			}
+			resilientClient := musicapi.NewResilientClient(api, musicapi.ResilienceConfig{
+				MaxAttempts:      cfg.APIMaxAttempts,
+				Backoff:          cfg.APIRetryBackoff,
+				MaxBackoff:       cfg.APIRetryMaxBackoff,
+				FailureThreshold: cfg.APIBreakerThreshold,
+				OpenTimeout:      cfg.APIBreakerOpenTimeout,
+			})
+			cacheConfig := musicapi.CacheConfig{
+				Size:        cfg.APICacheSize,
+				MaxBytes:    cfg.APICacheMaxBytes,
+				TTL:         cfg.APICacheTTL,
+				NegativeTTL: cfg.APICacheNegativeTTL,
+			}
+			if cfg.APICachePersistent {
+				if lookupCache, ok := songStorage.(storage.LookupCache); ok {
+					cacheConfig.Store = lookupCache
+					go purgeExpired(context.Background(), "API lookups", lookupCache.DeleteExpiredLookups, time.Hour)
+				} else {
+					utils.Logger.Warn("API_CACHE_PERSISTENT is ignored, the storage driver does not support it", zap.String("driver", cfg.StorageDriver))
+				}
+			}
+			providers = append(providers, musicapi.Provider{Name: name, MusicAPI: musicapi.NewCachedClient(resilientClient, cacheConfig)})
+			apiBreaker = resilientClient
+		case musicapi.ProviderLyrics:
+			providers = append(providers, musicapi.Provider{Name: name, MusicAPI: musicapi.NewLyricsDirectory(cfg.LyricsDir)})
+		case musicapi.ProviderCatalog:
+			catalog, err := musicapi.NewCatalog(cfg.CatalogFile)
+			if err != nil {
+				return nil, nil, err
+			}
+			providers = append(providers, musicapi.Provider{Name: name, MusicAPI: catalog})
+		}
 	}
+	utils.Logger.Info("Providers of song details configured", zap.Strings("providers", cfg.Providers))
+	return musicapi.NewChain(providers...), apiBreaker, nil
+}
+
+func initStorage(cfg *config.Config) (storage.SongStorage, func(), error) {
+	switch cfg.StorageDriver {
+	case config.StorageDriverMemory:
+		utils.Logger.Info("Using in-memory storage, data will not survive a restart")
+		return memory.NewMemStorage(), func() {}, nil
+	case config.StorageDriverSQLite:
+		db, err := sqlite.NewDB(context.Background(), cfg.SQLiteDSN)
+		if err != nil {
+			return nil, nil, fmt.Errorf("database connection failed: %w", err)
+		}
+		utils.Logger.Info("SQLite database opened", zap.String("dsn", cfg.SQLiteDSN))
+
+		if cfg.AutoMigrate {
+			if err := migrateUp(migrator.NewSQLite(db, cfg.MigrationLockTimeout)); err != nil {
+				db.Close()
+				return nil, nil, fmt.Errorf("database migration failed: %w", err)
+			}
+			utils.Logger.Info("Database migrations completed successfully")
+		}
+
+		return sqlite.NewSqliteStorage(db), func() { db.Close() }, nil
+	case config.StorageDriverPostgres:
+		pool, err := postgres.NewPool(context.Background(), cfg)
+		if err != nil {
+			return nil, nil, fmt.Errorf("database connection failed: %w", err)
+		}
+		utils.Logger.Info("Database connected", zap.Int32("max_conns", cfg.DBMaxConns), zap.Int32("min_conns", cfg.DBMinConns))
+		if err := postgres.CheckSearchLanguage(context.Background(), pool, cfg.SearchLanguage); err != nil {
+			pool.Close()
+			return nil, nil, err
+		}
 
-	if err := m.Down(); err != nil && err != migrate.ErrNoChange {
-		return fmt.Errorf("failed to run migrations: %w", err)
+		if cfg.AutoMigrate {
+			if err := migrateUp(migrator.NewPostgres(cfg.DBURL, cfg.MigrationLockTimeout)); err != nil {
+				pool.Close()
+				return nil, nil, fmt.Errorf("database migration failed: %w", err)
+			}
+			utils.Logger.Info("Database migrations completed successfully")
+		}
+
+		return postgres.NewPgStorage(pool, cfg.SearchLanguage), pool.Close, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported storage driver %q", cfg.StorageDriver)
 	}
+}
 
-	if err := m.Up(); err != nil && err != migrate.ErrNoChange {
-		return fmt.Errorf("failed to run migrations: %w", err)
+// purgeExpired calls purge, which deletes the expired records named what, every
+// interval until ctx is done.
+func purgeExpired(ctx context.Context, what string, purge func(context.Context) (int, error), interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deleted, err := purge(ctx)
+			if err != nil {
+				utils.Logger.Error("Failed to purge expired "+what, zap.Error(err))
+				continue
+			}
+			utils.Logger.Debug("Expired "+what+" purged", zap.Int("deleted", deleted))
+		}
 	}
+}
 
-	return nil
+func migrateUp(m *migrator.Migrator, err error) error {
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+	return m.Up()
 }
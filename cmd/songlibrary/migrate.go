@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"songlibrary/config"
+	"songlibrary/internal/migrator"
+	"songlibrary/internal/storage/sqlite"
+)
+
+const migrateUsage = `usage: songlibrary migrate <command>
+
+commands:
+  up          apply all pending migrations
+  down N      roll back the last N migrations
+  status      show applied and pending migrations
+  force V     set the migration version to V without running migrations
+              and clear the dirty flag (0 resets to "no migrations applied")`
+
+func runMigrateCommand(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	m, closeMigrator, err := openMigrator(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeMigrator()
+
+	switch args[0] {
+	case "up":
+		return m.Up()
+	case "down":
+		steps, err := intArg(args, "N")
+		if err != nil {
+			return err
+		}
+		return m.Down(steps)
+	case "force":
+		version, err := intArg(args, "V")
+		if err != nil {
+			return err
+		}
+		return m.Force(version)
+	case "status":
+		status, err := m.Status()
+		if err != nil {
+			return err
+		}
+		printMigrationStatus(status)
+		return nil
+	default:
+		return fmt.Errorf("unknown migrate command %q\n%s", args[0], migrateUsage)
+	}
+}
+
+func openMigrator(cfg *config.Config) (*migrator.Migrator, func(), error) {
+	switch cfg.StorageDriver {
+	case config.StorageDriverPostgres:
+		m, err := migrator.NewPostgres(cfg.DBURL, cfg.MigrationLockTimeout)
+		if err != nil {
+			return nil, nil, err
+		}
+		return m, func() { m.Close() }, nil
+	case config.StorageDriverSQLite:
+		db, err := sqlite.NewDB(context.Background(), cfg.SQLiteDSN)
+		if err != nil {
+			return nil, nil, err
+		}
+		m, err := migrator.NewSQLite(db, cfg.MigrationLockTimeout)
+		if err != nil {
+			db.Close()
+			return nil, nil, err
+		}
+		return m, func() { m.Close(); db.Close() }, nil
+	default:
+		return nil, nil, fmt.Errorf("storage driver %q has no migrations", cfg.StorageDriver)
+	}
+}
+
+func intArg(args []string, name string) (int, error) {
+	if len(args) != 2 {
+		return 0, fmt.Errorf("%s expects exactly one argument %s\n%s", args[0], name, migrateUsage)
+	}
+	value, err := strconv.Atoi(args[1])
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", name, args[1], err)
+	}
+	return value, nil
+}
+
+func printMigrationStatus(status *migrator.Status) {
+	state := "clean"
+	if status.Dirty {
+		state = "dirty"
+	}
+	fmt.Printf("current version: %d (%s)\n\n", status.Version, state)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATUS")
+	for _, migration := range status.Migrations {
+		applied := "pending"
+		if migration.Applied {
+			applied = "applied"
+		}
+		if status.Dirty && migration.Version == status.Version {
+			applied = "dirty"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", migration.Version, migration.Name, applied)
+	}
+	w.Flush()
+}
@@ -4,13 +4,28 @@ import (
 	"fmt"
 	"net/url"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
+
+	"songlibrary/internal/lib/normalize"
+	"songlibrary/internal/models"
+	"songlibrary/internal/musicapi"
+)
+
+const (
+	StorageDriverPostgres = "postgres"
+	StorageDriverMemory   = "memory"
+	StorageDriverSQLite   = "sqlite"
 )
 
 type Config struct {
+	StorageDriver string
+	SQLiteDSN     string
+
 	DBURL      string
 	DBHost     string
 	DBPort     int
@@ -19,11 +34,132 @@ type Config struct {
 	DBName     string
 	APIURL     string
 	ServerPort int
+
+	// FakeFixturesDir holds the fixtures of the fake external API used when APIURL is empty,
+	// the demo fixtures when empty. FakeLatency delays its every response.
+	FakeFixturesDir string
+	FakeLatency     time.Duration
+
+	// APITimeout bounds a whole call to the external API, APIConnectTimeout connecting to it.
+	APITimeout        time.Duration
+	APIConnectTimeout time.Duration
+	// APIMaxResponseSize is the largest external API response accepted, in bytes.
+	APIMaxResponseSize int64
+	// APIMaxAttempts is the number of calls made for one external API lookup. The delay before
+	// a retry is APIRetryBackoff, doubled with every retry up to APIRetryMaxBackoff.
+	APIMaxAttempts     int
+	APIRetryBackoff    time.Duration
+	APIRetryMaxBackoff time.Duration
+	// APIBreakerThreshold consecutive failed calls stop the calls to the external API for APIBreakerOpenTimeout.
+	APIBreakerThreshold   int
+	APIBreakerOpenTimeout time.Duration
+	// APICacheSize external API lookups, of APICacheMaxBytes in total, are cached in memory, found
+	// songs for APICacheTTL and unknown ones for APICacheNegativeTTL. APICachePersistent also
+	// caches them in the database.
+	APICacheSize        int
+	APICacheMaxBytes    int64
+	APICacheTTL         time.Duration
+	APICacheNegativeTTL time.Duration
+	APICachePersistent  bool
+
+	// Providers are the names of the sources of song details, in priority order, see
+	// musicapi.Chain. LyricsDir and CatalogFile configure the lyrics and catalog providers.
+	Providers   []string
+	LyricsDir   string
+	CatalogFile string
+
+	// SearchLanguage is the PostgreSQL text search configuration for lyrics, e.g. "english".
+	SearchLanguage string
+	// Articles are ignored when telling whether two song names are the same, e.g. "the".
+	Articles []string
+
+	AutoMigrate          bool
+	MigrationLockTimeout time.Duration
+
+	// IdempotencyTTL is how long the responses of requests made with an Idempotency-Key are kept.
+	IdempotencyTTL time.Duration
+	// BatchWorkers is the number of concurrent external API calls made to add or refresh batches of songs.
+	BatchWorkers int
+
+	// EnrichmentMode selects when new songs get their details from the external API.
+	EnrichmentMode models.EnrichmentMode
+	// EnrichmentWorkers is the number of enrichment jobs run concurrently.
+	EnrichmentWorkers int
+	// EnrichmentMaxAttempts is the number of attempts after which an enrichment job is dead.
+	EnrichmentMaxAttempts int
+	// EnrichmentBackoff is the delay before retrying a job, doubled with every attempt up to EnrichmentMaxBackoff.
+	EnrichmentBackoff    time.Duration
+	EnrichmentMaxBackoff time.Duration
+
+	DBMaxConns          int32
+	DBMinConns          int32
+	DBMaxConnLifetime   time.Duration
+	DBMaxConnIdleTime   time.Duration
+	DBHealthCheckPeriod time.Duration
 }
 
 func LoadConfig() (*Config, error) {
 	godotenv.Load()
 
+	storageDriver := strings.ToLower(os.Getenv("STORAGE_DRIVER"))
+	switch storageDriver {
+	case "":
+		storageDriver = StorageDriverPostgres
+	case StorageDriverPostgres, StorageDriverMemory, StorageDriverSQLite:
+	default:
+		return nil, fmt.Errorf("unknown STORAGE_DRIVER %q", storageDriver)
+	}
+
+	enrichmentMode := models.EnrichmentMode(strings.ToLower(os.Getenv("ENRICHMENT_MODE")))
+	switch enrichmentMode {
+	case "":
+		enrichmentMode = models.EnrichSync
+	case models.EnrichSync, models.EnrichAsync, models.EnrichFallback:
+	default:
+		return nil, fmt.Errorf("unknown ENRICHMENT_MODE %q", enrichmentMode)
+	}
+
+	sqliteDSN := os.Getenv("SQLITE_DSN")
+	if sqliteDSN == "" {
+		sqliteDSN = "songlibrary.db"
+	}
+
+	searchLanguage := strings.ToLower(os.Getenv("SEARCH_LANGUAGE"))
+	if searchLanguage == "" {
+		searchLanguage = "simple"
+	}
+	if !isIdentifier(searchLanguage) {
+		return nil, fmt.Errorf("invalid SEARCH_LANGUAGE %q", searchLanguage)
+	}
+
+	articles := slices.Clone(normalize.DefaultArticles)
+	if value, ok := os.LookupEnv("SONG_ARTICLES"); ok {
+		articles = nil
+		for _, article := range strings.Split(value, ",") {
+			if article = strings.TrimSpace(article); article != "" {
+				articles = append(articles, article)
+			}
+		}
+	}
+
+	providers, err := parseProviders(os.Getenv("PROVIDERS"))
+	if err != nil {
+		return nil, err
+	}
+	lyricsDir := os.Getenv("LYRICS_DIR")
+	if slices.Contains(providers, musicapi.ProviderLyrics) && lyricsDir == "" {
+		return nil, fmt.Errorf("LYRICS_DIR is required by the %s provider", musicapi.ProviderLyrics)
+	}
+	catalogFile := os.Getenv("CATALOG_FILE")
+	if slices.Contains(providers, musicapi.ProviderCatalog) && catalogFile == "" {
+		return nil, fmt.Errorf("CATALOG_FILE is required by the %s provider", musicapi.ProviderCatalog)
+	}
+
+	dbMaxConns, dbMinConns := getEnvInt("DB_MAX_CONNS", 10), getEnvInt("DB_MIN_CONNS", 2)
+	if dbMinConns > dbMaxConns {
+		return nil, fmt.Errorf("DB_MIN_CONNS (%d) must not exceed DB_MAX_CONNS (%d)", dbMinConns, dbMaxConns)
+	}
+
 	apiURL := os.Getenv("API_URL")
 	serverPortStr := os.Getenv("SERVER_PORT")
 	serverPort, err := strconv.Atoi(serverPortStr)
@@ -59,6 +195,9 @@ func LoadConfig() (*Config, error) {
 	dbName := strings.TrimPrefix(parsedDBURL.Path, "/")
 
 	return &Config{
+		StorageDriver: storageDriver,
+		SQLiteDSN:     sqliteDSN,
+
 		DBURL:      dbURL,
 		DBHost:     dbHost,
 		DBPort:     dbPortParsed,
@@ -67,5 +206,105 @@ func LoadConfig() (*Config, error) {
 		DBName:     dbName,
 		APIURL:     apiURL,
 		ServerPort: serverPort,
+
+		FakeFixturesDir: os.Getenv("FAKE_FIXTURES_DIR"),
+		FakeLatency:     getEnvDuration("FAKE_LATENCY", 0),
+
+		APITimeout:         getEnvDuration("API_TIMEOUT", 10*time.Second),
+		APIConnectTimeout:  getEnvDuration("API_CONNECT_TIMEOUT", 3*time.Second),
+		APIMaxResponseSize: int64(getEnvInt("API_MAX_RESPONSE_SIZE", 1<<20)),
+
+		APIMaxAttempts:        getEnvInt("API_MAX_ATTEMPTS", 3),
+		APIRetryBackoff:       getEnvDuration("API_RETRY_BACKOFF", 200*time.Millisecond),
+		APIRetryMaxBackoff:    getEnvDuration("API_RETRY_MAX_BACKOFF", 5*time.Second),
+		APIBreakerThreshold:   getEnvInt("API_BREAKER_THRESHOLD", 5),
+		APIBreakerOpenTimeout: getEnvDuration("API_BREAKER_OPEN_TIMEOUT", 30*time.Second),
+
+		APICacheSize:        getEnvInt("API_CACHE_SIZE", 1000),
+		APICacheMaxBytes:    int64(getEnvInt("API_CACHE_MAX_BYTES", 64<<20)),
+		APICacheTTL:         getEnvDuration("API_CACHE_TTL", 24*time.Hour),
+		APICacheNegativeTTL: getEnvDuration("API_CACHE_NEGATIVE_TTL", 10*time.Minute),
+		APICachePersistent:  getEnvBool("API_CACHE_PERSISTENT", false),
+
+		Providers:   providers,
+		LyricsDir:   lyricsDir,
+		CatalogFile: catalogFile,
+
+		SearchLanguage: searchLanguage,
+		Articles:       articles,
+
+		AutoMigrate:          getEnvBool("AUTO_MIGRATE", true),
+		MigrationLockTimeout: getEnvDuration("MIGRATION_LOCK_TIMEOUT", 5*time.Minute),
+
+		IdempotencyTTL: getEnvDuration("IDEMPOTENCY_TTL", 24*time.Hour),
+		BatchWorkers:   getEnvInt("BATCH_WORKERS", 8),
+
+		EnrichmentMode:        enrichmentMode,
+		EnrichmentWorkers:     getEnvInt("ENRICHMENT_WORKERS", 2),
+		EnrichmentMaxAttempts: getEnvInt("ENRICHMENT_MAX_ATTEMPTS", 5),
+		EnrichmentBackoff:     getEnvDuration("ENRICHMENT_BACKOFF", 10*time.Second),
+		EnrichmentMaxBackoff:  getEnvDuration("ENRICHMENT_MAX_BACKOFF", 10*time.Minute),
+
+		DBMaxConns:          int32(dbMaxConns),
+		DBMinConns:          int32(dbMinConns),
+		DBMaxConnLifetime:   getEnvDuration("DB_MAX_CONN_LIFETIME", time.Hour),
+		DBMaxConnIdleTime:   getEnvDuration("DB_MAX_CONN_IDLE_TIME", 30*time.Minute),
+		DBHealthCheckPeriod: getEnvDuration("DB_HEALTH_CHECK_PERIOD", time.Minute),
 	}, nil
 }
+
+// parseProviders parses the comma-separated PROVIDERS, the external API alone by default.
+func parseProviders(value string) ([]string, error) {
+	if strings.TrimSpace(value) == "" {
+		return []string{musicapi.ProviderAPI}, nil
+	}
+	var providers []string
+	for _, name := range strings.Split(value, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch name {
+		case musicapi.ProviderAPI, musicapi.ProviderLyrics, musicapi.ProviderCatalog:
+		default:
+			return nil, fmt.Errorf("unknown provider %q in PROVIDERS", name)
+		}
+		if slices.Contains(providers, name) {
+			return nil, fmt.Errorf("duplicate provider %q in PROVIDERS", name)
+		}
+		providers = append(providers, name)
+	}
+	return providers, nil
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// getEnvDuration falls back to defaultValue for durations that are not positive,
+// which none of the settings, the pool's health check period included, can use.
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return defaultValue
+	}
+	return value
+}
+
+func isIdentifier(s string) bool {
+	for _, r := range s {
+		if (r < 'a' || r > 'z') && r != '_' {
+			return false
+		}
+	}
+	return s != ""
+}
@@ -0,0 +1,33 @@
+package config_test
+
+import (
+	"testing"
+	"time"
+
+	"songlibrary/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfigDBHealthCheckPeriod(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  time.Duration
+	}{
+		{name: "Set", value: "30s", want: 30 * time.Second},
+		{name: "Zero", value: "0", want: time.Minute},
+		{name: "Negative", value: "-5s", want: time.Minute},
+		{name: "Invalid", value: "often", want: time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DB_HEALTH_CHECK_PERIOD", tt.value)
+
+			cfg, err := config.LoadConfig()
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, cfg.DBHealthCheckPeriod)
+		})
+	}
+}
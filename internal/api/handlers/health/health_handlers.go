@@ -0,0 +1,50 @@
+package health
+
+import (
+	"net/http"
+
+	"songlibrary/internal/lib/logger/utils"
+	"songlibrary/internal/lib/response"
+	"songlibrary/internal/musicapi"
+	"songlibrary/internal/storage"
+)
+
+type HealthHandlers struct {
+	dbStats    storage.StatsReporter
+	apiBreaker musicapi.BreakerReporter
+}
+
+func NewHealthHandlers(dbStats storage.StatsReporter, apiBreaker musicapi.BreakerReporter) *HealthHandlers {
+	return &HealthHandlers{
+		dbStats:    dbStats,
+		apiBreaker: apiBreaker,
+	}
+}
+
+type StatsResponse struct {
+	Database *storage.PoolStats     `json:"database,omitempty"`
+	MusicAPI *musicapi.BreakerStats `json:"musicApi,omitempty"`
+}
+
+// @Summary Show runtime statistics
+// @Description Get connection pool statistics of the storage backend and the circuit breaker state of the external music API.
+// @Tags root
+// @Produce json
+// @Success 200 {object} health.StatsResponse
+// @Router /health/stats [get]
+// @swaggo:operation GET /health/stats healthStats
+func (h *HealthHandlers) StatsHandler(w http.ResponseWriter, r *http.Request) {
+	utils.Logger.Debug("StatsHandler called")
+
+	var resp StatsResponse
+	if h.dbStats != nil {
+		stats := h.dbStats.Stats()
+		resp.Database = &stats
+	}
+	if h.apiBreaker != nil {
+		stats := h.apiBreaker.BreakerStats()
+		resp.MusicAPI = &stats
+	}
+
+	response.JSON(w, http.StatusOK, resp)
+}
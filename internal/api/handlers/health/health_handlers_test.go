@@ -0,0 +1,85 @@
+package health_test
+
+import (
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"songlibrary/internal/api/handlers/health"
+	"songlibrary/internal/lib/logger/utils"
+	"songlibrary/internal/musicapi"
+	"songlibrary/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	if err := utils.InitLogger(); err != nil {
+		log.Fatalf("Failed to initialize logger: %v", err)
+	}
+	exitCode := m.Run()
+	utils.Logger.Sync()
+	os.Exit(exitCode)
+}
+
+type fakeStatsReporter struct {
+	stats storage.PoolStats
+}
+
+func (f *fakeStatsReporter) Stats() storage.PoolStats {
+	return f.stats
+}
+
+type fakeBreakerReporter struct {
+	stats musicapi.BreakerStats
+}
+
+func (f *fakeBreakerReporter) BreakerStats() musicapi.BreakerStats {
+	return f.stats
+}
+
+func TestStatsHandler_Unit(t *testing.T) {
+	openUntil := time.Date(2024, 5, 1, 12, 0, 30, 0, time.UTC)
+	testCases := []struct {
+		name           string
+		dbStats        storage.StatsReporter
+		apiBreaker     musicapi.BreakerReporter
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Pool stats",
+			dbStats:        &fakeStatsReporter{stats: storage.PoolStats{TotalConns: 3, IdleConns: 2, AcquiredConns: 1, MaxConns: 10, AcquireCount: 42}},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"database":{"totalConns":3,"idleConns":2,"acquiredConns":1,"constructingConns":0,"maxConns":10,"acquireCount":42,"emptyAcquireCount":0,"canceledAcquireCount":0,"acquireDurationNs":0,"newConnsCount":0,"maxLifetimeDestroyCount":0,"maxIdleDestroyCount":0}}`,
+		},
+		{
+			name:           "Open circuit breaker",
+			apiBreaker:     &fakeBreakerReporter{stats: musicapi.BreakerStats{State: musicapi.BreakerOpen, ConsecutiveFailures: 5, OpenUntil: &openUntil}},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"musicApi":{"state":"open","consecutiveFailures":5,"openUntil":"2024-05-01T12:00:30Z"}}`,
+		},
+		{
+			name:           "No pool",
+			dbStats:        nil,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := health.NewHealthHandlers(tc.dbStats, tc.apiBreaker)
+			req := httptest.NewRequest("GET", "/health/stats", nil)
+			w := httptest.NewRecorder()
+
+			handler.StatsHandler(w, req)
+
+			assert.Equal(t, tc.expectedStatus, w.Code)
+			assert.JSONEq(t, tc.expectedBody, w.Body.String())
+		})
+	}
+}
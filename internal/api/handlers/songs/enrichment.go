@@ -0,0 +1,99 @@
+package songs
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+
+	"songlibrary/internal/lib/logger/utils"
+	"songlibrary/internal/lib/response"
+	"songlibrary/internal/service"
+	"songlibrary/internal/storage"
+)
+
+// @Summary Get the enrichment job of a song
+// @Description Get the job fetching the release date, text and link of a song added with ENRICHMENT_MODE async or fallback.
+// @Description Failed attempts are retried with exponential backoff until the job is dead, which marks the song's enrichmentStatus failed.
+// @Tags songs
+// @Produce json
+// @Param id path int true "Song ID"
+// @Success 200 {object} models.EnrichmentJob
+// @Failure 400 {string} string "Bad Request"
+// @Failure 404 {string} string "Not Found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /songs/{id}/enrichment [get]
+// @swaggo:operation GET /songs/{id}/enrichment getEnrichmentJob
+func (h *SongHandlers) GetEnrichmentJobHandler(w http.ResponseWriter, r *http.Request) {
+	utils.Logger.Info("GetEnrichmentJobHandler called")
+	idStr := mux.Vars(r)["id"]
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		utils.Logger.Warn("GetEnrichmentJobHandler - invalid song ID", zap.Error(err), zap.String("id", idStr))
+		response.Error(w, http.StatusBadRequest, "Invalid song ID")
+		return
+	}
+
+	job, err := h.songService.GetEnrichmentJob(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, storage.ErrJobNotFound) {
+			response.Error(w, http.StatusNotFound, "Enrichment job not found")
+			return
+		}
+		utils.Logger.Error("GetEnrichmentJobHandler - songService.GetEnrichmentJob failed", zap.Error(err), zap.Int("id", id))
+		response.Error(w, http.StatusInternalServerError, "Failed to get enrichment job")
+		return
+	}
+
+	response.JSON(w, http.StatusOK, job)
+	utils.Logger.Debug("GetEnrichmentJobHandler - enrichment job retrieved", zap.Int("song_id", id), zap.String("status", string(job.Status)))
+}
+
+// @Summary Retry the enrichment of a song
+// @Description Schedule a new job fetching the details a song is missing, e.g. after its job is dead. A pending or running job is returned as is.
+// @Tags songs
+// @Produce json
+// @Param id path int true "Song ID"
+// @Param Idempotency-Key header string false "Unique key making retries of the request safe, see README"
+// @Success 202 {object} models.EnrichmentJob
+// @Header 202 {string} Location "URL of the enrichment job"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 404 {string} string "Not Found"
+// @Failure 409 {string} string "Conflict"
+// @Failure 422 {string} string "Unprocessable Entity"
+// @Failure 500 {string} string "Internal Server Error"
+// @Failure 501 {string} string "Not Implemented"
+// @Router /songs/{id}/enrichment [post]
+// @swaggo:operation POST /songs/{id}/enrichment retryEnrichment
+func (h *SongHandlers) RetryEnrichmentHandler(w http.ResponseWriter, r *http.Request) {
+	utils.Logger.Info("RetryEnrichmentHandler called")
+	idStr := mux.Vars(r)["id"]
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		utils.Logger.Warn("RetryEnrichmentHandler - invalid song ID", zap.Error(err), zap.String("id", idStr))
+		response.Error(w, http.StatusBadRequest, "Invalid song ID")
+		return
+	}
+
+	job, err := h.songService.RetryEnrichment(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, storage.ErrSongNotFound) {
+			response.Error(w, http.StatusNotFound, "Song not found")
+			return
+		}
+		if errors.Is(err, service.ErrNoEnrichmentQueue) {
+			utils.Logger.Warn("RetryEnrichmentHandler - storage keeps no enrichment jobs", zap.Int("id", id))
+			response.Error(w, http.StatusNotImplemented, "The storage does not support enrichment jobs")
+			return
+		}
+		utils.Logger.Error("RetryEnrichmentHandler - songService.RetryEnrichment failed", zap.Error(err), zap.Int("id", id))
+		response.Error(w, http.StatusInternalServerError, "Failed to retry enrichment")
+		return
+	}
+
+	w.Header().Set("Location", songLocation(id)+"/enrichment")
+	response.JSON(w, http.StatusAccepted, job)
+	utils.Logger.Info("RetryEnrichmentHandler - enrichment scheduled", zap.Int("song_id", id), zap.String("status", string(job.Status)))
+}
@@ -0,0 +1,142 @@
+package songs_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"songlibrary/internal/api/handlers/songs"
+	"songlibrary/internal/models"
+	"songlibrary/internal/service"
+	mock_service "songlibrary/internal/service/mocks"
+	"songlibrary/internal/storage"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnrichmentHandlers_Unit(t *testing.T) {
+	runAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	job := &models.EnrichmentJob{SongID: 1, Status: models.JobPending, Attempts: 2, RunAt: runAt, LastError: "external API error", CreatedAt: runAt, UpdatedAt: runAt}
+	jobJSON := `{"songId":1,"status":"pending","attempts":2,"runAt":"2024-05-01T12:00:00Z","lastError":"external API error","createdAt":"2024-05-01T12:00:00Z","updatedAt":"2024-05-01T12:00:00Z"}`
+
+	testCases := []struct {
+		name             string
+		method           string
+		songID           string
+		mockServiceFn    func(s *mock_service.MockSongService)
+		expectedStatus   int
+		expectedLocation string
+		expectedBody     string
+	}{
+		{
+			name:   "Get job",
+			method: "GET",
+			songID: "1",
+			mockServiceFn: func(s *mock_service.MockSongService) {
+				s.EXPECT().GetEnrichmentJob(gomock.Any(), 1).Return(job, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   jobJSON,
+		},
+		{
+			name:   "Get missing job",
+			method: "GET",
+			songID: "2",
+			mockServiceFn: func(s *mock_service.MockSongService) {
+				s.EXPECT().GetEnrichmentJob(gomock.Any(), 2).Return(nil, storage.ErrJobNotFound)
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"error":"Enrichment job not found"}`,
+		},
+		{
+			name:           "Get with invalid ID",
+			method:         "GET",
+			songID:         "abc",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"Invalid song ID"}`,
+		},
+		{
+			name:   "Get service error",
+			method: "GET",
+			songID: "1",
+			mockServiceFn: func(s *mock_service.MockSongService) {
+				s.EXPECT().GetEnrichmentJob(gomock.Any(), 1).Return(nil, errors.New("service error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"error":"Failed to get enrichment job"}`,
+		},
+		{
+			name:   "Retry",
+			method: "POST",
+			songID: "1",
+			mockServiceFn: func(s *mock_service.MockSongService) {
+				s.EXPECT().RetryEnrichment(gomock.Any(), 1).Return(job, nil)
+			},
+			expectedStatus:   http.StatusAccepted,
+			expectedLocation: "/songs/1/enrichment",
+			expectedBody:     jobJSON,
+		},
+		{
+			name:   "Retry missing song",
+			method: "POST",
+			songID: "2",
+			mockServiceFn: func(s *mock_service.MockSongService) {
+				s.EXPECT().RetryEnrichment(gomock.Any(), 2).Return(nil, storage.ErrSongNotFound)
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"error":"Song not found"}`,
+		},
+		{
+			name:   "Retry without enrichment queue",
+			method: "POST",
+			songID: "1",
+			mockServiceFn: func(s *mock_service.MockSongService) {
+				s.EXPECT().RetryEnrichment(gomock.Any(), 1).Return(nil, service.ErrNoEnrichmentQueue)
+			},
+			expectedStatus: http.StatusNotImplemented,
+			expectedBody:   `{"error":"The storage does not support enrichment jobs"}`,
+		},
+		{
+			name:   "Retry service error",
+			method: "POST",
+			songID: "1",
+			mockServiceFn: func(s *mock_service.MockSongService) {
+				s.EXPECT().RetryEnrichment(gomock.Any(), 1).Return(nil, errors.New("service error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"error":"Failed to retry enrichment"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockService := mock_service.NewMockSongService(ctrl)
+			if tc.mockServiceFn != nil {
+				tc.mockServiceFn(mockService)
+			}
+
+			handler := songs.NewSongHandlers(mockService)
+
+			req := httptest.NewRequest(tc.method, "/songs/"+tc.songID+"/enrichment", nil)
+			req = mux.SetURLVars(req, map[string]string{"id": tc.songID})
+			w := httptest.NewRecorder()
+
+			if tc.method == "POST" {
+				handler.RetryEnrichmentHandler(w, req)
+			} else {
+				handler.GetEnrichmentJobHandler(w, req)
+			}
+
+			assert.Equal(t, tc.expectedStatus, w.Code)
+			assert.Equal(t, tc.expectedLocation, w.Header().Get("Location"))
+			assert.JSONEq(t, tc.expectedBody, w.Body.String())
+		})
+	}
+}
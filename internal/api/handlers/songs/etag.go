@@ -0,0 +1,80 @@
+package songs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"songlibrary/internal/lib/logger/utils"
+	"songlibrary/internal/lib/response"
+)
+
+// songETag is the strong entity tag of a song version. It is what If-Match must
+// hold to change the song.
+func songETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// contentETag is a weak entity tag derived from the JSON encoding of v, for
+// responses made of several songs.
+func contentETag(v interface{}) (string, error) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(body)
+	return `W/"` + hex.EncodeToString(sum[:16]) + `"`, nil
+}
+
+// writeJSONWithETag sets the ETag header and answers 304 Not Modified when the
+// If-None-Match header of the request already holds it, or writes v otherwise.
+func writeJSONWithETag(w http.ResponseWriter, r *http.Request, etag string, v interface{}) {
+	w.Header().Set("ETag", etag)
+	if noneMatch := r.Header.Get("If-None-Match"); noneMatch != "" && etagListContains(noneMatch, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	response.JSON(w, http.StatusOK, v)
+}
+
+// etagListContains reports whether the If-None-Match list holds etag, using the
+// weak comparison of RFC 9110: the W/ prefix is ignored.
+func etagListContains(list, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// ifMatchVersion returns the song version required by the If-Match header of the
+// request, 0 when the header is absent or "*". When the header is malformed or
+// cannot match a song version it answers the request and returns false.
+func ifMatchVersion(w http.ResponseWriter, r *http.Request) (int, bool) {
+	ifMatch := strings.TrimSpace(r.Header.Get("If-Match"))
+	if ifMatch == "" || ifMatch == "*" {
+		return 0, true
+	}
+	if strings.Contains(ifMatch, ",") {
+		utils.Logger.Warn("ifMatchVersion - several entity tags", zap.String("ifMatch", ifMatch))
+		response.Error(w, http.StatusBadRequest, "If-Match must hold a single ETag")
+		return 0, false
+	}
+
+	// If-Match uses the strong comparison, so weak tags never match.
+	version, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(ifMatch, `"`), `"`))
+	if err != nil || version < 1 || songETag(version) != ifMatch {
+		utils.Logger.Warn("ifMatchVersion - not a song version", zap.String("ifMatch", ifMatch))
+		response.Error(w, http.StatusPreconditionFailed, "Song version does not match If-Match")
+		return 0, false
+	}
+	return version, true
+}
@@ -0,0 +1,152 @@
+package songs
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+
+	"songlibrary/internal/lib/logger/utils"
+	"songlibrary/internal/lib/response"
+	"songlibrary/internal/models"
+	"songlibrary/internal/service"
+	"songlibrary/internal/storage"
+)
+
+// @Summary Refresh song details from the external API
+// @Description Fetch the release date, text and link of a song from the external API again and report the fields that differ from the stored ones.
+// @Description With apply=unedited (the default) only the changes of fields never edited with PUT or PATCH are stored, with apply=all all of them,
+// @Description with apply=none none. Fields the external API does not return are kept.
+// @Tags songs
+// @Produce json
+// @Param id path int true "Song ID"
+// @Param apply query string false "Which changes to store" Enums(unedited, all, none) default(unedited)
+// @Param Idempotency-Key header string false "Unique key making retries of the request safe, see README"
+// @Success 200 {object} models.RefreshResult
+// @Header 200 {string} ETag "Song version"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 404 {string} string "Not Found"
+// @Failure 409 {string} string "Conflict"
+// @Failure 422 {string} string "Unprocessable Entity"
+// @Failure 500 {string} string "Internal Server Error"
+// @Failure 503 {string} string "Service Unavailable"
+// @Router /songs/{id}/refresh [post]
+// @swaggo:operation POST /songs/{id}/refresh refreshSong
+func (h *SongHandlers) RefreshSongHandler(w http.ResponseWriter, r *http.Request) {
+	utils.Logger.Info("RefreshSongHandler called")
+	idStr := mux.Vars(r)["id"]
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		utils.Logger.Warn("RefreshSongHandler - invalid song ID", zap.Error(err), zap.String("id", idStr))
+		response.Error(w, http.StatusBadRequest, "Invalid song ID")
+		return
+	}
+	mode, ok := refreshMode(w, r)
+	if !ok {
+		return
+	}
+
+	result, err := h.songService.RefreshSong(r.Context(), id, mode)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrSongNotFound):
+			response.Error(w, http.StatusNotFound, "Song not found")
+		case errors.Is(err, storage.ErrVersionMismatch):
+			response.Error(w, http.StatusConflict, "Song was changed during the refresh")
+		case errors.Is(err, service.ErrExternalAPI):
+			utils.Logger.Error("RefreshSongHandler - songService.RefreshSong failed", zap.Error(err), zap.Int("id", id))
+			response.Error(w, http.StatusServiceUnavailable, "Failed to fetch song details")
+		default:
+			utils.Logger.Error("RefreshSongHandler - songService.RefreshSong failed", zap.Error(err), zap.Int("id", id))
+			response.Error(w, http.StatusInternalServerError, "Failed to refresh song")
+		}
+		return
+	}
+
+	w.Header().Set("ETag", songETag(result.Song.Version))
+	result.Location = songLocation(id)
+	response.JSON(w, http.StatusOK, result)
+	utils.Logger.Info("RefreshSongHandler - song refreshed", zap.Int("song_id", id), zap.String("status", string(result.Status)))
+}
+
+// @Summary Refresh the details of songs from the external API
+// @Description Refresh all songs, or those matching the filters, as POST /songs/{id}/refresh does, fetching their details concurrently.
+// @Description At most 1000 songs can be refreshed at once. The outcome of each song is reported separately: updated, unchanged,
+// @Description skipped (there are changes but apply stored none of them) or failed with the reason.
+// @Tags songs
+// @Produce json
+// @Param apply query string false "Which changes to store" Enums(unedited, all, none) default(unedited)
+// @Param group query string false "Filter by group name"
+// @Param song query string false "Filter by song name"
+// @Param match query string false "How group and song are matched, case-insensitively" Enums(substring, prefix, exact) default(substring)
+// @Param releaseDateFrom query string false "Earliest release date, inclusive" format(date)
+// @Param releaseDateTo query string false "Latest release date, inclusive" format(date)
+// @Param hasText query bool false "Only songs with (true) or without (false) text"
+// @Param hasLink query bool false "Only songs with (true) or without (false) a link"
+// @Param createdFrom query string false "Earliest creation time, inclusive, RFC 3339" format(date-time)
+// @Param createdTo query string false "Latest creation time, inclusive, RFC 3339" format(date-time)
+// @Param updatedFrom query string false "Earliest update time, inclusive, RFC 3339" format(date-time)
+// @Param updatedTo query string false "Latest update time, inclusive, RFC 3339" format(date-time)
+// @Param Idempotency-Key header string false "Unique key making retries of the request safe, see README"
+// @Success 200 {object} models.RefreshBatchResult
+// @Failure 400 {string} string "Bad Request"
+// @Failure 409 {string} string "Conflict"
+// @Failure 422 {string} string "Unprocessable Entity"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /songs/refresh [post]
+// @swaggo:operation POST /songs/refresh refreshSongs
+func (h *SongHandlers) RefreshSongsHandler(w http.ResponseWriter, r *http.Request) {
+	utils.Logger.Info("RefreshSongsHandler called")
+	mode, ok := refreshMode(w, r)
+	if !ok {
+		return
+	}
+	filter, err := parseSongFilter(r.URL.Query())
+	if err == nil {
+		err = filter.Validate()
+	}
+	if err != nil {
+		utils.Logger.Warn("RefreshSongsHandler - invalid filter", zap.Error(err), zap.String("query", r.URL.RawQuery))
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if filter.IsFuzzy() {
+		utils.Logger.Warn("RefreshSongsHandler - fuzzy match", zap.String("query", r.URL.RawQuery))
+		response.Error(w, http.StatusBadRequest, "invalid filter: fuzzy match is not supported by refresh")
+		return
+	}
+
+	result, err := h.songService.RefreshSongs(r.Context(), filter, mode)
+	if err != nil {
+		if errors.Is(err, service.ErrTooManySongs) {
+			response.Error(w, http.StatusUnprocessableEntity, err.Error()+", narrow it down")
+			return
+		}
+		utils.Logger.Error("RefreshSongsHandler - songService.RefreshSongs failed", zap.Error(err))
+		response.Error(w, http.StatusInternalServerError, "Failed to refresh songs")
+		return
+	}
+
+	for i := range result.Items {
+		result.Items[i].Location = songLocation(result.Items[i].ID)
+	}
+	response.JSON(w, http.StatusOK, result)
+	utils.Logger.Info("RefreshSongsHandler - songs refreshed", zap.Int("updated", result.Updated), zap.Int("unchanged", result.Unchanged), zap.Int("skipped", result.Skipped), zap.Int("failed", result.Failed))
+}
+
+// refreshMode returns the apply query parameter, models.RefreshUnedited by default,
+// or writes a 400 response and returns false when it is invalid.
+func refreshMode(w http.ResponseWriter, r *http.Request) (models.RefreshMode, bool) {
+	mode := models.RefreshMode(r.URL.Query().Get("apply"))
+	switch mode {
+	case "":
+		return models.RefreshUnedited, true
+	case models.RefreshUnedited, models.RefreshAll, models.RefreshNone:
+		return mode, true
+	}
+	utils.Logger.Warn("refreshMode - invalid apply", zap.String("apply", string(mode)))
+	response.Error(w, http.StatusBadRequest, "apply must be unedited, all or none")
+	return "", false
+}
@@ -0,0 +1,216 @@
+package songs_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"songlibrary/internal/api/handlers/songs"
+	"songlibrary/internal/models"
+	"songlibrary/internal/service"
+	mock_service "songlibrary/internal/service/mocks"
+	"songlibrary/internal/storage"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRefreshSongHandler_Unit(t *testing.T) {
+	newText := "Far away"
+	result := &models.RefreshResult{
+		ID:      1,
+		Status:  models.RefreshUpdated,
+		Changes: []models.FieldChange{{Field: models.FieldText, New: &newText, Applied: true}},
+		Song:    &models.Song{ID: 1, GroupName: "Muse", SongName: "Starlight", Version: 3},
+	}
+
+	testCases := []struct {
+		name           string
+		songID         string
+		queryParams    string
+		mockServiceFn  func(s *mock_service.MockSongService)
+		expectedStatus int
+		expectedETag   string
+		expectedError  string
+	}{
+		{
+			name:   "Unedited by default",
+			songID: "1",
+			mockServiceFn: func(s *mock_service.MockSongService) {
+				s.EXPECT().RefreshSong(gomock.Any(), 1, models.RefreshUnedited).Return(result, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedETag:   `"3"`,
+		},
+		{
+			name:        "Apply all",
+			songID:      "1",
+			queryParams: "?apply=all",
+			mockServiceFn: func(s *mock_service.MockSongService) {
+				s.EXPECT().RefreshSong(gomock.Any(), 1, models.RefreshAll).Return(result, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedETag:   `"3"`,
+		},
+		{
+			name:           "Invalid apply",
+			songID:         "1",
+			queryParams:    "?apply=some",
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "apply must be unedited, all or none",
+		},
+		{
+			name:           "Invalid ID",
+			songID:         "abc",
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "Invalid song ID",
+		},
+		{
+			name:   "Song not found",
+			songID: "2",
+			mockServiceFn: func(s *mock_service.MockSongService) {
+				s.EXPECT().RefreshSong(gomock.Any(), 2, models.RefreshUnedited).Return(nil, storage.ErrSongNotFound)
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedError:  "Song not found",
+		},
+		{
+			name:   "Concurrent update",
+			songID: "1",
+			mockServiceFn: func(s *mock_service.MockSongService) {
+				s.EXPECT().RefreshSong(gomock.Any(), 1, models.RefreshUnedited).Return(nil, storage.ErrVersionMismatch)
+			},
+			expectedStatus: http.StatusConflict,
+			expectedError:  "Song was changed during the refresh",
+		},
+		{
+			name:   "External API error",
+			songID: "1",
+			mockServiceFn: func(s *mock_service.MockSongService) {
+				s.EXPECT().RefreshSong(gomock.Any(), 1, models.RefreshUnedited).Return(nil, fmt.Errorf("fetch failed: %w", service.ErrExternalAPI))
+			},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedError:  "Failed to fetch song details",
+		},
+		{
+			name:   "Service error",
+			songID: "1",
+			mockServiceFn: func(s *mock_service.MockSongService) {
+				s.EXPECT().RefreshSong(gomock.Any(), 1, models.RefreshUnedited).Return(nil, errors.New("service error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedError:  "Failed to refresh song",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockService := mock_service.NewMockSongService(ctrl)
+			if tc.mockServiceFn != nil {
+				tc.mockServiceFn(mockService)
+			}
+			handler := songs.NewSongHandlers(mockService)
+
+			req := httptest.NewRequest("POST", "/songs/"+tc.songID+"/refresh"+tc.queryParams, nil)
+			req = mux.SetURLVars(req, map[string]string{"id": tc.songID})
+			w := httptest.NewRecorder()
+			handler.RefreshSongHandler(w, req)
+
+			assert.Equal(t, tc.expectedStatus, w.Code)
+			assert.Equal(t, tc.expectedETag, w.Header().Get("ETag"))
+			if tc.expectedError != "" {
+				assert.JSONEq(t, `{"error":"`+tc.expectedError+`"}`, w.Body.String())
+				return
+			}
+			var body models.RefreshResult
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			assert.Equal(t, models.RefreshUpdated, body.Status)
+			assert.Equal(t, "/songs/1", body.Location)
+			assert.Equal(t, result.Changes, body.Changes)
+		})
+	}
+}
+
+func TestRefreshSongsHandler_Unit(t *testing.T) {
+	testCases := []struct {
+		name           string
+		queryParams    string
+		mockServiceFn  func(s *mock_service.MockSongService)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:        "Refresh filtered songs",
+			queryParams: "?group=Muse&apply=none",
+			mockServiceFn: func(s *mock_service.MockSongService) {
+				s.EXPECT().RefreshSongs(gomock.Any(), gomock.Any(), models.RefreshNone).DoAndReturn(
+					func(_ any, filter *models.SongFilter, _ models.RefreshMode) (*models.RefreshBatchResult, error) {
+						assert.Equal(t, "Muse", *filter.GroupName)
+						return &models.RefreshBatchResult{
+							Items:     []models.RefreshResult{{ID: 1, Status: models.RefreshUnchanged, Changes: []models.FieldChange{}}, {ID: 2, Status: models.RefreshFailed, Changes: []models.FieldChange{}, Error: "failed to fetch song details"}},
+							Unchanged: 1,
+							Failed:    1,
+						}, nil
+					})
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"items":[{"id":1,"status":"unchanged","changes":[],"location":"/songs/1"},` +
+				`{"id":2,"status":"failed","changes":[],"location":"/songs/2","error":"failed to fetch song details"}],"updated":0,"unchanged":1,"skipped":0,"failed":1}`,
+		},
+		{
+			name:           "Fuzzy match",
+			queryParams:    "?group=Muse&match=fuzzy",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"invalid filter: fuzzy match is not supported by refresh"}`,
+		},
+		{
+			name:           "Invalid apply",
+			queryParams:    "?apply=edited",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"apply must be unedited, all or none"}`,
+		},
+		{
+			name: "Too many songs",
+			mockServiceFn: func(s *mock_service.MockSongService) {
+				s.EXPECT().RefreshSongs(gomock.Any(), gomock.Any(), models.RefreshUnedited).Return(nil, service.ErrTooManySongs)
+			},
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedBody:   `{"error":"more than 1000 songs match the filter, narrow it down"}`,
+		},
+		{
+			name: "Service error",
+			mockServiceFn: func(s *mock_service.MockSongService) {
+				s.EXPECT().RefreshSongs(gomock.Any(), gomock.Any(), models.RefreshUnedited).Return(nil, errors.New("service error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"error":"Failed to refresh songs"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockService := mock_service.NewMockSongService(ctrl)
+			if tc.mockServiceFn != nil {
+				tc.mockServiceFn(mockService)
+			}
+			handler := songs.NewSongHandlers(mockService)
+
+			req := httptest.NewRequest("POST", "/songs/refresh"+tc.queryParams, nil)
+			w := httptest.NewRecorder()
+			handler.RefreshSongsHandler(w, req)
+
+			assert.Equal(t, tc.expectedStatus, w.Code)
+			assert.JSONEq(t, tc.expectedBody, w.Body.String())
+		})
+	}
+}
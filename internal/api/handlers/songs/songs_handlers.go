@@ -3,12 +3,20 @@ package songs
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"mime"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/gorilla/mux"
 	"go.uber.org/zap"
 
+	"songlibrary/internal/lib/jsonpatch"
 	"songlibrary/internal/lib/logger/utils"
 	"songlibrary/internal/lib/response"
 	"songlibrary/internal/models"
@@ -16,6 +24,11 @@ import (
 	"songlibrary/internal/storage"
 )
 
+const maxSearchQueryLength = 256
+
+// maxPatchSize bounds PATCH request bodies, which are read whole before being applied.
+const maxPatchSize = 1 << 20
+
 type SongHandlers struct {
 	songService service.SongService
 }
@@ -28,13 +41,31 @@ func NewSongHandlers(songService service.SongService) *SongHandlers {
 
 // @Summary Get songs with filtering and pagination
 // @Description Get songs with optional filters for group and song name, and pagination.
+// @Description Pages are addressed either by number (page) or, for stable iteration over large lists, by the nextCursor of the previous page (cursor).
+// @Description When no song matches a group or song filter, suggestions lists similar existing names ("did you mean").
 // @Tags songs
 // @Produce json
 // @Param group query string false "Filter by group name"
 // @Param song query string false "Filter by song name"
-// @Param page query int false "Page number for pagination" default(1)
-// @Param pageSize query int false "Number of songs per page" default(10)
-// @Success 200 {array} models.Song
+// @Param match query string false "How group and song are matched, case-insensitively. fuzzy tolerates typos and orders songs by similarity" Enums(substring, prefix, exact, fuzzy) default(substring)
+// @Param releaseDateFrom query string false "Earliest release date, inclusive" format(date)
+// @Param releaseDateTo query string false "Latest release date, inclusive" format(date)
+// @Param hasText query bool false "Only songs with (true) or without (false) text"
+// @Param hasLink query bool false "Only songs with (true) or without (false) a link"
+// @Param createdFrom query string false "Earliest creation time, inclusive, RFC 3339" format(date-time)
+// @Param createdTo query string false "Latest creation time, inclusive, RFC 3339" format(date-time)
+// @Param updatedFrom query string false "Earliest update time, inclusive, RFC 3339" format(date-time)
+// @Param updatedTo query string false "Latest update time, inclusive, RFC 3339" format(date-time)
+// @Param page query int false "Page number for pagination" default(1) maximum(21474836)
+// @Param pageSize query int false "Number of songs per page" default(10) maximum(100)
+// @Param cursor query string false "Cursor returned as nextCursor by the previous page, excludes page"
+// @Param sort query string false "Comma-separated sort fields, '-' prefix for descending order. Fields: id, group, song, releaseDate, createdAt, updatedAt" default(id) example(-releaseDate,group)
+// @Param If-None-Match header string false "ETag of a cached response"
+// @Success 200 {object} models.SongList
+// @Header 200 {string} ETag "Weak ETag of the response"
+// @Success 304 "Not Modified"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 500 {string} string "Internal Server Error"
 // @Router /songs [get]
 // @swaggo:operation GET /songs getSongs
 func (h *SongHandlers) GetSongsHandler(w http.ResponseWriter, r *http.Request) {
@@ -45,12 +76,51 @@ func (h *SongHandlers) GetSongsHandler(w http.ResponseWriter, r *http.Request) {
 	pageSize, _ := strconv.Atoi(queryParams.Get("pageSize"))
 
 	pagination := models.NewPagination(page, pageSize)
+	if cursor := queryParams.Get("cursor"); cursor != "" {
+		after, err := models.DecodeCursor(cursor)
+		if err != nil {
+			utils.Logger.Warn("GetSongsHandler - invalid cursor", zap.Error(err), zap.String("cursor", cursor))
+			response.Error(w, http.StatusBadRequest, "Invalid cursor")
+			return
+		}
+		pagination.After = after
+	}
+	if err := pagination.Validate(); err != nil {
+		utils.Logger.Warn("GetSongsHandler - invalid pagination", zap.Error(err), zap.Any("pagination", pagination))
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
-	filter := &models.SongFilter{
-		GroupName: stringPointer(queryParams.Get("group")),
-		SongName:  stringPointer(queryParams.Get("song")),
+	sort, err := models.ParseSort(queryParams.Get("sort"))
+	if err != nil {
+		utils.Logger.Warn("GetSongsHandler - invalid sort", zap.Error(err), zap.String("sort", queryParams.Get("sort")))
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if pagination.After != nil {
+		if err := pagination.After.Validate(sort); err != nil {
+			utils.Logger.Warn("GetSongsHandler - cursor does not match sort", zap.Error(err), zap.Stringer("sort", sort))
+			response.Error(w, http.StatusBadRequest, "Invalid cursor")
+			return
+		}
 	}
 
+	filter, err := parseSongFilter(queryParams)
+	if err == nil {
+		err = filter.Validate()
+	}
+	if err != nil {
+		utils.Logger.Warn("GetSongsHandler - invalid filter", zap.Error(err), zap.String("query", r.URL.RawQuery))
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if filter.IsFuzzy() && pagination.After != nil {
+		utils.Logger.Warn("GetSongsHandler - cursor with fuzzy match", zap.String("query", r.URL.RawQuery))
+		response.Error(w, http.StatusBadRequest, "invalid filter: cursor is not supported with fuzzy match")
+		return
+	}
+	filter.Sort = sort
+
 	songs, err := h.songService.GetSongs(r.Context(), filter, pagination)
 	if err != nil {
 		utils.Logger.Error("GetSongsHandler - songService.GetSongs failed", zap.Error(err), zap.Any("filter", filter), zap.Any("pagination", pagination))
@@ -58,23 +128,108 @@ func (h *SongHandlers) GetSongsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	response.JSON(w, http.StatusOK, songs)
-	utils.Logger.Debug("GetSongsHandler - songs retrieved", zap.Int("count", len(songs)))
+	if pagination.After != nil {
+		if songs.NextCursor != "" {
+			songs.Next = cursorLink(r, songs.NextCursor)
+		}
+	} else {
+		if pagination.Page < songs.TotalPages {
+			songs.Next = pageLink(r, pagination.Page+1)
+		}
+		if pagination.Page > 1 {
+			songs.Prev = pageLink(r, min(pagination.Page-1, max(songs.TotalPages, 1)))
+		}
+	}
+
+	etag, err := contentETag(songs)
+	if err != nil {
+		utils.Logger.Error("GetSongsHandler - contentETag failed", zap.Error(err))
+		response.Error(w, http.StatusInternalServerError, "Failed to get songs")
+		return
+	}
+	writeJSONWithETag(w, r, etag, songs)
+	utils.Logger.Debug("GetSongsHandler - songs retrieved", zap.Int("count", len(songs.Items)), zap.Int("total", songs.TotalItems))
+}
+
+// @Summary Full-text search of songs
+// @Description Search songs by words of their group name, song name and text. Results are ranked by relevance,
+// @Description name matches rank higher than text matches. The snippet holds the matching verses with the matched
+// @Description words wrapped in <mark></mark>, the text being HTML-escaped. The query supports quoted phrases, "or" and "-" exclusions.
+// @Tags songs
+// @Produce json
+// @Param q query string true "Search query" maxlength(256)
+// @Param page query int false "Page number for pagination" default(1) maximum(21474836)
+// @Param pageSize query int false "Number of songs per page" default(10) maximum(100)
+// @Success 200 {object} models.SongSearchList
+// @Failure 400 {string} string "Bad Request"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /songs/search [get]
+// @swaggo:operation GET /songs/search searchSongs
+func (h *SongHandlers) SearchSongsHandler(w http.ResponseWriter, r *http.Request) {
+	utils.Logger.Info("SearchSongsHandler called")
+
+	queryParams := r.URL.Query()
+	query := strings.TrimSpace(queryParams.Get("q"))
+	if query == "" {
+		response.Error(w, http.StatusBadRequest, "Search query is required")
+		return
+	}
+	if utf8.RuneCountInString(query) > maxSearchQueryLength {
+		response.Error(w, http.StatusBadRequest, fmt.Sprintf("Search query must not exceed %d characters", maxSearchQueryLength))
+		return
+	}
+
+	page, _ := strconv.Atoi(queryParams.Get("page"))
+	pageSize, _ := strconv.Atoi(queryParams.Get("pageSize"))
+	pagination := models.NewPagination(page, pageSize)
+	if err := pagination.Validate(); err != nil {
+		utils.Logger.Warn("SearchSongsHandler - invalid pagination", zap.Error(err), zap.Any("pagination", pagination))
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	results, err := h.songService.SearchSongs(r.Context(), query, pagination)
+	if err != nil {
+		utils.Logger.Error("SearchSongsHandler - songService.SearchSongs failed", zap.Error(err), zap.String("query", query))
+		response.Error(w, http.StatusInternalServerError, "Failed to search songs")
+		return
+	}
+
+	response.JSON(w, http.StatusOK, results)
+	utils.Logger.Debug("SearchSongsHandler - songs found", zap.Int("count", len(results.Items)), zap.Int("total", results.TotalItems))
 }
 
 // @Summary Add a new song
 // @Description Add a new song to the library, fetching details from external API.
+// @Description With ENRICHMENT_MODE async, or fallback when the API fails, the song is added at once with enrichmentStatus pending
+// @Description and its details are filled in later by a job, see GET /songs/{id}/enrichment.
+// @Description A song with the same group and song names is a conflict, answered with 409 and the existing song's location
+// @Description unless onConflict is set: return answers 200 with the existing song, update refreshes its details first, keeping the ones edited by hand.
 // @Tags songs
 // @Accept json
 // @Produce json
 // @Param body body models.AddSongRequest true "Song details to add"
+// @Param onConflict query string false "What to do when the song already exists" Enums(return, update)
+// @Param Idempotency-Key header string false "Unique key making retries of the request safe, see README"
+// @Success 200 {object} models.Song "The song already existed"
 // @Success 201 {object} models.Song
+// @Header 200,201 {string} ETag "Song version"
+// @Header 201,409 {string} Location "URL of the song"
 // @Failure 400 {string} string "Bad Request"
+// @Failure 409 {object} models.SongConflict "Conflict"
+// @Failure 422 {string} string "Unprocessable Entity"
 // @Failure 500 {string} string "Internal Server Error"
 // @Router /songs [post]
 // @swaggo:operation POST /songs addSong
 func (h *SongHandlers) AddSongHandler(w http.ResponseWriter, r *http.Request) {
 	utils.Logger.Info("AddSongHandler called")
+	onConflict := models.ConflictMode(r.URL.Query().Get("onConflict"))
+	if onConflict != models.ConflictFail && onConflict != models.ConflictReturn && onConflict != models.ConflictUpdate {
+		utils.Logger.Warn("AddSongHandler - invalid onConflict", zap.String("onConflict", string(onConflict)))
+		response.Error(w, http.StatusBadRequest, "onConflict must be return or update")
+		return
+	}
+
 	var req models.AddSongRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		utils.Logger.Warn("AddSongHandler - invalid request body", zap.Error(err))
@@ -82,27 +237,95 @@ func (h *SongHandlers) AddSongHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.GroupName == "" || req.SongName == "" {
+	if strings.TrimSpace(req.GroupName) == "" || strings.TrimSpace(req.SongName) == "" {
 		utils.Logger.Warn("AddSongHandler - group and song names are required")
 		response.Error(w, http.StatusBadRequest, "Group and song names are required")
 		return
 	}
 
-	addedSong, err := h.songService.AddSong(r.Context(), &req)
+	addedSong, created, err := h.songService.AddSong(r.Context(), &req, onConflict)
 	if err != nil {
-		utils.Logger.Error("AddSongHandler - songService.AddSong failed", zap.Error(err))
-		statusCode := http.StatusInternalServerError
-		if errors.Is(err, service.ErrExternalAPI) {
-			statusCode = http.StatusServiceUnavailable
+		var existsErr *service.SongExistsError
+		switch {
+		case errors.As(err, &existsErr):
+			utils.Logger.Warn("AddSongHandler - song already exists", zap.Int("song_id", existsErr.Song.ID))
+			location := songLocation(existsErr.Song.ID)
+			w.Header().Set("Location", location)
+			response.JSON(w, http.StatusConflict, models.SongConflict{Error: "Song already exists", ID: existsErr.Song.ID, Location: location})
+		case errors.Is(err, storage.ErrSongAlreadyExists):
+			response.Error(w, http.StatusConflict, "Song already exists")
+		case errors.Is(err, service.ErrExternalAPI):
+			utils.Logger.Error("AddSongHandler - songService.AddSong failed", zap.Error(err))
+			response.Error(w, http.StatusServiceUnavailable, "Failed to add song")
+		default:
+			utils.Logger.Error("AddSongHandler - songService.AddSong failed", zap.Error(err))
+			response.Error(w, http.StatusInternalServerError, "Failed to add song")
 		}
-		response.Error(w, statusCode, "Failed to add song")
 		return
 	}
 
+	w.Header().Set("ETag", songETag(addedSong.Version))
+	if !created {
+		response.JSON(w, http.StatusOK, addedSong)
+		utils.Logger.Info("AddSongHandler - existing song returned", zap.Int("song_id", addedSong.ID), zap.String("onConflict", string(onConflict)))
+		return
+	}
+	w.Header().Set("Location", songLocation(addedSong.ID))
 	response.JSON(w, http.StatusCreated, addedSong)
 	utils.Logger.Info("AddSongHandler - song added successfully", zap.Int("song_id", addedSong.ID), zap.String("group", addedSong.GroupName), zap.String("song", addedSong.SongName))
 }
 
+// @Summary Add songs in bulk
+// @Description Add up to 1000 songs in a single transaction, fetching their details from the external API concurrently.
+// @Description The outcome of each item is reported separately: created, duplicate (the song exists, or repeats an earlier item) or failed with the reason.
+// @Description Duplicates and failures do not prevent the other songs from being added.
+// @Tags songs
+// @Accept json
+// @Produce json
+// @Param body body models.AddSongsRequest true "Songs to add"
+// @Param Idempotency-Key header string false "Unique key making retries of the request safe, see README"
+// @Success 200 {object} models.BatchResult
+// @Failure 400 {string} string "Bad Request"
+// @Failure 409 {string} string "Conflict"
+// @Failure 422 {string} string "Unprocessable Entity"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /songs/batch [post]
+// @swaggo:operation POST /songs/batch addSongs
+func (h *SongHandlers) AddSongsHandler(w http.ResponseWriter, r *http.Request) {
+	utils.Logger.Info("AddSongsHandler called")
+
+	var req models.AddSongsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.Logger.Warn("AddSongsHandler - invalid request body", zap.Error(err))
+		response.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if len(req.Items) == 0 || len(req.Items) > models.MaxBatchSize {
+		utils.Logger.Warn("AddSongsHandler - invalid batch size", zap.Int("items", len(req.Items)))
+		response.Error(w, http.StatusBadRequest, fmt.Sprintf("items must hold 1 to %d songs", models.MaxBatchSize))
+		return
+	}
+
+	result, err := h.songService.AddSongs(r.Context(), req.Items)
+	if err != nil {
+		utils.Logger.Error("AddSongsHandler - songService.AddSongs failed", zap.Error(err))
+		response.Error(w, http.StatusInternalServerError, "Failed to add songs")
+		return
+	}
+
+	for i := range result.Items {
+		if result.Items[i].ID != 0 {
+			result.Items[i].Location = songLocation(result.Items[i].ID)
+		}
+	}
+	response.JSON(w, http.StatusOK, result)
+	utils.Logger.Info("AddSongsHandler - batch processed", zap.Int("created", result.Created), zap.Int("duplicates", result.Duplicates), zap.Int("failed", result.Failed))
+}
+
+func songLocation(id int) string {
+	return "/songs/" + strconv.Itoa(id)
+}
+
 // @Summary Get song text by ID with pagination
 // @Description Get the text of a song by its ID, with pagination for verses.
 // @Tags songs
@@ -110,7 +333,10 @@ func (h *SongHandlers) AddSongHandler(w http.ResponseWriter, r *http.Request) {
 // @Param id path int true "Song ID"
 // @Param page query int false "Page number for verses" default(1)
 // @Param pageSize query int false "Number of verses per page" default(1)
+// @Param If-None-Match header string false "ETag of a cached response"
 // @Success 200 {object} models.Song
+// @Header 200 {string} ETag "Song version"
+// @Success 304 "Not Modified"
 // @Failure 404 {string} string "Not Found"
 // @Failure 500 {string} string "Internal Server Error"
 // @Router /songs/{id}/text [get]
@@ -143,20 +369,27 @@ func (h *SongHandlers) GetSongTextHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	response.JSON(w, http.StatusOK, song)
+	writeJSONWithETag(w, r, songETag(song.Version), song)
 	utils.Logger.Debug("GetSongTextHandler - song text retrieved", zap.Int("song_id", song.ID))
 }
 
 // @Summary Update song by ID
-// @Description Update an existing song's details.
+// @Description Update an existing song's details. Send the ETag of the song as If-Match to make sure
+// @Description nobody changed it since it was read; the version field of the body is ignored.
 // @Tags songs
 // @Accept json
 // @Produce json
 // @Param id path int true "Song ID"
 // @Param body body models.Song true "Song details to update"
+// @Param If-Match header string false "ETag of the song version being changed, e.g. \"3\", or *"
+// @Param Idempotency-Key header string false "Unique key making retries of the request safe, see README"
 // @Success 200 {object} models.Song
+// @Header 200 {string} ETag "Song version"
 // @Failure 400 {string} string "Bad Request"
 // @Failure 404 {string} string "Not Found"
+// @Failure 409 {string} string "Conflict"
+// @Failure 412 {string} string "Precondition Failed"
+// @Failure 422 {string} string "Unprocessable Entity"
 // @Failure 500 {string} string "Internal Server Error"
 // @Router /songs/{id} [put]
 // @swaggo:operation PUT /songs/{id} updateSong
@@ -171,6 +404,11 @@ func (h *SongHandlers) UpdateSongHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	version, ok := ifMatchVersion(w, r)
+	if !ok {
+		return
+	}
+
 	var updatedSongData models.Song
 	if err := json.NewDecoder(r.Body).Decode(&updatedSongData); err != nil {
 		utils.Logger.Warn("UpdateSongHandler - invalid request body", zap.Error(err))
@@ -178,6 +416,7 @@ func (h *SongHandlers) UpdateSongHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 	updatedSongData.ID = id
+	updatedSongData.Version = version
 
 	updatedSong, err := h.songService.UpdateSong(r.Context(), &updatedSongData)
 	if err != nil {
@@ -185,22 +424,117 @@ func (h *SongHandlers) UpdateSongHandler(w http.ResponseWriter, r *http.Request)
 			response.Error(w, http.StatusNotFound, "Song not found")
 			return
 		}
+		if errors.Is(err, storage.ErrVersionMismatch) {
+			response.Error(w, http.StatusPreconditionFailed, "Song version does not match If-Match")
+			return
+		}
+		if errors.Is(err, storage.ErrSongAlreadyExists) {
+			response.Error(w, http.StatusConflict, "Another song has the same group and song names")
+			return
+		}
 		utils.Logger.Error("UpdateSongHandler - songService.UpdateSong failed", zap.Error(err), zap.Int("id", id))
 		response.Error(w, http.StatusInternalServerError, "Failed to update song")
 		return
 	}
 
+	w.Header().Set("ETag", songETag(updatedSong.Version))
 	response.JSON(w, http.StatusOK, updatedSong)
 	utils.Logger.Info("UpdateSongHandler - song updated successfully", zap.Int("song_id", updatedSong.ID), zap.String("group", updatedSong.GroupName), zap.String("song", updatedSong.SongName))
 }
 
+// @Summary Partially update song by ID
+// @Description Change some fields of a song, leaving the others as they are. The patch applies to the document
+// @Description {"group", "song", "releaseDate", "text", "link"}, where releaseDate is YYYY-MM-DD and a missing
+// @Description releaseDate, text or link is null. Send a JSON Merge Patch (RFC 7396) as application/merge-patch+json
+// @Description or application/json, e.g. {"text": null} removes the text, or a JSON Patch (RFC 6902) as application/json-patch+json.
+// @Tags songs
+// @Accept application/merge-patch+json,application/json-patch+json,json
+// @Produce json
+// @Param id path int true "Song ID"
+// @Param body body models.SongDocument true "Merge patch or JSON Patch of the song document"
+// @Param If-Match header string false "ETag of the song version being changed, e.g. \"3\", or *"
+// @Param Idempotency-Key header string false "Unique key making retries of the request safe, see README"
+// @Success 200 {object} models.Song
+// @Header 200 {string} ETag "Song version"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 404 {string} string "Not Found"
+// @Failure 409 {string} string "Conflict"
+// @Failure 412 {string} string "Precondition Failed"
+// @Failure 415 {string} string "Unsupported Media Type"
+// @Failure 422 {string} string "Unprocessable Entity"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /songs/{id} [patch]
+// @swaggo:operation PATCH /songs/{id} patchSong
+func (h *SongHandlers) PatchSongHandler(w http.ResponseWriter, r *http.Request) {
+	utils.Logger.Info("PatchSongHandler called")
+	vars := mux.Vars(r)
+	idStr := vars["id"]
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		utils.Logger.Warn("PatchSongHandler - invalid song ID", zap.Error(err), zap.String("id", idStr))
+		response.Error(w, http.StatusBadRequest, "Invalid song ID")
+		return
+	}
+
+	version, ok := ifMatchVersion(w, r)
+	if !ok {
+		return
+	}
+
+	format, ok := patchFormat(r.Header.Get("Content-Type"))
+	if !ok {
+		utils.Logger.Warn("PatchSongHandler - unsupported content type", zap.String("contentType", r.Header.Get("Content-Type")))
+		response.Error(w, http.StatusUnsupportedMediaType, fmt.Sprintf("Content-Type must be %s or %s", models.MergePatch, models.JSONPatch))
+		return
+	}
+
+	patch, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPatchSize))
+	if err != nil {
+		utils.Logger.Warn("PatchSongHandler - invalid request body", zap.Error(err))
+		response.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	song, err := h.songService.PatchSong(r.Context(), id, version, format, patch)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrSongNotFound):
+			response.Error(w, http.StatusNotFound, "Song not found")
+		case errors.Is(err, storage.ErrVersionMismatch):
+			response.Error(w, http.StatusPreconditionFailed, "Song version does not match If-Match")
+		case errors.Is(err, jsonpatch.ErrInvalidPatch):
+			response.Error(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, storage.ErrSongAlreadyExists):
+			response.Error(w, http.StatusConflict, "Another song has the same group and song names")
+		case errors.Is(err, jsonpatch.ErrConflict):
+			response.Error(w, http.StatusConflict, err.Error())
+		case errors.Is(err, models.ErrInvalidSong):
+			response.Error(w, http.StatusUnprocessableEntity, err.Error())
+		default:
+			utils.Logger.Error("PatchSongHandler - songService.PatchSong failed", zap.Error(err), zap.Int("id", id))
+			response.Error(w, http.StatusInternalServerError, "Failed to update song")
+		}
+		return
+	}
+
+	w.Header().Set("ETag", songETag(song.Version))
+	response.JSON(w, http.StatusOK, song)
+	utils.Logger.Info("PatchSongHandler - song patched successfully", zap.Int("song_id", song.ID), zap.String("group", song.GroupName), zap.String("song", song.SongName))
+}
+
 // @Summary Delete song by ID
 // @Description Delete a song from the library.
 // @Tags songs
 // @Produce json
 // @Param id path int true "Song ID"
+// @Param If-Match header string false "ETag of the song version being changed, e.g. \"3\", or *"
+// @Param Idempotency-Key header string false "Unique key making retries of the request safe, see README"
 // @Success 204 "No Content"
+// @Failure 400 {string} string "Bad Request"
 // @Failure 404 {string} string "Not Found"
+// @Failure 409 {string} string "Conflict"
+// @Failure 412 {string} string "Precondition Failed"
+// @Failure 422 {string} string "Unprocessable Entity"
 // @Failure 500 {string} string "Internal Server Error"
 // @Router /songs/{id} [delete]
 // @swaggo:operation DELETE /songs/{id} deleteSong
@@ -215,12 +549,21 @@ func (h *SongHandlers) DeleteSongHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err = h.songService.DeleteSong(r.Context(), id)
+	version, ok := ifMatchVersion(w, r)
+	if !ok {
+		return
+	}
+
+	err = h.songService.DeleteSong(r.Context(), id, version)
 	if err != nil {
 		if errors.Is(err, storage.ErrSongNotFound) {
 			response.Error(w, http.StatusNotFound, "Song not found")
 			return
 		}
+		if errors.Is(err, storage.ErrVersionMismatch) {
+			response.Error(w, http.StatusPreconditionFailed, "Song version does not match If-Match")
+			return
+		}
 		utils.Logger.Error("DeleteSongHandler - songService.DeleteSong failed", zap.Error(err), zap.Int("id", id))
 		response.Error(w, http.StatusInternalServerError, "Failed to delete song")
 		return
@@ -243,6 +586,95 @@ func (h *SongHandlers) HealthCheckHandler(w http.ResponseWriter, r *http.Request
 	w.Write([]byte("OK"))
 }
 
+// patchFormat maps the Content-Type of a PATCH request to the patch format.
+// Plain JSON is taken as a merge patch.
+func patchFormat(contentType string) (models.PatchFormat, bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", false
+	}
+	switch format := models.PatchFormat(mediaType); format {
+	case models.MergePatch, models.JSONPatch:
+		return format, true
+	case "application/json":
+		return models.MergePatch, true
+	}
+	return "", false
+}
+
+// pageLink returns the request URL pointing to another page, keeping the other query parameters.
+func pageLink(r *http.Request, page int) string {
+	return linkWith(r, "page", strconv.Itoa(page))
+}
+
+// cursorLink is pageLink for keyset pages.
+func cursorLink(r *http.Request, cursor string) string {
+	return linkWith(r, "cursor", cursor)
+}
+
+func linkWith(r *http.Request, key, value string) string {
+	u := *r.URL
+	query := u.Query()
+	query.Set(key, value)
+	u.RawQuery = query.Encode()
+	return u.RequestURI()
+}
+
+func parseSongFilter(queryParams url.Values) (*models.SongFilter, error) {
+	filter := &models.SongFilter{
+		GroupName:       stringPointer(queryParams.Get("group")),
+		SongName:        stringPointer(queryParams.Get("song")),
+		Match:           models.MatchMode(queryParams.Get("match")),
+		ReleaseDateFrom: stringPointer(queryParams.Get("releaseDateFrom")),
+		ReleaseDateTo:   stringPointer(queryParams.Get("releaseDateTo")),
+	}
+
+	var err error
+	if filter.HasText, err = boolParam(queryParams, "hasText"); err != nil {
+		return nil, err
+	}
+	if filter.HasLink, err = boolParam(queryParams, "hasLink"); err != nil {
+		return nil, err
+	}
+	if filter.CreatedFrom, err = timeParam(queryParams, "createdFrom"); err != nil {
+		return nil, err
+	}
+	if filter.CreatedTo, err = timeParam(queryParams, "createdTo"); err != nil {
+		return nil, err
+	}
+	if filter.UpdatedFrom, err = timeParam(queryParams, "updatedFrom"); err != nil {
+		return nil, err
+	}
+	if filter.UpdatedTo, err = timeParam(queryParams, "updatedTo"); err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
+
+func boolParam(queryParams url.Values, name string) (*bool, error) {
+	value := queryParams.Get(name)
+	if value == "" {
+		return nil, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s must be true or false", models.ErrInvalidFilter, name)
+	}
+	return &b, nil
+}
+
+func timeParam(queryParams url.Values, name string) (*time.Time, error) {
+	value := queryParams.Get(name)
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s must be an RFC 3339 timestamp", models.ErrInvalidFilter, name)
+	}
+	return &t, nil
+}
+
 func stringPointer(s string) *string {
 	if s == "" {
 		return nil
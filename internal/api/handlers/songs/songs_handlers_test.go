@@ -2,23 +2,30 @@ package songs_test
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"songlibrary/internal/api/handlers/songs"
+	"songlibrary/internal/lib/jsonpatch"
 	"songlibrary/internal/lib/logger/utils"
 	"songlibrary/internal/models"
+	"songlibrary/internal/service"
 	mock_service "songlibrary/internal/service/mocks"
 	"songlibrary/internal/storage"
 
 	"github.com/golang/mock/gomock"
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestMain(m *testing.M) {
@@ -33,22 +40,26 @@ func TestMain(m *testing.M) {
 func TestAddSongHandler_Unit(t *testing.T) {
 	testCases := []struct {
 		name           string
+		queryParams    string
 		requestBody    string
 		mockServiceFn  func(s *mock_service.MockSongService)
 		expectedStatus int
 		expectedBody   string
+		expectedHeader map[string]string
 	}{
 		{
 			name:        "Valid request",
 			requestBody: `{"group": "Test Group", "song": "Test Song"}`,
 			mockServiceFn: func(s *mock_service.MockSongService) {
-				s.EXPECT().AddSong(gomock.Any(), gomock.Any()).Return(
+				s.EXPECT().AddSong(gomock.Any(), gomock.Any(), models.ConflictFail).Return(
 					&models.Song{ID: 1, GroupName: "Test Group", SongName: "Test Song"},
+					true,
 					nil,
 				)
 			},
 			expectedStatus: http.StatusCreated,
-			expectedBody:   `{"id":1,"group":"Test Group","song":"Test Song","releaseDate":{"String":"","Valid":false},"text":{"String":"","Valid":false},"link":{"String":"","Valid":false},"createdAt":"0001-01-01T00:00:00Z","updatedAt":"0001-01-01T00:00:00Z"}`, // Исправлено
+			expectedHeader: map[string]string{"Location": "/songs/1"},
+			expectedBody:   `{"id":1,"group":"Test Group","song":"Test Song","releaseDate":{"String":"","Valid":false},"text":{"String":"","Valid":false},"link":{"String":"","Valid":false},"createdAt":"0001-01-01T00:00:00Z","updatedAt":"0001-01-01T00:00:00Z","version":0}`, // Исправлено
 		},
 		{
 			name:           "Invalid request body",
@@ -66,11 +77,59 @@ func TestAddSongHandler_Unit(t *testing.T) {
 			name:        "Service error",
 			requestBody: `{"group": "Test Group", "song": "Test Song"}`,
 			mockServiceFn: func(s *mock_service.MockSongService) {
-				s.EXPECT().AddSong(gomock.Any(), gomock.Any()).Return(nil, errors.New("service error"))
+				s.EXPECT().AddSong(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, false, errors.New("service error"))
 			},
 			expectedStatus: http.StatusInternalServerError,
 			expectedBody:   `{"error":"Failed to add song"}`,
 		},
+		{
+			name:        "Song exists",
+			requestBody: `{"group": "Test Group", "song": "Test Song"}`,
+			mockServiceFn: func(s *mock_service.MockSongService) {
+				s.EXPECT().AddSong(gomock.Any(), gomock.Any(), models.ConflictFail).Return(
+					nil, false, fmt.Errorf("wrapped: %w", &service.SongExistsError{Song: &models.Song{ID: 7}}),
+				)
+			},
+			expectedStatus: http.StatusConflict,
+			expectedBody:   `{"error":"Song already exists","id":7,"location":"/songs/7"}`,
+			expectedHeader: map[string]string{"Location": "/songs/7"},
+		},
+		{
+			name:        "Return existing song",
+			queryParams: "?onConflict=return",
+			requestBody: `{"group": "Test Group", "song": "Test Song"}`,
+			mockServiceFn: func(s *mock_service.MockSongService) {
+				s.EXPECT().AddSong(gomock.Any(), gomock.Any(), models.ConflictReturn).Return(
+					&models.Song{ID: 1, GroupName: "Test Group", SongName: "Test Song"},
+					false,
+					nil,
+				)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"id":1,"group":"Test Group","song":"Test Song","releaseDate":{"String":"","Valid":false},"text":{"String":"","Valid":false},"link":{"String":"","Valid":false},"createdAt":"0001-01-01T00:00:00Z","updatedAt":"0001-01-01T00:00:00Z","version":0}`,
+			expectedHeader: map[string]string{"Location": ""},
+		},
+		{
+			name:        "Update existing song",
+			queryParams: "?onConflict=update",
+			requestBody: `{"group": "Test Group", "song": "Test Song"}`,
+			mockServiceFn: func(s *mock_service.MockSongService) {
+				s.EXPECT().AddSong(gomock.Any(), gomock.Any(), models.ConflictUpdate).Return(
+					&models.Song{ID: 1, GroupName: "Test Group", SongName: "Test Song"},
+					false,
+					nil,
+				)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"id":1,"group":"Test Group","song":"Test Song","releaseDate":{"String":"","Valid":false},"text":{"String":"","Valid":false},"link":{"String":"","Valid":false},"createdAt":"0001-01-01T00:00:00Z","updatedAt":"0001-01-01T00:00:00Z","version":0}`,
+		},
+		{
+			name:           "Invalid onConflict",
+			queryParams:    "?onConflict=ignore",
+			requestBody:    `{"group": "Test Group", "song": "Test Song"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"onConflict must be return or update"}`,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -85,11 +144,100 @@ func TestAddSongHandler_Unit(t *testing.T) {
 
 			handler := songs.NewSongHandlers(mockService)
 
-			req := httptest.NewRequest("POST", "/songs", bytes.NewBufferString(tc.requestBody))
+			req := httptest.NewRequest("POST", "/songs"+tc.queryParams, bytes.NewBufferString(tc.requestBody))
 			w := httptest.NewRecorder()
 
 			handler.AddSongHandler(w, req)
 
+			assert.Equal(t, tc.expectedStatus, w.Code)
+			assert.JSONEq(t, tc.expectedBody, w.Body.String())
+			for name, value := range tc.expectedHeader {
+				assert.Equal(t, value, w.Header().Get(name), name)
+			}
+		})
+	}
+}
+
+func TestAddSongsHandler_Unit(t *testing.T) {
+	testCases := []struct {
+		name           string
+		requestBody    string
+		mockServiceFn  func(s *mock_service.MockSongService)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:        "Valid request",
+			requestBody: `{"items": [{"group": "Test Group", "song": "Test Song"}, {"group": "Test Group", "song": "Old Song"}, {"group": "Test Group", "song": ""}]}`,
+			mockServiceFn: func(s *mock_service.MockSongService) {
+				s.EXPECT().AddSongs(gomock.Any(), []models.AddSongRequest{
+					{GroupName: "Test Group", SongName: "Test Song"},
+					{GroupName: "Test Group", SongName: "Old Song"},
+					{GroupName: "Test Group", SongName: ""},
+				}).Return(&models.BatchResult{
+					Items: []models.BatchItemResult{
+						{Index: 0, Status: models.BatchCreated, ID: 3, Song: &models.Song{ID: 3, GroupName: "Test Group", SongName: "Test Song", Version: 1}},
+						{Index: 1, Status: models.BatchDuplicate, ID: 2},
+						{Index: 2, Status: models.BatchFailed, Error: "group and song names are required"},
+					},
+					Created:    1,
+					Duplicates: 1,
+					Failed:     1,
+				}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"items":[` +
+				`{"index":0,"status":"created","id":3,"location":"/songs/3","song":{"id":3,"group":"Test Group","song":"Test Song","releaseDate":{"String":"","Valid":false},"text":{"String":"","Valid":false},"link":{"String":"","Valid":false},"createdAt":"0001-01-01T00:00:00Z","updatedAt":"0001-01-01T00:00:00Z","version":1}},` +
+				`{"index":1,"status":"duplicate","id":2,"location":"/songs/2"},` +
+				`{"index":2,"status":"failed","error":"group and song names are required"}],` +
+				`"created":1,"duplicates":1,"failed":1}`,
+		},
+		{
+			name:           "Invalid request body",
+			requestBody:    `[{"group": "Test Group", "song": "Test Song"}]`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"Invalid request body"}`,
+		},
+		{
+			name:           "Empty batch",
+			requestBody:    `{"items": []}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"items must hold 1 to 1000 songs"}`,
+		},
+		{
+			name:           "Batch too large",
+			requestBody:    `{"items": [` + strings.Repeat(`{"group": "g", "song": "s"},`, models.MaxBatchSize) + `{"group": "g", "song": "s"}]}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"items must hold 1 to 1000 songs"}`,
+		},
+		{
+			name:        "Service error",
+			requestBody: `{"items": [{"group": "Test Group", "song": "Test Song"}]}`,
+			mockServiceFn: func(s *mock_service.MockSongService) {
+				s.EXPECT().AddSongs(gomock.Any(), gomock.Any()).Return(nil, errors.New("service error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"error":"Failed to add songs"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockService := mock_service.NewMockSongService(ctrl)
+			if tc.mockServiceFn != nil {
+				tc.mockServiceFn(mockService)
+			}
+
+			handler := songs.NewSongHandlers(mockService)
+
+			req := httptest.NewRequest("POST", "/songs/batch", bytes.NewBufferString(tc.requestBody))
+			w := httptest.NewRecorder()
+
+			handler.AddSongsHandler(w, req)
+
 			assert.Equal(t, tc.expectedStatus, w.Code)
 			assert.JSONEq(t, tc.expectedBody, w.Body.String())
 		})
@@ -109,12 +257,12 @@ func TestGetSongsHandler_Unit(t *testing.T) {
 			queryParams: "",
 			mockServiceFn: func(s *mock_service.MockSongService) {
 				s.EXPECT().GetSongs(gomock.Any(), gomock.Any(), gomock.Any()).Return(
-					[]models.Song{{ID: 1, GroupName: "Test Group", SongName: "Test Song"}},
+					&models.SongList{Items: []models.Song{{ID: 1, GroupName: "Test Group", SongName: "Test Song"}}, Page: 1, PageSize: 10, TotalItems: 1, TotalPages: 1},
 					nil,
 				)
 			},
 			expectedStatus: http.StatusOK,
-			expectedBody:   `[{"id":1,"group":"Test Group","song":"Test Song","releaseDate":{"String":"","Valid":false},"text":{"String":"","Valid":false},"link":{"String":"","Valid":false},"createdAt":"0001-01-01T00:00:00Z","updatedAt":"0001-01-01T00:00:00Z"}]`, // Исправлено
+			expectedBody:   `{"items":[{"id":1,"group":"Test Group","song":"Test Song","releaseDate":{"String":"","Valid":false},"text":{"String":"","Valid":false},"link":{"String":"","Valid":false},"createdAt":"0001-01-01T00:00:00Z","updatedAt":"0001-01-01T00:00:00Z","version":0}],"page":1,"pageSize":10,"totalItems":1,"totalPages":1}`,
 		},
 		{
 			name:        "Filter by group",
@@ -122,12 +270,174 @@ func TestGetSongsHandler_Unit(t *testing.T) {
 			mockServiceFn: func(s *mock_service.MockSongService) {
 				filter := &models.SongFilter{GroupName: stringPointer("Test Group")}
 				s.EXPECT().GetSongs(gomock.Any(), gomock.Eq(filter), gomock.Any()).Return(
-					[]models.Song{{ID: 1, GroupName: "Test Group", SongName: "Test Song"}},
+					&models.SongList{Items: []models.Song{{ID: 1, GroupName: "Test Group", SongName: "Test Song"}}, Page: 1, PageSize: 10, TotalItems: 1, TotalPages: 1},
+					nil,
+				)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"items":[{"id":1,"group":"Test Group","song":"Test Song","releaseDate":{"String":"","Valid":false},"text":{"String":"","Valid":false},"link":{"String":"","Valid":false},"createdAt":"0001-01-01T00:00:00Z","updatedAt":"0001-01-01T00:00:00Z","version":0}],"page":1,"pageSize":10,"totalItems":1,"totalPages":1}`,
+		},
+		{
+			name:        "Middle page links",
+			queryParams: "?group=Muse&page=2&pageSize=1",
+			mockServiceFn: func(s *mock_service.MockSongService) {
+				s.EXPECT().GetSongs(gomock.Any(), gomock.Any(), gomock.Eq(models.NewPagination(2, 1))).Return(
+					&models.SongList{Items: []models.Song{}, Page: 2, PageSize: 1, TotalItems: 3, TotalPages: 3},
+					nil,
+				)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"items":[],"page":2,"pageSize":1,"totalItems":3,"totalPages":3,"next":"/songs?group=Muse&page=3&pageSize=1","prev":"/songs?group=Muse&page=1&pageSize=1"}`,
+		},
+		{
+			name:        "Page past the end links back to the last page",
+			queryParams: "?page=5",
+			mockServiceFn: func(s *mock_service.MockSongService) {
+				s.EXPECT().GetSongs(gomock.Any(), gomock.Any(), gomock.Any()).Return(
+					&models.SongList{Items: []models.Song{}, Page: 5, PageSize: 10, TotalItems: 12, TotalPages: 2},
+					nil,
+				)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"items":[],"page":5,"pageSize":10,"totalItems":12,"totalPages":2,"prev":"/songs?page=2"}`,
+		},
+		{
+			name:        "Cursor",
+			queryParams: "?cursor=" + models.CursorAfter(models.Song{ID: 3}, nil).Encode() + "&pageSize=1",
+			mockServiceFn: func(s *mock_service.MockSongService) {
+				pagination := &models.Pagination{Page: 1, PageSize: 1, After: models.CursorAfter(models.Song{ID: 3}, nil)}
+				s.EXPECT().GetSongs(gomock.Any(), gomock.Any(), gomock.Eq(pagination)).Return(
+					&models.SongList{Items: []models.Song{}, PageSize: 1, TotalItems: 5, TotalPages: 5, NextCursor: "next"},
+					nil,
+				)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"items":[],"pageSize":1,"totalItems":5,"totalPages":5,"nextCursor":"next","next":"/songs?cursor=next&pageSize=1"}`,
+		},
+		{
+			name:           "Invalid cursor",
+			queryParams:    "?cursor=not-a-cursor",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"Invalid cursor"}`,
+		},
+		{
+			name:           "Page too large",
+			queryParams:    "?page=9223372036854775807",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"invalid pagination: page must not exceed 21474836"}`,
+		},
+		{
+			name:           "Cursor with page",
+			queryParams:    "?page=2&cursor=" + models.CursorAfter(models.Song{ID: 3}, nil).Encode(),
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"invalid pagination: page and cursor are mutually exclusive"}`,
+		},
+		{
+			name:        "Sort",
+			queryParams: "?sort=-releaseDate,group",
+			mockServiceFn: func(s *mock_service.MockSongService) {
+				filter := &models.SongFilter{Sort: models.Sort{{Field: models.SortByReleaseDate, Desc: true}, {Field: models.SortByGroup}}}
+				s.EXPECT().GetSongs(gomock.Any(), gomock.Eq(filter), gomock.Any()).Return(
+					&models.SongList{Items: []models.Song{}, Page: 1, PageSize: 10},
 					nil,
 				)
 			},
 			expectedStatus: http.StatusOK,
-			expectedBody:   `[{"id":1,"group":"Test Group","song":"Test Song","releaseDate":{"String":"","Valid":false},"text":{"String":"","Valid":false},"link":{"String":"","Valid":false},"createdAt":"0001-01-01T00:00:00Z","updatedAt":"0001-01-01T00:00:00Z"}]`, // Исправлено
+			expectedBody:   `{"items":[],"page":1,"pageSize":10,"totalItems":0,"totalPages":0}`,
+		},
+		{
+			name:           "Unknown sort field",
+			queryParams:    "?sort=group,-name",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"invalid sort: unknown field \"name\""}`,
+		},
+		{
+			name:           "Cursor issued for another sort",
+			queryParams:    "?sort=group&cursor=" + models.CursorAfter(models.Song{ID: 3}, nil).Encode(),
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"Invalid cursor"}`,
+		},
+		{
+			name:        "All filters",
+			queryParams: "?group=Muse&match=exact&releaseDateFrom=2006-01-01&releaseDateTo=2009-12-31&hasText=true&hasLink=false&createdFrom=2024-01-01T00:00:00Z&createdTo=2024-02-01T00:00:00%2B03:00&updatedFrom=2024-01-01T00:00:00Z",
+			mockServiceFn: func(s *mock_service.MockSongService) {
+				createdFrom := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+				createdTo := time.Date(2024, 1, 31, 21, 0, 0, 0, time.UTC)
+				hasText, hasLink := true, false
+				s.EXPECT().GetSongs(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+					func(_ context.Context, filter *models.SongFilter, _ *models.Pagination) (*models.SongList, error) {
+						assert.Equal(t, stringPointer("Muse"), filter.GroupName)
+						assert.Nil(t, filter.SongName)
+						assert.Equal(t, models.MatchExact, filter.Match)
+						assert.Equal(t, stringPointer("2006-01-01"), filter.ReleaseDateFrom)
+						assert.Equal(t, stringPointer("2009-12-31"), filter.ReleaseDateTo)
+						assert.Equal(t, &hasText, filter.HasText)
+						assert.Equal(t, &hasLink, filter.HasLink)
+						assert.True(t, createdFrom.Equal(*filter.CreatedFrom))
+						assert.True(t, createdTo.Equal(*filter.CreatedTo))
+						assert.True(t, createdFrom.Equal(*filter.UpdatedFrom))
+						assert.Nil(t, filter.UpdatedTo)
+						return &models.SongList{Items: []models.Song{}, Page: 1, PageSize: 10}, nil
+					},
+				)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"items":[],"page":1,"pageSize":10,"totalItems":0,"totalPages":0}`,
+		},
+		{
+			name:           "Unknown match mode",
+			queryParams:    "?group=Muse&match=regexp",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"invalid filter: unknown match mode \"regexp\""}`,
+		},
+		{
+			name:        "Fuzzy match with suggestions",
+			queryParams: "?group=Metalica&match=fuzzy",
+			mockServiceFn: func(s *mock_service.MockSongService) {
+				filter := &models.SongFilter{GroupName: stringPointer("Metalica"), Match: models.MatchFuzzy}
+				s.EXPECT().GetSongs(gomock.Any(), gomock.Eq(filter), gomock.Any()).Return(
+					&models.SongList{Items: []models.Song{}, Page: 1, PageSize: 10, Suggestions: []models.NameSuggestion{{Field: "group", Value: "Metallica", Similarity: 0.25}}},
+					nil,
+				)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"items":[],"page":1,"pageSize":10,"totalItems":0,"totalPages":0,"suggestions":[{"field":"group","value":"Metallica","similarity":0.25}]}`,
+		},
+		{
+			name:           "Fuzzy match with cursor",
+			queryParams:    "?group=Metalica&match=fuzzy&cursor=" + models.CursorAfter(models.Song{ID: 3}, nil).Encode(),
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"invalid filter: cursor is not supported with fuzzy match"}`,
+		},
+		{
+			name:           "Invalid release date",
+			queryParams:    "?releaseDateFrom=04.09.2006",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"invalid filter: releaseDateFrom must be a YYYY-MM-DD date"}`,
+		},
+		{
+			name:           "Release date range reversed",
+			queryParams:    "?releaseDateFrom=2009-01-01&releaseDateTo=2006-01-01",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"invalid filter: releaseDateFrom is after releaseDateTo"}`,
+		},
+		{
+			name:           "Invalid boolean",
+			queryParams:    "?hasLink=maybe",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"invalid filter: hasLink must be true or false"}`,
+		},
+		{
+			name:           "Invalid timestamp",
+			queryParams:    "?updatedTo=yesterday",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"invalid filter: updatedTo must be an RFC 3339 timestamp"}`,
+		},
+		{
+			name:           "Page size too large",
+			queryParams:    "?pageSize=101",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"invalid pagination: pageSize must not exceed 100"}`,
 		},
 		{
 			name:        "Service error",
@@ -163,6 +473,84 @@ func TestGetSongsHandler_Unit(t *testing.T) {
 	}
 }
 
+func TestSearchSongsHandler_Unit(t *testing.T) {
+	testCases := []struct {
+		name           string
+		queryParams    string
+		mockServiceFn  func(s *mock_service.MockSongService)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:        "Found",
+			queryParams: "?q=%20far%20away%20&pageSize=5",
+			mockServiceFn: func(s *mock_service.MockSongService) {
+				s.EXPECT().SearchSongs(gomock.Any(), "far away", gomock.Eq(models.NewPagination(1, 5))).Return(
+					&models.SongSearchList{
+						Items:      []models.SongSearchResult{{Song: models.Song{ID: 1, GroupName: "Muse", SongName: "Starlight"}, Rank: 0.5, Snippet: "<mark>far</mark> <mark>away</mark>"}},
+						Page:       1,
+						PageSize:   5,
+						TotalItems: 1,
+						TotalPages: 1,
+					},
+					nil,
+				)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"items":[{"id":1,"group":"Muse","song":"Starlight","releaseDate":{"String":"","Valid":false},"text":{"String":"","Valid":false},"link":{"String":"","Valid":false},"createdAt":"0001-01-01T00:00:00Z","updatedAt":"0001-01-01T00:00:00Z","version":0,"rank":0.5,"snippet":"\u003cmark\u003efar\u003c/mark\u003e \u003cmark\u003eaway\u003c/mark\u003e"}],"page":1,"pageSize":5,"totalItems":1,"totalPages":1}`,
+		},
+		{
+			name:           "Missing query",
+			queryParams:    "?q=%20",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"Search query is required"}`,
+		},
+		{
+			name:           "Query too long",
+			queryParams:    "?q=" + strings.Repeat("a", 257),
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"Search query must not exceed 256 characters"}`,
+		},
+		{
+			name:           "Page size too large",
+			queryParams:    "?q=away&pageSize=1000",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"invalid pagination: pageSize must not exceed 100"}`,
+		},
+		{
+			name:        "Service error",
+			queryParams: "?q=away",
+			mockServiceFn: func(s *mock_service.MockSongService) {
+				s.EXPECT().SearchSongs(gomock.Any(), "away", gomock.Any()).Return(nil, errors.New("service error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"error":"Failed to search songs"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockService := mock_service.NewMockSongService(ctrl)
+			if tc.mockServiceFn != nil {
+				tc.mockServiceFn(mockService)
+			}
+
+			handler := songs.NewSongHandlers(mockService)
+
+			req := httptest.NewRequest("GET", "/songs/search"+tc.queryParams, nil)
+			w := httptest.NewRecorder()
+
+			handler.SearchSongsHandler(w, req)
+
+			assert.Equal(t, tc.expectedStatus, w.Code)
+			assert.JSONEq(t, tc.expectedBody, w.Body.String())
+		})
+	}
+}
+
 func TestGetSongTextHandler_Unit(t *testing.T) {
 	testCases := []struct {
 		name           string
@@ -183,7 +571,7 @@ func TestGetSongTextHandler_Unit(t *testing.T) {
 				)
 			},
 			expectedStatus: http.StatusOK,
-			expectedBody:   `{"id":1,"group":"Test Group","song":"Test Song","releaseDate":{"String":"","Valid":false},"text":{"String":"Test Text","Valid":true},"link":{"String":"","Valid":false},"createdAt":"0001-01-01T00:00:00Z","updatedAt":"0001-01-01T00:00:00Z"}`, // Исправлено
+			expectedBody:   `{"id":1,"group":"Test Group","song":"Test Song","releaseDate":{"String":"","Valid":false},"text":{"String":"Test Text","Valid":true},"link":{"String":"","Valid":false},"createdAt":"0001-01-01T00:00:00Z","updatedAt":"0001-01-01T00:00:00Z","version":0}`, // Исправлено
 		},
 		{
 			name:        "Valid request with pagination",
@@ -197,7 +585,7 @@ func TestGetSongTextHandler_Unit(t *testing.T) {
 				)
 			},
 			expectedStatus: http.StatusOK,
-			expectedBody:   `{"id":1,"group":"Test Group","song":"Test Song","releaseDate":{"String":"","Valid":false},"text":{"String":"Verse1","Valid":true},"link":{"String":"","Valid":false},"createdAt":"0001-01-01T00:00:00Z","updatedAt":"0001-01-01T00:00:00Z"}`, // Исправлено
+			expectedBody:   `{"id":1,"group":"Test Group","song":"Test Song","releaseDate":{"String":"","Valid":false},"text":{"String":"Verse1","Valid":true},"link":{"String":"","Valid":false},"createdAt":"0001-01-01T00:00:00Z","updatedAt":"0001-01-01T00:00:00Z","version":0}`, // Исправлено
 		},
 		{
 			name:        "Request with pagination no content",
@@ -211,7 +599,7 @@ func TestGetSongTextHandler_Unit(t *testing.T) {
 				)
 			},
 			expectedStatus: http.StatusOK,
-			expectedBody:   `{"id":1,"group":"Test Group","song":"Test Song","releaseDate":{"String":"","Valid":false},"text":{"String":"","Valid":true},"link":{"String":"","Valid":false},"createdAt":"0001-01-01T00:00:00Z","updatedAt":"0001-01-01T00:00:00Z"}`, // Исправлено, text valid true because sql.NullString is present, even if string is empty
+			expectedBody:   `{"id":1,"group":"Test Group","song":"Test Song","releaseDate":{"String":"","Valid":false},"text":{"String":"","Valid":true},"link":{"String":"","Valid":false},"createdAt":"0001-01-01T00:00:00Z","updatedAt":"0001-01-01T00:00:00Z","version":0}`, // Исправлено, text valid true because sql.NullString is present, even if string is empty
 		},
 		{
 			name:           "Invalid song ID",
@@ -285,7 +673,7 @@ func TestUpdateSongHandler_Unit(t *testing.T) {
 				)
 			},
 			expectedStatus: http.StatusOK,
-			expectedBody:   `{"id":1,"group":"Updated Group","song":"Updated Song","releaseDate":{"String":"","Valid":false},"text":{"String":"","Valid":false},"link":{"String":"","Valid":false},"createdAt":"0001-01-01T00:00:00Z","updatedAt":"0001-01-01T00:00:00Z"}`, // Исправлено
+			expectedBody:   `{"id":1,"group":"Updated Group","song":"Updated Song","releaseDate":{"String":"","Valid":false},"text":{"String":"","Valid":false},"link":{"String":"","Valid":false},"createdAt":"0001-01-01T00:00:00Z","updatedAt":"0001-01-01T00:00:00Z","version":0}`, // Исправлено
 		},
 		{
 			name:           "Invalid song ID",
@@ -346,6 +734,149 @@ func TestUpdateSongHandler_Unit(t *testing.T) {
 	}
 }
 
+func TestPatchSongHandler_Unit(t *testing.T) {
+	testCases := []struct {
+		name           string
+		songID         string
+		contentType    string
+		requestBody    string
+		mockServiceFn  func(s *mock_service.MockSongService)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:        "Merge patch",
+			songID:      "1",
+			contentType: "application/merge-patch+json",
+			requestBody: `{"text": null}`,
+			mockServiceFn: func(s *mock_service.MockSongService) {
+				s.EXPECT().PatchSong(gomock.Any(), 1, 0, models.MergePatch, []byte(`{"text": null}`)).Return(
+					&models.Song{ID: 1, GroupName: "Muse", SongName: "Starlight"},
+					nil,
+				)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"id":1,"group":"Muse","song":"Starlight","releaseDate":{"String":"","Valid":false},"text":{"String":"","Valid":false},"link":{"String":"","Valid":false},"createdAt":"0001-01-01T00:00:00Z","updatedAt":"0001-01-01T00:00:00Z","version":0}`,
+		},
+		{
+			name:        "Plain JSON is a merge patch",
+			songID:      "1",
+			contentType: "application/json; charset=utf-8",
+			requestBody: `{"song": "Starlight"}`,
+			mockServiceFn: func(s *mock_service.MockSongService) {
+				s.EXPECT().PatchSong(gomock.Any(), 1, 0, models.MergePatch, gomock.Any()).Return(&models.Song{ID: 1}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"id":1,"group":"","song":"","releaseDate":{"String":"","Valid":false},"text":{"String":"","Valid":false},"link":{"String":"","Valid":false},"createdAt":"0001-01-01T00:00:00Z","updatedAt":"0001-01-01T00:00:00Z","version":0}`,
+		},
+		{
+			name:        "JSON Patch",
+			songID:      "1",
+			contentType: "application/json-patch+json",
+			requestBody: `[{"op": "replace", "path": "/song", "value": "Starlight"}]`,
+			mockServiceFn: func(s *mock_service.MockSongService) {
+				s.EXPECT().PatchSong(gomock.Any(), 1, 0, models.JSONPatch, gomock.Any()).Return(&models.Song{ID: 1}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"id":1,"group":"","song":"","releaseDate":{"String":"","Valid":false},"text":{"String":"","Valid":false},"link":{"String":"","Valid":false},"createdAt":"0001-01-01T00:00:00Z","updatedAt":"0001-01-01T00:00:00Z","version":0}`,
+		},
+		{
+			name:           "Invalid song ID",
+			songID:         "invalid",
+			contentType:    "application/merge-patch+json",
+			requestBody:    `{}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"Invalid song ID"}`,
+		},
+		{
+			name:           "Unsupported content type",
+			songID:         "1",
+			contentType:    "text/plain",
+			requestBody:    `{}`,
+			expectedStatus: http.StatusUnsupportedMediaType,
+			expectedBody:   `{"error":"Content-Type must be application/merge-patch+json or application/json-patch+json"}`,
+		},
+		{
+			name:        "Song not found",
+			songID:      "1",
+			contentType: "application/merge-patch+json",
+			requestBody: `{}`,
+			mockServiceFn: func(s *mock_service.MockSongService) {
+				s.EXPECT().PatchSong(gomock.Any(), 1, 0, gomock.Any(), gomock.Any()).Return(nil, storage.ErrSongNotFound)
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"error":"Song not found"}`,
+		},
+		{
+			name:        "Malformed patch",
+			songID:      "1",
+			contentType: "application/json-patch+json",
+			requestBody: `[{"op": "merge"}]`,
+			mockServiceFn: func(s *mock_service.MockSongService) {
+				s.EXPECT().PatchSong(gomock.Any(), 1, 0, gomock.Any(), gomock.Any()).Return(nil, fmt.Errorf("%w: unknown operation", jsonpatch.ErrInvalidPatch))
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"invalid patch: unknown operation"}`,
+		},
+		{
+			name:        "Failed test operation",
+			songID:      "1",
+			contentType: "application/json-patch+json",
+			requestBody: `[{"op": "test", "path": "/song", "value": "Hysteria"}]`,
+			mockServiceFn: func(s *mock_service.MockSongService) {
+				s.EXPECT().PatchSong(gomock.Any(), 1, 0, gomock.Any(), gomock.Any()).Return(nil, fmt.Errorf("%w: test failed", jsonpatch.ErrConflict))
+			},
+			expectedStatus: http.StatusConflict,
+			expectedBody:   `{"error":"patch cannot be applied: test failed"}`,
+		},
+		{
+			name:        "Invalid result",
+			songID:      "1",
+			contentType: "application/merge-patch+json",
+			requestBody: `{"group": null}`,
+			mockServiceFn: func(s *mock_service.MockSongService) {
+				s.EXPECT().PatchSong(gomock.Any(), 1, 0, gomock.Any(), gomock.Any()).Return(nil, fmt.Errorf("%w: group is required", models.ErrInvalidSong))
+			},
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedBody:   `{"error":"invalid song: group is required"}`,
+		},
+		{
+			name:        "Service error",
+			songID:      "1",
+			contentType: "application/merge-patch+json",
+			requestBody: `{}`,
+			mockServiceFn: func(s *mock_service.MockSongService) {
+				s.EXPECT().PatchSong(gomock.Any(), 1, 0, gomock.Any(), gomock.Any()).Return(nil, errors.New("service error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"error":"Failed to update song"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockService := mock_service.NewMockSongService(ctrl)
+			if tc.mockServiceFn != nil {
+				tc.mockServiceFn(mockService)
+			}
+
+			handler := songs.NewSongHandlers(mockService)
+			req := httptest.NewRequest("PATCH", "/songs/"+tc.songID, bytes.NewBufferString(tc.requestBody))
+			req.Header.Set("Content-Type", tc.contentType)
+			req = mux.SetURLVars(req, map[string]string{"id": tc.songID})
+			w := httptest.NewRecorder()
+
+			handler.PatchSongHandler(w, req)
+
+			assert.Equal(t, tc.expectedStatus, w.Code)
+			assert.JSONEq(t, tc.expectedBody, w.Body.String())
+		})
+	}
+}
+
 func TestDeleteSongHandler_Unit(t *testing.T) {
 	testCases := []struct {
 		name           string
@@ -358,7 +889,7 @@ func TestDeleteSongHandler_Unit(t *testing.T) {
 			name:   "Valid request",
 			songID: "1",
 			mockServiceFn: func(s *mock_service.MockSongService) {
-				s.EXPECT().DeleteSong(gomock.Any(), gomock.Eq(1)).Return(nil)
+				s.EXPECT().DeleteSong(gomock.Any(), gomock.Eq(1), 0).Return(nil)
 			},
 			expectedStatus: http.StatusNoContent,
 			expectedBody:   ``,
@@ -373,7 +904,7 @@ func TestDeleteSongHandler_Unit(t *testing.T) {
 			name:   "Song not found",
 			songID: "1",
 			mockServiceFn: func(s *mock_service.MockSongService) {
-				s.EXPECT().DeleteSong(gomock.Any(), gomock.Eq(1)).Return(storage.ErrSongNotFound)
+				s.EXPECT().DeleteSong(gomock.Any(), gomock.Eq(1), 0).Return(storage.ErrSongNotFound)
 			},
 			expectedStatus: http.StatusNotFound,
 			expectedBody:   `{"error":"Song not found"}`,
@@ -382,7 +913,7 @@ func TestDeleteSongHandler_Unit(t *testing.T) {
 			name:   "Service error",
 			songID: "1",
 			mockServiceFn: func(s *mock_service.MockSongService) {
-				s.EXPECT().DeleteSong(gomock.Any(), gomock.Eq(1)).Return(errors.New("service error"))
+				s.EXPECT().DeleteSong(gomock.Any(), gomock.Eq(1), 0).Return(errors.New("service error"))
 			},
 			expectedStatus: http.StatusInternalServerError,
 			expectedBody:   `{"error":"Failed to delete song"}`,
@@ -441,3 +972,208 @@ func sqlStringPointer(s string) sql.NullString {
 	}
 	return sqlString
 }
+
+func TestConditionalRequests_Unit(t *testing.T) {
+	song := &models.Song{ID: 1, GroupName: "Muse", SongName: "Starlight", Version: 3}
+	listETag := func(t *testing.T, mockService *mock_service.MockSongService, handler *songs.SongHandlers) string {
+		mockService.EXPECT().GetSongs(gomock.Any(), gomock.Any(), gomock.Any()).Return(&models.SongList{Items: []models.Song{*song}, Page: 1, PageSize: 10, TotalItems: 1, TotalPages: 1}, nil)
+		w := httptest.NewRecorder()
+		handler.GetSongsHandler(w, httptest.NewRequest("GET", "/songs", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		return w.Header().Get("ETag")
+	}
+
+	testCases := []struct {
+		name           string
+		method         string
+		path           string
+		headers        map[string]string
+		body           string
+		mockServiceFn  func(s *mock_service.MockSongService)
+		handler        func(h *songs.SongHandlers) http.HandlerFunc
+		expectedStatus int
+		expectedETag   string
+	}{
+		{
+			name:   "Text has the version as ETag",
+			method: "GET",
+			path:   "/songs/1/text",
+			mockServiceFn: func(s *mock_service.MockSongService) {
+				s.EXPECT().GetSongText(gomock.Any(), 1, gomock.Any()).Return(song, nil)
+			},
+			handler:        func(h *songs.SongHandlers) http.HandlerFunc { return h.GetSongTextHandler },
+			expectedStatus: http.StatusOK,
+			expectedETag:   `"3"`,
+		},
+		{
+			name:    "Text not modified",
+			method:  "GET",
+			path:    "/songs/1/text",
+			headers: map[string]string{"If-None-Match": `"2", W/"3"`},
+			mockServiceFn: func(s *mock_service.MockSongService) {
+				s.EXPECT().GetSongText(gomock.Any(), 1, gomock.Any()).Return(song, nil)
+			},
+			handler:        func(h *songs.SongHandlers) http.HandlerFunc { return h.GetSongTextHandler },
+			expectedStatus: http.StatusNotModified,
+			expectedETag:   `"3"`,
+		},
+		{
+			name:    "Text modified",
+			method:  "GET",
+			path:    "/songs/1/text",
+			headers: map[string]string{"If-None-Match": `"2"`},
+			mockServiceFn: func(s *mock_service.MockSongService) {
+				s.EXPECT().GetSongText(gomock.Any(), 1, gomock.Any()).Return(song, nil)
+			},
+			handler:        func(h *songs.SongHandlers) http.HandlerFunc { return h.GetSongTextHandler },
+			expectedStatus: http.StatusOK,
+			expectedETag:   `"3"`,
+		},
+		{
+			name:    "Update with matching version",
+			method:  "PUT",
+			path:    "/songs/1",
+			headers: map[string]string{"If-Match": `"3"`},
+			body:    `{"group": "Muse", "song": "Starlight", "version": 7}`,
+			mockServiceFn: func(s *mock_service.MockSongService) {
+				s.EXPECT().UpdateSong(gomock.Any(), &models.Song{ID: 1, GroupName: "Muse", SongName: "Starlight", Version: 3}).Return(&models.Song{ID: 1, Version: 4}, nil)
+			},
+			handler:        func(h *songs.SongHandlers) http.HandlerFunc { return h.UpdateSongHandler },
+			expectedStatus: http.StatusOK,
+			expectedETag:   `"4"`,
+		},
+		{
+			name:    "Update with stale version",
+			method:  "PUT",
+			path:    "/songs/1",
+			headers: map[string]string{"If-Match": `"2"`},
+			body:    `{"group": "Muse", "song": "Starlight"}`,
+			mockServiceFn: func(s *mock_service.MockSongService) {
+				s.EXPECT().UpdateSong(gomock.Any(), gomock.Any()).Return(nil, storage.ErrVersionMismatch)
+			},
+			handler:        func(h *songs.SongHandlers) http.HandlerFunc { return h.UpdateSongHandler },
+			expectedStatus: http.StatusPreconditionFailed,
+		},
+		{
+			name:           "Update with weak ETag",
+			method:         "PUT",
+			path:           "/songs/1",
+			headers:        map[string]string{"If-Match": `W/"3"`},
+			body:           `{"group": "Muse", "song": "Starlight"}`,
+			handler:        func(h *songs.SongHandlers) http.HandlerFunc { return h.UpdateSongHandler },
+			expectedStatus: http.StatusPreconditionFailed,
+		},
+		{
+			name:           "Update with several ETags",
+			method:         "PUT",
+			path:           "/songs/1",
+			headers:        map[string]string{"If-Match": `"2", "3"`},
+			body:           `{"group": "Muse", "song": "Starlight"}`,
+			handler:        func(h *songs.SongHandlers) http.HandlerFunc { return h.UpdateSongHandler },
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:    "Patch with any version",
+			method:  "PATCH",
+			path:    "/songs/1",
+			headers: map[string]string{"If-Match": "*", "Content-Type": "application/merge-patch+json"},
+			body:    `{}`,
+			mockServiceFn: func(s *mock_service.MockSongService) {
+				s.EXPECT().PatchSong(gomock.Any(), 1, 0, models.MergePatch, gomock.Any()).Return(&models.Song{ID: 1, Version: 4}, nil)
+			},
+			handler:        func(h *songs.SongHandlers) http.HandlerFunc { return h.PatchSongHandler },
+			expectedStatus: http.StatusOK,
+			expectedETag:   `"4"`,
+		},
+		{
+			name:    "Patch with stale version",
+			method:  "PATCH",
+			path:    "/songs/1",
+			headers: map[string]string{"If-Match": `"2"`, "Content-Type": "application/merge-patch+json"},
+			body:    `{}`,
+			mockServiceFn: func(s *mock_service.MockSongService) {
+				s.EXPECT().PatchSong(gomock.Any(), 1, 2, models.MergePatch, gomock.Any()).Return(nil, storage.ErrVersionMismatch)
+			},
+			handler:        func(h *songs.SongHandlers) http.HandlerFunc { return h.PatchSongHandler },
+			expectedStatus: http.StatusPreconditionFailed,
+		},
+		{
+			name:    "Delete with matching version",
+			method:  "DELETE",
+			path:    "/songs/1",
+			headers: map[string]string{"If-Match": `"3"`},
+			mockServiceFn: func(s *mock_service.MockSongService) {
+				s.EXPECT().DeleteSong(gomock.Any(), 1, 3).Return(nil)
+			},
+			handler:        func(h *songs.SongHandlers) http.HandlerFunc { return h.DeleteSongHandler },
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:    "Delete with stale version",
+			method:  "DELETE",
+			path:    "/songs/1",
+			headers: map[string]string{"If-Match": `"2"`},
+			mockServiceFn: func(s *mock_service.MockSongService) {
+				s.EXPECT().DeleteSong(gomock.Any(), 1, 2).Return(storage.ErrVersionMismatch)
+			},
+			handler:        func(h *songs.SongHandlers) http.HandlerFunc { return h.DeleteSongHandler },
+			expectedStatus: http.StatusPreconditionFailed,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockService := mock_service.NewMockSongService(ctrl)
+			if tc.mockServiceFn != nil {
+				tc.mockServiceFn(mockService)
+			}
+
+			handler := songs.NewSongHandlers(mockService)
+			req := httptest.NewRequest(tc.method, tc.path, bytes.NewBufferString(tc.body))
+			for key, value := range tc.headers {
+				req.Header.Set(key, value)
+			}
+			req = mux.SetURLVars(req, map[string]string{"id": "1"})
+			w := httptest.NewRecorder()
+
+			tc.handler(handler)(w, req)
+
+			assert.Equal(t, tc.expectedStatus, w.Code)
+			assert.Equal(t, tc.expectedETag, w.Header().Get("ETag"))
+			if tc.expectedStatus == http.StatusNotModified {
+				assert.Empty(t, w.Body.String())
+			}
+		})
+	}
+
+	t.Run("List", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockService := mock_service.NewMockSongService(ctrl)
+		handler := songs.NewSongHandlers(mockService)
+
+		etag := listETag(t, mockService, handler)
+		assert.True(t, strings.HasPrefix(etag, `W/"`), "Expected a weak ETag, got %q", etag)
+		assert.Equal(t, etag, listETag(t, mockService, handler), "Expected the same ETag for the same songs")
+
+		mockService.EXPECT().GetSongs(gomock.Any(), gomock.Any(), gomock.Any()).Return(&models.SongList{Items: []models.Song{*song}, Page: 1, PageSize: 10, TotalItems: 1, TotalPages: 1}, nil)
+		req := httptest.NewRequest("GET", "/songs", nil)
+		req.Header.Set("If-None-Match", etag)
+		w := httptest.NewRecorder()
+		handler.GetSongsHandler(w, req)
+		assert.Equal(t, http.StatusNotModified, w.Code)
+		assert.Empty(t, w.Body.String())
+
+		changed := *song
+		changed.Version = 4
+		mockService.EXPECT().GetSongs(gomock.Any(), gomock.Any(), gomock.Any()).Return(&models.SongList{Items: []models.Song{changed}, Page: 1, PageSize: 10, TotalItems: 1, TotalPages: 1}, nil)
+		w = httptest.NewRecorder()
+		handler.GetSongsHandler(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotEqual(t, etag, w.Header().Get("ETag"))
+	})
+}
@@ -0,0 +1,169 @@
+package songs
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"songlibrary/internal/lib/logger/utils"
+	"songlibrary/internal/lib/response"
+	"songlibrary/internal/lib/songfile"
+	"songlibrary/internal/models"
+)
+
+// @Summary Export songs
+// @Description Stream all songs, or those matching the filters, in id order as CSV, NDJSON or a JSON array.
+// @Description Each song is exported as its group, song, releaseDate, text and link; CSV files have a header row and leave missing values empty.
+// @Description Exported files can be imported with POST /songs/import.
+// @Tags songs
+// @Produce json
+// @Produce text/csv
+// @Produce application/x-ndjson
+// @Param format query string false "File format" Enums(csv, ndjson, json) default(json)
+// @Param group query string false "Filter by group name"
+// @Param song query string false "Filter by song name"
+// @Param match query string false "How group and song are matched, case-insensitively" Enums(substring, prefix, exact) default(substring)
+// @Param releaseDateFrom query string false "Earliest release date, inclusive" format(date)
+// @Param releaseDateTo query string false "Latest release date, inclusive" format(date)
+// @Param hasText query bool false "Only songs with (true) or without (false) text"
+// @Param hasLink query bool false "Only songs with (true) or without (false) a link"
+// @Param createdFrom query string false "Earliest creation time, inclusive, RFC 3339" format(date-time)
+// @Param createdTo query string false "Latest creation time, inclusive, RFC 3339" format(date-time)
+// @Param updatedFrom query string false "Earliest update time, inclusive, RFC 3339" format(date-time)
+// @Param updatedTo query string false "Latest update time, inclusive, RFC 3339" format(date-time)
+// @Success 200 {array} models.SongDocument
+// @Header 200 {string} Content-Disposition "attachment; filename=songs.<format>"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /songs/export [get]
+// @swaggo:operation GET /songs/export exportSongs
+func (h *SongHandlers) ExportSongsHandler(w http.ResponseWriter, r *http.Request) {
+	utils.Logger.Info("ExportSongsHandler called")
+
+	queryParams := r.URL.Query()
+	format := songfile.JSON
+	if value := queryParams.Get("format"); value != "" {
+		var err error
+		if format, err = songfile.ParseFormat(value); err != nil {
+			utils.Logger.Warn("ExportSongsHandler - invalid format", zap.String("format", value))
+			response.Error(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	filter, err := parseSongFilter(queryParams)
+	if err == nil {
+		err = filter.Validate()
+	}
+	if err != nil {
+		utils.Logger.Warn("ExportSongsHandler - invalid filter", zap.Error(err), zap.String("query", r.URL.RawQuery))
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if filter.IsFuzzy() {
+		utils.Logger.Warn("ExportSongsHandler - fuzzy match", zap.String("query", r.URL.RawQuery))
+		response.Error(w, http.StatusBadRequest, "invalid filter: fuzzy match is not supported by export")
+		return
+	}
+
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", `attachment; filename="songs.`+string(format)+`"`)
+	writer := songfile.NewWriter(w, format)
+	exported := 0
+	err = h.songService.ExportSongs(r.Context(), filter, func(song *models.Song) error {
+		exported++
+		return writer.Write(models.NewSongDocument(song))
+	})
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		utils.Logger.Error("ExportSongsHandler - songService.ExportSongs failed", zap.Error(err), zap.Int("exported", exported))
+		if exported == 0 {
+			w.Header().Del("Content-Disposition")
+			response.Error(w, http.StatusInternalServerError, "Failed to export songs")
+			return
+		}
+		// The status is sent already: abort the response, so that the client does not
+		// take the truncated file for a complete one.
+		panic(http.ErrAbortHandler)
+	}
+	utils.Logger.Info("ExportSongsHandler - songs exported", zap.Int("exported", exported), zap.String("format", string(format)))
+}
+
+// @Summary Import songs
+// @Description Add the songs of a file in the format of GET /songs/export: CSV with a header row naming at least the group and song columns,
+// @Description NDJSON or a JSON array. Rows are added in batches of 1000, each in its own transaction, and validated one by one:
+// @Description invalid rows, songs that cannot be added and duplicates do not prevent the others from being imported.
+// @Description The response counts the outcomes and lists the failed rows, up to 1000 of them.
+// @Tags songs
+// @Accept json
+// @Accept text/csv
+// @Accept application/x-ndjson
+// @Produce json
+// @Param format query string false "File format, taken from the Content-Type by default" Enums(csv, ndjson, json)
+// @Param skipEnrichment query bool false "Store rows giving a release date, text or link as given, without calling the external API"
+// @Param body body []models.SongDocument true "Songs to import"
+// @Param Idempotency-Key header string false "Unique key making retries of the request safe, see README"
+// @Success 200 {object} models.ImportResult
+// @Failure 400 {string} string "Bad Request"
+// @Failure 409 {string} string "Conflict"
+// @Failure 422 {string} string "Unprocessable Entity"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /songs/import [post]
+// @swaggo:operation POST /songs/import importSongs
+func (h *SongHandlers) ImportSongsHandler(w http.ResponseWriter, r *http.Request) {
+	utils.Logger.Info("ImportSongsHandler called")
+
+	queryParams := r.URL.Query()
+	format := importFormat(r.Header.Get("Content-Type"))
+	if value := queryParams.Get("format"); value != "" {
+		var err error
+		if format, err = songfile.ParseFormat(value); err != nil {
+			utils.Logger.Warn("ImportSongsHandler - invalid format", zap.String("format", value))
+			response.Error(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	skipEnrichment, err := boolParam(queryParams, "skipEnrichment")
+	if err != nil {
+		utils.Logger.Warn("ImportSongsHandler - invalid skipEnrichment", zap.String("skipEnrichment", queryParams.Get("skipEnrichment")))
+		response.Error(w, http.StatusBadRequest, "skipEnrichment must be true or false")
+		return
+	}
+
+	reader, err := songfile.NewReader(r.Body, format)
+	if err != nil {
+		utils.Logger.Warn("ImportSongsHandler - invalid file", zap.Error(err))
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	result, err := h.songService.ImportSongs(r.Context(), reader, skipEnrichment != nil && *skipEnrichment)
+	if err != nil {
+		if errors.Is(err, songfile.ErrInvalidFile) {
+			utils.Logger.Warn("ImportSongsHandler - invalid file", zap.Error(err))
+			response.Error(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		utils.Logger.Error("ImportSongsHandler - songService.ImportSongs failed", zap.Error(err))
+		response.Error(w, http.StatusInternalServerError, "Failed to import songs")
+		return
+	}
+
+	response.JSON(w, http.StatusOK, result)
+	utils.Logger.Info("ImportSongsHandler - songs imported", zap.Int("created", result.Created), zap.Int("duplicates", result.Duplicates), zap.Int("failed", result.Failed))
+}
+
+// importFormat maps the Content-Type of an import request to the file format, JSON by default.
+func importFormat(contentType string) songfile.Format {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "text/csv":
+		return songfile.CSV
+	case "application/x-ndjson", "application/ndjson":
+		return songfile.NDJSON
+	}
+	return songfile.JSON
+}
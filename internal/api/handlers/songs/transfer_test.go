@@ -0,0 +1,303 @@
+package songs_test
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"songlibrary/internal/api/handlers/songs"
+	"songlibrary/internal/api/middleware"
+	"songlibrary/internal/lib/songfile"
+	"songlibrary/internal/models"
+	mock_service "songlibrary/internal/service/mocks"
+	"songlibrary/internal/storage"
+	"songlibrary/internal/storage/memory"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportSongsHandler_Unit(t *testing.T) {
+	exportSongs := func(s *mock_service.MockSongService) {
+		s.EXPECT().ExportSongs(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+			func(_ any, _ *models.SongFilter, fn func(*models.Song) error) error {
+				for _, song := range []models.Song{
+					{ID: 1, GroupName: "Muse", SongName: "Starlight", ReleaseDate: sql.NullString{String: "2006-09-04", Valid: true}},
+					{ID: 2, GroupName: "Queen", SongName: "Innuendo", Link: sql.NullString{String: "https://example.com", Valid: true}},
+				} {
+					if err := fn(&song); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+	}
+
+	testCases := []struct {
+		name                string
+		queryParams         string
+		mockServiceFn       func(s *mock_service.MockSongService)
+		expectedStatus      int
+		expectedContentType string
+		expectedBody        string
+	}{
+		{
+			name:                "JSON by default",
+			mockServiceFn:       exportSongs,
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/json",
+			expectedBody: "[\n" +
+				`{"group":"Muse","song":"Starlight","releaseDate":"2006-09-04","text":null,"link":null}` + ",\n" +
+				`{"group":"Queen","song":"Innuendo","releaseDate":null,"text":null,"link":"https://example.com"}` + "\n]\n",
+		},
+		{
+			name:                "CSV",
+			queryParams:         "?format=csv",
+			mockServiceFn:       exportSongs,
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "text/csv; charset=utf-8",
+			expectedBody:        "group,song,releaseDate,text,link\nMuse,Starlight,2006-09-04,,\nQueen,Innuendo,,,https://example.com\n",
+		},
+		{
+			name:        "NDJSON with filter",
+			queryParams: "?format=ndjson&group=Muse&hasText=false",
+			mockServiceFn: func(s *mock_service.MockSongService) {
+				s.EXPECT().ExportSongs(gomock.Any(), &models.SongFilter{GroupName: stringPointer("Muse"), HasText: boolPointer(false)}, gomock.Any()).Return(nil)
+			},
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/x-ndjson",
+			expectedBody:        "",
+		},
+		{
+			name:                "Invalid format",
+			queryParams:         "?format=xml",
+			expectedStatus:      http.StatusBadRequest,
+			expectedContentType: "application/json",
+			expectedBody:        `{"error":"format must be csv, ndjson or json"}` + "\n",
+		},
+		{
+			name:                "Fuzzy match",
+			queryParams:         "?group=Muse&match=fuzzy",
+			expectedStatus:      http.StatusBadRequest,
+			expectedContentType: "application/json",
+			expectedBody:        `{"error":"invalid filter: fuzzy match is not supported by export"}` + "\n",
+		},
+		{
+			name: "Service error",
+			mockServiceFn: func(s *mock_service.MockSongService) {
+				s.EXPECT().ExportSongs(gomock.Any(), gomock.Any(), gomock.Any()).Return(errors.New("service error"))
+			},
+			expectedStatus:      http.StatusInternalServerError,
+			expectedContentType: "application/json",
+			expectedBody:        `{"error":"Failed to export songs"}` + "\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockService := mock_service.NewMockSongService(ctrl)
+			if tc.mockServiceFn != nil {
+				tc.mockServiceFn(mockService)
+			}
+
+			handler := songs.NewSongHandlers(mockService)
+
+			req := httptest.NewRequest("GET", "/songs/export"+tc.queryParams, nil)
+			w := httptest.NewRecorder()
+
+			handler.ExportSongsHandler(w, req)
+
+			assert.Equal(t, tc.expectedStatus, w.Code)
+			assert.Equal(t, tc.expectedContentType, w.Header().Get("Content-Type"))
+			assert.Equal(t, tc.expectedBody, w.Body.String())
+		})
+	}
+}
+
+func TestExportSongsHandler_AbortsTruncatedFile(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockService := mock_service.NewMockSongService(ctrl)
+	mockService.EXPECT().ExportSongs(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ any, _ *models.SongFilter, fn func(*models.Song) error) error {
+			if err := fn(&models.Song{ID: 1, GroupName: "Muse", SongName: "Starlight"}); err != nil {
+				return err
+			}
+			return errors.New("storage error")
+		})
+
+	handler := songs.NewSongHandlers(mockService)
+	req := httptest.NewRequest("GET", "/songs/export", nil)
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		handler.ExportSongsHandler(httptest.NewRecorder(), req)
+	})
+}
+
+func TestImportSongsHandler_Unit(t *testing.T) {
+	testCases := []struct {
+		name           string
+		queryParams    string
+		contentType    string
+		requestBody    string
+		mockServiceFn  func(s *mock_service.MockSongService)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:        "CSV by Content-Type",
+			queryParams: "?skipEnrichment=true",
+			contentType: "text/csv; charset=utf-8",
+			requestBody: "group,song\nMuse,Starlight\n",
+			mockServiceFn: func(s *mock_service.MockSongService) {
+				s.EXPECT().ImportSongs(gomock.Any(), gomock.Any(), true).DoAndReturn(
+					func(_ any, reader songfile.Reader, _ bool) (*models.ImportResult, error) {
+						doc, row, err := reader.Read()
+						assert.NoError(t, err)
+						assert.Equal(t, 2, row)
+						assert.Equal(t, models.SongDocument{GroupName: "Muse", SongName: "Starlight"}, doc)
+						return &models.ImportResult{Created: 1, Errors: []models.ImportError{}}, nil
+					})
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"created":1,"duplicates":0,"failed":0,"errors":[]}`,
+		},
+		{
+			name:        "Format parameter",
+			queryParams: "?format=ndjson",
+			contentType: "application/json",
+			requestBody: `{"group": "Muse", "song": "Starlight"}`,
+			mockServiceFn: func(s *mock_service.MockSongService) {
+				s.EXPECT().ImportSongs(gomock.Any(), gomock.Any(), false).Return(
+					&models.ImportResult{Duplicates: 1, Failed: 1, Errors: []models.ImportError{{Row: 2, Error: "failed to fetch song details"}}, Stopped: "invalid song file: element 3"}, nil,
+				)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"created":0,"duplicates":1,"failed":1,"errors":[{"row":2,"error":"failed to fetch song details"}],"stopped":"invalid song file: element 3"}`,
+		},
+		{
+			name:           "Invalid format",
+			queryParams:    "?format=xml",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"format must be csv, ndjson or json"}`,
+		},
+		{
+			name:           "Invalid skipEnrichment",
+			queryParams:    "?skipEnrichment=maybe",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"skipEnrichment must be true or false"}`,
+		},
+		{
+			name:           "CSV without song column",
+			contentType:    "text/csv",
+			requestBody:    "group,text\nMuse,\n",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"invalid song file: CSV header must name the group and song columns"}`,
+		},
+		{
+			name:        "Invalid file",
+			requestBody: `{"group": "Muse"}`,
+			mockServiceFn: func(s *mock_service.MockSongService) {
+				s.EXPECT().ImportSongs(gomock.Any(), gomock.Any(), false).Return(nil, songfile.ErrInvalidFile)
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"invalid song file"}`,
+		},
+		{
+			name:        "Service error",
+			requestBody: `[]`,
+			mockServiceFn: func(s *mock_service.MockSongService) {
+				s.EXPECT().ImportSongs(gomock.Any(), gomock.Any(), false).Return(nil, errors.New("service error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"error":"Failed to import songs"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockService := mock_service.NewMockSongService(ctrl)
+			if tc.mockServiceFn != nil {
+				tc.mockServiceFn(mockService)
+			}
+
+			handler := songs.NewSongHandlers(mockService)
+
+			req := httptest.NewRequest("POST", "/songs/import"+tc.queryParams, strings.NewReader(tc.requestBody))
+			if tc.contentType != "" {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+			w := httptest.NewRecorder()
+
+			handler.ImportSongsHandler(w, req)
+
+			assert.Equal(t, tc.expectedStatus, w.Code)
+			assert.JSONEq(t, tc.expectedBody, w.Body.String())
+		})
+	}
+}
+
+func TestImportSongsHandler_Idempotency(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// A keyed import larger than any buffer is streamed to the service, then replayed.
+	var file strings.Builder
+	for i := 0; file.Len() <= 12<<20; i++ {
+		fmt.Fprintf(&file, `{"group": "Group %d", "song": "Song %d", "text": "%s"}`+"\n", i, i, strings.Repeat("la ", 30))
+	}
+	mockService := mock_service.NewMockSongService(ctrl)
+	mockService.EXPECT().ImportSongs(gomock.Any(), gomock.Any(), true).DoAndReturn(
+		func(_ any, reader songfile.Reader, _ bool) (*models.ImportResult, error) {
+			result := &models.ImportResult{Errors: []models.ImportError{}}
+			for {
+				_, _, err := reader.Read()
+				if errors.Is(err, io.EOF) {
+					return result, nil
+				}
+				assert.NoError(t, err)
+				result.Created++
+			}
+		})
+	store := memory.NewMemStorage().(storage.IdempotencyStore)
+	handler := middleware.Idempotency(store, time.Hour)(http.HandlerFunc(songs.NewSongHandlers(mockService).ImportSongsHandler))
+
+	serve := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/songs/import?format=ndjson&skipEnrichment=true", strings.NewReader(body))
+		req.Header.Set(middleware.IdempotencyKeyHeader, "import")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	first := serve(file.String())
+	assert.Equal(t, http.StatusOK, first.Code)
+	var result models.ImportResult
+	assert.NoError(t, json.Unmarshal(first.Body.Bytes(), &result))
+	assert.Equal(t, strings.Count(file.String(), "\n"), result.Created)
+
+	retry := serve(file.String())
+	assert.Equal(t, http.StatusOK, retry.Code)
+	assert.Equal(t, "true", retry.Header().Get(middleware.IdempotentReplayedHeader))
+	assert.Equal(t, first.Body.String(), retry.Body.String())
+
+	other := serve(file.String() + `{"group": "Muse", "song": "Starlight"}` + "\n")
+	assert.Equal(t, http.StatusUnprocessableEntity, other.Code)
+}
+
+func boolPointer(b bool) *bool {
+	return &b
+}
@@ -0,0 +1,214 @@
+// Package middleware holds the HTTP middleware shared by the API handlers.
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+
+	"songlibrary/internal/lib/logger/utils"
+	"songlibrary/internal/lib/response"
+	"songlibrary/internal/models"
+	"songlibrary/internal/storage"
+)
+
+const (
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader is set to "true" on responses replayed from a stored record.
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+	// maxUnreadBodySize bounds the part of a request body left unread by the handler
+	// that is still read to fingerprint the request.
+	maxUnreadBodySize = 64 << 10
+)
+
+// replayedHeaders are the response headers stored along with the body.
+var replayedHeaders = []string{"Content-Type", "ETag", "Location"}
+
+// Idempotency makes write requests (POST, PUT, PATCH, DELETE) carrying an
+// Idempotency-Key header safe to retry. The response to the first request with a
+// key is stored for ttl and replayed to later requests with the same key, without
+// running the handler again. Reusing a key for a different request is answered
+// with 422, and a retry arriving while the first request runs with 409.
+// Server errors are not stored, so that the request can be retried.
+//
+// Keys are not scoped to the client address, so that a client retrying from
+// another network finds its record; the fingerprint check keeps a key reused for
+// another request from replaying the response. Request bodies are fingerprinted while the handler reads them, never
+// buffered; the response to a request whose body the handler left mostly unread
+// is not stored.
+func Idempotency(store storage.IdempotencyStore, ttl time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyKeyHeader)
+			if key == "" || !isWrite(r.Method) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > maxIdempotencyKeyLength {
+				utils.Logger.Warn("Idempotency - key too long", zap.Int("length", len(key)))
+				response.Error(w, http.StatusBadRequest, "Idempotency-Key must be at most 255 characters")
+				return
+			}
+
+			// The body is only known once read: the key is reserved with the fingerprint
+			// of the method and URI, completed with the one of the whole request.
+			body := newFingerprintReader(r)
+			existing, err := store.ReserveIdempotencyKey(r.Context(), key, body.sum(), ttl)
+			if err != nil {
+				utils.Logger.Error("Idempotency - ReserveIdempotencyKey failed", zap.Error(err), zap.String("key", key))
+				response.Error(w, http.StatusInternalServerError, "Failed to process Idempotency-Key")
+				return
+			}
+			if existing != nil {
+				if existing.StatusCode != 0 {
+					if _, err := io.Copy(io.Discard, body); err != nil {
+						utils.Logger.Warn("Idempotency - failed to read request body", zap.Error(err))
+						response.Error(w, http.StatusBadRequest, "Invalid request body")
+						return
+					}
+				}
+				switch {
+				case existing.Fingerprint != body.sum():
+					utils.Logger.Warn("Idempotency - key reused with a different request", zap.String("key", key))
+					response.Error(w, http.StatusUnprocessableEntity, "Idempotency-Key was already used with a different request")
+				case existing.StatusCode == 0:
+					utils.Logger.Warn("Idempotency - request with the key in progress", zap.String("key", key))
+					response.Error(w, http.StatusConflict, "A request with this Idempotency-Key is in progress")
+				default:
+					utils.Logger.Info("Idempotency - replaying stored response", zap.String("key", key), zap.Int("status", existing.StatusCode))
+					replay(w, existing)
+				}
+				return
+			}
+
+			// The record is settled even if the client goes away or the handler panics.
+			ctx := context.WithoutCancel(r.Context())
+			completed := false
+			defer func() {
+				if completed {
+					return
+				}
+				if err := store.ReleaseIdempotencyKey(ctx, key); err != nil {
+					utils.Logger.Error("Idempotency - ReleaseIdempotencyKey failed", zap.Error(err), zap.String("key", key))
+				}
+			}()
+
+			recorder := &responseRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+			r.Body = body
+			next.ServeHTTP(recorder, r)
+			if recorder.statusCode >= http.StatusInternalServerError {
+				return
+			}
+			if !body.drain(maxUnreadBodySize) {
+				utils.Logger.Warn("Idempotency - request body left unread, response not stored", zap.String("key", key))
+				return
+			}
+
+			record := &models.IdempotencyRecord{
+				Key:         key,
+				Fingerprint: body.sum(),
+				StatusCode:  recorder.statusCode,
+				Header:      make(map[string]string),
+				Body:        recorder.body.Bytes(),
+			}
+			for _, name := range replayedHeaders {
+				if value := w.Header().Get(name); value != "" {
+					record.Header[name] = value
+				}
+			}
+			if err := store.CompleteIdempotencyKey(ctx, record); err != nil {
+				utils.Logger.Error("Idempotency - CompleteIdempotencyKey failed", zap.Error(err), zap.String("key", key))
+				return
+			}
+			completed = true
+		})
+	}
+}
+
+func isWrite(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// fingerprintReader reads a request body, fingerprinting the request by its method,
+// URI and the part of the body read so far.
+type fingerprintReader struct {
+	body io.ReadCloser
+	hash hash.Hash
+	eof  bool
+}
+
+func newFingerprintReader(r *http.Request) *fingerprintReader {
+	hash := sha256.New()
+	io.WriteString(hash, r.Method+" "+r.URL.RequestURI()+"\n")
+	return &fingerprintReader{body: r.Body, hash: hash}
+}
+
+func (f *fingerprintReader) Read(p []byte) (int, error) {
+	n, err := f.body.Read(p)
+	f.hash.Write(p[:n])
+	if err == io.EOF {
+		f.eof = true
+	}
+	return n, err
+}
+
+func (f *fingerprintReader) Close() error {
+	return f.body.Close()
+}
+
+// drain reads at most limit bytes of the rest of the body and reports whether the
+// whole body was read.
+func (f *fingerprintReader) drain(limit int64) bool {
+	if !f.eof {
+		io.Copy(io.Discard, io.LimitReader(f, limit+1))
+	}
+	return f.eof
+}
+
+func (f *fingerprintReader) sum() string {
+	return hex.EncodeToString(f.hash.Sum(nil))
+}
+
+func replay(w http.ResponseWriter, record *models.IdempotencyRecord) {
+	for name, value := range record.Header {
+		w.Header().Set(name, value)
+	}
+	w.Header().Set(IdempotentReplayedHeader, "true")
+	w.WriteHeader(record.StatusCode)
+	w.Write(record.Body)
+}
+
+// responseRecorder passes the response through while keeping a copy of it.
+type responseRecorder struct {
+	http.ResponseWriter
+	statusCode  int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(statusCode int) {
+	if !r.wroteHeader {
+		r.statusCode = statusCode
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
@@ -0,0 +1,151 @@
+package middleware_test
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"songlibrary/internal/api/middleware"
+	"songlibrary/internal/lib/logger/utils"
+	"songlibrary/internal/storage"
+	"songlibrary/internal/storage/memory"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	if err := utils.InitLogger(); err != nil {
+		log.Fatalf("Failed to initialize logger: %v", err)
+	}
+	exitCode := m.Run()
+	utils.Logger.Sync()
+	os.Exit(exitCode)
+}
+
+func TestIdempotency(t *testing.T) {
+	store := memory.NewMemStorage().(storage.IdempotencyStore)
+	calls := 0
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.URL.Query().Get("fail") != "" {
+			http.Error(w, "failed", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", fmt.Sprintf("/songs/%d", calls))
+		w.Header().Set("X-Request", "not replayed")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"id":%d}`, calls)
+	})
+	handler := middleware.Idempotency(store, time.Hour)(next)
+
+	serve := func(method, target, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if key != "" {
+			req.Header.Set(middleware.IdempotencyKeyHeader, key)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Replay", func(t *testing.T) {
+		first := serve("POST", "/songs", "replay", `{"group":"Muse"}`)
+		assert.Equal(t, http.StatusCreated, first.Code)
+		assert.Empty(t, first.Header().Get(middleware.IdempotentReplayedHeader))
+
+		retry := serve("POST", "/songs", "replay", `{"group":"Muse"}`)
+		assert.Equal(t, http.StatusCreated, retry.Code)
+		assert.Equal(t, first.Body.String(), retry.Body.String())
+		assert.Equal(t, first.Header().Get("Location"), retry.Header().Get("Location"))
+		assert.Equal(t, "application/json", retry.Header().Get("Content-Type"))
+		assert.Empty(t, retry.Header().Get("X-Request"))
+		assert.Equal(t, "true", retry.Header().Get(middleware.IdempotentReplayedHeader))
+	})
+
+	t.Run("Different request", func(t *testing.T) {
+		serve("POST", "/songs", "different", `{"group":"Muse"}`)
+		w := serve("POST", "/songs", "different", `{"group":"Queen"}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.JSONEq(t, `{"error":"Idempotency-Key was already used with a different request"}`, w.Body.String())
+
+		w = serve("PUT", "/songs", "different", `{"group":"Muse"}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	})
+
+	t.Run("In progress", func(t *testing.T) {
+		before := calls
+		first := httptest.NewRequest("DELETE", "/songs/1", nil)
+		first.Header.Set(middleware.IdempotencyKeyHeader, "in-progress")
+		blocking := middleware.Idempotency(store, time.Hour)(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+			retry := serve("DELETE", "/songs/1", "in-progress", "")
+			assert.Equal(t, http.StatusConflict, retry.Code)
+		}))
+		blocking.ServeHTTP(httptest.NewRecorder(), first)
+		assert.Equal(t, before, calls)
+	})
+
+	t.Run("Server errors are not stored", func(t *testing.T) {
+		before := calls
+		assert.Equal(t, http.StatusServiceUnavailable, serve("POST", "/songs?fail=1", "failure", "").Code)
+		assert.Equal(t, http.StatusServiceUnavailable, serve("POST", "/songs?fail=1", "failure", "").Code)
+		assert.Equal(t, before+2, calls)
+	})
+
+	t.Run("Without key", func(t *testing.T) {
+		before := calls
+		serve("POST", "/songs", "", `{}`)
+		serve("POST", "/songs", "", `{}`)
+		assert.Equal(t, before+2, calls)
+	})
+
+	t.Run("Reads are ignored", func(t *testing.T) {
+		before := calls
+		serve("GET", "/songs", "read", "")
+		serve("GET", "/songs", "read", "")
+		assert.Equal(t, before+2, calls)
+	})
+
+	t.Run("Key too long", func(t *testing.T) {
+		w := serve("POST", "/songs", strings.Repeat("k", 256), `{}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		before := calls
+		expiring := middleware.Idempotency(store, -time.Second)(next)
+		for range 2 {
+			req := httptest.NewRequest("POST", "/songs", strings.NewReader(`{}`))
+			req.Header.Set(middleware.IdempotencyKeyHeader, "expired")
+			expiring.ServeHTTP(httptest.NewRecorder(), req)
+		}
+		assert.Equal(t, before+2, calls)
+	})
+
+	t.Run("Retry from another address", func(t *testing.T) {
+		first := serve("POST", "/songs", "address", `{"group":"Muse"}`)
+		req := httptest.NewRequest("POST", "/songs", strings.NewReader(`{"group":"Muse"}`))
+		req.Header.Set(middleware.IdempotencyKeyHeader, "address")
+		req.RemoteAddr = "198.51.100.7:4321"
+		retry := httptest.NewRecorder()
+		handler.ServeHTTP(retry, req)
+		assert.Equal(t, http.StatusCreated, retry.Code)
+		assert.Equal(t, "true", retry.Header().Get(middleware.IdempotentReplayedHeader))
+		assert.Equal(t, first.Body.String(), retry.Body.String())
+	})
+
+	t.Run("Unread body", func(t *testing.T) {
+		before := calls
+		body := strings.Repeat("x", 1<<20)
+		assert.Equal(t, http.StatusCreated, serve("POST", "/songs", "unread", body).Code)
+		retry := serve("POST", "/songs", "unread", body)
+		assert.Equal(t, http.StatusCreated, retry.Code)
+		assert.Empty(t, retry.Header().Get(middleware.IdempotentReplayedHeader))
+		assert.Equal(t, before+2, calls)
+	})
+}
@@ -0,0 +1,150 @@
+package jsonpatch_test
+
+import (
+	"testing"
+
+	"songlibrary/internal/lib/jsonpatch"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergePatch(t *testing.T) {
+	testCases := []struct {
+		name     string
+		doc      string
+		patch    string
+		expected string
+	}{
+		{
+			name:     "Replace member",
+			doc:      `{"a":"b","c":"d"}`,
+			patch:    `{"a":"z"}`,
+			expected: `{"a":"z","c":"d"}`,
+		},
+		{
+			name:     "Remove member",
+			doc:      `{"a":"b","c":"d"}`,
+			patch:    `{"c":null}`,
+			expected: `{"a":"b"}`,
+		},
+		{
+			name:     "Nested objects",
+			doc:      `{"title":"Goodbye!","author":{"givenName":"John","familyName":"Doe"},"tags":["example","sample"]}`,
+			patch:    `{"title":"Hello!","author":{"familyName":null},"tags":["example"],"phoneNumber":"+01-123-456-7890"}`,
+			expected: `{"title":"Hello!","author":{"givenName":"John"},"tags":["example"],"phoneNumber":"+01-123-456-7890"}`,
+		},
+		{
+			name:     "Non-object patch replaces the document",
+			doc:      `{"a":"b"}`,
+			patch:    `["c"]`,
+			expected: `["c"]`,
+		},
+		{
+			name:     "Empty patch",
+			doc:      `{"a":"b"}`,
+			patch:    `{}`,
+			expected: `{"a":"b"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := jsonpatch.MergePatch([]byte(tc.doc), []byte(tc.patch))
+			require.NoError(t, err)
+			assert.JSONEq(t, tc.expected, string(result))
+		})
+	}
+
+	_, err := jsonpatch.MergePatch([]byte(`{}`), []byte(`{"a":`))
+	assert.ErrorIs(t, err, jsonpatch.ErrInvalidPatch)
+}
+
+func TestApply(t *testing.T) {
+	testCases := []struct {
+		name        string
+		doc         string
+		patch       string
+		expected    string
+		expectedErr error
+	}{
+		{
+			name:     "Add member",
+			doc:      `{"foo":"bar"}`,
+			patch:    `[{"op":"add","path":"/baz","value":"qux"}]`,
+			expected: `{"baz":"qux","foo":"bar"}`,
+		},
+		{
+			name:     "Add array element",
+			doc:      `{"foo":["bar","baz"]}`,
+			patch:    `[{"op":"add","path":"/foo/1","value":"qux"},{"op":"add","path":"/foo/-","value":"end"}]`,
+			expected: `{"foo":["bar","qux","baz","end"]}`,
+		},
+		{
+			name:     "Remove and replace",
+			doc:      `{"baz":"qux","foo":"bar","list":[1,2,3]}`,
+			patch:    `[{"op":"remove","path":"/baz"},{"op":"replace","path":"/foo","value":null},{"op":"remove","path":"/list/0"}]`,
+			expected: `{"foo":null,"list":[2,3]}`,
+		},
+		{
+			name:     "Move and copy",
+			doc:      `{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`,
+			patch:    `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"},{"op":"copy","from":"/qux","path":"/copy"}]`,
+			expected: `{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"},"copy":{"corge":"grault","thud":"fred"}}`,
+		},
+		{
+			name:     "Escaped pointer",
+			doc:      `{"a/b":1,"m~n":2}`,
+			patch:    `[{"op":"test","path":"/a~1b","value":1},{"op":"remove","path":"/m~0n"}]`,
+			expected: `{"a/b":1}`,
+		},
+		{
+			name:        "Test failed",
+			doc:         `{"foo":"bar"}`,
+			patch:       `[{"op":"test","path":"/foo","value":"baz"}]`,
+			expectedErr: jsonpatch.ErrConflict,
+		},
+		{
+			name:        "Missing member",
+			doc:         `{"foo":"bar"}`,
+			patch:       `[{"op":"replace","path":"/baz","value":"qux"}]`,
+			expectedErr: jsonpatch.ErrConflict,
+		},
+		{
+			name:        "Index out of range",
+			doc:         `{"foo":["bar"]}`,
+			patch:       `[{"op":"add","path":"/foo/2","value":"qux"}]`,
+			expectedErr: jsonpatch.ErrConflict,
+		},
+		{
+			name:        "Unknown operation",
+			doc:         `{"foo":"bar"}`,
+			patch:       `[{"op":"merge","path":"/foo","value":"baz"}]`,
+			expectedErr: jsonpatch.ErrInvalidPatch,
+		},
+		{
+			name:        "Missing value",
+			doc:         `{"foo":"bar"}`,
+			patch:       `[{"op":"add","path":"/baz"}]`,
+			expectedErr: jsonpatch.ErrInvalidPatch,
+		},
+		{
+			name:        "Not an array",
+			doc:         `{"foo":"bar"}`,
+			patch:       `{"foo":"baz"}`,
+			expectedErr: jsonpatch.ErrInvalidPatch,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := jsonpatch.Apply([]byte(tc.doc), []byte(tc.patch))
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.JSONEq(t, tc.expected, string(result))
+		})
+	}
+}
@@ -0,0 +1,56 @@
+// Package normalize computes the identity keys of song names, so that names
+// differing only in Unicode form, case, spacing or article placement denote the
+// same song: "The Beatles", "the beatles " and "Beatles, The" share a key.
+package normalize
+
+import (
+	"strings"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// DefaultArticles are the articles ignored when none are configured.
+var DefaultArticles = []string{"the"}
+
+type Normalizer struct {
+	articles []string
+}
+
+// New returns a Normalizer ignoring the given articles, either leading the name
+// ("The Beatles") or trailing it after a comma ("Beatles, The").
+func New(articles []string) *Normalizer {
+	n := &Normalizer{}
+	for _, article := range articles {
+		if article = fold(article); article != "" {
+			n.articles = append(n.articles, article)
+		}
+	}
+	return n
+}
+
+// Key returns the identity key of name: NFKC normalized, case folded, with
+// whitespace runs collapsed into single spaces and the article removed.
+func (n *Normalizer) Key(name string) string {
+	key := fold(name)
+	for _, article := range n.articles {
+		if rest, ok := strings.CutPrefix(key, article+" "); ok {
+			return rest
+		}
+		if rest, ok := strings.CutSuffix(key, ", "+article); ok {
+			return rest
+		}
+	}
+	return key
+}
+
+// Name returns name as it is stored: NFKC normalized with surrounding whitespace trimmed.
+func Name(name string) string {
+	return strings.TrimSpace(norm.NFKC.String(name))
+}
+
+func fold(s string) string {
+	// Case folding may denormalize the string, hence the second NFKC pass.
+	s = norm.NFKC.String(cases.Fold().String(norm.NFKC.String(s)))
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,44 @@
+package normalize_test
+
+import (
+	"testing"
+
+	"songlibrary/internal/lib/normalize"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKey(t *testing.T) {
+	testCases := []struct {
+		name     string
+		articles []string
+		input    string
+		expected string
+	}{
+		{name: "Case", articles: normalize.DefaultArticles, input: "Muse", expected: "muse"},
+		{name: "Whitespace", articles: normalize.DefaultArticles, input: "  Pink \t Floyd ", expected: "pink floyd"},
+		{name: "Leading article", articles: normalize.DefaultArticles, input: "The Beatles", expected: "beatles"},
+		{name: "Trailing article", articles: normalize.DefaultArticles, input: "Beatles, The", expected: "beatles"},
+		{name: "Lowercase article with space", articles: normalize.DefaultArticles, input: "the beatles ", expected: "beatles"},
+		{name: "Article only", articles: normalize.DefaultArticles, input: "The", expected: "the"},
+		{name: "Article inside", articles: normalize.DefaultArticles, input: "Rage Against the Machine", expected: "rage against the machine"},
+		{name: "Article prefix of a word", articles: normalize.DefaultArticles, input: "Therapy?", expected: "therapy?"},
+		{name: "No articles", articles: nil, input: "The Beatles", expected: "the beatles"},
+		{name: "Configured articles", articles: []string{"Die", "La"}, input: "Die Ärzte", expected: "ärzte"},
+		{name: "Compatibility form", articles: nil, input: "Ｍｕｓｅ", expected: "muse"},
+		{name: "Full case folding", articles: nil, input: "STRASSE", expected: "strasse"},
+		{name: "Sharp s", articles: nil, input: "Straße", expected: "strasse"},
+		{name: "Composed and decomposed", articles: nil, input: "Beyoncé", expected: "beyoncé"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, normalize.New(tc.articles).Key(tc.input))
+		})
+	}
+}
+
+func TestName(t *testing.T) {
+	assert.Equal(t, "Muse", normalize.Name(" Ｍｕｓｅ \n"))
+	assert.Equal(t, "the beatles", normalize.Name("the beatles "))
+}
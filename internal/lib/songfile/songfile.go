@@ -0,0 +1,315 @@
+// Package songfile reads and writes songs as CSV, newline-delimited JSON or a
+// JSON array of models.SongDocument, one record at a time.
+package songfile
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+
+	"songlibrary/internal/models"
+)
+
+type Format string
+
+const (
+	CSV    Format = "csv"
+	NDJSON Format = "ndjson"
+	JSON   Format = "json"
+)
+
+// ErrInvalidFile is returned for input that cannot be read any further, such as
+// a CSV header without group and song columns or broken JSON array syntax.
+var ErrInvalidFile = errors.New("invalid song file")
+
+// csvHeader names the columns written to CSV files. Read accepts them in any order.
+var csvHeader = []string{"group", "song", "releaseDate", "text", "link"}
+
+func ParseFormat(s string) (Format, error) {
+	switch format := Format(s); format {
+	case CSV, NDJSON, JSON:
+		return format, nil
+	}
+	return "", errors.New("format must be csv, ndjson or json")
+}
+
+func (f Format) ContentType() string {
+	switch f {
+	case CSV:
+		return "text/csv; charset=utf-8"
+	case NDJSON:
+		return "application/x-ndjson"
+	}
+	return "application/json"
+}
+
+// RowError reports a malformed record. Reading continues with the next one.
+type RowError struct {
+	// Row is the line of the record for CSV and NDJSON, counting the CSV header,
+	// and the position of the element, from 1, for a JSON array.
+	Row int
+	Err error
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("row %d: %v", e.Row, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// Writer writes songs to a file in one of the formats.
+type Writer interface {
+	Write(doc models.SongDocument) error
+	// Close completes the file. It does not close the underlying writer.
+	Close() error
+}
+
+func NewWriter(w io.Writer, format Format) Writer {
+	switch format {
+	case CSV:
+		return &csvWriter{w: csv.NewWriter(w)}
+	case NDJSON:
+		return &ndjsonWriter{encoder: json.NewEncoder(w)}
+	}
+	return &jsonWriter{w: w}
+}
+
+type csvWriter struct {
+	w             *csv.Writer
+	headerWritten bool
+}
+
+func (w *csvWriter) writeHeader() error {
+	if w.headerWritten {
+		return nil
+	}
+	w.headerWritten = true
+	return w.w.Write(csvHeader)
+}
+
+func (w *csvWriter) Write(doc models.SongDocument) error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	return w.w.Write([]string{doc.GroupName, doc.SongName, deref(doc.ReleaseDate), deref(doc.Text), deref(doc.Link)})
+}
+
+func (w *csvWriter) Close() error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	w.w.Flush()
+	return w.w.Error()
+}
+
+type ndjsonWriter struct {
+	encoder *json.Encoder
+}
+
+func (w *ndjsonWriter) Write(doc models.SongDocument) error {
+	return w.encoder.Encode(doc)
+}
+
+func (w *ndjsonWriter) Close() error {
+	return nil
+}
+
+// jsonWriter writes the array one element per line, so that it is never held in memory.
+type jsonWriter struct {
+	w     io.Writer
+	count int
+}
+
+func (w *jsonWriter) Write(doc models.SongDocument) error {
+	element, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	separator := ",\n"
+	if w.count == 0 {
+		separator = "[\n"
+	}
+	w.count++
+	_, err = w.w.Write(append([]byte(separator), element...))
+	return err
+}
+
+func (w *jsonWriter) Close() error {
+	end := "\n]\n"
+	if w.count == 0 {
+		end = "[]\n"
+	}
+	_, err := io.WriteString(w.w, end)
+	return err
+}
+
+// Reader reads songs from a file in one of the formats.
+type Reader interface {
+	// Read returns the next record and its row. Malformed records are reported
+	// with a *RowError, after which Read may be called again; any other error,
+	// including io.EOF at the end of the file, ends reading.
+	Read() (models.SongDocument, int, error)
+}
+
+// NewReader returns a Reader of r. For CSV it reads the header first and fails
+// with ErrInvalidFile when it does not name the group and song columns.
+func NewReader(r io.Reader, format Format) (Reader, error) {
+	switch format {
+	case CSV:
+		return newCSVReader(r)
+	case NDJSON:
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(nil, maxLineSize)
+		return &ndjsonReader{scanner: scanner}, nil
+	}
+	return &jsonReader{decoder: json.NewDecoder(r)}, nil
+}
+
+// maxLineSize bounds NDJSON lines, which may hold the text of a song.
+const maxLineSize = 1 << 20
+
+type csvReader struct {
+	r *csv.Reader
+	// columns maps the indexes of csvHeader to the columns of the file, -1 when absent.
+	columns []int
+}
+
+func newCSVReader(r io.Reader) (*csvReader, error) {
+	reader := csv.NewReader(r)
+	reader.ReuseRecord = true
+	header, err := reader.Read()
+	if err != nil {
+		if err == io.EOF {
+			return nil, fmt.Errorf("%w: missing CSV header", ErrInvalidFile)
+		}
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+	}
+
+	columns := make([]int, len(csvHeader))
+	for i, name := range csvHeader {
+		columns[i] = slices.Index(header, name)
+	}
+	if columns[0] < 0 || columns[1] < 0 {
+		return nil, fmt.Errorf("%w: CSV header must name the group and song columns", ErrInvalidFile)
+	}
+	reader.FieldsPerRecord = len(header)
+	return &csvReader{r: reader, columns: columns}, nil
+}
+
+func (r *csvReader) Read() (models.SongDocument, int, error) {
+	record, err := r.r.Read()
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return models.SongDocument{}, parseErr.StartLine, &RowError{Row: parseErr.StartLine, Err: parseErr.Err}
+		}
+		return models.SongDocument{}, 0, err
+	}
+	row, _ := r.r.FieldPos(0)
+
+	field := func(i int) string {
+		if r.columns[i] < 0 {
+			return ""
+		}
+		return record[r.columns[i]]
+	}
+	// An empty cell is a missing value.
+	optional := func(i int) *string {
+		if value := field(i); value != "" {
+			return &value
+		}
+		return nil
+	}
+	return models.SongDocument{
+		GroupName:   field(0),
+		SongName:    field(1),
+		ReleaseDate: optional(2),
+		Text:        optional(3),
+		Link:        optional(4),
+	}, row, nil
+}
+
+type ndjsonReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func (r *ndjsonReader) Read() (models.SongDocument, int, error) {
+	for r.scanner.Scan() {
+		r.line++
+		line := bytes.TrimSpace(r.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		doc, err := decodeDocument(line)
+		if err != nil {
+			return models.SongDocument{}, r.line, &RowError{Row: r.line, Err: err}
+		}
+		return doc, r.line, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return models.SongDocument{}, 0, fmt.Errorf("%w: line %d is longer than %d bytes", ErrInvalidFile, r.line+1, maxLineSize)
+		}
+		return models.SongDocument{}, 0, err
+	}
+	return models.SongDocument{}, 0, io.EOF
+}
+
+type jsonReader struct {
+	decoder *json.Decoder
+	started bool
+	element int
+}
+
+func (r *jsonReader) Read() (models.SongDocument, int, error) {
+	if !r.started {
+		r.started = true
+		token, err := r.decoder.Token()
+		if err != nil || token != json.Delim('[') {
+			return models.SongDocument{}, 0, fmt.Errorf("%w: expected a JSON array", ErrInvalidFile)
+		}
+	}
+	if !r.decoder.More() {
+		if _, err := r.decoder.Token(); err != nil {
+			return models.SongDocument{}, 0, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+		}
+		return models.SongDocument{}, 0, io.EOF
+	}
+
+	r.element++
+	var element json.RawMessage
+	if err := r.decoder.Decode(&element); err != nil {
+		// The array cannot be resynchronized after a syntax error.
+		return models.SongDocument{}, 0, fmt.Errorf("%w: element %d: %v", ErrInvalidFile, r.element, err)
+	}
+	doc, err := decodeDocument(element)
+	if err != nil {
+		return models.SongDocument{}, r.element, &RowError{Row: r.element, Err: err}
+	}
+	return doc, r.element, nil
+}
+
+func decodeDocument(data []byte) (models.SongDocument, error) {
+	var doc models.SongDocument
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&doc); err != nil {
+		return models.SongDocument{}, err
+	}
+	return doc, nil
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,151 @@
+package songfile_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"songlibrary/internal/lib/songfile"
+	"songlibrary/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type record struct {
+	doc models.SongDocument
+	row int
+	err string
+}
+
+func readAll(reader songfile.Reader) ([]record, error) {
+	var records []record
+	for {
+		doc, row, err := reader.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		var rowErr *songfile.RowError
+		if errors.As(err, &rowErr) {
+			records = append(records, record{row: rowErr.Row, err: rowErr.Err.Error()})
+			continue
+		}
+		if err != nil {
+			return records, err
+		}
+		records = append(records, record{doc: doc, row: row})
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	date, text, link := "2006-07-16", "First verse, \"quoted\"\n\nSecond verse", "https://example.com"
+	docs := []models.SongDocument{
+		{GroupName: "Muse", SongName: "Supermassive Black Hole", ReleaseDate: &date, Text: &text, Link: &link},
+		{GroupName: "Queen", SongName: "Innuendo"},
+	}
+
+	for _, format := range []songfile.Format{songfile.CSV, songfile.NDJSON, songfile.JSON} {
+		t.Run(string(format), func(t *testing.T) {
+			var file bytes.Buffer
+			writer := songfile.NewWriter(&file, format)
+			for _, doc := range docs {
+				require.NoError(t, writer.Write(doc))
+			}
+			require.NoError(t, writer.Close())
+
+			reader, err := songfile.NewReader(&file, format)
+			require.NoError(t, err)
+			records, err := readAll(reader)
+			require.NoError(t, err)
+			if assert.Len(t, records, 2) {
+				assert.Equal(t, docs[0], records[0].doc)
+				assert.Equal(t, docs[1], records[1].doc)
+			}
+		})
+	}
+}
+
+func TestEmptyFile(t *testing.T) {
+	for format, expected := range map[songfile.Format]string{
+		songfile.CSV:    "group,song,releaseDate,text,link\n",
+		songfile.NDJSON: "",
+		songfile.JSON:   "[]\n",
+	} {
+		var file bytes.Buffer
+		require.NoError(t, songfile.NewWriter(&file, format).Close())
+		assert.Equal(t, expected, file.String(), format)
+	}
+}
+
+func TestReadCSV(t *testing.T) {
+	file := "id,song,group,link\n" +
+		"1,Starlight,Muse,\n" +
+		"2,Uprising\n" +
+		"3,\"Bohemian\nRhapsody\",Queen,https://example.com\n"
+	reader, err := songfile.NewReader(strings.NewReader(file), songfile.CSV)
+	require.NoError(t, err)
+	records, err := readAll(reader)
+	require.NoError(t, err)
+
+	link := "https://example.com"
+	assert.Equal(t, []record{
+		{doc: models.SongDocument{GroupName: "Muse", SongName: "Starlight"}, row: 2},
+		{row: 3, err: "wrong number of fields"},
+		{doc: models.SongDocument{GroupName: "Queen", SongName: "Bohemian\nRhapsody", Link: &link}, row: 4},
+	}, records)
+
+	for _, file := range []string{"", "group,text\nMuse,\n"} {
+		_, err := songfile.NewReader(strings.NewReader(file), songfile.CSV)
+		assert.ErrorIs(t, err, songfile.ErrInvalidFile, file)
+	}
+}
+
+func TestReadNDJSON(t *testing.T) {
+	file := `{"group": "Muse", "song": "Starlight"}` + "\n\n" +
+		`{"group": "Muse", "song": ` + "\n" +
+		`{"group": "Muse", "song": "Uprising", "year": 2009}` + "\n" +
+		`{"group": "Queen", "song": "Innuendo"}`
+	reader, err := songfile.NewReader(strings.NewReader(file), songfile.NDJSON)
+	require.NoError(t, err)
+	records, err := readAll(reader)
+	require.NoError(t, err)
+
+	if assert.Len(t, records, 4) {
+		assert.Equal(t, record{doc: models.SongDocument{GroupName: "Muse", SongName: "Starlight"}, row: 1}, records[0])
+		assert.Equal(t, 3, records[1].row)
+		assert.NotEmpty(t, records[1].err)
+		assert.Equal(t, 4, records[2].row)
+		assert.Contains(t, records[2].err, "unknown field")
+		assert.Equal(t, record{doc: models.SongDocument{GroupName: "Queen", SongName: "Innuendo"}, row: 5}, records[3])
+	}
+}
+
+func TestReadJSON(t *testing.T) {
+	reader, err := songfile.NewReader(strings.NewReader(`[{"group": "Muse", "song": "Starlight"}, 42, {"group": "Queen", "song": "Innuendo"}]`), songfile.JSON)
+	require.NoError(t, err)
+	records, err := readAll(reader)
+	require.NoError(t, err)
+	if assert.Len(t, records, 3) {
+		assert.Equal(t, 2, records[1].row)
+		assert.NotEmpty(t, records[1].err)
+		assert.Equal(t, record{doc: models.SongDocument{GroupName: "Queen", SongName: "Innuendo"}, row: 3}, records[2])
+	}
+
+	for _, file := range []string{`{"group": "Muse"}`, `[{"group": "Muse", "song": "Starlight"} {"group": "Muse"}]`, `[{"group": "Muse"`} {
+		reader, err := songfile.NewReader(strings.NewReader(file), songfile.JSON)
+		require.NoError(t, err)
+		_, err = readAll(reader)
+		assert.ErrorIs(t, err, songfile.ErrInvalidFile, file)
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	format, err := songfile.ParseFormat("ndjson")
+	assert.NoError(t, err)
+	assert.Equal(t, songfile.NDJSON, format)
+
+	_, err = songfile.ParseFormat("xml")
+	assert.Error(t, err)
+}
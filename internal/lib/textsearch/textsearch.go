@@ -0,0 +1,142 @@
+// Package textsearch is a small in-process approximation of the PostgreSQL
+// full-text search, used by the storages that have no tsvector support.
+// Words are compared case-insensitively and without stemming.
+package textsearch
+
+import (
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+
+	"songlibrary/internal/models"
+)
+
+const (
+	nameWeight = 1.0
+	textWeight = 0.4
+
+	maxSnippetVerses = 2
+)
+
+// Search returns the page of songs matching query, most relevant first,
+// and the total number of matches.
+func Search(songs []models.Song, query string, pagination *models.Pagination) ([]models.SongSearchResult, int) {
+	terms := Terms(query)
+	var results []models.SongSearchResult
+	for _, song := range songs {
+		if rank := Rank(song, terms); rank > 0 {
+			results = append(results, models.SongSearchResult{Song: song, Rank: rank})
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Rank != results[j].Rank {
+			return results[i].Rank > results[j].Rank
+		}
+		return results[i].ID < results[j].ID
+	})
+
+	total := len(results)
+	offset := pagination.GetOffset()
+	if offset >= total {
+		return nil, total
+	}
+	results = results[offset:min(offset+pagination.GetLimit(), total)]
+	for i := range results {
+		results[i].Snippet = Snippet(results[i].Text.String, terms)
+	}
+	return results, total
+}
+
+// Terms splits query into lower-cased words.
+func Terms(query string) []string {
+	return words(query)
+}
+
+// Rank scores how well song matches terms, 0 when it does not contain all of them.
+// Matches in the group and song names weigh more than matches in the text.
+func Rank(song models.Song, terms []string) float64 {
+	if len(terms) == 0 {
+		return 0
+	}
+	nameCounts := countWords(song.GroupName + " " + song.SongName)
+	textCounts := countWords(song.Text.String)
+
+	var rank float64
+	for _, term := range terms {
+		if nameCounts[term] == 0 && textCounts[term] == 0 {
+			return 0
+		}
+		rank += nameWeight*float64(nameCounts[term]) + textWeight*float64(textCounts[term])
+	}
+	return rank
+}
+
+// Snippet returns the first verses of text containing any of terms, HTML-escaped
+// with the terms wrapped in <mark></mark>, or "" when text does not match.
+func Snippet(text string, terms []string) string {
+	wanted := make(map[string]bool, len(terms))
+	for _, term := range terms {
+		wanted[term] = true
+	}
+
+	var fragments []string
+	for _, verse := range strings.Split(text, "\n\n") {
+		if highlighted, ok := highlight(verse, wanted); ok {
+			fragments = append(fragments, highlighted)
+			if len(fragments) == maxSnippetVerses {
+				break
+			}
+		}
+	}
+	return strings.Join(fragments, " … ")
+}
+
+func highlight(verse string, wanted map[string]bool) (string, bool) {
+	var b strings.Builder
+	matched := false
+	start := -1
+	flush := func(end int) {
+		word := verse[start:end]
+		if wanted[strings.ToLower(word)] {
+			matched = true
+			b.WriteString("<mark>" + html.EscapeString(word) + "</mark>")
+		} else {
+			b.WriteString(html.EscapeString(word))
+		}
+		start = -1
+	}
+
+	for i, r := range verse {
+		if isWordRune(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			flush(i)
+		}
+		b.WriteString(html.EscapeString(string(r)))
+	}
+	if start >= 0 {
+		flush(len(verse))
+	}
+	return b.String(), matched
+}
+
+func countWords(s string) map[string]int {
+	counts := make(map[string]int)
+	for _, word := range words(s) {
+		counts[word]++
+	}
+	return counts
+}
+
+func words(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool { return !isWordRune(r) })
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,96 @@
+// Package trigram computes string similarity the way the PostgreSQL pg_trgm
+// extension does, for the storages that have no pg_trgm.
+package trigram
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"songlibrary/internal/models"
+)
+
+// Match returns the similarity of the song names to the name conditions of a
+// fuzzy filter, averaged over the conditions, and whether each of the names is
+// at least models.SimilarityThreshold similar to its value.
+func Match(song models.Song, filter *models.SongFilter) (float64, bool) {
+	var total float64
+	var conditions int
+	for _, name := range []struct {
+		actual string
+		value  *string
+	}{{song.GroupName, filter.GroupName}, {song.SongName, filter.SongName}} {
+		if name.value == nil || *name.value == "" {
+			continue
+		}
+		similarity := Similarity(name.actual, *name.value)
+		if similarity < models.SimilarityThreshold {
+			return 0, false
+		}
+		total += similarity
+		conditions++
+	}
+	if conditions == 0 {
+		return 0, false
+	}
+	return total / float64(conditions), true
+}
+
+// Suggest returns up to models.MaxSuggestions of the distinct names at least
+// models.SuggestionThreshold similar to value, most similar first.
+func Suggest(field string, names []string, value string) []models.NameSuggestion {
+	seen := make(map[string]bool, len(names))
+	var suggestions []models.NameSuggestion
+	for _, name := range names {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		if similarity := Similarity(name, value); similarity >= models.SuggestionThreshold {
+			suggestions = append(suggestions, models.NameSuggestion{Field: field, Value: name, Similarity: similarity})
+		}
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].Similarity != suggestions[j].Similarity {
+			return suggestions[i].Similarity > suggestions[j].Similarity
+		}
+		return suggestions[i].Value < suggestions[j].Value
+	})
+	if len(suggestions) > models.MaxSuggestions {
+		suggestions = suggestions[:models.MaxSuggestions]
+	}
+	return suggestions
+}
+
+// Similarity returns the pg_trgm similarity of a and b: the number of trigrams
+// they share divided by the number of distinct trigrams of both, from 0 to 1.
+func Similarity(a, b string) float64 {
+	ta, tb := trigrams(a), trigrams(b)
+	if len(ta) == 0 || len(tb) == 0 {
+		return 0
+	}
+	common := 0
+	for t := range ta {
+		if tb[t] {
+			common++
+		}
+	}
+	return float64(common) / float64(len(ta)+len(tb)-common)
+}
+
+// trigrams returns the set of trigrams of s. Like pg_trgm, it lower-cases s,
+// splits it into alphanumeric words and pads each word with two spaces in
+// front and one at the end.
+func trigrams(s string) map[string]bool {
+	set := make(map[string]bool)
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range words {
+		padded := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(padded); i++ {
+			set[string(padded[i:i+3])] = true
+		}
+	}
+	return set
+}
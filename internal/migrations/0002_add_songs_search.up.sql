@@ -0,0 +1,10 @@
+-- search_language is the text search configuration the lyrics are indexed with.
+-- Names are indexed with 'simple' so that band and song names are never stemmed.
+ALTER TABLE songs ADD COLUMN search_language REGCONFIG NOT NULL DEFAULT 'simple';
+
+ALTER TABLE songs ADD COLUMN search_vector TSVECTOR GENERATED ALWAYS AS (
+    setweight(to_tsvector('simple', group_name || ' ' || song_name), 'A') ||
+    setweight(to_tsvector(search_language, COALESCE(text, '')), 'B')
+) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_songs_search_vector ON songs USING GIN (search_vector);
@@ -0,0 +1,6 @@
+-- pg_trgm powers the fuzzy name matching (match=fuzzy) and "did you mean" suggestions.
+-- Creating the extension requires the CREATE privilege on the database.
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX IF NOT EXISTS idx_songs_group_name_trgm ON songs USING GIN (group_name gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_songs_song_name_trgm ON songs USING GIN (song_name gin_trgm_ops);
@@ -0,0 +1,2 @@
+-- version is incremented by every update and backs the ETag / If-Match optimistic concurrency control.
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS unique_song_key;
+
+ALTER TABLE songs
+    DROP COLUMN IF EXISTS song_key,
+    DROP COLUMN IF EXISTS group_key;
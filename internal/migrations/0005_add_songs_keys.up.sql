@@ -0,0 +1,7 @@
+-- group_key and song_key hold the normalized names set by the application (NFKC,
+-- case folding, articles). Songs added before are filled by the backfill-keys command.
+ALTER TABLE songs
+    ADD COLUMN IF NOT EXISTS group_key TEXT,
+    ADD COLUMN IF NOT EXISTS song_key TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS unique_song_key ON songs (group_key, song_key);
@@ -0,0 +1,17 @@
+-- enrichment_status is pending while a job fetches the details of a song added
+-- without them, and failed once the job is dead.
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS enrichment_status VARCHAR(16) NOT NULL DEFAULT 'complete';
+
+-- At most one job per song. run_at is when a pending job is due and when the
+-- lease of a running job expires; workers claim due jobs with FOR UPDATE SKIP LOCKED.
+CREATE TABLE IF NOT EXISTS enrichment_jobs (
+    song_id INTEGER PRIMARY KEY REFERENCES songs (id) ON DELETE CASCADE,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    run_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_error TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_enrichment_jobs_run_at ON enrichment_jobs (run_at) WHERE status IN ('pending', 'running');
@@ -0,0 +1,3 @@
+-- edited_fields lists the details of a song changed by hand ("releaseDate", "text",
+-- "link"), which refreshing the song from the external API keeps by default.
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS edited_fields TEXT[] NOT NULL DEFAULT '{}';
@@ -0,0 +1,12 @@
+-- Cached results of external API lookups. details is NULL when the external API
+-- does not know the song.
+CREATE TABLE IF NOT EXISTS api_lookups (
+    group_name VARCHAR(255) NOT NULL,
+    song_name VARCHAR(255) NOT NULL,
+    details JSONB,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+
+    PRIMARY KEY (group_name, song_name)
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_lookups_expires_at ON api_lookups (expires_at);
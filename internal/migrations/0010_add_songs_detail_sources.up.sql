@@ -0,0 +1,3 @@
+-- detail_sources maps the details of a song fetched from the external providers
+-- ("releaseDate", "text", "link") to the name of the provider that supplied them.
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS detail_sources JSONB NOT NULL DEFAULT '{}';
@@ -0,0 +1,15 @@
+// Package migrations embeds the SQL migrations so the binary does not depend
+// on the migration files being present on disk.
+package migrations
+
+import "embed"
+
+// Postgres holds the PostgreSQL migrations at the root of the file system.
+//
+//go:embed *.sql
+var Postgres embed.FS
+
+// SQLite holds the SQLite migrations under the "sqlite" directory.
+//
+//go:embed sqlite/*.sql
+var SQLite embed.FS
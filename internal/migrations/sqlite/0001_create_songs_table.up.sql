@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS songs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    group_name VARCHAR(255) NOT NULL,
+    song_name VARCHAR(255) NOT NULL,
+    release_date TEXT,
+    text TEXT,
+    link VARCHAR(255),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT unique_song_group UNIQUE (group_name, song_name)
+);
+
+CREATE INDEX IF NOT EXISTS idx_songs_group_name ON songs (group_name);
+CREATE INDEX IF NOT EXISTS idx_songs_song_name ON songs (song_name);
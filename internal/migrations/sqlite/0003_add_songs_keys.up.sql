@@ -0,0 +1,4 @@
+ALTER TABLE songs ADD COLUMN group_key TEXT;
+ALTER TABLE songs ADD COLUMN song_key TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS unique_song_key ON songs (group_key, song_key);
@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS delete_enrichment_job;
+DROP TABLE IF EXISTS enrichment_jobs;
+
+ALTER TABLE songs DROP COLUMN enrichment_status;
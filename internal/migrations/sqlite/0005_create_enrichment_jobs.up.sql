@@ -0,0 +1,20 @@
+ALTER TABLE songs ADD COLUMN enrichment_status VARCHAR(16) NOT NULL DEFAULT 'complete';
+
+CREATE TABLE IF NOT EXISTS enrichment_jobs (
+    song_id INTEGER PRIMARY KEY REFERENCES songs (id) ON DELETE CASCADE,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    run_at TIMESTAMP NOT NULL,
+    last_error TEXT,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_enrichment_jobs_run_at ON enrichment_jobs (run_at);
+
+-- Foreign keys are not enforced unless enabled per connection, so jobs are deleted
+-- along with their song by a trigger.
+CREATE TRIGGER IF NOT EXISTS delete_enrichment_job AFTER DELETE ON songs
+BEGIN
+    DELETE FROM enrichment_jobs WHERE song_id = OLD.id;
+END;
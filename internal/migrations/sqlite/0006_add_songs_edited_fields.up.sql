@@ -0,0 +1,2 @@
+-- edited_fields is a comma-separated list, SQLite having no arrays.
+ALTER TABLE songs ADD COLUMN edited_fields TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+-- detail_sources is a JSON object, as in PostgreSQL.
+ALTER TABLE songs ADD COLUMN detail_sources TEXT NOT NULL DEFAULT '{}';
@@ -0,0 +1,199 @@
+package migrator
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"time"
+
+	"songlibrary/internal/lib/logger/utils"
+	"songlibrary/internal/migrations"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database"
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	migratesqlite "github.com/golang-migrate/migrate/v4/database/sqlite"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"go.uber.org/zap"
+)
+
+var ErrDirty = errors.New("database is in a dirty migration state, fix it manually and run \"migrate force VERSION\"")
+
+type Migrator struct {
+	m      *migrate.Migrate
+	source source.Driver
+	// ownsDB is false when the migrator runs on top of a caller-owned *sql.DB,
+	// which must outlive the migrator.
+	ownsDB bool
+}
+
+type MigrationStatus struct {
+	Version uint
+	Name    string
+	Applied bool
+}
+
+type Status struct {
+	// Version is the last applied migration, 0 when none has been applied.
+	Version    uint
+	Dirty      bool
+	Migrations []MigrationStatus
+}
+
+// NewPostgres creates a migrator for the embedded PostgreSQL migrations.
+// The postgres driver serializes migrators with pg_advisory_lock, so replicas
+// starting at the same time wait for each other instead of racing; lockTimeout
+// bounds that wait.
+func NewPostgres(dbURL string, lockTimeout time.Duration) (*Migrator, error) {
+	src, err := iofs.New(migrations.Postgres, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open embedded migrations: %w", err)
+	}
+
+	m, err := migrate.NewWithSourceInstance("iofs", src, dbURL)
+	if err != nil {
+		src.Close()
+		return nil, fmt.Errorf("failed to initialize migration: %w", err)
+	}
+
+	return newMigrator(m, src, true, lockTimeout), nil
+}
+
+// NewSQLite creates a migrator for the embedded SQLite migrations on top of db.
+func NewSQLite(db *sql.DB, lockTimeout time.Duration) (*Migrator, error) {
+	src, err := iofs.New(migrations.SQLite, "sqlite")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open embedded migrations: %w", err)
+	}
+
+	driver, err := migratesqlite.WithInstance(db, &migratesqlite.Config{})
+	if err != nil {
+		src.Close()
+		return nil, fmt.Errorf("failed to initialize migration driver: %w", err)
+	}
+
+	m, err := migrate.NewWithInstance("iofs", src, "sqlite", driver)
+	if err != nil {
+		src.Close()
+		return nil, fmt.Errorf("failed to initialize migration: %w", err)
+	}
+
+	return newMigrator(m, src, false, lockTimeout), nil
+}
+
+func newMigrator(m *migrate.Migrate, src source.Driver, ownsDB bool, lockTimeout time.Duration) *Migrator {
+	if lockTimeout > 0 {
+		m.LockTimeout = lockTimeout
+	}
+	m.Log = migrateLogger{}
+	return &Migrator{m: m, source: src, ownsDB: ownsDB}
+}
+
+// Up applies all pending migrations. It never rolls anything back.
+func (m *Migrator) Up() error {
+	if err := m.checkDirty(); err != nil {
+		return err
+	}
+	err := m.m.Up()
+	if errors.Is(err, migrate.ErrNoChange) {
+		utils.Logger.Info("Database schema is up to date")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to apply migrations: %w", err)
+	}
+
+	version, _, _ := m.m.Version()
+	utils.Logger.Info("Database migrated", zap.Uint("version", version))
+	return nil
+}
+
+// Down rolls back the last steps migrations.
+func (m *Migrator) Down(steps int) error {
+	if steps <= 0 {
+		return fmt.Errorf("number of migrations to roll back must be positive, got %d", steps)
+	}
+	if err := m.checkDirty(); err != nil {
+		return err
+	}
+	if err := m.m.Steps(-steps); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("failed to roll back migrations: %w", err)
+	}
+	return nil
+}
+
+// Force sets the migration version without running any migration and clears
+// the dirty flag. Version 0 marks the database as not migrated at all.
+func (m *Migrator) Force(version int) error {
+	if version < 0 {
+		return fmt.Errorf("version must not be negative, got %d", version)
+	}
+	if version == 0 {
+		version = database.NilVersion
+	}
+	if err := m.m.Force(version); err != nil {
+		return fmt.Errorf("failed to force version: %w", err)
+	}
+	return nil
+}
+
+func (m *Migrator) Status() (*Status, error) {
+	version, dirty, err := m.m.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return nil, fmt.Errorf("failed to read migration version: %w", err)
+	}
+	status := &Status{Version: version, Dirty: dirty}
+
+	v, err := m.source.First()
+	for err == nil {
+		r, identifier, readErr := m.source.ReadUp(v)
+		if readErr != nil {
+			return nil, fmt.Errorf("failed to read migration %d: %w", v, readErr)
+		}
+		r.Close()
+
+		status.Migrations = append(status.Migrations, MigrationStatus{
+			Version: v,
+			Name:    identifier,
+			Applied: status.Version > 0 && v <= status.Version,
+		})
+		v, err = m.source.Next(v)
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to list migrations: %w", err)
+	}
+
+	return status, nil
+}
+
+func (m *Migrator) Close() error {
+	if !m.ownsDB {
+		return m.source.Close()
+	}
+	sourceErr, dbErr := m.m.Close()
+	return errors.Join(sourceErr, dbErr)
+}
+
+func (m *Migrator) checkDirty() error {
+	version, dirty, err := m.m.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return fmt.Errorf("failed to read migration version: %w", err)
+	}
+	if dirty {
+		return fmt.Errorf("version %d: %w", version, ErrDirty)
+	}
+	return nil
+}
+
+type migrateLogger struct{}
+
+func (migrateLogger) Printf(format string, v ...interface{}) {
+	utils.Logger.Info("migrate: " + strings.TrimSpace(fmt.Sprintf(format, v...)))
+}
+
+func (migrateLogger) Verbose() bool {
+	return false
+}
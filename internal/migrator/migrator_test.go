@@ -0,0 +1,73 @@
+package migrator_test
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"songlibrary/internal/lib/logger/utils"
+	"songlibrary/internal/migrator"
+	"songlibrary/internal/storage/sqlite"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	if err := utils.InitLogger(); err != nil {
+		log.Fatalf("Failed to initialize logger: %v", err)
+	}
+	exitCode := m.Run()
+	utils.Logger.Sync()
+	os.Exit(exitCode)
+}
+
+func TestMigrator_SQLite(t *testing.T) {
+	ctx := context.Background()
+	db, err := sqlite.NewDB(ctx, filepath.Join(t.TempDir(), "songlibrary.db"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	m, err := migrator.NewSQLite(db, 0)
+	require.NoError(t, err)
+	defer m.Close()
+
+	status, err := m.Status()
+	require.NoError(t, err)
+	assert.Zero(t, status.Version)
+	require.NotEmpty(t, status.Migrations)
+	for _, migration := range status.Migrations {
+		assert.False(t, migration.Applied, "Expected %s to be pending", migration.Name)
+	}
+	latest := status.Migrations[len(status.Migrations)-1].Version
+
+	require.NoError(t, m.Up())
+	_, err = db.ExecContext(ctx, "INSERT INTO songs (group_name, song_name) VALUES ('Muse', 'Starlight')")
+	require.NoError(t, err)
+
+	require.NoError(t, m.Up(), "Expected re-running up to be a no-op")
+	var count int
+	require.NoError(t, db.QueryRowContext(ctx, "SELECT COUNT(*) FROM songs").Scan(&count))
+	assert.Equal(t, 1, count, "Expected data to survive a second up")
+
+	status, err = m.Status()
+	require.NoError(t, err)
+	assert.Equal(t, latest, status.Version)
+	for _, migration := range status.Migrations {
+		assert.True(t, migration.Applied, "Expected %s to be applied", migration.Name)
+	}
+
+	assert.Error(t, m.Down(0))
+	require.NoError(t, m.Down(len(status.Migrations)))
+	status, err = m.Status()
+	require.NoError(t, err)
+	assert.Zero(t, status.Version)
+
+	require.NoError(t, m.Force(int(latest)))
+	status, err = m.Status()
+	require.NoError(t, err)
+	assert.Equal(t, latest, status.Version)
+	assert.False(t, status.Dirty)
+}
@@ -0,0 +1,83 @@
+package models
+
+import "slices"
+
+// MaxBatchSize is the maximum number of songs added by a single batch request.
+const MaxBatchSize = 1000
+
+type AddSongsRequest struct {
+	Items []AddSongRequest `json:"items"`
+}
+
+// BatchItemStatus is the outcome of adding one song of a batch.
+type BatchItemStatus string
+
+const (
+	// BatchCreated means the song was added.
+	BatchCreated BatchItemStatus = "created"
+	// BatchDuplicate means the song already existed, or appears earlier in the batch.
+	BatchDuplicate BatchItemStatus = "duplicate"
+	// BatchFailed means the song could not be added, see the item's error.
+	BatchFailed BatchItemStatus = "failed"
+)
+
+type BatchItemResult struct {
+	// Index is the position of the item in the request.
+	Index  int             `json:"index"`
+	Status BatchItemStatus `json:"status"`
+	// ID is the id of the created song, or of the existing song a duplicate matches.
+	// It is absent for failures and for duplicates of a failed item.
+	ID       int    `json:"id,omitempty"`
+	Location string `json:"location,omitempty"`
+	Song     *Song  `json:"song,omitempty"`
+	// Error is the reason the item failed, or names the earlier item a duplicate repeats.
+	Error string `json:"error,omitempty"`
+}
+
+type BatchResult struct {
+	Items      []BatchItemResult `json:"items"`
+	Created    int               `json:"created"`
+	Duplicates int               `json:"duplicates"`
+	Failed     int               `json:"failed"`
+}
+
+// MaxImportErrors bounds the errors listed by an import; the others are only counted.
+const MaxImportErrors = 1000
+
+type ImportResult struct {
+	Created    int `json:"created"`
+	Duplicates int `json:"duplicates"`
+	Failed     int `json:"failed"`
+	// Errors lists the rows that failed, up to MaxImportErrors of them, ordered by row.
+	Errors []ImportError `json:"errors"`
+	// Stopped is why reading stopped before the end of the file, the rows after it
+	// were not imported.
+	Stopped string `json:"stopped,omitempty"`
+}
+
+type ImportError struct {
+	// Row is the line of the row for CSV and NDJSON, counting the CSV header, and
+	// the position of the element, from 1, for a JSON array.
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+// AddError records the failure of row. Rows may fail out of order; call SortErrors once
+// all are recorded.
+func (r *ImportResult) AddError(row int, message string) {
+	r.Failed++
+	r.Errors = append(r.Errors, ImportError{Row: row, Error: message})
+	if len(r.Errors) >= 2*MaxImportErrors {
+		r.SortErrors()
+	}
+}
+
+// SortErrors orders Errors by row and keeps the first MaxImportErrors of them.
+func (r *ImportResult) SortErrors() {
+	slices.SortStableFunc(r.Errors, func(a, b ImportError) int {
+		return a.Row - b.Row
+	})
+	if len(r.Errors) > MaxImportErrors {
+		r.Errors = r.Errors[:MaxImportErrors]
+	}
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor marks the position right after the last song of a page. Clients get
+// it as an opaque string and must not rely on its contents.
+type Cursor struct {
+	// Sort is the order the cursor was issued for, a cursor is only valid for it.
+	Sort string `json:"sort"`
+	// Values holds the song's values of the sort keys other than id, in order.
+	Values []*string `json:"values,omitempty"`
+	ID     int       `json:"id"`
+}
+
+// CursorAfter returns the cursor of the page that starts right after song in the given order.
+func CursorAfter(song Song, sort Sort) *Cursor {
+	keys := sort.WithTieBreaker()
+	cursor := &Cursor{Sort: keys.String(), ID: song.ID}
+	for _, key := range keys {
+		if key.Field != SortByID {
+			cursor.Values = append(cursor.Values, key.Field.Value(song))
+		}
+	}
+	return cursor
+}
+
+// Validate checks that the cursor was issued for sort and holds well-formed values.
+func (c *Cursor) Validate(sort Sort) error {
+	keys := sort.WithTieBreaker()
+	if c.Sort != keys.String() || len(c.Values) != len(keys)-1 {
+		return fmt.Errorf("%w: issued for a different sort", ErrInvalidCursor)
+	}
+	for i, key := range keys[:len(keys)-1] {
+		if _, err := key.Field.ParseValue(c.Values[i]); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+		}
+	}
+	return nil
+}
+
+func (c *Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func DecodeCursor(s string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	var c Cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	if c.ID <= 0 {
+		return nil, fmt.Errorf("%w: missing position", ErrInvalidCursor)
+	}
+	return &c, nil
+}
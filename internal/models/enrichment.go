@@ -0,0 +1,57 @@
+package models
+
+import "time"
+
+// EnrichmentStatus tells whether the release date, text and link of a song were
+// fetched from the external API.
+type EnrichmentStatus string
+
+const (
+	// EnrichmentComplete means the details were fetched, or given when the song was added.
+	EnrichmentComplete EnrichmentStatus = "complete"
+	// EnrichmentPending means a job will fetch the details.
+	EnrichmentPending EnrichmentStatus = "pending"
+	// EnrichmentFailed means the job gave up fetching the details.
+	EnrichmentFailed EnrichmentStatus = "failed"
+)
+
+// EnrichmentMode selects when adding a song fetches its details.
+type EnrichmentMode string
+
+const (
+	// EnrichSync fetches the details before the song is added, failing when the API does.
+	EnrichSync EnrichmentMode = "sync"
+	// EnrichAsync adds the song at once and leaves the details to an enrichment job.
+	EnrichAsync EnrichmentMode = "async"
+	// EnrichFallback fetches the details first and only leaves them to a job when the API fails.
+	EnrichFallback EnrichmentMode = "fallback"
+)
+
+// JobStatus is the state of an enrichment job.
+type JobStatus string
+
+const (
+	// JobPending jobs wait for their RunAt time.
+	JobPending JobStatus = "pending"
+	// JobRunning jobs are held by a worker until their lease, RunAt, expires.
+	JobRunning JobStatus = "running"
+	// JobDone jobs filled in the details of their song.
+	JobDone JobStatus = "done"
+	// JobDead jobs failed their last attempt and are not retried.
+	JobDead JobStatus = "dead"
+)
+
+// EnrichmentJob fetches the details of a song added without them. A song has at
+// most one job, which is deleted along with it.
+type EnrichmentJob struct {
+	SongID int       `json:"songId"`
+	Status JobStatus `json:"status"`
+	// Attempts counts the calls to the external API made so far.
+	Attempts int `json:"attempts"`
+	// RunAt is when a pending job is due and when the lease of a running job expires.
+	RunAt time.Time `json:"runAt"`
+	// LastError is the reason the last attempt failed.
+	LastError string    `json:"lastError,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
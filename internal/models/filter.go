@@ -0,0 +1,101 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var ErrInvalidFilter = errors.New("invalid filter")
+
+// MatchMode selects how name filters are compared with song and group names.
+// All modes ignore case.
+type MatchMode string
+
+const (
+	// MatchSubstring matches names containing the value. '%' and '_' in the
+	// value act as LIKE wildcards and '\' escapes them.
+	MatchSubstring MatchMode = "substring"
+	// MatchPrefix matches names starting with the value, wildcards as for MatchSubstring.
+	MatchPrefix MatchMode = "prefix"
+	// MatchExact matches names equal to the value, taken literally.
+	MatchExact MatchMode = "exact"
+	// MatchFuzzy matches names whose trigram similarity to the value is at least
+	// SimilarityThreshold, tolerating typos. Results are ordered by similarity.
+	MatchFuzzy MatchMode = "fuzzy"
+)
+
+const (
+	// SimilarityThreshold is the minimal similarity of a fuzzy match, the pg_trgm default.
+	SimilarityThreshold = 0.3
+	// SuggestionThreshold is the minimal similarity of a "did you mean" suggestion.
+	SuggestionThreshold = 0.2
+	// MaxSuggestions bounds the number of suggestions per name filter.
+	MaxSuggestions = 5
+)
+
+// NameSuggestion is an existing group or song name close to a filter value that matched nothing.
+type NameSuggestion struct {
+	// Field is the filter the suggestion is for, "group" or "song".
+	Field      string  `json:"field"`
+	Value      string  `json:"value"`
+	Similarity float64 `json:"similarity"`
+}
+
+// HasNames reports whether the filter has a group or song name condition.
+func (f *SongFilter) HasNames() bool {
+	return f != nil && ((f.GroupName != nil && *f.GroupName != "") || (f.SongName != nil && *f.SongName != ""))
+}
+
+// IsFuzzy reports whether the filter matches names by similarity.
+func (f *SongFilter) IsFuzzy() bool {
+	return f != nil && f.Match == MatchFuzzy && f.HasNames()
+}
+
+// LikePattern returns the (I)LIKE pattern, escaped with '\', matching value in this mode.
+// It does not apply to MatchFuzzy.
+func (m MatchMode) LikePattern(value string) string {
+	switch m {
+	case MatchExact:
+		return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+	case MatchPrefix:
+		return value + "%"
+	}
+	return "%" + value + "%"
+}
+
+func (f *SongFilter) Validate() error {
+	switch f.Match {
+	case "", MatchSubstring, MatchPrefix, MatchExact, MatchFuzzy:
+	default:
+		return fmt.Errorf("%w: unknown match mode %q", ErrInvalidFilter, f.Match)
+	}
+
+	if err := validateDate("releaseDateFrom", f.ReleaseDateFrom); err != nil {
+		return err
+	}
+	if err := validateDate("releaseDateTo", f.ReleaseDateTo); err != nil {
+		return err
+	}
+	if f.ReleaseDateFrom != nil && f.ReleaseDateTo != nil && *f.ReleaseDateFrom > *f.ReleaseDateTo {
+		return fmt.Errorf("%w: releaseDateFrom is after releaseDateTo", ErrInvalidFilter)
+	}
+	if f.CreatedFrom != nil && f.CreatedTo != nil && f.CreatedFrom.After(*f.CreatedTo) {
+		return fmt.Errorf("%w: createdFrom is after createdTo", ErrInvalidFilter)
+	}
+	if f.UpdatedFrom != nil && f.UpdatedTo != nil && f.UpdatedFrom.After(*f.UpdatedTo) {
+		return fmt.Errorf("%w: updatedFrom is after updatedTo", ErrInvalidFilter)
+	}
+	return nil
+}
+
+func validateDate(name string, date *string) error {
+	if date == nil {
+		return nil
+	}
+	if _, err := time.Parse(time.DateOnly, *date); err != nil {
+		return fmt.Errorf("%w: %s must be a YYYY-MM-DD date", ErrInvalidFilter, name)
+	}
+	return nil
+}
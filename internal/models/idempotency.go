@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// IdempotencyRecord is the stored outcome of a write request made with an
+// Idempotency-Key header, replayed when the request is retried with the same key.
+type IdempotencyRecord struct {
+	Key string
+	// Fingerprint identifies the request the key was first used with: its method and
+	// URI while in progress, along with its body once completed.
+	Fingerprint string
+	// StatusCode is 0 while the first request with the key is still in progress.
+	StatusCode int
+	// Header holds the replayed response headers, such as Content-Type, ETag and Location.
+	Header    map[string]string
+	Body      []byte
+	ExpiresAt time.Time
+}
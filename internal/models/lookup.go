@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// CachedLookup is a cached result of looking a song up in the external API.
+type CachedLookup struct {
+	GroupName string
+	SongName  string
+	// Details is nil when the external API does not know the song.
+	Details   *SongDetailFromAPI
+	ExpiresAt time.Time
+}
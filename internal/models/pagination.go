@@ -1,8 +1,24 @@
 package models
 
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+const MaxPageSize = 100
+
+// MaxPage keeps the offset of the last page within a 32-bit integer.
+const MaxPage = math.MaxInt32 / MaxPageSize
+
+var ErrInvalidPagination = errors.New("invalid pagination")
+
 type Pagination struct {
 	Page     int `json:"page" form:"page"`
 	PageSize int `json:"pageSize" form:"pageSize"`
+	// After switches to keyset pagination: the page starts right after the
+	// cursor position and Page is ignored.
+	After *Cursor `json:"after,omitempty" form:"-"`
 }
 
 func NewPagination(page, pageSize int) *Pagination {
@@ -19,9 +35,30 @@ func NewPagination(page, pageSize int) *Pagination {
 }
 
 func (p *Pagination) GetOffset() int {
+	if p.After != nil {
+		return 0
+	}
 	return (p.Page - 1) * p.PageSize
 }
 
 func (p *Pagination) GetLimit() int {
 	return p.PageSize
 }
+
+func (p *Pagination) Validate() error {
+	if p.PageSize > MaxPageSize {
+		return fmt.Errorf("%w: pageSize must not exceed %d", ErrInvalidPagination, MaxPageSize)
+	}
+	if p.Page > MaxPage {
+		return fmt.Errorf("%w: page must not exceed %d", ErrInvalidPagination, MaxPage)
+	}
+	if p.After != nil && p.Page > 1 {
+		return fmt.Errorf("%w: page and cursor are mutually exclusive", ErrInvalidPagination)
+	}
+	return nil
+}
+
+// TotalPages returns the number of pages needed to hold totalItems.
+func (p *Pagination) TotalPages(totalItems int) int {
+	return (totalItems + p.PageSize - 1) / p.PageSize
+}
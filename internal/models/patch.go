@@ -0,0 +1,97 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrInvalidSong = errors.New("invalid song")
+
+// PatchFormat is the media type of a PATCH /songs/{id} request body.
+type PatchFormat string
+
+const (
+	// MergePatch is a JSON Merge Patch (RFC 7396) of the SongDocument.
+	MergePatch PatchFormat = "application/merge-patch+json"
+	// JSONPatch is a JSON Patch (RFC 6902) of the SongDocument.
+	JSONPatch PatchFormat = "application/json-patch+json"
+)
+
+// SongDocument is the editable part of a song as patched by PATCH /songs/{id}.
+// Unlike Song, a missing release date, text or link is null rather than an object.
+type SongDocument struct {
+	GroupName   string  `json:"group"`
+	SongName    string  `json:"song"`
+	ReleaseDate *string `json:"releaseDate"`
+	Text        *string `json:"text"`
+	Link        *string `json:"link"`
+}
+
+func NewSongDocument(song *Song) SongDocument {
+	return SongDocument{
+		GroupName:   song.GroupName,
+		SongName:    song.SongName,
+		ReleaseDate: nullStringPointer(song.ReleaseDate),
+		Text:        nullStringPointer(song.Text),
+		Link:        nullStringPointer(song.Link),
+	}
+}
+
+func (d SongDocument) Validate() error {
+	if d.GroupName == "" {
+		return fmt.Errorf("%w: group is required", ErrInvalidSong)
+	}
+	if d.SongName == "" {
+		return fmt.Errorf("%w: song is required", ErrInvalidSong)
+	}
+	if d.ReleaseDate != nil {
+		if _, err := time.Parse(time.DateOnly, *d.ReleaseDate); err != nil {
+			return fmt.Errorf("%w: releaseDate must be a YYYY-MM-DD date", ErrInvalidSong)
+		}
+	}
+	return nil
+}
+
+// ApplyTo copies the document into song. Empty strings are stored as NULL, as AddSong does.
+func (d SongDocument) ApplyTo(song *Song) {
+	song.GroupName = d.GroupName
+	song.SongName = d.SongName
+	song.ReleaseDate = nullString(d.ReleaseDate)
+	song.Text = nullString(d.Text)
+	song.Link = nullString(d.Link)
+}
+
+// HasDetails reports whether the document gives any of the release date, text and link.
+func (d SongDocument) HasDetails() bool {
+	return d.ReleaseDate != nil || d.Text != nil || d.Link != nil
+}
+
+// OverrideDetails copies the release date, text and link the document gives into song,
+// keeping the others.
+func (d SongDocument) OverrideDetails(song *Song) {
+	if d.ReleaseDate != nil {
+		song.ReleaseDate = nullString(d.ReleaseDate)
+	}
+	if d.Text != nil {
+		song.Text = nullString(d.Text)
+	}
+	if d.Link != nil {
+		song.Link = nullString(d.Link)
+	}
+}
+
+func nullStringPointer(s sql.NullString) *string {
+	if !s.Valid {
+		return nil
+	}
+	return &s.String
+}
+
+func nullString(s *string) sql.NullString {
+	if s == nil || *s == "" {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: *s, Valid: true}
+}
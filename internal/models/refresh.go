@@ -0,0 +1,114 @@
+package models
+
+import (
+	"database/sql"
+	"slices"
+)
+
+// The details of a song, which are fetched from the external API.
+const (
+	FieldReleaseDate = "releaseDate"
+	FieldText        = "text"
+	FieldLink        = "link"
+)
+
+// DetailFields are the details of a song in the order they are reported.
+var DetailFields = []string{FieldReleaseDate, FieldText, FieldLink}
+
+// Detail returns the detail of the song named field, or nil for other fields.
+func (s *Song) Detail(field string) *sql.NullString {
+	switch field {
+	case FieldReleaseDate:
+		return &s.ReleaseDate
+	case FieldText:
+		return &s.Text
+	case FieldLink:
+		return &s.Link
+	}
+	return nil
+}
+
+// IsEdited reports whether field is one of the song's EditedFields.
+func (s *Song) IsEdited(field string) bool {
+	return slices.Contains(s.EditedFields, field)
+}
+
+// SetSource records source as the provider of field, or forgets the provider of
+// field when source is empty. Sources is changed in place.
+func (s *Song) SetSource(field, source string) {
+	if source == "" {
+		delete(s.Sources, field)
+		return
+	}
+	if s.Sources == nil {
+		s.Sources = make(map[string]string)
+	}
+	s.Sources[field] = source
+}
+
+// Enrich fills in the details of the song that are NULL from details, along with
+// their sources.
+func (s *Song) Enrich(details *Song) {
+	for _, field := range DetailFields {
+		if s.Detail(field).Valid || !details.Detail(field).Valid {
+			continue
+		}
+		*s.Detail(field) = *details.Detail(field)
+		s.SetSource(field, details.Sources[field])
+	}
+}
+
+// RefreshMode selects which changes found by refreshing a song are stored.
+type RefreshMode string
+
+const (
+	// RefreshNone stores nothing, the changes are only reported.
+	RefreshNone RefreshMode = "none"
+	// RefreshUnedited stores the changes of the details never edited by hand.
+	RefreshUnedited RefreshMode = "unedited"
+	// RefreshAll stores all the changes, overwriting edited details.
+	RefreshAll RefreshMode = "all"
+)
+
+// RefreshStatus is the outcome of refreshing a song.
+type RefreshStatus string
+
+const (
+	// RefreshUpdated means some changes were stored.
+	RefreshUpdated RefreshStatus = "updated"
+	// RefreshUnchanged means the external API returned the stored details.
+	RefreshUnchanged RefreshStatus = "unchanged"
+	// RefreshSkipped means there are changes but the mode stored none of them.
+	RefreshSkipped RefreshStatus = "skipped"
+	// RefreshFailed means the song could not be refreshed, see the result's error.
+	RefreshFailed RefreshStatus = "failed"
+)
+
+// FieldChange is a detail of a song that differs from the one returned by the
+// external API. A nil value is missing.
+type FieldChange struct {
+	Field string  `json:"field"`
+	Old   *string `json:"old"`
+	New   *string `json:"new"`
+	// Edited reports that the stored value was set by hand.
+	Edited  bool `json:"edited"`
+	Applied bool `json:"applied"`
+}
+
+type RefreshResult struct {
+	ID      int           `json:"id"`
+	Status  RefreshStatus `json:"status"`
+	Changes []FieldChange `json:"changes"`
+	// Song is the song after the refresh. It is absent for failures.
+	Song     *Song  `json:"song,omitempty"`
+	Location string `json:"location,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+type RefreshBatchResult struct {
+	Items     []RefreshResult `json:"items"`
+	Updated   int             `json:"updated"`
+	Unchanged int             `json:"unchanged"`
+	Skipped   int             `json:"skipped"`
+	Failed    int             `json:"failed"`
+}
@@ -0,0 +1,19 @@
+package models
+
+// SongSearchResult is a song found by full-text search.
+type SongSearchResult struct {
+	Song
+	// Rank orders results, higher is more relevant. It is only comparable within one search.
+	Rank float64 `json:"rank"`
+	// Snippet holds the matching verses of the text, HTML-escaped, with the matched words
+	// wrapped in <mark></mark>, the only tags. Empty when only the names match.
+	Snippet string `json:"snippet"`
+}
+
+type SongSearchList struct {
+	Items      []SongSearchResult `json:"items"`
+	Page       int                `json:"page"`
+	PageSize   int                `json:"pageSize"`
+	TotalItems int                `json:"totalItems"`
+	TotalPages int                `json:"totalPages"`
+}
@@ -19,12 +19,42 @@ type Song struct {
 	Link      sql.NullString `json:"link" swaggertype:"string"`
 	CreatedAt time.Time      `json:"createdAt"`
 	UpdatedAt time.Time      `json:"updatedAt"`
+	// Version starts at 1 and is incremented by every update. It is the song's ETag.
+	Version int `json:"version"`
+	// EnrichmentStatus is pending while a job fetches the details of the song and
+	// failed when it gave up. Create stores an empty status as complete.
+	EnrichmentStatus EnrichmentStatus `json:"enrichmentStatus,omitempty"`
+	// EditedFields lists the DetailFields changed by hand since the song was added,
+	// which RefreshUnedited keeps. Update stores it as given.
+	EditedFields []string `json:"editedFields,omitempty"`
+	// Sources maps the DetailFields fetched from the external providers to the name
+	// of the provider that supplied them. Update stores it as given.
+	Sources map[string]string `json:"sources,omitempty"`
+	// Similarity is how close the names are to a fuzzy filter, set only by match=fuzzy listings.
+	Similarity *float64 `json:"similarity,omitempty"`
+	// GroupKey and SongKey are the normalized names identifying the song, see
+	// package normalize. They are written by Create and Update but not read back.
+	GroupKey string `json:"-"`
+	SongKey  string `json:"-"`
+}
+
+// SongIdentity is a song's names along with its stored identity keys, empty when
+// the song was added before keys existed.
+type SongIdentity struct {
+	ID        int
+	GroupName string
+	SongName  string
+	GroupKey  string
+	SongKey   string
 }
 
 type SongDetailFromAPI struct {
 	ReleaseDate string `json:"releaseDate"`
 	Text        string `json:"text"`
 	Link        string `json:"link"`
+	// Sources maps the non-empty fields to the provider that supplied them, set by a
+	// chain of providers.
+	Sources map[string]string `json:"sources,omitempty"`
 }
 
 type AddSongRequest struct {
@@ -32,7 +62,56 @@ type AddSongRequest struct {
 	SongName  string `json:"song"`
 }
 
+// SongConflict is the body of a 409 response to adding a song that already exists.
+type SongConflict struct {
+	Error    string `json:"error"`
+	ID       int    `json:"id"`
+	Location string `json:"location"`
+}
+
+// ConflictMode selects what adding a song does when a song with the same group and
+// song names already exists.
+type ConflictMode string
+
+const (
+	// ConflictFail rejects the new song.
+	ConflictFail ConflictMode = ""
+	// ConflictReturn returns the existing song unchanged.
+	ConflictReturn ConflictMode = "return"
+	// ConflictUpdate refreshes the existing song with the details of the new one, as
+	// RefreshUnedited does.
+	ConflictUpdate ConflictMode = "update"
+)
+
 type SongFilter struct {
 	GroupName *string
 	SongName  *string
+	// Match selects how GroupName and SongName are matched, substring by default.
+	Match MatchMode
+	// ReleaseDateFrom and ReleaseDateTo are inclusive YYYY-MM-DD bounds.
+	ReleaseDateFrom *string
+	ReleaseDateTo   *string
+	// HasText and HasLink select songs with (true) or without (false) a non-empty value.
+	HasText     *bool
+	HasLink     *bool
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	UpdatedFrom *time.Time
+	UpdatedTo   *time.Time
+	// Sort orders List results; Count ignores it.
+	Sort Sort
+}
+
+type SongList struct {
+	Items []Song `json:"items"`
+	// Page is omitted for keyset pages, which have no page number.
+	Page       int    `json:"page,omitempty"`
+	PageSize   int    `json:"pageSize"`
+	TotalItems int    `json:"totalItems"`
+	TotalPages int    `json:"totalPages"`
+	NextCursor string `json:"nextCursor,omitempty"`
+	Next       string `json:"next,omitempty"`
+	Prev       string `json:"prev,omitempty"`
+	// Suggestions lists close existing names when the name filters matched nothing.
+	Suggestions []NameSuggestion `json:"suggestions,omitempty"`
 }
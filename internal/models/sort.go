@@ -0,0 +1,144 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var ErrInvalidSort = errors.New("invalid sort")
+
+// SortField is a song field the list can be ordered by. Only the fields
+// declared here are accepted, so storages can map them to trusted SQL.
+type SortField string
+
+const (
+	SortByID          SortField = "id"
+	SortByGroup       SortField = "group"
+	SortBySong        SortField = "song"
+	SortByReleaseDate SortField = "releaseDate"
+	SortByCreatedAt   SortField = "createdAt"
+	SortByUpdatedAt   SortField = "updatedAt"
+)
+
+var sortFields = map[SortField]bool{
+	SortByID:          true,
+	SortByGroup:       true,
+	SortBySong:        true,
+	SortByReleaseDate: true,
+	SortByCreatedAt:   true,
+	SortByUpdatedAt:   true,
+}
+
+type SortKey struct {
+	Field SortField
+	Desc  bool
+}
+
+// Sort lists the keys songs are ordered by, most significant first.
+// An empty Sort orders by id.
+type Sort []SortKey
+
+// ParseSort parses a comma-separated list of fields, each optionally
+// prefixed with '-' for descending order, e.g. "-releaseDate,group".
+func ParseSort(s string) (Sort, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+
+	var sort Sort
+	seen := make(map[SortField]bool)
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		key := SortKey{Field: SortField(strings.TrimPrefix(part, "-")), Desc: strings.HasPrefix(part, "-")}
+		if !sortFields[key.Field] {
+			return nil, fmt.Errorf("%w: unknown field %q", ErrInvalidSort, key.Field)
+		}
+		if seen[key.Field] {
+			return nil, fmt.Errorf("%w: duplicate field %q", ErrInvalidSort, key.Field)
+		}
+		seen[key.Field] = true
+		sort = append(sort, key)
+	}
+	return sort, nil
+}
+
+func (s Sort) String() string {
+	parts := make([]string, len(s))
+	for i, key := range s {
+		parts[i] = string(key.Field)
+		if key.Desc {
+			parts[i] = "-" + parts[i]
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// WithTieBreaker returns the keys that make the order total: keys after id are
+// dropped as they can never decide, and id is appended when missing.
+func (s Sort) WithTieBreaker() Sort {
+	keys := make(Sort, 0, len(s)+1)
+	for _, key := range s {
+		keys = append(keys, key)
+		if key.Field == SortByID {
+			return keys
+		}
+	}
+	return append(keys, SortKey{Field: SortByID})
+}
+
+// Value returns the song's value of the field in the form stored in cursors,
+// nil when the field is NULL.
+func (f SortField) Value(song Song) *string {
+	var value string
+	switch f {
+	case SortByID:
+		value = fmt.Sprint(song.ID)
+	case SortByGroup:
+		value = song.GroupName
+	case SortBySong:
+		value = song.SongName
+	case SortByReleaseDate:
+		if !song.ReleaseDate.Valid {
+			return nil
+		}
+		value = song.ReleaseDate.String
+	case SortByCreatedAt:
+		value = song.CreatedAt.UTC().Format(time.RFC3339Nano)
+	case SortByUpdatedAt:
+		value = song.UpdatedAt.UTC().Format(time.RFC3339Nano)
+	}
+	return &value
+}
+
+// ParseValue converts a value returned by Value back: nil stays nil,
+// timestamps become time.Time and everything else a string.
+func (f SortField) ParseValue(value *string) (interface{}, error) {
+	if value == nil {
+		if f != SortByReleaseDate {
+			return nil, fmt.Errorf("%s must not be null", f)
+		}
+		return nil, nil
+	}
+	switch f {
+	case SortByID:
+		return nil, fmt.Errorf("id is not a cursor value")
+	case SortByReleaseDate:
+		if _, err := time.Parse(time.DateOnly, *value); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", f, err)
+		}
+	case SortByCreatedAt, SortByUpdatedAt:
+		t, err := time.Parse(time.RFC3339Nano, *value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", f, err)
+		}
+		return t, nil
+	}
+	return *value, nil
+}
+
+// CompareNames orders group and song names ignoring case, the way every storage sorts them.
+func CompareNames(a, b string) int {
+	return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+}
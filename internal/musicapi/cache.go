@@ -0,0 +1,203 @@
+package musicapi
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
+
+	"songlibrary/internal/lib/logger/utils"
+	"songlibrary/internal/models"
+	"songlibrary/internal/storage"
+)
+
+// CacheConfig tunes a CachedClient. Zero fields take the defaults.
+type CacheConfig struct {
+	// Size is the number of lookups kept in memory, 1000 by default.
+	Size int
+	// MaxBytes bounds the total size of the names and details of the lookups kept in
+	// memory, 64 MiB by default. A lookup larger than MaxBytes is not kept in memory.
+	MaxBytes int64
+	// TTL is how long the details of a song are cached, 24h by default.
+	TTL time.Duration
+	// NegativeTTL is how long a song the external API does not know is cached, 10m by default.
+	NegativeTTL time.Duration
+	// Store, when set, keeps the lookups across restarts and instances.
+	Store storage.LookupCache
+}
+
+type freshKey struct{}
+
+// Fresh returns a context that makes CachedClient look songs up again instead of
+// returning cached results. The new results are cached.
+func Fresh(ctx context.Context) context.Context {
+	return context.WithValue(ctx, freshKey{}, true)
+}
+
+// CachedClient caches the lookups of another MusicAPI, both the details found and
+// the songs not found, in a LRU cache and optionally in Store. Concurrent lookups of
+// the same song make one call to the other MusicAPI.
+type CachedClient struct {
+	next  MusicAPI
+	cfg   CacheConfig
+	calls singleflight.Group
+
+	mu      sync.Mutex
+	entries map[lookupKey]*list.Element
+	// order holds the *models.CachedLookup of entries, the most recently used first.
+	order *list.List
+	// bytes is the total lookupSize of entries.
+	bytes int64
+}
+
+type lookupKey struct {
+	group, song string
+}
+
+func NewCachedClient(next MusicAPI, cfg CacheConfig) *CachedClient {
+	if cfg.Size <= 0 {
+		cfg.Size = 1000
+	}
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = 64 << 20
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = 24 * time.Hour
+	}
+	if cfg.NegativeTTL <= 0 {
+		cfg.NegativeTTL = 10 * time.Minute
+	}
+	return &CachedClient{
+		next:    next,
+		cfg:     cfg,
+		entries: make(map[lookupKey]*list.Element),
+		order:   list.New(),
+	}
+}
+
+func (c *CachedClient) GetSongDetailsFromAPI(ctx context.Context, group string, song string) (*models.SongDetailFromAPI, error) {
+	fresh, _ := ctx.Value(freshKey{}).(bool)
+	if !fresh {
+		if lookup := c.get(lookupKey{group, song}); lookup != nil {
+			utils.Logger.Debug("CachedClient - cache hit", zap.String("group", group), zap.String("song", song))
+			return lookupResult(lookup)
+		}
+	}
+
+	// A fresh lookup must not share a call that may return a cached result.
+	key := group + "\x00" + song
+	if fresh {
+		key = "fresh\x00" + key
+	}
+	// The shared call outlives the callers that give up, so that the others still
+	// get its result and it is cached. The client of next bounds its duration.
+	results := c.calls.DoChan(key, func() (any, error) {
+		return c.lookup(context.WithoutCancel(ctx), group, song, fresh)
+	})
+	select {
+	case result := <-results:
+		if result.Err != nil {
+			return nil, result.Err
+		}
+		return lookupResult(result.Val.(*models.CachedLookup))
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// lookup returns the lookup of Store unless fresh, or calls next and caches its result.
+// Errors other than ErrSongNotFound are not cached.
+func (c *CachedClient) lookup(ctx context.Context, group, song string, fresh bool) (*models.CachedLookup, error) {
+	if c.cfg.Store != nil && !fresh {
+		lookup, err := c.cfg.Store.GetCachedLookup(ctx, group, song)
+		if err != nil {
+			utils.Logger.Warn("CachedClient - Store.GetCachedLookup failed", zap.Error(err))
+		} else if lookup != nil {
+			c.put(lookup)
+			return lookup, nil
+		}
+	}
+
+	details, err := c.next.GetSongDetailsFromAPI(ctx, group, song)
+	ttl := c.cfg.TTL
+	if err != nil {
+		if !errors.Is(err, ErrSongNotFound) {
+			return nil, err
+		}
+		details, ttl = nil, c.cfg.NegativeTTL
+	}
+
+	lookup := &models.CachedLookup{GroupName: group, SongName: song, Details: details, ExpiresAt: time.Now().Add(ttl)}
+	c.put(lookup)
+	if c.cfg.Store != nil {
+		if err := c.cfg.Store.PutCachedLookup(ctx, lookup); err != nil {
+			utils.Logger.Warn("CachedClient - Store.PutCachedLookup failed", zap.Error(err))
+		}
+	}
+	return lookup, nil
+}
+
+// lookupResult returns a copy of the cached details, or a 404 StatusError for a song
+// the external API does not know.
+func lookupResult(lookup *models.CachedLookup) (*models.SongDetailFromAPI, error) {
+	if lookup.Details == nil {
+		return nil, &StatusError{StatusCode: http.StatusNotFound, Status: "404 Not Found"}
+	}
+	details := *lookup.Details
+	return &details, nil
+}
+
+// get returns the unexpired lookup of key from memory, or nil.
+func (c *CachedClient) get(key lookupKey) *models.CachedLookup {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	lookup := element.Value.(*models.CachedLookup)
+	if !time.Now().Before(lookup.ExpiresAt) {
+		c.remove(element)
+		return nil
+	}
+	c.order.MoveToFront(element)
+	return lookup
+}
+
+// put stores lookup in memory, evicting the least recently used lookups when full.
+func (c *CachedClient) put(lookup *models.CachedLookup) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := lookupKey{lookup.GroupName, lookup.SongName}
+	if element, ok := c.entries[key]; ok {
+		c.remove(element)
+	}
+	c.entries[key] = c.order.PushFront(lookup)
+	c.bytes += lookupSize(lookup)
+	for c.order.Len() > c.cfg.Size || c.bytes > c.cfg.MaxBytes {
+		c.remove(c.order.Back())
+	}
+}
+
+func (c *CachedClient) remove(element *list.Element) {
+	lookup := c.order.Remove(element).(*models.CachedLookup)
+	delete(c.entries, lookupKey{lookup.GroupName, lookup.SongName})
+	c.bytes -= lookupSize(lookup)
+}
+
+// lookupSize approximates the memory held by lookup with the length of its strings.
+func lookupSize(lookup *models.CachedLookup) int64 {
+	size := len(lookup.GroupName) + len(lookup.SongName)
+	if details := lookup.Details; details != nil {
+		size += len(details.ReleaseDate) + len(details.Text) + len(details.Link)
+		for field, source := range details.Sources {
+			size += len(field) + len(source)
+		}
+	}
+	return int64(size)
+}
@@ -0,0 +1,194 @@
+package musicapi_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"songlibrary/internal/models"
+	"songlibrary/internal/musicapi"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingServer knows the songs Starlight and Uprising and counts the lookups of each song.
+func countingServer(t *testing.T, delay time.Duration) (*httptest.Server, func(song string) int32) {
+	var mu sync.Mutex
+	calls := map[string]*atomic.Int32{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		song := r.URL.Query().Get("song")
+		mu.Lock()
+		if calls[song] == nil {
+			calls[song] = &atomic.Int32{}
+		}
+		calls[song].Add(1)
+		mu.Unlock()
+		time.Sleep(delay)
+		switch song {
+		case "Starlight", "Uprising":
+			w.Write([]byte(`{"releaseDate": "04.09.2006", "text": "` + song + `"}`))
+		case "Broken":
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		default:
+			http.Error(w, "not found", http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, func(song string) int32 {
+		mu.Lock()
+		defer mu.Unlock()
+		if calls[song] == nil {
+			return 0
+		}
+		return calls[song].Load()
+	}
+}
+
+type fakeLookupCache struct {
+	mu      sync.Mutex
+	lookups map[string]models.CachedLookup
+}
+
+func (f *fakeLookupCache) GetCachedLookup(_ context.Context, group, song string) (*models.CachedLookup, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	lookup, ok := f.lookups[group+"/"+song]
+	if !ok || !time.Now().Before(lookup.ExpiresAt) {
+		return nil, nil
+	}
+	return &lookup, nil
+}
+
+func (f *fakeLookupCache) PutCachedLookup(_ context.Context, lookup *models.CachedLookup) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.lookups[lookup.GroupName+"/"+lookup.SongName] = *lookup
+	return nil
+}
+
+func (f *fakeLookupCache) DeleteExpiredLookups(context.Context) (int, error) {
+	return 0, nil
+}
+
+func TestCachedClient(t *testing.T) {
+	server, calls := countingServer(t, 0)
+	client := musicapi.NewCachedClient(musicapi.NewMusicAPIClient(server.URL, musicapi.Config{}), musicapi.CacheConfig{
+		Size:        2,
+		NegativeTTL: 100 * time.Millisecond,
+	})
+	ctx := context.Background()
+
+	for range 3 {
+		details, err := client.GetSongDetailsFromAPI(ctx, "Muse", "Starlight")
+		require.NoError(t, err)
+		assert.Equal(t, "Starlight", details.Text)
+	}
+	assert.Equal(t, int32(1), calls("Starlight"))
+
+	for range 2 {
+		_, err := client.GetSongDetailsFromAPI(ctx, "Muse", "Unknown")
+		assert.ErrorContains(t, err, "404")
+	}
+	assert.Equal(t, int32(1), calls("Unknown"), "Expected the 404 response to be cached")
+	time.Sleep(150 * time.Millisecond)
+	_, err := client.GetSongDetailsFromAPI(ctx, "Muse", "Unknown")
+	assert.ErrorContains(t, err, "404")
+	assert.Equal(t, int32(2), calls("Unknown"), "Expected the 404 response to expire")
+
+	for range 2 {
+		_, err := client.GetSongDetailsFromAPI(ctx, "Muse", "Broken")
+		assert.ErrorContains(t, err, "503")
+	}
+	assert.Equal(t, int32(2), calls("Broken"), "Expected other errors not to be cached")
+
+	// Starlight is the least recently used of the three songs looked up, so it is evicted.
+	_, err = client.GetSongDetailsFromAPI(ctx, "Muse", "Uprising")
+	require.NoError(t, err)
+	_, err = client.GetSongDetailsFromAPI(ctx, "Muse", "Starlight")
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), calls("Starlight"))
+
+	_, err = client.GetSongDetailsFromAPI(musicapi.Fresh(ctx), "Muse", "Uprising")
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), calls("Uprising"), "Expected a fresh lookup to bypass the cache")
+}
+
+func TestCachedClient_MaxBytes(t *testing.T) {
+	server, calls := countingServer(t, 0)
+	// The lookups of Starlight and Uprising take 32 and 30 bytes: the group, the song and its
+	// details. The one of the unknown Hysteria takes 12.
+	client := musicapi.NewCachedClient(musicapi.NewMusicAPIClient(server.URL, musicapi.Config{}), musicapi.CacheConfig{MaxBytes: 70})
+	ctx := context.Background()
+
+	for _, song := range []string{"Starlight", "Uprising", "Uprising", "Hysteria", "Uprising", "Starlight"} {
+		client.GetSongDetailsFromAPI(ctx, "Muse", song)
+	}
+	assert.Equal(t, int32(1), calls("Uprising"))
+	assert.Equal(t, int32(2), calls("Starlight"), "Expected Starlight to be evicted to make room for Hysteria")
+
+	// A lookup larger than the cache is not kept.
+	server, calls = countingServer(t, 0)
+	client = musicapi.NewCachedClient(musicapi.NewMusicAPIClient(server.URL, musicapi.Config{}), musicapi.CacheConfig{MaxBytes: 10})
+	for range 2 {
+		_, err := client.GetSongDetailsFromAPI(ctx, "Muse", "Starlight")
+		require.NoError(t, err)
+	}
+	assert.Equal(t, int32(2), calls("Starlight"))
+}
+
+func TestCachedClient_Coalescing(t *testing.T) {
+	server, calls := countingServer(t, 100*time.Millisecond)
+	client := musicapi.NewCachedClient(musicapi.NewMusicAPIClient(server.URL, musicapi.Config{}), musicapi.CacheConfig{})
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			details, err := client.GetSongDetailsFromAPI(context.Background(), "Muse", "Starlight")
+			assert.NoError(t, err)
+			assert.Equal(t, "Starlight", details.Text)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), calls("Starlight"))
+
+	// A caller giving up does not fail the lookup shared with the others.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := client.GetSongDetailsFromAPI(ctx, "Muse", "Uprising")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	details, err := client.GetSongDetailsFromAPI(context.Background(), "Muse", "Uprising")
+	assert.NoError(t, err)
+	assert.Equal(t, "Uprising", details.Text)
+	assert.Equal(t, int32(1), calls("Uprising"))
+}
+
+func TestCachedClient_Store(t *testing.T) {
+	server, calls := countingServer(t, 0)
+	store := &fakeLookupCache{lookups: map[string]models.CachedLookup{}}
+	newClient := func() *musicapi.CachedClient {
+		return musicapi.NewCachedClient(musicapi.NewMusicAPIClient(server.URL, musicapi.Config{}), musicapi.CacheConfig{Store: store})
+	}
+	ctx := context.Background()
+
+	_, err := newClient().GetSongDetailsFromAPI(ctx, "Muse", "Starlight")
+	require.NoError(t, err)
+	_, err = newClient().GetSongDetailsFromAPI(ctx, "Muse", "Unknown")
+	assert.ErrorContains(t, err, "404")
+
+	// A new client, as after a restart, finds the lookups in the store.
+	client := newClient()
+	details, err := client.GetSongDetailsFromAPI(ctx, "Muse", "Starlight")
+	require.NoError(t, err)
+	assert.Equal(t, "Starlight", details.Text)
+	_, err = client.GetSongDetailsFromAPI(ctx, "Muse", "Unknown")
+	assert.ErrorContains(t, err, "404")
+	assert.Equal(t, int32(1), calls("Starlight"))
+	assert.Equal(t, int32(1), calls("Unknown"))
+}
@@ -0,0 +1,63 @@
+package musicapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"songlibrary/internal/models"
+)
+
+// CatalogEntry is a song of a Catalog file, its release date in the format of the
+// external API (dd.mm.yyyy).
+type CatalogEntry struct {
+	GroupName   string `json:"group"`
+	SongName    string `json:"song"`
+	ReleaseDate string `json:"releaseDate"`
+	Text        string `json:"text"`
+	Link        string `json:"link"`
+}
+
+// Catalog supplies the details of songs from a static JSON file holding an array of
+// CatalogEntry, loaded once. Names are matched case-insensitively.
+type Catalog struct {
+	songs map[lookupKey]models.SongDetailFromAPI
+}
+
+func NewCatalog(path string) (*Catalog, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read catalog: %w", err)
+	}
+	var entries []CatalogEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to decode catalog %s: %w", path, err)
+	}
+
+	catalog := &Catalog{songs: make(map[lookupKey]models.SongDetailFromAPI, len(entries))}
+	for i, entry := range entries {
+		if entry.GroupName == "" || entry.SongName == "" {
+			return nil, fmt.Errorf("catalog %s: entry %d: group and song are required", path, i)
+		}
+		key := catalogKey(entry.GroupName, entry.SongName)
+		if _, ok := catalog.songs[key]; ok {
+			return nil, fmt.Errorf("catalog %s: entry %d: duplicate song %q by %q", path, i, entry.SongName, entry.GroupName)
+		}
+		catalog.songs[key] = models.SongDetailFromAPI{ReleaseDate: entry.ReleaseDate, Text: entry.Text, Link: entry.Link}
+	}
+	return catalog, nil
+}
+
+func (c *Catalog) GetSongDetailsFromAPI(ctx context.Context, group string, song string) (*models.SongDetailFromAPI, error) {
+	details, ok := c.songs[catalogKey(group, song)]
+	if !ok {
+		return nil, ErrSongNotFound
+	}
+	return &details, nil
+}
+
+func catalogKey(group, song string) lookupKey {
+	return lookupKey{strings.ToLower(group), strings.ToLower(song)}
+}
@@ -0,0 +1,44 @@
+package musicapi_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"songlibrary/internal/models"
+	"songlibrary/internal/musicapi"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCatalog(t *testing.T) {
+	writeCatalog := func(t *testing.T, content string) string {
+		path := filepath.Join(t.TempDir(), "catalog.json")
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+		return path
+	}
+
+	catalog, err := musicapi.NewCatalog(writeCatalog(t, `[
+		{"group": "Muse", "song": "Starlight", "releaseDate": "04.09.2006", "link": "https://example.com"},
+		{"group": "Muse", "song": "Uprising", "text": "Paranoia is in bloom"}
+	]`))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	details, err := catalog.GetSongDetailsFromAPI(ctx, "MUSE", "starlight")
+	require.NoError(t, err)
+	assert.Equal(t, &models.SongDetailFromAPI{ReleaseDate: "04.09.2006", Link: "https://example.com"}, details)
+	_, err = catalog.GetSongDetailsFromAPI(ctx, "Muse", "Hysteria")
+	assert.ErrorIs(t, err, musicapi.ErrSongNotFound)
+
+	_, err = musicapi.NewCatalog(writeCatalog(t, `[{"group": "Muse", "song": "Starlight"}, {"group": "muse", "song": "STARLIGHT"}]`))
+	assert.ErrorContains(t, err, "duplicate song")
+	_, err = musicapi.NewCatalog(writeCatalog(t, `[{"group": "Muse"}]`))
+	assert.ErrorContains(t, err, "group and song are required")
+	_, err = musicapi.NewCatalog(writeCatalog(t, `{"group": "Muse"}`))
+	assert.ErrorContains(t, err, "failed to decode catalog")
+	_, err = musicapi.NewCatalog(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+}
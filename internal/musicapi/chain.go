@@ -0,0 +1,109 @@
+package musicapi
+
+import (
+	"context"
+	"errors"
+
+	"go.uber.org/zap"
+
+	"songlibrary/internal/lib/logger/utils"
+	"songlibrary/internal/models"
+)
+
+// Names of the providers a Chain can be configured with.
+const (
+	// ProviderAPI is the external API serving /info.
+	ProviderAPI = "api"
+	// ProviderLyrics is a LyricsDirectory.
+	ProviderLyrics = "lyrics"
+	// ProviderCatalog is a Catalog.
+	ProviderCatalog = "catalog"
+)
+
+// Provider is a named source of song details.
+type Provider struct {
+	Name string
+	MusicAPI
+}
+
+// Chain looks songs up in its providers in priority order and merges their
+// details: each field comes from the first provider that supplies it, recorded in
+// Sources, and the next providers are only asked while fields are missing.
+//
+// A provider that fails is skipped, and its failure reported as a *ProviderError
+// next to the details merged from the others: such a lookup is partial, and callers
+// that can look the song up again later should treat it as failed. When no provider
+// supplies anything, the lookup fails with the first failure, or with
+// ErrSongNotFound when no provider knows the song.
+type Chain struct {
+	providers []Provider
+}
+
+// ProviderError is the failure of a provider of a Chain.
+type ProviderError struct {
+	Provider string
+	Err      error
+}
+
+func (e *ProviderError) Error() string {
+	return "provider " + e.Provider + ": " + e.Err.Error()
+}
+
+func (e *ProviderError) Unwrap() error {
+	return e.Err
+}
+
+func NewChain(providers ...Provider) *Chain {
+	return &Chain{providers: providers}
+}
+
+func (c *Chain) GetSongDetailsFromAPI(ctx context.Context, group string, song string) (*models.SongDetailFromAPI, error) {
+	merged := &models.SongDetailFromAPI{Sources: make(map[string]string)}
+	fields := map[string]*string{
+		models.FieldReleaseDate: &merged.ReleaseDate,
+		models.FieldText:        &merged.Text,
+		models.FieldLink:        &merged.Link,
+	}
+	found := false
+	var failures []error
+	for _, provider := range c.providers {
+		details, err := provider.GetSongDetailsFromAPI(ctx, group, song)
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, ctxErr
+			}
+			if !errors.Is(err, ErrSongNotFound) {
+				utils.Logger.Warn("Chain - provider failed", zap.String("provider", provider.Name), zap.Error(err))
+				failures = append(failures, &ProviderError{Provider: provider.Name, Err: err})
+			}
+			continue
+		}
+
+		found = true
+		supplied := map[string]string{
+			models.FieldReleaseDate: details.ReleaseDate,
+			models.FieldText:        details.Text,
+			models.FieldLink:        details.Link,
+		}
+		for _, field := range models.DetailFields {
+			if *fields[field] == "" && supplied[field] != "" {
+				*fields[field] = supplied[field]
+				merged.Sources[field] = provider.Name
+			}
+		}
+		if len(merged.Sources) == len(models.DetailFields) {
+			break
+		}
+	}
+
+	if len(merged.Sources) == 0 {
+		if len(failures) > 0 {
+			return nil, failures[0]
+		}
+		if !found {
+			return nil, ErrSongNotFound
+		}
+	}
+	utils.Logger.Debug("Chain - song details merged", zap.String("group", group), zap.String("song", song), zap.Any("sources", merged.Sources))
+	return merged, errors.Join(failures...)
+}
@@ -0,0 +1,133 @@
+package musicapi_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"songlibrary/internal/models"
+	"songlibrary/internal/musicapi"
+	mock_musicapi "songlibrary/internal/musicapi/mocks"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChain(t *testing.T) {
+	unavailable := errors.New("connection refused")
+
+	testCases := []struct {
+		name            string
+		api             func(m *mock_musicapi.MockMusicAPI)
+		lyrics          func(m *mock_musicapi.MockMusicAPI)
+		catalog         func(m *mock_musicapi.MockMusicAPI)
+		expectedDetails *models.SongDetailFromAPI
+		expectedError   error
+	}{
+		{
+			name: "Fields merged in priority order",
+			api: func(m *mock_musicapi.MockMusicAPI) {
+				m.EXPECT().GetSongDetailsFromAPI(gomock.Any(), "Muse", "Starlight").Return(&models.SongDetailFromAPI{ReleaseDate: "04.09.2006"}, nil)
+			},
+			lyrics: func(m *mock_musicapi.MockMusicAPI) {
+				m.EXPECT().GetSongDetailsFromAPI(gomock.Any(), "Muse", "Starlight").Return(&models.SongDetailFromAPI{Text: "Far away"}, nil)
+			},
+			catalog: func(m *mock_musicapi.MockMusicAPI) {
+				m.EXPECT().GetSongDetailsFromAPI(gomock.Any(), "Muse", "Starlight").Return(&models.SongDetailFromAPI{ReleaseDate: "01.01.2000", Text: "Other", Link: "https://example.com"}, nil)
+			},
+			expectedDetails: &models.SongDetailFromAPI{
+				ReleaseDate: "04.09.2006",
+				Text:        "Far away",
+				Link:        "https://example.com",
+				Sources:     map[string]string{models.FieldReleaseDate: "api", models.FieldText: "lyrics", models.FieldLink: "catalog"},
+			},
+		},
+		{
+			name: "Complete details stop the chain",
+			api: func(m *mock_musicapi.MockMusicAPI) {
+				m.EXPECT().GetSongDetailsFromAPI(gomock.Any(), "Muse", "Starlight").Return(&models.SongDetailFromAPI{ReleaseDate: "04.09.2006", Text: "Far away", Link: "https://example.com"}, nil)
+			},
+			expectedDetails: &models.SongDetailFromAPI{
+				ReleaseDate: "04.09.2006",
+				Text:        "Far away",
+				Link:        "https://example.com",
+				Sources:     map[string]string{models.FieldReleaseDate: "api", models.FieldText: "api", models.FieldLink: "api"},
+			},
+		},
+		{
+			name: "Failing provider skipped",
+			api: func(m *mock_musicapi.MockMusicAPI) {
+				m.EXPECT().GetSongDetailsFromAPI(gomock.Any(), "Muse", "Starlight").Return(nil, unavailable)
+			},
+			lyrics: func(m *mock_musicapi.MockMusicAPI) {
+				m.EXPECT().GetSongDetailsFromAPI(gomock.Any(), "Muse", "Starlight").Return(&models.SongDetailFromAPI{Text: "Far away"}, nil)
+			},
+			catalog: func(m *mock_musicapi.MockMusicAPI) {
+				m.EXPECT().GetSongDetailsFromAPI(gomock.Any(), "Muse", "Starlight").Return(nil, musicapi.ErrSongNotFound)
+			},
+			expectedDetails: &models.SongDetailFromAPI{Text: "Far away", Sources: map[string]string{models.FieldText: "lyrics"}},
+			expectedError:   &musicapi.ProviderError{Provider: "api", Err: unavailable},
+		},
+		{
+			name: "Failure without details",
+			api: func(m *mock_musicapi.MockMusicAPI) {
+				m.EXPECT().GetSongDetailsFromAPI(gomock.Any(), "Muse", "Starlight").Return(nil, musicapi.ErrSongNotFound)
+			},
+			lyrics: func(m *mock_musicapi.MockMusicAPI) {
+				m.EXPECT().GetSongDetailsFromAPI(gomock.Any(), "Muse", "Starlight").Return(nil, unavailable)
+			},
+			catalog: func(m *mock_musicapi.MockMusicAPI) {
+				m.EXPECT().GetSongDetailsFromAPI(gomock.Any(), "Muse", "Starlight").Return(nil, musicapi.ErrSongNotFound)
+			},
+			expectedError: &musicapi.ProviderError{Provider: "lyrics", Err: unavailable},
+		},
+		{
+			name: "Unknown to all providers",
+			api: func(m *mock_musicapi.MockMusicAPI) {
+				m.EXPECT().GetSongDetailsFromAPI(gomock.Any(), "Muse", "Starlight").Return(nil, &musicapi.StatusError{StatusCode: 404, Status: "404 Not Found"})
+			},
+			lyrics: func(m *mock_musicapi.MockMusicAPI) {
+				m.EXPECT().GetSongDetailsFromAPI(gomock.Any(), "Muse", "Starlight").Return(nil, musicapi.ErrSongNotFound)
+			},
+			catalog: func(m *mock_musicapi.MockMusicAPI) {
+				m.EXPECT().GetSongDetailsFromAPI(gomock.Any(), "Muse", "Starlight").Return(nil, musicapi.ErrSongNotFound)
+			},
+			expectedError: musicapi.ErrSongNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			var providers []musicapi.Provider
+			for _, provider := range []struct {
+				name   string
+				expect func(m *mock_musicapi.MockMusicAPI)
+			}{{"api", tc.api}, {"lyrics", tc.lyrics}, {"catalog", tc.catalog}} {
+				mock := mock_musicapi.NewMockMusicAPI(ctrl)
+				if provider.expect != nil {
+					provider.expect(mock)
+				}
+				providers = append(providers, musicapi.Provider{Name: provider.name, MusicAPI: mock})
+			}
+
+			details, err := musicapi.NewChain(providers...).GetSongDetailsFromAPI(context.Background(), "Muse", "Starlight")
+			assert.Equal(t, tc.expectedDetails, details)
+			if expected := (*musicapi.ProviderError)(nil); errors.As(tc.expectedError, &expected) {
+				var providerErr *musicapi.ProviderError
+				if assert.ErrorAs(t, err, &providerErr) {
+					assert.Equal(t, expected, providerErr)
+				}
+				assert.ErrorIs(t, err, unavailable)
+				return
+			}
+			if tc.expectedError != nil {
+				assert.ErrorIs(t, err, tc.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package musicapi
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"songlibrary/internal/models"
+)
+
+// FixtureWildcard as the group or song of a Fixture matches any name.
+const FixtureWildcard = "*"
+
+// Fixture is the response of a Fake to the lookups of a song, its release date in the
+// format of the external API (dd.mm.yyyy). Latency, Status and Malformed simulate a
+// slow or failing API for the song.
+type Fixture struct {
+	GroupName   string `yaml:"group"`
+	SongName    string `yaml:"song"`
+	ReleaseDate string `yaml:"releaseDate"`
+	Text        string `yaml:"text"`
+	Link        string `yaml:"link"`
+	// Latency delays the response, e.g. "1.5s".
+	Latency time.Duration `yaml:"latency"`
+	// Status is the status of the response, 200 OK when zero.
+	Status int `yaml:"status"`
+	// Malformed responds with a body that is not valid JSON.
+	Malformed bool `yaml:"malformed"`
+}
+
+//go:embed fixtures
+var defaultFixtures embed.FS
+
+// Fixtures are the songs known to a Fake, keyed by group and song. Names are matched
+// case-insensitively.
+type Fixtures struct {
+	songs map[lookupKey]Fixture
+}
+
+// LoadFixtures reads the .yaml, .yml and .json files of dir, each holding an array of
+// Fixture. An empty dir loads the demo fixtures embedded in the binary.
+func LoadFixtures(dir string) (*Fixtures, error) {
+	fsys, err := fs.Sub(defaultFixtures, "fixtures")
+	if err != nil {
+		return nil, err
+	}
+	if dir != "" {
+		fsys = os.DirFS(dir)
+	}
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixtures: %w", err)
+	}
+
+	fixtures := &Fixtures{songs: make(map[lookupKey]Fixture)}
+	for _, entry := range entries {
+		switch path.Ext(entry.Name()) {
+		case ".yaml", ".yml", ".json":
+		default:
+			continue
+		}
+		if entry.IsDir() {
+			continue
+		}
+		if err := fixtures.load(fsys, entry.Name()); err != nil {
+			return nil, err
+		}
+	}
+	return fixtures, nil
+}
+
+func (f *Fixtures) load(fsys fs.FS, name string) error {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return fmt.Errorf("failed to read fixtures: %w", err)
+	}
+	// JSON is YAML, one decoder reads both.
+	var entries []Fixture
+	if err := yaml.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("failed to decode fixtures %s: %w", name, err)
+	}
+	for i, entry := range entries {
+		if entry.GroupName == "" || entry.SongName == "" {
+			return fmt.Errorf("fixtures %s: entry %d: group and song are required", name, i)
+		}
+		if entry.Status != 0 && (entry.Status < 100 || entry.Status > 599) {
+			return fmt.Errorf("fixtures %s: entry %d: invalid status %d", name, i, entry.Status)
+		}
+		key := catalogKey(entry.GroupName, entry.SongName)
+		if _, ok := f.songs[key]; ok {
+			return fmt.Errorf("fixtures %s: entry %d: duplicate song %q by %q", name, i, entry.SongName, entry.GroupName)
+		}
+		f.songs[key] = entry
+	}
+	return nil
+}
+
+// Len returns the number of fixtures.
+func (f *Fixtures) Len() int {
+	return len(f.songs)
+}
+
+// lookup returns the fixture of the song, else the one of any song of the group, else
+// the one of any song at all.
+func (f *Fixtures) lookup(group, song string) (Fixture, bool) {
+	for _, key := range []lookupKey{
+		catalogKey(group, song),
+		catalogKey(group, FixtureWildcard),
+		catalogKey(FixtureWildcard, FixtureWildcard),
+	} {
+		if fixture, ok := f.songs[key]; ok {
+			return fixture, true
+		}
+	}
+	return Fixture{}, false
+}
+
+// FakeConfig tunes a Fake.
+type FakeConfig struct {
+	// Latency delays every response, on top of the latency of its fixture.
+	Latency time.Duration
+}
+
+// Fake stands in for the external API, answering lookups from Fixtures. It is a MusicAPI
+// and, as an http.Handler, serves the /info contract of the external API.
+type Fake struct {
+	fixtures *Fixtures
+	latency  time.Duration
+}
+
+func NewFake(fixtures *Fixtures, cfg FakeConfig) *Fake {
+	return &Fake{fixtures: fixtures, latency: cfg.Latency}
+}
+
+// GetSongDetailsFromAPI fails as a MusicAPIClient calling the Fake over HTTP would.
+func (f *Fake) GetSongDetailsFromAPI(ctx context.Context, group string, song string) (*models.SongDetailFromAPI, error) {
+	status, body, err := f.respond(ctx, group, song)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call external API: %w", err)
+	}
+	if status != http.StatusOK {
+		return nil, &StatusError{StatusCode: status, Status: fmt.Sprintf("%d %s", status, http.StatusText(status))}
+	}
+	var songDetails models.SongDetailFromAPI
+	if err := json.Unmarshal(body, &songDetails); err != nil {
+		return nil, fmt.Errorf("failed to decode external API response: %w", err)
+	}
+	return &songDetails, nil
+}
+
+func (f *Fake) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	group, song := r.URL.Query().Get("group"), r.URL.Query().Get("song")
+	if group == "" || song == "" {
+		http.Error(w, "group and song are required", http.StatusBadRequest)
+		return
+	}
+
+	status, body, err := f.respond(r.Context(), group, song)
+	if err != nil {
+		return
+	}
+	if status != http.StatusOK {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+// respond waits for the latency of the song and returns the status and body of the
+// response to its lookup, or the error of ctx when it is done first.
+func (f *Fake) respond(ctx context.Context, group, song string) (int, []byte, error) {
+	fixture, ok := f.fixtures.lookup(group, song)
+	if latency := f.latency + fixture.Latency; latency > 0 {
+		timer := time.NewTimer(latency)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return 0, nil, ctx.Err()
+		}
+	}
+
+	switch {
+	case !ok:
+		return http.StatusNotFound, nil, nil
+	case fixture.Status != 0 && fixture.Status != http.StatusOK:
+		return fixture.Status, nil, nil
+	case fixture.Malformed:
+		return http.StatusOK, []byte(`{"releaseDate": "` + fixture.ReleaseDate), nil
+	}
+	body, err := json.Marshal(models.SongDetailFromAPI{ReleaseDate: fixture.ReleaseDate, Text: fixture.Text, Link: fixture.Link})
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, body, nil
+}
@@ -0,0 +1,119 @@
+package musicapi_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"songlibrary/internal/models"
+	"songlibrary/internal/musicapi"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFake(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "songs.yaml"), []byte(`
+- group: Muse
+  song: Starlight
+  releaseDate: 04.09.2006
+  text: Far away
+  link: https://example.com
+- group: Muse
+  song: Slow
+  latency: 1s
+- group: Muse
+  song: Broken
+  status: 503
+- group: Muse
+  song: Garbled
+  malformed: true
+- group: Queen
+  song: "*"
+  text: Any Queen song
+`), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "more.json"), []byte(`[
+	{"group": "Gone", "song": "Missing", "status": 404}
+]`), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("not fixtures"), 0o644))
+
+	fixtures, err := musicapi.LoadFixtures(dir)
+	require.NoError(t, err)
+	assert.Equal(t, 6, fixtures.Len())
+	fake := musicapi.NewFake(fixtures, musicapi.FakeConfig{})
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	// The in-process fake and a client calling it over HTTP behave the same.
+	for name, api := range map[string]musicapi.MusicAPI{
+		"in-process": fake,
+		"http":       musicapi.NewMusicAPIClient(server.URL, musicapi.Config{Timeout: 100 * time.Millisecond}),
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			details, err := api.GetSongDetailsFromAPI(ctx, "muse", "STARLIGHT")
+			require.NoError(t, err)
+			assert.Equal(t, &models.SongDetailFromAPI{ReleaseDate: "04.09.2006", Text: "Far away", Link: "https://example.com"}, details)
+
+			details, err = api.GetSongDetailsFromAPI(ctx, "Queen", "Bohemian Rhapsody")
+			require.NoError(t, err)
+			assert.Equal(t, "Any Queen song", details.Text)
+
+			_, err = api.GetSongDetailsFromAPI(ctx, "Muse", "Uprising")
+			assert.ErrorIs(t, err, musicapi.ErrSongNotFound)
+			_, err = api.GetSongDetailsFromAPI(ctx, "Gone", "Missing")
+			assert.ErrorIs(t, err, musicapi.ErrSongNotFound)
+
+			_, err = api.GetSongDetailsFromAPI(ctx, "Muse", "Broken")
+			var statusErr *musicapi.StatusError
+			require.ErrorAs(t, err, &statusErr)
+			assert.Equal(t, http.StatusServiceUnavailable, statusErr.StatusCode)
+
+			_, err = api.GetSongDetailsFromAPI(ctx, "Muse", "Garbled")
+			assert.ErrorContains(t, err, "failed to decode external API response")
+
+			timeoutCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+			defer cancel()
+			start := time.Now()
+			_, err = api.GetSongDetailsFromAPI(timeoutCtx, "Muse", "Slow")
+			assert.ErrorIs(t, err, context.DeadlineExceeded)
+			assert.Less(t, time.Since(start), time.Second)
+		})
+	}
+
+	resp, err := http.Get(server.URL + "/info?group=Muse")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestLoadFixtures(t *testing.T) {
+	writeFixtures := func(t *testing.T, content string) string {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "songs.yaml"), []byte(content), 0o644))
+		return dir
+	}
+
+	fixtures, err := musicapi.LoadFixtures("")
+	require.NoError(t, err)
+	details, err := musicapi.NewFake(fixtures, musicapi.FakeConfig{}).GetSongDetailsFromAPI(context.Background(), "Muse", "Supermassive Black Hole")
+	require.NoError(t, err)
+	assert.Equal(t, "16.07.2006", details.ReleaseDate)
+
+	_, err = musicapi.LoadFixtures(writeFixtures(t, "- {group: Muse, song: Starlight}\n- {group: muse, song: STARLIGHT}\n"))
+	assert.ErrorContains(t, err, "duplicate song")
+	_, err = musicapi.LoadFixtures(writeFixtures(t, "- {group: Muse}\n"))
+	assert.ErrorContains(t, err, "group and song are required")
+	_, err = musicapi.LoadFixtures(writeFixtures(t, "- {group: Muse, song: Starlight, status: 42}\n"))
+	assert.ErrorContains(t, err, "invalid status")
+	_, err = musicapi.LoadFixtures(writeFixtures(t, "- {group: Muse, song: Starlight, latency: soon}\n"))
+	assert.ErrorContains(t, err, "failed to decode fixtures")
+	_, err = musicapi.LoadFixtures(filepath.Join(t.TempDir(), "missing"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,52 @@
+package musicapi
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"songlibrary/internal/models"
+)
+
+// LyricsDirectory supplies the text of songs from files named <group>/<song>.txt
+// in a directory. Names are matched case-insensitively.
+type LyricsDirectory struct {
+	dir string
+}
+
+func NewLyricsDirectory(dir string) *LyricsDirectory {
+	return &LyricsDirectory{dir: dir}
+}
+
+func (l *LyricsDirectory) GetSongDetailsFromAPI(ctx context.Context, group string, song string) (*models.SongDetailFromAPI, error) {
+	groupDir, err := findEntry(l.dir, group, true)
+	if err != nil {
+		return nil, err
+	}
+	file, err := findEntry(filepath.Join(l.dir, groupDir), song+".txt", false)
+	if err != nil {
+		return nil, err
+	}
+	text, err := os.ReadFile(filepath.Join(l.dir, groupDir, file))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lyrics: %w", err)
+	}
+	return &models.SongDetailFromAPI{Text: strings.TrimSpace(string(text))}, nil
+}
+
+// findEntry returns the name of the directory (isDir) or regular file in dir named
+// name, ignoring case, or ErrSongNotFound.
+func findEntry(dir, name string, isDir bool) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to read lyrics directory: %w", err)
+	}
+	for _, entry := range entries {
+		if strings.EqualFold(entry.Name(), name) && entry.IsDir() == isDir {
+			return entry.Name(), nil
+		}
+	}
+	return "", ErrSongNotFound
+}
@@ -0,0 +1,37 @@
+package musicapi_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"songlibrary/internal/musicapi"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLyricsDirectory(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "Muse"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "Muse", "Starlight.txt"), []byte("Far away\nThis ship is taking me far away\n"), 0o644))
+	lyrics := musicapi.NewLyricsDirectory(dir)
+	ctx := context.Background()
+
+	details, err := lyrics.GetSongDetailsFromAPI(ctx, "muse", "STARLIGHT")
+	require.NoError(t, err)
+	assert.Equal(t, "Far away\nThis ship is taking me far away", details.Text)
+	assert.Empty(t, details.ReleaseDate)
+
+	_, err = lyrics.GetSongDetailsFromAPI(ctx, "Muse", "Uprising")
+	assert.ErrorIs(t, err, musicapi.ErrSongNotFound)
+	_, err = lyrics.GetSongDetailsFromAPI(ctx, "Queen", "Starlight")
+	assert.ErrorIs(t, err, musicapi.ErrSongNotFound)
+	_, err = lyrics.GetSongDetailsFromAPI(ctx, "..", "Muse/Starlight")
+	assert.ErrorIs(t, err, musicapi.ErrSongNotFound)
+
+	_, err = musicapi.NewLyricsDirectory(filepath.Join(dir, "missing")).GetSongDetailsFromAPI(ctx, "Muse", "Starlight")
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, musicapi.ErrSongNotFound)
+}
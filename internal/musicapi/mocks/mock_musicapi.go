@@ -5,6 +5,7 @@
 package mocks
 
 import (
+	context "context"
 	reflect "reflect"
 	models "songlibrary/internal/models"
 
@@ -35,16 +36,16 @@ func (m *MockMusicAPI) EXPECT() *MockMusicAPIMockRecorder {
 }
 
 // GetSongDetailsFromAPI mocks base method.
-func (m *MockMusicAPI) GetSongDetailsFromAPI(arg0, arg1 string) (*models.SongDetailFromAPI, error) {
+func (m *MockMusicAPI) GetSongDetailsFromAPI(arg0 context.Context, arg1, arg2 string) (*models.SongDetailFromAPI, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetSongDetailsFromAPI", arg0, arg1)
+	ret := m.ctrl.Call(m, "GetSongDetailsFromAPI", arg0, arg1, arg2)
 	ret0, _ := ret[0].(*models.SongDetailFromAPI)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetSongDetailsFromAPI indicates an expected call of GetSongDetailsFromAPI.
-func (mr *MockMusicAPIMockRecorder) GetSongDetailsFromAPI(arg0, arg1 interface{}) *gomock.Call {
+func (mr *MockMusicAPIMockRecorder) GetSongDetailsFromAPI(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSongDetailsFromAPI", reflect.TypeOf((*MockMusicAPI)(nil).GetSongDetailsFromAPI), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSongDetailsFromAPI", reflect.TypeOf((*MockMusicAPI)(nil).GetSongDetailsFromAPI), arg0, arg1, arg2)
 }
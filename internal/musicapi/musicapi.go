@@ -1,13 +1,19 @@
 package musicapi
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"net/url"
 	"songlibrary/internal/lib/logger/utils"
 	"songlibrary/internal/models"
+	"strconv"
 	"strings"
+	"time"
 
 	"go.uber.org/zap"
 )
@@ -15,45 +21,77 @@ import (
 //go:generate mockgen -destination=mocks/mock_musicapi.go -package=mocks songlibrary/internal/musicapi MusicAPI
 
 type MusicAPI interface {
-	GetSongDetailsFromAPI(group string, song string) (*models.SongDetailFromAPI, error)
+	// GetSongDetailsFromAPI fetches the details of a song. The call is abandoned
+	// when ctx is done.
+	GetSongDetailsFromAPI(ctx context.Context, group string, song string) (*models.SongDetailFromAPI, error)
+}
+
+// ErrResponseTooLarge is returned when the body of a response exceeds Config.MaxResponseSize.
+var ErrResponseTooLarge = errors.New("external API response too large")
+
+// ErrSongNotFound is returned when a provider does not know the song. A 404 StatusError is one.
+var ErrSongNotFound = errors.New("song not found")
+
+// StatusError is returned when the external API responds with a status other than 200 OK.
+type StatusError struct {
+	StatusCode int
+	Status     string
+	// RetryAfter is the delay the Retry-After header asks for, zero without one.
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	return "external API returned error: " + e.Status
+}
+
+func (e *StatusError) Is(target error) bool {
+	return target == ErrSongNotFound && e.StatusCode == http.StatusNotFound
+}
+
+// Config tunes a MusicAPIClient. Zero fields take the defaults.
+type Config struct {
+	// Timeout bounds a whole call, from connecting to reading the body, 10s by default.
+	Timeout time.Duration
+	// ConnectTimeout bounds establishing a connection, TLS handshake included, 3s by default.
+	ConnectTimeout time.Duration
+	// MaxResponseSize is the largest response body accepted, in bytes, 1 MiB by default.
+	MaxResponseSize int64
 }
 
 type MusicAPIClient struct {
-	baseURL     string
-	client      *http.Client
-	useMockData bool
+	baseURL         string
+	client          *http.Client
+	maxResponseSize int64
 }
 
-func NewMusicAPIClient(baseURL string) *MusicAPIClient {
-	useMockData := baseURL == ""
-	if useMockData {
-		utils.Logger.Info("MusicAPIClient initialized in mock data mode because API_URL is not configured.")
-	} else {
-		utils.Logger.Info("MusicAPIClient initialized with API_URL", zap.String("url", baseURL))
+func NewMusicAPIClient(baseURL string, cfg Config) *MusicAPIClient {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.ConnectTimeout <= 0 {
+		cfg.ConnectTimeout = 3 * time.Second
+	}
+	if cfg.MaxResponseSize <= 0 {
+		cfg.MaxResponseSize = 1 << 20
 	}
 
+	utils.Logger.Info("MusicAPIClient initialized with API_URL", zap.String("url", baseURL), zap.Duration("timeout", cfg.Timeout), zap.Duration("connectTimeout", cfg.ConnectTimeout))
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: cfg.ConnectTimeout, KeepAlive: 30 * time.Second}).DialContext
+	transport.TLSHandshakeTimeout = cfg.ConnectTimeout
 	return &MusicAPIClient{
-		baseURL:     baseURL,
-		client:      &http.Client{},
-		useMockData: useMockData,
+		baseURL:         baseURL,
+		client:          &http.Client{Transport: transport, Timeout: cfg.Timeout},
+		maxResponseSize: cfg.MaxResponseSize,
 	}
 }
 
-func (api *MusicAPIClient) GetSongDetailsFromAPI(group string, song string) (*models.SongDetailFromAPI, error) {
-	if api.useMockData {
-		utils.Logger.Debug("MusicAPIClient is in mock data mode. Returning mock data.")
-		mockSongDetails := new(models.SongDetailFromAPI)
-		mockSongDetails.ReleaseDate = "2023-10-27"
-		mockSongDetails.Text = fmt.Sprintf("Mock Text: This is a sample verse for '%s' by '%s'.\n\n(Data from Mock MusicAPIClient)", song, group)
-		mockSongDetails.Link = "https://www.youtube.com/watch?v=mockExample"
-		return mockSongDetails, nil
-	}
-
+func (api *MusicAPIClient) GetSongDetailsFromAPI(ctx context.Context, group string, song string) (*models.SongDetailFromAPI, error) {
 	apiURL := api.baseURL
 	if apiURL == "" {
-		return nil, fmt.Errorf("API_URL not configured and mock data mode is not active, which should not happen")
+		return nil, errors.New("API_URL not configured")
 	}
-
 	apiURL = strings.TrimSuffix(apiURL, "/") + "/info"
 
 	u, err := url.Parse(apiURL)
@@ -67,22 +105,51 @@ func (api *MusicAPIClient) GetSongDetailsFromAPI(group string, song string) (*mo
 	u.RawQuery = query.Encode()
 
 	utils.Logger.Debug("Calling external API", zap.String("url", u.String()))
-
-	resp, err := api.client.Get(u.String())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create external API request: %w", err)
+	}
+	resp, err := api.client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to call external API: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("external API returned error: %s", resp.Status)
+		return nil, &StatusError{StatusCode: resp.StatusCode, Status: resp.Status, RetryAfter: retryAfter(resp.Header.Get("Retry-After"))}
+	}
+	if resp.ContentLength > api.maxResponseSize {
+		return nil, fmt.Errorf("%w: %d bytes", ErrResponseTooLarge, resp.ContentLength)
+	}
+
+	// Read one byte more than allowed to tell a body of the maximum size from a larger one.
+	body, err := io.ReadAll(io.LimitReader(resp.Body, api.maxResponseSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read external API response: %w", err)
+	}
+	if int64(len(body)) > api.maxResponseSize {
+		return nil, fmt.Errorf("%w: more than %d bytes", ErrResponseTooLarge, api.maxResponseSize)
 	}
 
 	var songDetails models.SongDetailFromAPI
-	if err := json.NewDecoder(resp.Body).Decode(&songDetails); err != nil {
+	if err := json.Unmarshal(body, &songDetails); err != nil {
 		return nil, fmt.Errorf("failed to decode external API response: %w", err)
 	}
 
 	utils.Logger.Debug("External API response", zap.Any("details", songDetails))
 	return &songDetails, nil
 }
+
+// retryAfter parses a Retry-After header, either a number of seconds or an HTTP date.
+func retryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0)
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0)
+	}
+	return 0
+}
@@ -0,0 +1,69 @@
+package musicapi_test
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"songlibrary/internal/lib/logger/utils"
+	"songlibrary/internal/models"
+	"songlibrary/internal/musicapi"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	if err := utils.InitLogger(); err != nil {
+		log.Fatalf("Failed to initialize logger: %v", err)
+	}
+	exitCode := m.Run()
+	utils.Logger.Sync()
+	os.Exit(exitCode)
+}
+
+func TestMusicAPIClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("song") {
+		case "Starlight":
+			w.Write([]byte(`{"releaseDate": "04.09.2006", "text": "Far away", "link": "https://example.com"}`))
+		case "Large":
+			w.Write([]byte(`{"text": "` + strings.Repeat("a", 100) + `"}`))
+		case "Slow":
+			select {
+			case <-time.After(time.Second):
+			case <-r.Context().Done():
+			}
+		default:
+			http.Error(w, "not found", http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := musicapi.NewMusicAPIClient(server.URL, musicapi.Config{Timeout: 100 * time.Millisecond, MaxResponseSize: 96})
+	ctx := context.Background()
+
+	details, err := client.GetSongDetailsFromAPI(ctx, "Muse", "Starlight")
+	assert.NoError(t, err)
+	assert.Equal(t, &models.SongDetailFromAPI{ReleaseDate: "04.09.2006", Text: "Far away", Link: "https://example.com"}, details)
+
+	_, err = client.GetSongDetailsFromAPI(ctx, "Muse", "Unknown")
+	assert.ErrorContains(t, err, "404")
+
+	_, err = client.GetSongDetailsFromAPI(ctx, "Muse", "Large")
+	assert.ErrorIs(t, err, musicapi.ErrResponseTooLarge)
+
+	start := time.Now()
+	_, err = client.GetSongDetailsFromAPI(ctx, "Muse", "Slow")
+	assert.Error(t, err, "Expected the call to time out")
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+
+	canceledCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = musicapi.NewMusicAPIClient(server.URL, musicapi.Config{}).GetSongDetailsFromAPI(canceledCtx, "Muse", "Slow")
+	assert.ErrorIs(t, err, context.Canceled)
+}
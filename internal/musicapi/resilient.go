@@ -0,0 +1,239 @@
+package musicapi
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"songlibrary/internal/lib/logger/utils"
+	"songlibrary/internal/models"
+)
+
+// ErrCircuitOpen is returned without calling the external API while the circuit breaker is open.
+var ErrCircuitOpen = errors.New("external API circuit breaker is open")
+
+type BreakerState string
+
+const (
+	// BreakerClosed lets calls through.
+	BreakerClosed BreakerState = "closed"
+	// BreakerOpen fails calls fast until ResilienceConfig.OpenTimeout has passed.
+	BreakerOpen BreakerState = "open"
+	// BreakerHalfOpen lets one trial call through, which closes the breaker or opens it again.
+	BreakerHalfOpen BreakerState = "half-open"
+)
+
+// BreakerReporter is implemented by clients behind a circuit breaker.
+type BreakerReporter interface {
+	BreakerStats() BreakerStats
+}
+
+type BreakerStats struct {
+	State BreakerState `json:"state"`
+	// ConsecutiveFailures is the number of failed calls since the last successful one.
+	ConsecutiveFailures int `json:"consecutiveFailures"`
+	// OpenUntil is when an open breaker lets a trial call through.
+	OpenUntil *time.Time `json:"openUntil,omitempty"`
+}
+
+// ResilienceConfig tunes a ResilientClient. Zero fields take the defaults.
+type ResilienceConfig struct {
+	// MaxAttempts is the number of calls made for one lookup, 3 by default.
+	MaxAttempts int
+	// Backoff is the delay before the first retry, doubled with every retry up to MaxBackoff,
+	// 200ms and 5s by default. Half of each delay is random. A Retry-After longer than
+	// MaxBackoff ends the retries.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// FailureThreshold is the number of consecutive failed calls that opens the breaker, 5 by default.
+	FailureThreshold int
+	// OpenTimeout is how long the breaker stays open before a trial call, 30s by default.
+	OpenTimeout time.Duration
+}
+
+// ResilientClient retries the failed lookups of another MusicAPI and stops calling
+// it for a while after repeated failures. Only transport errors, timeouts, 429 and
+// 5xx responses are failures; other errors are returned as they are.
+type ResilientClient struct {
+	next    MusicAPI
+	cfg     ResilienceConfig
+	breaker *breaker
+}
+
+func NewResilientClient(next MusicAPI, cfg ResilienceConfig) *ResilientClient {
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 3
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = 200 * time.Millisecond
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = 5 * time.Second
+	}
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = 5
+	}
+	if cfg.OpenTimeout <= 0 {
+		cfg.OpenTimeout = 30 * time.Second
+	}
+	return &ResilientClient{
+		next:    next,
+		cfg:     cfg,
+		breaker: &breaker{threshold: cfg.FailureThreshold, openTimeout: cfg.OpenTimeout, state: BreakerClosed},
+	}
+}
+
+func (c *ResilientClient) GetSongDetailsFromAPI(ctx context.Context, group string, song string) (*models.SongDetailFromAPI, error) {
+	for attempt := 1; ; attempt++ {
+		if !c.breaker.allow() {
+			return nil, ErrCircuitOpen
+		}
+		details, err := c.next.GetSongDetailsFromAPI(ctx, group, song)
+		if err != nil && ctx.Err() != nil {
+			// The caller gave up, which tells nothing about the external API.
+			c.breaker.release()
+			return nil, err
+		}
+		if err == nil || !retryable(err) {
+			c.breaker.record(false)
+			return details, err
+		}
+		c.breaker.record(true)
+
+		if attempt >= c.cfg.MaxAttempts {
+			return nil, err
+		}
+		delay, ok := c.delay(attempt, err)
+		if !ok {
+			return nil, err
+		}
+		utils.Logger.Warn("ResilientClient - retrying external API call", zap.Error(err), zap.Int("attempt", attempt), zap.Duration("delay", delay))
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (c *ResilientClient) BreakerStats() BreakerStats {
+	return c.breaker.stats()
+}
+
+// delay returns how long to wait before the retry following attempt, or false
+// when the external API asks to wait longer than MaxBackoff.
+func (c *ResilientClient) delay(attempt int, err error) (time.Duration, bool) {
+	delay := c.cfg.Backoff << (attempt - 1)
+	if delay <= 0 || delay > c.cfg.MaxBackoff {
+		delay = c.cfg.MaxBackoff
+	}
+	delay = delay/2 + rand.N(delay/2+1)
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) && statusErr.RetryAfter > 0 {
+		if statusErr.RetryAfter > c.cfg.MaxBackoff {
+			return 0, false
+		}
+		delay = max(delay, statusErr.RetryAfter)
+	}
+	return delay, true
+}
+
+// retryable reports whether err means the external API is unavailable, so the
+// call may succeed later.
+func retryable(err error) bool {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		switch statusErr.StatusCode {
+		case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+			http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// breaker counts consecutive failed calls and opens after threshold of them.
+type breaker struct {
+	threshold   int
+	openTimeout time.Duration
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	// trial is set while the call of a half-open breaker is in flight.
+	trial bool
+}
+
+// allow reports whether a call may be made. Every allowed call must be followed
+// by record or release.
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.openedAt) < b.openTimeout {
+			return false
+		}
+		b.state = BreakerHalfOpen
+	case BreakerHalfOpen:
+		if b.trial {
+			return false
+		}
+	default:
+		return true
+	}
+	b.trial = true
+	return true
+}
+
+// record records the outcome of an allowed call.
+func (b *breaker) record(failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+	if !failed {
+		if b.state != BreakerClosed {
+			utils.Logger.Info("ResilientClient - circuit breaker closed")
+		}
+		b.state = BreakerClosed
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.state == BreakerHalfOpen || (b.state == BreakerClosed && b.failures >= b.threshold) {
+		utils.Logger.Warn("ResilientClient - circuit breaker opened", zap.Int("failures", b.failures), zap.Duration("openTimeout", b.openTimeout))
+		b.state = BreakerOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// release ends an allowed call without an outcome.
+func (b *breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+}
+
+func (b *breaker) stats() BreakerStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	stats := BreakerStats{State: b.state, ConsecutiveFailures: b.failures}
+	if b.state == BreakerOpen {
+		openUntil := b.openedAt.Add(b.openTimeout)
+		stats.OpenUntil = &openUntil
+	}
+	return stats
+}
@@ -0,0 +1,157 @@
+package musicapi_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"songlibrary/internal/models"
+	"songlibrary/internal/musicapi"
+	mock_musicapi "songlibrary/internal/musicapi/mocks"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyServer answers with the given statuses in turn, then with 200 OK.
+func flakyServer(t *testing.T, header http.Header, statuses ...int) (*httptest.Server, *atomic.Int32) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		call := int(calls.Add(1))
+		if call <= len(statuses) {
+			for key, values := range header {
+				w.Header()[key] = values
+			}
+			w.WriteHeader(statuses[call-1])
+			return
+		}
+		w.Write([]byte(`{"releaseDate": "04.09.2006"}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func TestResilientClient_Retries(t *testing.T) {
+	testCases := []struct {
+		name          string
+		statuses      []int
+		retryAfter    string
+		expectedCalls int32
+		expectedError string
+		minDuration   time.Duration
+	}{
+		{
+			name:          "Retried until success",
+			statuses:      []int{http.StatusServiceUnavailable, http.StatusBadGateway},
+			expectedCalls: 3,
+		},
+		{
+			name:          "Attempts exhausted",
+			statuses:      []int{http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError},
+			expectedCalls: 3,
+			expectedError: "500",
+		},
+		{
+			name:          "Not found is not retried",
+			statuses:      []int{http.StatusNotFound},
+			expectedCalls: 1,
+			expectedError: "404",
+		},
+		{
+			name:          "Retry-After honored",
+			statuses:      []int{http.StatusTooManyRequests},
+			retryAfter:    "1",
+			expectedCalls: 2,
+			minDuration:   time.Second,
+		},
+		{
+			name:          "Retry-After beyond max backoff",
+			statuses:      []int{http.StatusTooManyRequests},
+			retryAfter:    "120",
+			expectedCalls: 1,
+			expectedError: "429",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			header := http.Header{}
+			if tc.retryAfter != "" {
+				header.Set("Retry-After", tc.retryAfter)
+			}
+			server, calls := flakyServer(t, header, tc.statuses...)
+			client := musicapi.NewResilientClient(musicapi.NewMusicAPIClient(server.URL, musicapi.Config{}), musicapi.ResilienceConfig{
+				Backoff:    time.Millisecond,
+				MaxBackoff: 2 * time.Second,
+			})
+
+			start := time.Now()
+			details, err := client.GetSongDetailsFromAPI(context.Background(), "Muse", "Starlight")
+			if tc.expectedError != "" {
+				assert.ErrorContains(t, err, tc.expectedError)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, "04.09.2006", details.ReleaseDate)
+			}
+			assert.Equal(t, tc.expectedCalls, calls.Load())
+			assert.GreaterOrEqual(t, time.Since(start), tc.minDuration)
+			assert.Equal(t, musicapi.BreakerClosed, client.BreakerStats().State)
+		})
+	}
+}
+
+func TestResilientClient_CircuitBreaker(t *testing.T) {
+	server, calls := flakyServer(t, nil, http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway)
+	client := musicapi.NewResilientClient(musicapi.NewMusicAPIClient(server.URL, musicapi.Config{}), musicapi.ResilienceConfig{
+		MaxAttempts:      1,
+		FailureThreshold: 2,
+		OpenTimeout:      100 * time.Millisecond,
+	})
+	ctx := context.Background()
+
+	for range 2 {
+		_, err := client.GetSongDetailsFromAPI(ctx, "Muse", "Starlight")
+		assert.ErrorContains(t, err, "502")
+	}
+	stats := client.BreakerStats()
+	assert.Equal(t, musicapi.BreakerOpen, stats.State)
+	assert.Equal(t, 2, stats.ConsecutiveFailures)
+	assert.NotNil(t, stats.OpenUntil)
+
+	_, err := client.GetSongDetailsFromAPI(ctx, "Muse", "Starlight")
+	assert.ErrorIs(t, err, musicapi.ErrCircuitOpen)
+	assert.Equal(t, int32(2), calls.Load(), "Expected an open breaker to fail fast")
+
+	// The trial call fails, so the breaker opens again.
+	time.Sleep(150 * time.Millisecond)
+	_, err = client.GetSongDetailsFromAPI(ctx, "Muse", "Starlight")
+	assert.ErrorContains(t, err, "502")
+	assert.Equal(t, musicapi.BreakerOpen, client.BreakerStats().State)
+
+	// The next trial call succeeds and closes the breaker.
+	time.Sleep(150 * time.Millisecond)
+	_, err = client.GetSongDetailsFromAPI(ctx, "Muse", "Starlight")
+	assert.NoError(t, err)
+	assert.Equal(t, musicapi.BreakerStats{State: musicapi.BreakerClosed}, client.BreakerStats())
+	assert.Equal(t, int32(4), calls.Load())
+}
+
+func TestResilientClient_CanceledAfterSuccess(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	next := mock_musicapi.NewMockMusicAPI(ctrl)
+	next.EXPECT().GetSongDetailsFromAPI(gomock.Any(), "Muse", "Starlight").DoAndReturn(func(_ context.Context, group, song string) (*models.SongDetailFromAPI, error) {
+		cancel()
+		return &models.SongDetailFromAPI{ReleaseDate: "04.09.2006"}, nil
+	})
+
+	details, err := musicapi.NewResilientClient(next, musicapi.ResilienceConfig{}).GetSongDetailsFromAPI(ctx, "Muse", "Starlight")
+	require.NoError(t, err)
+	assert.Equal(t, &models.SongDetailFromAPI{ReleaseDate: "04.09.2006"}, details)
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"songlibrary/internal/lib/logger/utils"
+	"songlibrary/internal/lib/normalize"
+	"songlibrary/internal/models"
+	"songlibrary/internal/storage"
+)
+
+// KeyCollision is a set of songs whose names normalize to the same identity keys.
+// Only the first song holds the keys; the others keep none until they are merged
+// into it or renamed.
+type KeyCollision struct {
+	GroupKey string
+	SongKey  string
+	Songs    []models.SongIdentity
+}
+
+type BackfillReport struct {
+	// Songs is the number of songs examined.
+	Songs int
+	// Updated is the number of songs whose keys were set or changed.
+	Updated    int
+	Collisions []KeyCollision
+}
+
+// BackfillKeys sets the identity keys of every song to the keys normalizer computes
+// from its names and reports the songs that collide. A song already holding the
+// keys it collides on keeps them, otherwise the song with the lowest id gets them.
+// With dryRun nothing is written.
+func BackfillKeys(ctx context.Context, songStorage storage.SongStorage, normalizer *normalize.Normalizer, dryRun bool) (*BackfillReport, error) {
+	if _, ok := songStorage.(storage.KeyStore); !ok {
+		return nil, errors.New("storage does not support identity keys")
+	}
+
+	var report *BackfillReport
+	err := songStorage.WithTx(ctx, func(tx storage.SongStorage) error {
+		keyStore := tx.(storage.KeyStore)
+		identities, err := keyStore.Identities(ctx)
+		if err != nil {
+			return err
+		}
+
+		type key struct{ group, song string }
+		var order []key
+		byKey := make(map[key][]models.SongIdentity)
+		for _, identity := range identities {
+			k := key{normalizer.Key(identity.GroupName), normalizer.Key(identity.SongName)}
+			if _, ok := byKey[k]; !ok {
+				order = append(order, k)
+			}
+			byKey[k] = append(byKey[k], identity)
+		}
+
+		report = &BackfillReport{Songs: len(identities)}
+		var cleared []int
+		var set []models.SongIdentity
+		for _, k := range order {
+			songs := byKey[k]
+			keeper := 0
+			for i, song := range songs {
+				if song.GroupKey == k.group && song.SongKey == k.song {
+					keeper = i
+					break
+				}
+			}
+			if len(songs) > 1 {
+				collision := KeyCollision{GroupKey: k.group, SongKey: k.song, Songs: []models.SongIdentity{songs[keeper]}}
+				collision.Songs = append(collision.Songs, songs[:keeper]...)
+				collision.Songs = append(collision.Songs, songs[keeper+1:]...)
+				report.Collisions = append(report.Collisions, collision)
+			}
+
+			for i, song := range songs {
+				switch {
+				case i == keeper && (song.GroupKey != k.group || song.SongKey != k.song):
+					if song.GroupKey != "" {
+						cleared = append(cleared, song.ID)
+					}
+					song.GroupKey, song.SongKey = k.group, k.song
+					set = append(set, song)
+				case i != keeper && song.GroupKey != "":
+					cleared = append(cleared, song.ID)
+				}
+			}
+		}
+		report.Updated = len(set)
+		if dryRun {
+			return nil
+		}
+
+		// Changed keys are cleared first, so that a song may take over keys another one gives up.
+		for _, id := range cleared {
+			if err := keyStore.SetKeys(ctx, id, "", ""); err != nil {
+				return err
+			}
+		}
+		for _, song := range set {
+			if err := keyStore.SetKeys(ctx, song.ID, song.GroupKey, song.SongKey); err != nil {
+				return fmt.Errorf("song %d: %w", song.ID, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		utils.Logger.Error("BackfillKeys - backfill failed", zap.Error(err))
+		return nil, fmt.Errorf("BackfillKeys - backfill failed: %w", err)
+	}
+
+	utils.Logger.Info("BackfillKeys - keys backfilled", zap.Int("songs", report.Songs), zap.Int("updated", report.Updated), zap.Int("collisions", len(report.Collisions)), zap.Bool("dryRun", dryRun))
+	return report, nil
+}
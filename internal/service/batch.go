@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"songlibrary/internal/lib/logger/utils"
+	"songlibrary/internal/lib/normalize"
+	"songlibrary/internal/models"
+	"songlibrary/internal/storage"
+)
+
+func (s *songService) AddSongs(ctx context.Context, reqs []models.AddSongRequest) (*models.BatchResult, error) {
+	utils.Logger.Debug("SongService.AddSongs", zap.Int("items", len(reqs)))
+
+	docs := make([]models.SongDocument, len(reqs))
+	for i, req := range reqs {
+		docs[i] = models.SongDocument{GroupName: req.GroupName, SongName: req.SongName}
+	}
+	result, err := s.addBatch(ctx, docs, false)
+	if err != nil {
+		utils.Logger.Error("SongService.AddSongs - storage.Create failed", zap.Error(err))
+		return nil, fmt.Errorf("SongService.AddSongs - storage.Create failed: %w", err)
+	}
+	utils.Logger.Info("SongService.AddSongs - batch added", zap.Int("created", result.Created), zap.Int("duplicates", result.Duplicates), zap.Int("failed", result.Failed))
+	return result, nil
+}
+
+// addBatch adds the songs of docs in a single transaction. The details of each
+// song are fetched from the external API, or left to a job as the enrichment mode
+// decides, and overridden by those given in its document, unless skipEnrichment
+// is set and the document gives any.
+func (s *songService) addBatch(ctx context.Context, docs []models.SongDocument, skipEnrichment bool) (*models.BatchResult, error) {
+	items := make([]models.BatchItemResult, len(docs))
+	songs := make([]*models.Song, len(docs))
+	// duplicateOf maps items repeating an earlier item of the batch to that item.
+	duplicateOf := make(map[int]int)
+	firstOf := make(map[[2]string]int)
+	var pending []int
+	for i, doc := range docs {
+		items[i].Index = i
+		doc.GroupName, doc.SongName = normalize.Name(doc.GroupName), normalize.Name(doc.SongName)
+		if doc.GroupName == "" || doc.SongName == "" {
+			items[i].Status = models.BatchFailed
+			items[i].Error = "group and song names are required"
+			continue
+		}
+		key := [2]string{s.normalizer.Key(doc.GroupName), s.normalizer.Key(doc.SongName)}
+		if first, ok := firstOf[key]; ok {
+			duplicateOf[i] = first
+			continue
+		}
+		firstOf[key] = i
+		docs[i] = doc
+		pending = append(pending, i)
+	}
+
+	s.enrichBatch(ctx, pending, docs, skipEnrichment, songs, items)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	err := s.storage.WithTx(ctx, func(tx storage.SongStorage) error {
+		for i, song := range songs {
+			if song == nil {
+				continue
+			}
+			// Each song is created in its own savepoint, so that a duplicate does not abort the transaction.
+			var addedSong *models.Song
+			err := tx.WithTx(ctx, func(tx storage.SongStorage) error {
+				var err error
+				addedSong, err = create(ctx, tx, song)
+				return err
+			})
+			switch {
+			case err == nil:
+				items[i] = models.BatchItemResult{Index: i, Status: models.BatchCreated, ID: addedSong.ID, Song: addedSong}
+			case errors.Is(err, storage.ErrSongAlreadyExists):
+				items[i] = models.BatchItemResult{Index: i, Status: models.BatchDuplicate}
+				existing, err := tx.GetByKey(ctx, song.GroupKey, song.SongKey)
+				if err != nil && !errors.Is(err, storage.ErrSongNotFound) {
+					return err
+				}
+				if existing != nil {
+					items[i].ID = existing.ID
+				}
+			default:
+				return fmt.Errorf("item %d: %w", i, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i, first := range duplicateOf {
+		items[i] = models.BatchItemResult{Index: i, Status: models.BatchDuplicate, ID: items[first].ID, Error: fmt.Sprintf("same song as item %d", first)}
+	}
+
+	result := &models.BatchResult{Items: items}
+	for _, item := range items {
+		switch item.Status {
+		case models.BatchCreated:
+			result.Created++
+		case models.BatchDuplicate:
+			result.Duplicates++
+		case models.BatchFailed:
+			result.Failed++
+		}
+	}
+	return result, nil
+}
+
+// enrichBatch builds the songs of the documents at the pending indexes, making its
+// calls to the external API on the slots of s.workers. Songs that already exist or
+// cannot be fetched are left nil and their outcome recorded in items.
+func (s *songService) enrichBatch(ctx context.Context, pending []int, docs []models.SongDocument, skipEnrichment bool, songs []*models.Song, items []models.BatchItemResult) {
+	forEach(ctx, s.workers, len(pending), func(k int) {
+		i := pending[k]
+		songs[i] = s.enrichBatchItem(ctx, docs[i], skipEnrichment, &items[i])
+	})
+}
+
+func (s *songService) enrichBatchItem(ctx context.Context, doc models.SongDocument, skipEnrichment bool, item *models.BatchItemResult) *models.Song {
+	req := &models.AddSongRequest{GroupName: doc.GroupName, SongName: doc.SongName}
+	existing, err := s.existingSong(ctx, s.storage, req)
+	if err != nil {
+		item.Status = models.BatchFailed
+		item.Error = "failed to look up the song"
+		return nil
+	}
+	if existing != nil {
+		item.Status = models.BatchDuplicate
+		item.ID = existing.ID
+		return nil
+	}
+
+	song := &models.Song{GroupName: doc.GroupName, SongName: doc.SongName}
+	if !skipEnrichment || !doc.HasDetails() {
+		if song, err = s.newSong(ctx, req); err != nil {
+			item.Status = models.BatchFailed
+			item.Error = err.Error()
+			if errors.Is(err, ErrExternalAPI) {
+				item.Error = "failed to fetch song details"
+			}
+			return nil
+		}
+	}
+	doc.OverrideDetails(song)
+	s.identify(song)
+	return song
+}
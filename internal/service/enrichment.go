@@ -0,0 +1,201 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"songlibrary/internal/lib/logger/utils"
+	"songlibrary/internal/models"
+	"songlibrary/internal/musicapi"
+	"songlibrary/internal/storage"
+)
+
+// ErrNoEnrichmentQueue is returned by RetryEnrichment, and by adding songs left to a job,
+// when the storage keeps no enrichment jobs.
+var ErrNoEnrichmentQueue = errors.New("storage has no enrichment queue")
+
+func (s *songService) GetEnrichmentJob(ctx context.Context, id int) (*models.EnrichmentJob, error) {
+	utils.Logger.Debug("SongService.GetEnrichmentJob", zap.Int("id", id))
+
+	queue, ok := s.storage.(storage.EnrichmentQueue)
+	if !ok {
+		return nil, storage.ErrJobNotFound
+	}
+	job, err := queue.GetEnrichmentJob(ctx, id)
+	if err != nil {
+		if errors.Is(err, storage.ErrJobNotFound) {
+			return nil, err
+		}
+		utils.Logger.Error("SongService.GetEnrichmentJob - storage.GetEnrichmentJob failed", zap.Error(err), zap.Int("id", id))
+		return nil, fmt.Errorf("SongService.GetEnrichmentJob - storage.GetEnrichmentJob failed: %w", err)
+	}
+	return job, nil
+}
+
+func (s *songService) RetryEnrichment(ctx context.Context, id int) (*models.EnrichmentJob, error) {
+	utils.Logger.Debug("SongService.RetryEnrichment", zap.Int("id", id))
+
+	if _, ok := s.storage.(storage.EnrichmentQueue); !ok {
+		return nil, ErrNoEnrichmentQueue
+	}
+	var job *models.EnrichmentJob
+	err := s.storage.WithTx(ctx, func(tx storage.SongStorage) error {
+		queue, ok := tx.(storage.EnrichmentQueue)
+		if !ok {
+			return ErrNoEnrichmentQueue
+		}
+		if err := queue.EnqueueEnrichment(ctx, id); err != nil {
+			return err
+		}
+		var err error
+		job, err = queue.GetEnrichmentJob(ctx, id)
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, storage.ErrSongNotFound) || errors.Is(err, ErrNoEnrichmentQueue) {
+			return nil, err
+		}
+		utils.Logger.Error("SongService.RetryEnrichment - storage.EnqueueEnrichment failed", zap.Error(err), zap.Int("id", id))
+		return nil, fmt.Errorf("SongService.RetryEnrichment - storage.EnqueueEnrichment failed: %w", err)
+	}
+	utils.Logger.Info("SongService.RetryEnrichment - enrichment scheduled", zap.Int("song_id", id), zap.String("status", string(job.Status)))
+	return job, nil
+}
+
+// EnrichmentWorkerConfig tunes an EnrichmentWorker. Zero fields take the defaults.
+type EnrichmentWorkerConfig struct {
+	// Workers is the number of jobs processed concurrently, 2 by default.
+	Workers int
+	// PollInterval is how long an idle worker waits before looking for due jobs, 1s by default.
+	PollInterval time.Duration
+	// MaxAttempts is the number of attempts after which a failing job is dead, 5 by default.
+	MaxAttempts int
+	// Backoff is the delay before the second attempt, 10s by default. It doubles with
+	// every further attempt up to MaxBackoff, 10m by default, and is jittered by up to 20%.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Lease is how long a job is held by a worker before another worker may take it
+	// over, 5m by default. It must exceed the time a call to the external API takes.
+	Lease time.Duration
+}
+
+// EnrichmentWorker runs the enrichment jobs of a storage: it fetches the details
+// of pending songs from the external API, retrying failures with exponential backoff.
+type EnrichmentWorker struct {
+	storage        storage.SongStorage
+	queue          storage.EnrichmentQueue
+	musicAPIClient musicapi.MusicAPI
+	cfg            EnrichmentWorkerConfig
+}
+
+// NewEnrichmentWorker returns a worker of the jobs of queue, which is usually songStorage itself.
+func NewEnrichmentWorker(songStorage storage.SongStorage, queue storage.EnrichmentQueue, musicAPIClient musicapi.MusicAPI, cfg EnrichmentWorkerConfig) *EnrichmentWorker {
+	if cfg.Workers <= 0 {
+		cfg.Workers = 2
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Second
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 5
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = 10 * time.Second
+	}
+	if cfg.MaxBackoff < cfg.Backoff {
+		cfg.MaxBackoff = max(10*time.Minute, cfg.Backoff)
+	}
+	if cfg.Lease <= 0 {
+		cfg.Lease = 5 * time.Minute
+	}
+	return &EnrichmentWorker{storage: songStorage, queue: queue, musicAPIClient: musicAPIClient, cfg: cfg}
+}
+
+// Run processes due jobs with cfg.Workers goroutines until ctx is done.
+func (w *EnrichmentWorker) Run(ctx context.Context) {
+	utils.Logger.Info("Enrichment worker started", zap.Int("workers", w.cfg.Workers))
+	var wg sync.WaitGroup
+	for range w.cfg.Workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				processed, err := w.ProcessJobs(ctx, 1)
+				if err != nil {
+					utils.Logger.Error("EnrichmentWorker.Run - ProcessJobs failed", zap.Error(err))
+				}
+				if processed > 0 && err == nil {
+					continue
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(w.cfg.PollInterval):
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	utils.Logger.Info("Enrichment worker stopped")
+}
+
+// ProcessJobs claims up to limit due jobs, runs them one after the other and
+// returns their number.
+func (w *EnrichmentWorker) ProcessJobs(ctx context.Context, limit int) (int, error) {
+	jobs, err := w.queue.ClaimEnrichmentJobs(ctx, limit, w.cfg.Lease)
+	if err != nil {
+		return 0, fmt.Errorf("EnrichmentWorker.ProcessJobs - storage.ClaimEnrichmentJobs failed: %w", err)
+	}
+	for _, job := range jobs {
+		if err := w.process(ctx, job); err != nil {
+			// The job is taken over by another worker once its lease expires.
+			utils.Logger.Error("EnrichmentWorker.ProcessJobs - job failed", zap.Error(err), zap.Int("song_id", job.SongID))
+		}
+	}
+	return len(jobs), nil
+}
+
+func (w *EnrichmentWorker) process(ctx context.Context, job models.EnrichmentJob) error {
+	song, err := w.storage.GetByID(ctx, job.SongID)
+	if err != nil {
+		if errors.Is(err, storage.ErrSongNotFound) {
+			// The song was deleted, along with the job.
+			return nil
+		}
+		return err
+	}
+
+	details, err := fetchSong(ctx, w.musicAPIClient, &models.AddSongRequest{GroupName: song.GroupName, SongName: song.SongName})
+	switch {
+	case ctx.Err() != nil:
+		// The worker is stopping; the job is taken over once its lease expires.
+		return ctx.Err()
+	case err == nil:
+		utils.Logger.Info("EnrichmentWorker - song enriched", zap.Int("song_id", job.SongID), zap.Int("attempts", job.Attempts))
+		return w.queue.CompleteEnrichmentJob(ctx, job.SongID, job.Attempts, details)
+	case errors.Is(err, ErrExternalAPI) && job.Attempts < w.cfg.MaxAttempts:
+		delay := w.backoff(job.Attempts)
+		utils.Logger.Warn("EnrichmentWorker - attempt failed, retrying", zap.Error(err), zap.Int("song_id", job.SongID), zap.Int("attempts", job.Attempts), zap.Duration("delay", delay))
+		return w.queue.RetryEnrichmentJob(ctx, job.SongID, job.Attempts, delay, err.Error())
+	default:
+		// Out of attempts, or the details are invalid, which retries do not change.
+		utils.Logger.Error("EnrichmentWorker - job dead", zap.Error(err), zap.Int("song_id", job.SongID), zap.Int("attempts", job.Attempts))
+		return w.queue.FailEnrichmentJob(ctx, job.SongID, job.Attempts, err.Error())
+	}
+}
+
+// backoff returns the delay after the given number of failed attempts.
+func (w *EnrichmentWorker) backoff(attempts int) time.Duration {
+	delay := w.cfg.Backoff
+	for i := 1; i < attempts && delay < w.cfg.MaxBackoff; i++ {
+		delay *= 2
+	}
+	delay = min(delay, w.cfg.MaxBackoff)
+	return delay + rand.N(delay/5+1)
+}
@@ -7,6 +7,7 @@ package mocks
 import (
 	context "context"
 	reflect "reflect"
+	songfile "songlibrary/internal/lib/songfile"
 	models "songlibrary/internal/models"
 
 	gomock "github.com/golang/mock/gomock"
@@ -36,32 +37,77 @@ func (m *MockSongService) EXPECT() *MockSongServiceMockRecorder {
 }
 
 // AddSong mocks base method.
-func (m *MockSongService) AddSong(arg0 context.Context, arg1 *models.AddSongRequest) (*models.Song, error) {
+func (m *MockSongService) AddSong(arg0 context.Context, arg1 *models.AddSongRequest, arg2 models.ConflictMode) (*models.Song, bool, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AddSong", arg0, arg1)
+	ret := m.ctrl.Call(m, "AddSong", arg0, arg1, arg2)
 	ret0, _ := ret[0].(*models.Song)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// AddSong indicates an expected call of AddSong.
+func (mr *MockSongServiceMockRecorder) AddSong(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddSong", reflect.TypeOf((*MockSongService)(nil).AddSong), arg0, arg1, arg2)
+}
+
+// AddSongs mocks base method.
+func (m *MockSongService) AddSongs(arg0 context.Context, arg1 []models.AddSongRequest) (*models.BatchResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddSongs", arg0, arg1)
+	ret0, _ := ret[0].(*models.BatchResult)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// AddSong indicates an expected call of AddSong.
-func (mr *MockSongServiceMockRecorder) AddSong(arg0, arg1 interface{}) *gomock.Call {
+// AddSongs indicates an expected call of AddSongs.
+func (mr *MockSongServiceMockRecorder) AddSongs(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddSong", reflect.TypeOf((*MockSongService)(nil).AddSong), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddSongs", reflect.TypeOf((*MockSongService)(nil).AddSongs), arg0, arg1)
 }
 
 // DeleteSong mocks base method.
-func (m *MockSongService) DeleteSong(arg0 context.Context, arg1 int) error {
+func (m *MockSongService) DeleteSong(arg0 context.Context, arg1, arg2 int) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeleteSong", arg0, arg1)
+	ret := m.ctrl.Call(m, "DeleteSong", arg0, arg1, arg2)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // DeleteSong indicates an expected call of DeleteSong.
-func (mr *MockSongServiceMockRecorder) DeleteSong(arg0, arg1 interface{}) *gomock.Call {
+func (mr *MockSongServiceMockRecorder) DeleteSong(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteSong", reflect.TypeOf((*MockSongService)(nil).DeleteSong), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteSong", reflect.TypeOf((*MockSongService)(nil).DeleteSong), arg0, arg1, arg2)
+}
+
+// ExportSongs mocks base method.
+func (m *MockSongService) ExportSongs(arg0 context.Context, arg1 *models.SongFilter, arg2 func(*models.Song) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExportSongs", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ExportSongs indicates an expected call of ExportSongs.
+func (mr *MockSongServiceMockRecorder) ExportSongs(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExportSongs", reflect.TypeOf((*MockSongService)(nil).ExportSongs), arg0, arg1, arg2)
+}
+
+// GetEnrichmentJob mocks base method.
+func (m *MockSongService) GetEnrichmentJob(arg0 context.Context, arg1 int) (*models.EnrichmentJob, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetEnrichmentJob", arg0, arg1)
+	ret0, _ := ret[0].(*models.EnrichmentJob)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetEnrichmentJob indicates an expected call of GetEnrichmentJob.
+func (mr *MockSongServiceMockRecorder) GetEnrichmentJob(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEnrichmentJob", reflect.TypeOf((*MockSongService)(nil).GetEnrichmentJob), arg0, arg1)
 }
 
 // GetSongText mocks base method.
@@ -80,10 +126,10 @@ func (mr *MockSongServiceMockRecorder) GetSongText(arg0, arg1, arg2 interface{})
 }
 
 // GetSongs mocks base method.
-func (m *MockSongService) GetSongs(arg0 context.Context, arg1 *models.SongFilter, arg2 *models.Pagination) ([]models.Song, error) {
+func (m *MockSongService) GetSongs(arg0 context.Context, arg1 *models.SongFilter, arg2 *models.Pagination) (*models.SongList, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "GetSongs", arg0, arg1, arg2)
-	ret0, _ := ret[0].([]models.Song)
+	ret0, _ := ret[0].(*models.SongList)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
@@ -94,6 +140,96 @@ func (mr *MockSongServiceMockRecorder) GetSongs(arg0, arg1, arg2 interface{}) *g
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSongs", reflect.TypeOf((*MockSongService)(nil).GetSongs), arg0, arg1, arg2)
 }
 
+// ImportSongs mocks base method.
+func (m *MockSongService) ImportSongs(arg0 context.Context, arg1 songfile.Reader, arg2 bool) (*models.ImportResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ImportSongs", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*models.ImportResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ImportSongs indicates an expected call of ImportSongs.
+func (mr *MockSongServiceMockRecorder) ImportSongs(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ImportSongs", reflect.TypeOf((*MockSongService)(nil).ImportSongs), arg0, arg1, arg2)
+}
+
+// PatchSong mocks base method.
+func (m *MockSongService) PatchSong(arg0 context.Context, arg1, arg2 int, arg3 models.PatchFormat, arg4 []byte) (*models.Song, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PatchSong", arg0, arg1, arg2, arg3, arg4)
+	ret0, _ := ret[0].(*models.Song)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PatchSong indicates an expected call of PatchSong.
+func (mr *MockSongServiceMockRecorder) PatchSong(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PatchSong", reflect.TypeOf((*MockSongService)(nil).PatchSong), arg0, arg1, arg2, arg3, arg4)
+}
+
+// RefreshSong mocks base method.
+func (m *MockSongService) RefreshSong(arg0 context.Context, arg1 int, arg2 models.RefreshMode) (*models.RefreshResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RefreshSong", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*models.RefreshResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RefreshSong indicates an expected call of RefreshSong.
+func (mr *MockSongServiceMockRecorder) RefreshSong(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RefreshSong", reflect.TypeOf((*MockSongService)(nil).RefreshSong), arg0, arg1, arg2)
+}
+
+// RefreshSongs mocks base method.
+func (m *MockSongService) RefreshSongs(arg0 context.Context, arg1 *models.SongFilter, arg2 models.RefreshMode) (*models.RefreshBatchResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RefreshSongs", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*models.RefreshBatchResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RefreshSongs indicates an expected call of RefreshSongs.
+func (mr *MockSongServiceMockRecorder) RefreshSongs(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RefreshSongs", reflect.TypeOf((*MockSongService)(nil).RefreshSongs), arg0, arg1, arg2)
+}
+
+// RetryEnrichment mocks base method.
+func (m *MockSongService) RetryEnrichment(arg0 context.Context, arg1 int) (*models.EnrichmentJob, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RetryEnrichment", arg0, arg1)
+	ret0, _ := ret[0].(*models.EnrichmentJob)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RetryEnrichment indicates an expected call of RetryEnrichment.
+func (mr *MockSongServiceMockRecorder) RetryEnrichment(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RetryEnrichment", reflect.TypeOf((*MockSongService)(nil).RetryEnrichment), arg0, arg1)
+}
+
+// SearchSongs mocks base method.
+func (m *MockSongService) SearchSongs(arg0 context.Context, arg1 string, arg2 *models.Pagination) (*models.SongSearchList, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SearchSongs", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*models.SongSearchList)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SearchSongs indicates an expected call of SearchSongs.
+func (mr *MockSongServiceMockRecorder) SearchSongs(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchSongs", reflect.TypeOf((*MockSongService)(nil).SearchSongs), arg0, arg1, arg2)
+}
+
 // UpdateSong mocks base method.
 func (m *MockSongService) UpdateSong(arg0 context.Context, arg1 *models.Song) (*models.Song, error) {
 	m.ctrl.T.Helper()
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+
+	"go.uber.org/zap"
+
+	"songlibrary/internal/lib/logger/utils"
+	"songlibrary/internal/models"
+	"songlibrary/internal/musicapi"
+	"songlibrary/internal/storage"
+)
+
+// ErrTooManySongs is returned by RefreshSongs when more than models.MaxBatchSize songs match the filter.
+var ErrTooManySongs = fmt.Errorf("more than %d songs match the filter", models.MaxBatchSize)
+
+func (s *songService) RefreshSong(ctx context.Context, id int, mode models.RefreshMode) (*models.RefreshResult, error) {
+	utils.Logger.Debug("SongService.RefreshSong", zap.Int("id", id), zap.String("mode", string(mode)))
+
+	song, err := s.storage.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, storage.ErrSongNotFound) {
+			return nil, err
+		}
+		utils.Logger.Error("SongService.RefreshSong - storage.GetByID failed", zap.Error(err), zap.Int("id", id))
+		return nil, fmt.Errorf("SongService.RefreshSong - storage.GetByID failed: %w", err)
+	}
+
+	details, err := fetchSong(musicapi.Fresh(ctx), s.musicAPIClient, &models.AddSongRequest{GroupName: song.GroupName, SongName: song.SongName})
+	if err != nil {
+		utils.Logger.Error("SongService.RefreshSong - fetchSong failed", zap.Error(err), zap.Int("id", id))
+		return nil, fmt.Errorf("SongService.RefreshSong - fetchSong failed: %w", err)
+	}
+
+	result, err := s.refresh(ctx, s.storage, song, details, mode)
+	if err != nil {
+		if errors.Is(err, storage.ErrSongNotFound) || errors.Is(err, storage.ErrVersionMismatch) {
+			return nil, err
+		}
+		utils.Logger.Error("SongService.RefreshSong - storage.Update failed", zap.Error(err), zap.Int("id", id))
+		return nil, fmt.Errorf("SongService.RefreshSong - storage.Update failed: %w", err)
+	}
+	utils.Logger.Info("SongService.RefreshSong - song refreshed", zap.Int("song_id", id), zap.String("status", string(result.Status)), zap.Int("changes", len(result.Changes)))
+	return result, nil
+}
+
+func (s *songService) RefreshSongs(ctx context.Context, filter *models.SongFilter, mode models.RefreshMode) (*models.RefreshBatchResult, error) {
+	utils.Logger.Debug("SongService.RefreshSongs", zap.Any("filter", filter), zap.String("mode", string(mode)))
+
+	// Songs are listed by id; one more than allowed tells whether there are too many.
+	listFilter := models.SongFilter{}
+	if filter != nil {
+		listFilter = *filter
+		listFilter.Sort = nil
+	}
+	songs, err := s.storage.List(ctx, &listFilter, &models.Pagination{Page: 1, PageSize: models.MaxBatchSize + 1})
+	if err != nil {
+		utils.Logger.Error("SongService.RefreshSongs - storage.List failed", zap.Error(err), zap.Any("filter", filter))
+		return nil, fmt.Errorf("SongService.RefreshSongs - storage.List failed: %w", err)
+	}
+	if len(songs) > models.MaxBatchSize {
+		return nil, ErrTooManySongs
+	}
+
+	details := make([]*models.Song, len(songs))
+	errs := make([]error, len(songs))
+	s.fetchDetails(ctx, songs, details, errs)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	result := &models.RefreshBatchResult{Items: make([]models.RefreshResult, len(songs))}
+	for i := range songs {
+		item, err := s.refreshItem(ctx, &songs[i], details[i], errs[i], mode)
+		if err != nil {
+			utils.Logger.Error("SongService.RefreshSongs - storage.Update failed", zap.Error(err), zap.Int("id", songs[i].ID))
+			return nil, fmt.Errorf("SongService.RefreshSongs - storage.Update failed: %w", err)
+		}
+		result.Items[i] = *item
+		switch item.Status {
+		case models.RefreshUpdated:
+			result.Updated++
+		case models.RefreshUnchanged:
+			result.Unchanged++
+		case models.RefreshSkipped:
+			result.Skipped++
+		case models.RefreshFailed:
+			result.Failed++
+		}
+	}
+	utils.Logger.Info("SongService.RefreshSongs - songs refreshed", zap.Int("updated", result.Updated), zap.Int("unchanged", result.Unchanged), zap.Int("skipped", result.Skipped), zap.Int("failed", result.Failed))
+	return result, nil
+}
+
+// fetchDetails fetches the details of songs from the external API, never from the
+// cache, into details, or the error into errs, making its calls on the slots of s.workers.
+func (s *songService) fetchDetails(ctx context.Context, songs []models.Song, details []*models.Song, errs []error) {
+	fresh := musicapi.Fresh(ctx)
+	forEach(ctx, s.workers, len(songs), func(i int) {
+		details[i], errs[i] = fetchSong(fresh, s.musicAPIClient, &models.AddSongRequest{GroupName: songs[i].GroupName, SongName: songs[i].SongName})
+	})
+}
+
+// refreshItem refreshes one song of RefreshSongs, turning the failures specific to
+// the song into a failed result.
+func (s *songService) refreshItem(ctx context.Context, song, details *models.Song, fetchErr error, mode models.RefreshMode) (*models.RefreshResult, error) {
+	failed := func(message string) (*models.RefreshResult, error) {
+		return &models.RefreshResult{ID: song.ID, Status: models.RefreshFailed, Changes: []models.FieldChange{}, Error: message}, nil
+	}
+	if fetchErr != nil {
+		if errors.Is(fetchErr, ErrExternalAPI) {
+			return failed("failed to fetch song details")
+		}
+		return failed(fetchErr.Error())
+	}
+
+	result, err := s.refresh(ctx, s.storage, song, details, mode)
+	switch {
+	case errors.Is(err, storage.ErrSongNotFound):
+		return failed("song was deleted during the refresh")
+	case errors.Is(err, storage.ErrVersionMismatch):
+		return failed("song was changed during the refresh")
+	}
+	return result, err
+}
+
+// refresh compares the details of song with those fetched from the external API
+// and stores the changes mode selects in songStorage. Applied changes are no longer edited.
+func (s *songService) refresh(ctx context.Context, songStorage storage.SongStorage, song, details *models.Song, mode models.RefreshMode) (*models.RefreshResult, error) {
+	result := &models.RefreshResult{ID: song.ID, Status: models.RefreshUnchanged, Changes: []models.FieldChange{}, Song: song}
+	refreshed := *song
+	refreshed.EditedFields = nil
+	refreshed.Sources = maps.Clone(song.Sources)
+	for _, field := range models.DetailFields {
+		stored, fetched := *song.Detail(field), *details.Detail(field)
+		edited := song.IsEdited(field)
+		if !fetched.Valid || fetched == stored {
+			if edited {
+				refreshed.EditedFields = append(refreshed.EditedFields, field)
+			}
+			continue
+		}
+
+		change := models.FieldChange{Field: field, New: &fetched.String, Edited: edited}
+		if stored.Valid {
+			change.Old = &stored.String
+		}
+		change.Applied = mode == models.RefreshAll || (mode == models.RefreshUnedited && !edited)
+		if change.Applied {
+			*refreshed.Detail(field) = fetched
+			refreshed.SetSource(field, details.Sources[field])
+			result.Status = models.RefreshUpdated
+		} else if edited {
+			refreshed.EditedFields = append(refreshed.EditedFields, field)
+		}
+		result.Changes = append(result.Changes, change)
+	}
+
+	if result.Status != models.RefreshUpdated {
+		if len(result.Changes) > 0 {
+			result.Status = models.RefreshSkipped
+		}
+		return result, nil
+	}
+
+	// refreshed.Version is the version read, so a concurrent update makes this one fail.
+	s.identify(&refreshed)
+	updatedSong, err := songStorage.Update(ctx, &refreshed)
+	if err != nil {
+		return nil, err
+	}
+	result.Song = updatedSong
+	return result, nil
+}
@@ -1,11 +1,16 @@
 package service
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"songlibrary/internal/lib/jsonpatch"
 	"songlibrary/internal/lib/logger/utils"
+	"songlibrary/internal/lib/normalize"
+	"songlibrary/internal/lib/songfile"
 	"songlibrary/internal/models"
 	"songlibrary/internal/musicapi"
 	"songlibrary/internal/storage"
@@ -21,31 +26,284 @@ var (
 	ErrExternalAPI = errors.New("external API error")
 )
 
+// SongExistsError is returned by AddSong when the song already exists. It matches
+// storage.ErrSongAlreadyExists and holds the existing song.
+type SongExistsError struct {
+	Song *models.Song
+}
+
+func (e *SongExistsError) Error() string {
+	return fmt.Sprintf("song already exists with id %d", e.Song.ID)
+}
+
+func (e *SongExistsError) Unwrap() error {
+	return storage.ErrSongAlreadyExists
+}
+
 type SongService interface {
-	AddSong(ctx context.Context, req *models.AddSongRequest) (*models.Song, error)
-	GetSongs(ctx context.Context, filter *models.SongFilter, pagination *models.Pagination) ([]models.Song, error)
+	// AddSong adds the song with the details fetched from the external API and reports
+	// whether it was created. Depending on the enrichment mode, the song may instead be
+	// added pending, its details left to an enrichment job. When the song already exists
+	// onConflict decides the outcome: a *SongExistsError, the existing song, or the
+	// existing song updated with fresh details, which are always fetched at once.
+	AddSong(ctx context.Context, req *models.AddSongRequest, onConflict models.ConflictMode) (*models.Song, bool, error)
+	// AddSongs adds a batch of songs in a single transaction, fetching their details
+	// concurrently, and reports the outcome of each item: songs that already exist or
+	// fail do not prevent the others from being added. The error is for failures of
+	// the whole batch.
+	AddSongs(ctx context.Context, reqs []models.AddSongRequest) (*models.BatchResult, error)
+	// ExportSongs calls fn with each song matching filter, in id order, reading them
+	// from storage a page at a time. filter.Sort is ignored. An error of fn stops the export.
+	ExportSongs(ctx context.Context, filter *models.SongFilter, fn func(song *models.Song) error) error
+	// ImportSongs adds the songs read from reader in batches of models.MaxBatchSize,
+	// each in its own transaction, and reports the rows that could not be added. With
+	// skipEnrichment, rows giving a release date, text or link are stored as given
+	// without calling the external API. A file broken before its first row fails with
+	// songfile.ErrInvalidFile, later the rows read so far are imported.
+	ImportSongs(ctx context.Context, reader songfile.Reader, skipEnrichment bool) (*models.ImportResult, error)
+	GetSongs(ctx context.Context, filter *models.SongFilter, pagination *models.Pagination) (*models.SongList, error)
+	SearchSongs(ctx context.Context, query string, pagination *models.Pagination) (*models.SongSearchList, error)
 	GetSongText(ctx context.Context, id int, pagination *models.Pagination) (*models.Song, error)
+	// UpdateSong replaces the song. A non-zero song.Version must be the current version
+	// of the song, otherwise it fails with storage.ErrVersionMismatch. The details it
+	// changes are added to the EditedFields of the song, as they are by PatchSong.
 	UpdateSong(ctx context.Context, song *models.Song) (*models.Song, error)
-	DeleteSong(ctx context.Context, id int) error
+	// PatchSong applies patch, in the given format, to the SongDocument of the song
+	// and stores the result. Malformed patches fail with jsonpatch.ErrInvalidPatch,
+	// inapplicable ones with jsonpatch.ErrConflict and invalid results with models.ErrInvalidSong.
+	// version is checked as by UpdateSong.
+	PatchSong(ctx context.Context, id int, version int, format models.PatchFormat, patch []byte) (*models.Song, error)
+	// DeleteSong deletes the song, checking version as UpdateSong does.
+	DeleteSong(ctx context.Context, id int, version int) error
+	// RefreshSong fetches the details of the song from the external API again and
+	// reports how they differ from the stored ones. mode selects the changes stored.
+	// Details the external API does not return are never cleared. A concurrent update
+	// of the song makes it fail with storage.ErrVersionMismatch.
+	RefreshSong(ctx context.Context, id int, mode models.RefreshMode) (*models.RefreshResult, error)
+	// RefreshSongs refreshes the songs matching filter, in id order, as RefreshSong
+	// does, fetching their details concurrently. It fails with ErrTooManySongs when
+	// more than models.MaxBatchSize songs match. The outcome of each song is reported
+	// separately, the error is for failures of the whole refresh.
+	RefreshSongs(ctx context.Context, filter *models.SongFilter, mode models.RefreshMode) (*models.RefreshBatchResult, error)
+	// GetEnrichmentJob returns the enrichment job of the song, or storage.ErrJobNotFound
+	// when it has none, as its details were fetched when it was added.
+	GetEnrichmentJob(ctx context.Context, id int) (*models.EnrichmentJob, error)
+	// RetryEnrichment schedules a new enrichment job for the song, which fills in the
+	// details it is missing. A pending or running job is returned as is.
+	RetryEnrichment(ctx context.Context, id int) (*models.EnrichmentJob, error)
+}
+
+// Config tunes a SongService; the zero value is valid.
+type Config struct {
+	// BatchWorkers is the number of concurrent external API calls made by AddSongs
+	// and RefreshSongs together.
+	BatchWorkers int
+	// Enrichment selects when new songs get their details, models.EnrichSync by
+	// default. The other modes need a storage implementing storage.EnrichmentQueue.
+	Enrichment models.EnrichmentMode
 }
 
 type songService struct {
 	storage        storage.SongStorage
 	musicAPIClient musicapi.MusicAPI
+	normalizer     *normalize.Normalizer
+	// workers holds a slot for each external API call AddSongs or RefreshSongs is making.
+	workers    chan struct{}
+	enrichment models.EnrichmentMode
 }
 
-func NewSongService(storage storage.SongStorage, musicAPIClient musicapi.MusicAPI) SongService {
+// NewSongService returns a SongService identifying songs by the keys normalizer
+// computes from their names.
+func NewSongService(songStorage storage.SongStorage, musicAPIClient musicapi.MusicAPI, normalizer *normalize.Normalizer, cfg Config) SongService {
+	enrichment := cfg.Enrichment
+	if _, ok := songStorage.(storage.EnrichmentQueue); !ok && enrichment != "" && enrichment != models.EnrichSync {
+		utils.Logger.Warn("Storage has no enrichment queue, fetching song details synchronously", zap.String("enrichment", string(enrichment)))
+		enrichment = models.EnrichSync
+	}
 	return &songService{
-		storage:        storage,
+		storage:        songStorage,
 		musicAPIClient: musicAPIClient,
+		normalizer:     normalizer,
+		workers:        make(chan struct{}, max(cfg.BatchWorkers, 1)),
+		enrichment:     enrichment,
 	}
 }
 
-func (s *songService) AddSong(ctx context.Context, req *models.AddSongRequest) (*models.Song, error) {
-	utils.Logger.Debug("SongService.AddSong", zap.String("group", req.GroupName), zap.String("song", req.SongName))
+func (s *songService) AddSong(ctx context.Context, req *models.AddSongRequest, onConflict models.ConflictMode) (*models.Song, bool, error) {
+	utils.Logger.Debug("SongService.AddSong", zap.String("group", req.GroupName), zap.String("song", req.SongName), zap.String("onConflict", string(onConflict)))
+
+	req = &models.AddSongRequest{GroupName: normalize.Name(req.GroupName), SongName: normalize.Name(req.SongName)}
+
+	// Look for the song first, so that duplicates do not cost a call to the external API.
+	existing, err := s.existingSong(ctx, s.storage, req)
+	if err != nil {
+		return nil, false, err
+	}
+	if existing != nil && onConflict != models.ConflictUpdate {
+		return resolveConflict(existing, onConflict)
+	}
+
+	var newSong *models.Song
+	if existing == nil {
+		newSong, err = s.newSong(ctx, req)
+	} else {
+		newSong, err = fetchSong(ctx, s.musicAPIClient, req)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	s.identify(newSong)
+
+	if existing == nil {
+		var addedSong *models.Song
+		addedSong, existing, err = s.createNew(ctx, req, newSong)
+		if err != nil {
+			return nil, false, err
+		}
+		if addedSong != nil {
+			utils.Logger.Info("SongService.AddSong - song added", zap.Int("song_id", addedSong.ID), zap.String("group", req.GroupName), zap.String("song", req.SongName))
+			return addedSong, true, nil
+		}
+
+		// The song was added concurrently since the lookup.
+		if onConflict != models.ConflictUpdate {
+			return resolveConflict(existing, onConflict)
+		}
+		if newSong.EnrichmentStatus == models.EnrichmentPending {
+			if newSong, err = fetchSong(ctx, s.musicAPIClient, req); err != nil {
+				return nil, false, err
+			}
+			s.identify(newSong)
+		}
+	}
+
+	updatedSong, err := s.updateExisting(ctx, existing.ID, newSong)
+	if err != nil {
+		utils.Logger.Error("SongService.AddSong - storage.Update failed", zap.Error(err), zap.Int("id", existing.ID))
+		return nil, false, fmt.Errorf("SongService.AddSong - storage.Update failed: %w", err)
+	}
+	utils.Logger.Info("SongService.AddSong - existing song updated", zap.Int("song_id", updatedSong.ID), zap.String("group", req.GroupName), zap.String("song", req.SongName))
+	return updatedSong, false, nil
+}
+
+// updateExisting stores the details of newSong in the song of id as RefreshUnedited
+// does: details edited by hand are kept, and stay edited.
+func (s *songService) updateExisting(ctx context.Context, id int, newSong *models.Song) (*models.Song, error) {
+	var updatedSong *models.Song
+	err := s.storage.WithTx(ctx, func(tx storage.SongStorage) error {
+		existing, err := tx.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		result, err := s.refresh(ctx, tx, existing, newSong, models.RefreshUnedited)
+		if err != nil {
+			return err
+		}
+		updatedSong = result.Song
+		return nil
+	})
+	return updatedSong, err
+}
+
+// createNew adds newSong, named as req, unless a song with the same identity keys was
+// added since the first lookup, checking and adding in one transaction. The song found
+// is returned instead.
+func (s *songService) createNew(ctx context.Context, req *models.AddSongRequest, newSong *models.Song) (*models.Song, *models.Song, error) {
+	var addedSong, existing *models.Song
+	err := s.storage.WithTx(ctx, func(tx storage.SongStorage) error {
+		var err error
+		if existing, err = s.existingSong(ctx, tx, req); err != nil || existing != nil {
+			return err
+		}
+		if addedSong, err = create(ctx, tx, newSong); err != nil && !errors.Is(err, storage.ErrSongAlreadyExists) {
+			utils.Logger.Error("SongService.AddSong - storage.Create failed", zap.Error(err))
+			return fmt.Errorf("SongService.AddSong - storage.Create failed: %w", err)
+		}
+		return err
+	})
+	if errors.Is(err, storage.ErrSongAlreadyExists) {
+		// A concurrent transaction added the song after the check.
+		if existing, err = s.existingSong(ctx, s.storage, req); err == nil && existing == nil {
+			err = storage.ErrSongAlreadyExists
+		}
+		return nil, existing, err
+	}
+	return addedSong, existing, err
+}
+
+// existingSong returns the song of songStorage with the identity keys of req, or nil when there is none.
+func (s *songService) existingSong(ctx context.Context, songStorage storage.SongStorage, req *models.AddSongRequest) (*models.Song, error) {
+	song, err := songStorage.GetByKey(ctx, s.normalizer.Key(req.GroupName), s.normalizer.Key(req.SongName))
+	if err != nil {
+		if errors.Is(err, storage.ErrSongNotFound) {
+			return nil, nil
+		}
+		utils.Logger.Error("SongService.AddSong - storage.GetByKey failed", zap.Error(err))
+		return nil, fmt.Errorf("SongService.AddSong - storage.GetByKey failed: %w", err)
+	}
+	return song, nil
+}
+
+// identify normalizes the names of song and sets its identity keys.
+func (s *songService) identify(song *models.Song) {
+	song.GroupName = normalize.Name(song.GroupName)
+	song.SongName = normalize.Name(song.SongName)
+	song.GroupKey = s.normalizer.Key(song.GroupName)
+	song.SongKey = s.normalizer.Key(song.SongName)
+}
+
+func resolveConflict(existing *models.Song, onConflict models.ConflictMode) (*models.Song, bool, error) {
+	if onConflict == models.ConflictReturn {
+		utils.Logger.Info("SongService.AddSong - returning existing song", zap.Int("song_id", existing.ID))
+		return existing, false, nil
+	}
+	return nil, false, &SongExistsError{Song: existing}
+}
+
+// newSong builds the song named as req. Its details are fetched from the external
+// API, unless the enrichment mode leaves them to a job, in which case it is pending.
+func (s *songService) newSong(ctx context.Context, req *models.AddSongRequest) (*models.Song, error) {
+	if s.enrichment == models.EnrichAsync {
+		return &models.Song{GroupName: req.GroupName, SongName: req.SongName, EnrichmentStatus: models.EnrichmentPending}, nil
+	}
+	song, err := fetchSong(ctx, s.musicAPIClient, req)
+	if errors.Is(err, ErrExternalAPI) && s.enrichment == models.EnrichFallback {
+		utils.Logger.Warn("SongService.AddSong - external API failed, leaving details to an enrichment job", zap.String("group", req.GroupName), zap.String("song", req.SongName))
+		return &models.Song{GroupName: req.GroupName, SongName: req.SongName, EnrichmentStatus: models.EnrichmentPending}, nil
+	}
+	return song, err
+}
+
+// create adds song to songStorage, along with its enrichment job when it is pending.
+func create(ctx context.Context, songStorage storage.SongStorage, song *models.Song) (*models.Song, error) {
+	if song.EnrichmentStatus != models.EnrichmentPending {
+		return songStorage.Create(ctx, song)
+	}
+	var addedSong *models.Song
+	err := songStorage.WithTx(ctx, func(tx storage.SongStorage) error {
+		var err error
+		if addedSong, err = tx.Create(ctx, song); err != nil {
+			return err
+		}
+		queue, ok := tx.(storage.EnrichmentQueue)
+		if !ok {
+			return ErrNoEnrichmentQueue
+		}
+		return queue.EnqueueEnrichment(ctx, addedSong.ID)
+	})
+	return addedSong, err
+}
 
-	songDetails, err := s.musicAPIClient.GetSongDetailsFromAPI(req.GroupName, req.SongName)
+// fetchSong builds the song named as req from the details returned by the external API.
+// Failed calls are ErrExternalAPI, partial lookups of a Chain whose providers failed
+// included, unless ctx is done, in which case its error is returned.
+func fetchSong(ctx context.Context, musicAPIClient musicapi.MusicAPI, req *models.AddSongRequest) (*models.Song, error) {
+	songDetails, err := musicAPIClient.GetSongDetailsFromAPI(ctx, req.GroupName, req.SongName)
 	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			utils.Logger.Warn("SongService.AddSong - GetSongDetailsFromAPI canceled", zap.Error(err))
+			return nil, ctxErr
+		}
 		utils.Logger.Error("SongService.AddSong - GetSongDetailsFromAPI failed", zap.Error(err))
 		return nil, fmt.Errorf("SongService.AddSong - GetSongDetailsFromAPI failed: %w", ErrExternalAPI)
 	}
@@ -76,33 +334,102 @@ func (s *songService) AddSong(ctx context.Context, req *models.AddSongRequest) (
 	nullText := sql.NullString{String: songDetails.Text, Valid: songDetails.Text != ""}
 	nullLink := sql.NullString{String: songDetails.Link, Valid: songDetails.Link != ""}
 
-	newSong := &models.Song{
+	song := &models.Song{
 		GroupName:   req.GroupName,
 		SongName:    req.SongName,
 		ReleaseDate: nullReleaseDate,
 		Text:        nullText,
 		Link:        nullLink,
 	}
-
-	addedSong, err := s.storage.Create(ctx, newSong, nil)
-	if err != nil {
-		utils.Logger.Error("SongService.AddSong - storage.Create failed", zap.Error(err))
-		return nil, fmt.Errorf("SongService.AddSong - storage.Create failed: %w", err)
+	for _, field := range models.DetailFields {
+		if song.Detail(field).Valid {
+			song.SetSource(field, songDetails.Sources[field])
+		}
 	}
-
-	utils.Logger.Info("SongService.AddSong - song added", zap.Int("song_id", addedSong.ID), zap.String("group", req.GroupName), zap.String("song", req.SongName))
-	return addedSong, nil
+	return song, nil
 }
 
-func (s *songService) GetSongs(ctx context.Context, filter *models.SongFilter, pagination *models.Pagination) ([]models.Song, error) {
+func (s *songService) GetSongs(ctx context.Context, filter *models.SongFilter, pagination *models.Pagination) (*models.SongList, error) {
 	utils.Logger.Debug("SongService.GetSongs", zap.Any("filter", filter), zap.Any("pagination", pagination))
 
-	songs, err := s.storage.List(ctx, filter, pagination)
+	listPagination := pagination
+	if pagination.After != nil {
+		// Fetch one extra song to find out whether there is a next page.
+		listPagination = &models.Pagination{Page: 1, PageSize: pagination.PageSize + 1, After: pagination.After}
+	}
+
+	songs, err := s.storage.List(ctx, filter, listPagination)
 	if err != nil {
 		utils.Logger.Error("SongService.GetSongs - storage.List failed", zap.Error(err), zap.Any("filter", filter), zap.Any("pagination", pagination))
 		return nil, fmt.Errorf("SongService.GetSongs - storage.List failed: %w", err)
 	}
-	return songs, nil
+
+	totalItems, err := s.storage.Count(ctx, filter)
+	if err != nil {
+		utils.Logger.Error("SongService.GetSongs - storage.Count failed", zap.Error(err), zap.Any("filter", filter))
+		return nil, fmt.Errorf("SongService.GetSongs - storage.Count failed: %w", err)
+	}
+
+	songList := &models.SongList{
+		Items:      songs,
+		PageSize:   pagination.PageSize,
+		TotalItems: totalItems,
+		TotalPages: pagination.TotalPages(totalItems),
+	}
+
+	var hasMore bool
+	if pagination.After != nil {
+		hasMore = len(songs) > pagination.PageSize
+		if hasMore {
+			songList.Items = songs[:pagination.PageSize]
+		}
+	} else {
+		songList.Page = pagination.Page
+		hasMore = pagination.GetOffset()+len(songs) < totalItems
+	}
+
+	if songList.Items == nil {
+		songList.Items = []models.Song{}
+	}
+	// Fuzzy results are ordered by similarity, which a cursor cannot hold.
+	if hasMore && len(songList.Items) > 0 && !filter.IsFuzzy() {
+		var sort models.Sort
+		if filter != nil {
+			sort = filter.Sort
+		}
+		songList.NextCursor = models.CursorAfter(songList.Items[len(songList.Items)-1], sort).Encode()
+	}
+
+	if totalItems == 0 && filter.HasNames() {
+		suggestions, err := s.storage.Suggest(ctx, filter)
+		if err != nil {
+			utils.Logger.Error("SongService.GetSongs - storage.Suggest failed", zap.Error(err), zap.Any("filter", filter))
+			return nil, fmt.Errorf("SongService.GetSongs - storage.Suggest failed: %w", err)
+		}
+		songList.Suggestions = suggestions
+	}
+	return songList, nil
+}
+
+func (s *songService) SearchSongs(ctx context.Context, query string, pagination *models.Pagination) (*models.SongSearchList, error) {
+	utils.Logger.Debug("SongService.SearchSongs", zap.String("query", query), zap.Any("pagination", pagination))
+
+	results, totalItems, err := s.storage.Search(ctx, query, pagination)
+	if err != nil {
+		utils.Logger.Error("SongService.SearchSongs - storage.Search failed", zap.Error(err), zap.String("query", query), zap.Any("pagination", pagination))
+		return nil, fmt.Errorf("SongService.SearchSongs - storage.Search failed: %w", err)
+	}
+
+	if results == nil {
+		results = []models.SongSearchResult{}
+	}
+	return &models.SongSearchList{
+		Items:      results,
+		Page:       pagination.Page,
+		PageSize:   pagination.PageSize,
+		TotalItems: totalItems,
+		TotalPages: pagination.TotalPages(totalItems),
+	}, nil
 }
 
 func (s *songService) GetSongText(ctx context.Context, id int, pagination *models.Pagination) (*models.Song, error) {
@@ -139,10 +466,20 @@ func (s *songService) GetSongText(ctx context.Context, id int, pagination *model
 func (s *songService) UpdateSong(ctx context.Context, song *models.Song) (*models.Song, error) {
 	utils.Logger.Debug("SongService.UpdateSong", zap.Int("id", song.ID), zap.String("group", song.GroupName), zap.String("song", song.SongName))
 
-	updatedSong, err := s.storage.Update(ctx, song)
+	s.identify(song)
+	var updatedSong *models.Song
+	err := s.storage.WithTx(ctx, func(tx storage.SongStorage) error {
+		existing, err := tx.GetByID(ctx, song.ID)
+		if err != nil {
+			return err
+		}
+		markEdited(song, existing)
+		updatedSong, err = tx.Update(ctx, song)
+		return err
+	})
 	if err != nil {
-		if errors.Is(err, storage.ErrSongNotFound) {
-			return nil, storage.ErrSongNotFound
+		if errors.Is(err, storage.ErrSongNotFound) || errors.Is(err, storage.ErrVersionMismatch) || errors.Is(err, storage.ErrSongAlreadyExists) {
+			return nil, err
 		}
 		utils.Logger.Error("SongService.UpdateSong - storage.Update failed", zap.Error(err), zap.Int("id", song.ID))
 		return nil, fmt.Errorf("SongService.UpdateSong - storage.Update failed: %w", err)
@@ -151,13 +488,88 @@ func (s *songService) UpdateSong(ctx context.Context, song *models.Song) (*model
 	return updatedSong, nil
 }
 
-func (s *songService) DeleteSong(ctx context.Context, id int) error {
-	utils.Logger.Debug("SongService.DeleteSong", zap.Int("id", id))
+func (s *songService) PatchSong(ctx context.Context, id int, version int, format models.PatchFormat, patch []byte) (*models.Song, error) {
+	utils.Logger.Debug("SongService.PatchSong", zap.Int("id", id), zap.Int("version", version), zap.String("format", string(format)))
 
-	err := s.storage.Delete(ctx, id)
+	var patchedSong *models.Song
+	err := s.storage.WithTx(ctx, func(tx storage.SongStorage) error {
+		song, err := tx.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if version != 0 && version != song.Version {
+			return storage.ErrVersionMismatch
+		}
+
+		document, err := json.Marshal(models.NewSongDocument(song))
+		if err != nil {
+			return fmt.Errorf("json.Marshal failed: %w", err)
+		}
+		switch format {
+		case models.MergePatch:
+			document, err = jsonpatch.MergePatch(document, patch)
+		case models.JSONPatch:
+			document, err = jsonpatch.Apply(document, patch)
+		default:
+			err = fmt.Errorf("%w: unsupported format %q", jsonpatch.ErrInvalidPatch, format)
+		}
+		if err != nil {
+			return err
+		}
+
+		var patched models.SongDocument
+		decoder := json.NewDecoder(bytes.NewReader(document))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&patched); err != nil {
+			return fmt.Errorf("%w: %v", models.ErrInvalidSong, err)
+		}
+		if err := patched.Validate(); err != nil {
+			return err
+		}
+
+		// song.Version is the version read above, so a concurrent update makes this one fail.
+		existing := *song
+		patched.ApplyTo(song)
+		markEdited(song, &existing)
+		s.identify(song)
+		patchedSong, err = tx.Update(ctx, song)
+		return err
+	})
 	if err != nil {
-		if errors.Is(err, storage.ErrSongNotFound) {
-			return storage.ErrSongNotFound
+		if errors.Is(err, storage.ErrSongNotFound) || errors.Is(err, storage.ErrVersionMismatch) || errors.Is(err, storage.ErrSongAlreadyExists) || errors.Is(err, jsonpatch.ErrInvalidPatch) ||
+			errors.Is(err, jsonpatch.ErrConflict) || errors.Is(err, models.ErrInvalidSong) {
+			return nil, err
+		}
+		utils.Logger.Error("SongService.PatchSong - patch failed", zap.Error(err), zap.Int("id", id))
+		return nil, fmt.Errorf("SongService.PatchSong - patch failed: %w", err)
+	}
+	utils.Logger.Info("SongService.PatchSong - song patched", zap.Int("song_id", patchedSong.ID), zap.String("group", patchedSong.GroupName), zap.String("song", patchedSong.SongName))
+	return patchedSong, nil
+}
+
+// markEdited sets the EditedFields of song to those of existing, the stored version
+// of the song, along with the details song changes. The Sources of the details
+// that are not edited are kept, whatever song had.
+func markEdited(song, existing *models.Song) {
+	var edited []string
+	song.Sources = nil
+	for _, field := range models.DetailFields {
+		if existing.IsEdited(field) || *song.Detail(field) != *existing.Detail(field) {
+			edited = append(edited, field)
+		} else {
+			song.SetSource(field, existing.Sources[field])
+		}
+	}
+	song.EditedFields = edited
+}
+
+func (s *songService) DeleteSong(ctx context.Context, id int, version int) error {
+	utils.Logger.Debug("SongService.DeleteSong", zap.Int("id", id), zap.Int("version", version))
+
+	err := s.storage.Delete(ctx, id, version)
+	if err != nil {
+		if errors.Is(err, storage.ErrSongNotFound) || errors.Is(err, storage.ErrVersionMismatch) {
+			return err
 		}
 		utils.Logger.Error("SongService.DeleteSong - storage.Delete failed", zap.Error(err), zap.Int("id", id))
 		return fmt.Errorf("SongService.DeleteSong - storage.Delete failed: %w", err)
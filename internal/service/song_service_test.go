@@ -4,16 +4,24 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"log"
 	"os"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"songlibrary/internal/lib/jsonpatch"
 	"songlibrary/internal/lib/logger/utils"
+	"songlibrary/internal/lib/normalize"
+	"songlibrary/internal/lib/songfile"
 	"songlibrary/internal/models"
+	"songlibrary/internal/musicapi"
 	mock_musicapi "songlibrary/internal/musicapi/mocks"
 	"songlibrary/internal/service"
 	"songlibrary/internal/storage"
+	"songlibrary/internal/storage/memory"
 	mock_storage "songlibrary/internal/storage/mocks"
 
 	"github.com/golang/mock/gomock"
@@ -33,8 +41,10 @@ func TestSongService_AddSong(t *testing.T) {
 	testCases := []struct {
 		name           string
 		request        *models.AddSongRequest
+		onConflict     models.ConflictMode
 		mockMusicAPIFn func(m *mock_musicapi.MockMusicAPI)
 		mockStorageFn  func(m *mock_storage.MockSongStorage)
+		expectCreated  bool
 		expectError    bool
 	}{
 		{
@@ -44,12 +54,14 @@ func TestSongService_AddSong(t *testing.T) {
 				SongName:  "Test Song",
 			},
 			mockMusicAPIFn: func(m *mock_musicapi.MockMusicAPI) {
-				m.EXPECT().GetSongDetailsFromAPI("Test Group", "Test Song").Return(&models.SongDetailFromAPI{Text: "Test Text", ReleaseDate: "2023-01-01", Link: "http://test.link"}, nil)
+				m.EXPECT().GetSongDetailsFromAPI(gomock.Any(), "Test Group", "Test Song").Return(&models.SongDetailFromAPI{Text: "Test Text", ReleaseDate: "2023-01-01", Link: "http://test.link"}, nil)
 			},
 			mockStorageFn: func(m *mock_storage.MockSongStorage) {
-				m.EXPECT().Create(gomock.Any(), gomock.Any(), gomock.Any()).Return(&models.Song{ID: 1, GroupName: "Test Group", SongName: "Test Song"}, nil) // Исправлено: добавлен третий аргумент gomock.Any()
+				m.EXPECT().GetByKey(gomock.Any(), "test group", "test song").Return(nil, storage.ErrSongNotFound).Times(2)
+				m.EXPECT().Create(gomock.Any(), gomock.Any()).Return(&models.Song{ID: 1, GroupName: "Test Group", SongName: "Test Song"}, nil)
 			},
-			expectError: false,
+			expectCreated: true,
+			expectError:   false,
 		},
 		{
 			name: "MusicAPIClient error",
@@ -58,9 +70,10 @@ func TestSongService_AddSong(t *testing.T) {
 				SongName:  "Test Song",
 			},
 			mockMusicAPIFn: func(m *mock_musicapi.MockMusicAPI) {
-				m.EXPECT().GetSongDetailsFromAPI("Test Group", "Test Song").Return(nil, errors.New("music api error"))
+				m.EXPECT().GetSongDetailsFromAPI(gomock.Any(), "Test Group", "Test Song").Return(nil, errors.New("music api error"))
 			},
 			mockStorageFn: func(m *mock_storage.MockSongStorage) {
+				m.EXPECT().GetByKey(gomock.Any(), "test group", "test song").Return(nil, storage.ErrSongNotFound)
 			},
 			expectError: true,
 		},
@@ -71,10 +84,11 @@ func TestSongService_AddSong(t *testing.T) {
 				SongName:  "Test Song",
 			},
 			mockMusicAPIFn: func(m *mock_musicapi.MockMusicAPI) {
-				m.EXPECT().GetSongDetailsFromAPI("Test Group", "Test Song").Return(&models.SongDetailFromAPI{Text: "Test Text", ReleaseDate: "2023-01-01", Link: "http://test.link"}, nil)
+				m.EXPECT().GetSongDetailsFromAPI(gomock.Any(), "Test Group", "Test Song").Return(&models.SongDetailFromAPI{Text: "Test Text", ReleaseDate: "2023-01-01", Link: "http://test.link"}, nil)
 			},
 			mockStorageFn: func(m *mock_storage.MockSongStorage) {
-				m.EXPECT().Create(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, errors.New("storage error"))
+				m.EXPECT().GetByKey(gomock.Any(), "test group", "test song").Return(nil, storage.ErrSongNotFound).Times(2)
+				m.EXPECT().Create(gomock.Any(), gomock.Any()).Return(nil, errors.New("storage error"))
 			},
 			expectError: true,
 		},
@@ -86,12 +100,98 @@ func TestSongService_AddSong(t *testing.T) {
 			},
 			mockMusicAPIFn: func(m *mock_musicapi.MockMusicAPI) {
 				longText := strings.Repeat("A", 65536)
-				m.EXPECT().GetSongDetailsFromAPI("Test Group", "Test Song").Return(&models.SongDetailFromAPI{Text: longText, ReleaseDate: "2023-01-01", Link: "http://test.link"}, nil)
+				m.EXPECT().GetSongDetailsFromAPI(gomock.Any(), "Test Group", "Test Song").Return(&models.SongDetailFromAPI{Text: longText, ReleaseDate: "2023-01-01", Link: "http://test.link"}, nil)
 			},
 			mockStorageFn: func(m *mock_storage.MockSongStorage) {
+				m.EXPECT().GetByKey(gomock.Any(), "test group", "test song").Return(nil, storage.ErrSongNotFound)
 			},
 			expectError: true,
 		},
+		{
+			name: "Song exists",
+			request: &models.AddSongRequest{
+				GroupName: "Test Group",
+				SongName:  "Test Song",
+			},
+			mockMusicAPIFn: func(m *mock_musicapi.MockMusicAPI) {
+			},
+			mockStorageFn: func(m *mock_storage.MockSongStorage) {
+				m.EXPECT().GetByKey(gomock.Any(), "test group", "test song").Return(&models.Song{ID: 7, GroupName: "Test Group", SongName: "Test Song"}, nil)
+			},
+			expectError: true,
+		},
+		{
+			name: "Song added concurrently",
+			request: &models.AddSongRequest{
+				GroupName: "Test Group",
+				SongName:  "Test Song",
+			},
+			mockMusicAPIFn: func(m *mock_musicapi.MockMusicAPI) {
+				m.EXPECT().GetSongDetailsFromAPI(gomock.Any(), "Test Group", "Test Song").Return(&models.SongDetailFromAPI{Text: "Test Text"}, nil)
+			},
+			mockStorageFn: func(m *mock_storage.MockSongStorage) {
+				gomock.InOrder(
+					m.EXPECT().GetByKey(gomock.Any(), "test group", "test song").Return(nil, storage.ErrSongNotFound).Times(2),
+					m.EXPECT().Create(gomock.Any(), gomock.Any()).Return(nil, storage.ErrSongAlreadyExists),
+					m.EXPECT().GetByKey(gomock.Any(), "test group", "test song").Return(&models.Song{ID: 7, GroupName: "Test Group", SongName: "Test Song"}, nil),
+				)
+			},
+			onConflict: models.ConflictReturn,
+		},
+		{
+			name: "Song added since the lookup",
+			request: &models.AddSongRequest{
+				GroupName: "Test Group",
+				SongName:  "Test Song",
+			},
+			mockMusicAPIFn: func(m *mock_musicapi.MockMusicAPI) {
+				m.EXPECT().GetSongDetailsFromAPI(gomock.Any(), "Test Group", "Test Song").Return(&models.SongDetailFromAPI{Text: "Test Text"}, nil)
+			},
+			mockStorageFn: func(m *mock_storage.MockSongStorage) {
+				gomock.InOrder(
+					m.EXPECT().GetByKey(gomock.Any(), "test group", "test song").Return(nil, storage.ErrSongNotFound),
+					m.EXPECT().GetByKey(gomock.Any(), "test group", "test song").Return(&models.Song{ID: 7, GroupName: "Test Group", SongName: "Test Song"}, nil),
+				)
+			},
+			onConflict: models.ConflictReturn,
+		},
+		{
+			name: "Return existing song",
+			request: &models.AddSongRequest{
+				GroupName: "Test Group",
+				SongName:  "Test Song",
+			},
+			onConflict: models.ConflictReturn,
+			mockMusicAPIFn: func(m *mock_musicapi.MockMusicAPI) {
+			},
+			mockStorageFn: func(m *mock_storage.MockSongStorage) {
+				m.EXPECT().GetByKey(gomock.Any(), "test group", "test song").Return(&models.Song{ID: 7, GroupName: "Test Group", SongName: "Test Song"}, nil)
+			},
+		},
+		{
+			name: "Update existing song",
+			request: &models.AddSongRequest{
+				GroupName: "Test Group",
+				SongName:  "Test Song",
+			},
+			onConflict: models.ConflictUpdate,
+			mockMusicAPIFn: func(m *mock_musicapi.MockMusicAPI) {
+				m.EXPECT().GetSongDetailsFromAPI(gomock.Any(), "Test Group", "Test Song").Return(&models.SongDetailFromAPI{Text: "New Text"}, nil)
+			},
+			mockStorageFn: func(m *mock_storage.MockSongStorage) {
+				m.EXPECT().GetByKey(gomock.Any(), "test group", "test song").Return(&models.Song{ID: 7, GroupName: "Test Group", SongName: "Test Song", Version: 2}, nil)
+				m.EXPECT().GetByID(gomock.Any(), 7).Return(&models.Song{ID: 7, GroupName: "Test Group", SongName: "Test Song", Version: 2}, nil)
+				m.EXPECT().Update(gomock.Any(), &models.Song{
+					ID:        7,
+					GroupName: "Test Group",
+					SongName:  "Test Song",
+					Text:      sql.NullString{String: "New Text", Valid: true},
+					Version:   2,
+					GroupKey:  "test group",
+					SongKey:   "test song",
+				}).Return(&models.Song{ID: 7, GroupName: "Test Group", SongName: "Test Song", Version: 3}, nil)
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -104,26 +204,463 @@ func TestSongService_AddSong(t *testing.T) {
 
 			tc.mockMusicAPIFn(mockMusicAPIClient)
 			tc.mockStorageFn(mockStorage)
+			mockStorage.EXPECT().WithTx(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, fn func(tx storage.SongStorage) error) error {
+				return fn(mockStorage)
+			}).AnyTimes()
 
-			serviceInstance := service.NewSongService(mockStorage, mockMusicAPIClient)
+			serviceInstance := service.NewSongService(mockStorage, mockMusicAPIClient, normalize.New(normalize.DefaultArticles), service.Config{BatchWorkers: 4})
 
-			_, err := serviceInstance.AddSong(context.Background(), tc.request)
+			_, created, err := serviceInstance.AddSong(context.Background(), tc.request, tc.onConflict)
 
 			if tc.expectError {
 				assert.Error(t, err)
 			} else {
 				assert.NoError(t, err)
 			}
+			assert.Equal(t, tc.expectCreated, created)
 		})
 	}
 }
 
+func TestSongService_Normalization(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockMusicAPIClient := mock_musicapi.NewMockMusicAPI(ctrl)
+	mockMusicAPIClient.EXPECT().GetSongDetailsFromAPI(gomock.Any(), "The Beatles", "Help!").Return(&models.SongDetailFromAPI{}, nil)
+
+	serviceInstance := service.NewSongService(memory.NewMemStorage(), mockMusicAPIClient, normalize.New(normalize.DefaultArticles), service.Config{BatchWorkers: 4})
+
+	added, _, err := serviceInstance.AddSong(ctx, &models.AddSongRequest{GroupName: " The Beatles ", SongName: "Help!"}, models.ConflictFail)
+	assert.NoError(t, err)
+	assert.Equal(t, "The Beatles", added.GroupName)
+
+	for _, groupName := range []string{"the beatles ", "Beatles, The", "ＴＨＥ  ＢＥＡＴＬＥＳ"} {
+		_, _, err = serviceInstance.AddSong(ctx, &models.AddSongRequest{GroupName: groupName, SongName: "help!"}, models.ConflictFail)
+		assert.ErrorIs(t, err, storage.ErrSongAlreadyExists, groupName)
+	}
+
+	other, _, err := serviceInstance.AddSong(ctx, &models.AddSongRequest{GroupName: "Beatles", SongName: "HELP!"}, models.ConflictReturn)
+	assert.NoError(t, err)
+	assert.Equal(t, added.ID, other.ID)
+}
+
+func TestSongService_AddSong_UpdateKeepsEdits(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockMusicAPIClient := mock_musicapi.NewMockMusicAPI(ctrl)
+	gomock.InOrder(
+		mockMusicAPIClient.EXPECT().GetSongDetailsFromAPI(gomock.Any(), "Muse", "Starlight").Return(&models.SongDetailFromAPI{Text: "Far away"}, nil),
+		mockMusicAPIClient.EXPECT().GetSongDetailsFromAPI(gomock.Any(), "Muse", "Starlight").Return(&models.SongDetailFromAPI{Text: "Far away, again", Link: "https://example.com"}, nil),
+	)
+	serviceInstance := service.NewSongService(memory.NewMemStorage(), mockMusicAPIClient, normalize.New(normalize.DefaultArticles), service.Config{})
+
+	added, _, err := serviceInstance.AddSong(ctx, &models.AddSongRequest{GroupName: "Muse", SongName: "Starlight"}, models.ConflictFail)
+	assert.NoError(t, err)
+	_, err = serviceInstance.PatchSong(ctx, added.ID, 0, models.MergePatch, []byte(`{"text": "Far away, edited"}`))
+	assert.NoError(t, err)
+
+	updated, created, err := serviceInstance.AddSong(ctx, &models.AddSongRequest{GroupName: "Muse", SongName: "Starlight"}, models.ConflictUpdate)
+	assert.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, "Far away, edited", updated.Text.String, "Expected the edited text to be kept")
+	assert.Equal(t, []string{models.FieldText}, updated.EditedFields)
+	assert.Equal(t, "https://example.com", updated.Link.String)
+}
+
+func TestSongService_AddSongs(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	songStorage := memory.NewMemStorage()
+	existing, err := songStorage.Create(ctx, &models.Song{GroupName: "Muse", SongName: "Starlight", GroupKey: "muse", SongKey: "starlight"})
+	assert.NoError(t, err)
+
+	mockMusicAPIClient := mock_musicapi.NewMockMusicAPI(ctrl)
+	mockMusicAPIClient.EXPECT().GetSongDetailsFromAPI(gomock.Any(), "The Beatles", "Help!").Return(&models.SongDetailFromAPI{ReleaseDate: "06.08.1965"}, nil)
+	mockMusicAPIClient.EXPECT().GetSongDetailsFromAPI(gomock.Any(), "Queen", "Bohemian Rhapsody").Return(nil, errors.New("API error"))
+	mockMusicAPIClient.EXPECT().GetSongDetailsFromAPI(gomock.Any(), "Queen", "Innuendo").Return(&models.SongDetailFromAPI{}, nil)
+
+	serviceInstance := service.NewSongService(songStorage, mockMusicAPIClient, normalize.New(normalize.DefaultArticles), service.Config{BatchWorkers: 2})
+	result, err := serviceInstance.AddSongs(ctx, []models.AddSongRequest{
+		{GroupName: "The Beatles", SongName: "Help!"},
+		{GroupName: "muse", SongName: "starlight"},
+		{GroupName: "Queen", SongName: "Bohemian Rhapsody"},
+		{GroupName: "Beatles, The", SongName: "help!"},
+		{GroupName: " ", SongName: "Untitled"},
+		{GroupName: "Queen", SongName: "Innuendo"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, result.Created)
+	assert.Equal(t, 2, result.Duplicates)
+	assert.Equal(t, 2, result.Failed)
+
+	items := result.Items
+	if assert.Len(t, items, 6) {
+		assert.Equal(t, models.BatchCreated, items[0].Status)
+		assert.Equal(t, "1965-08-06", items[0].Song.ReleaseDate.String)
+		assert.Equal(t, models.BatchItemResult{Index: 1, Status: models.BatchDuplicate, ID: existing.ID}, items[1])
+		assert.Equal(t, models.BatchItemResult{Index: 2, Status: models.BatchFailed, Error: "failed to fetch song details"}, items[2])
+		assert.Equal(t, models.BatchItemResult{Index: 3, Status: models.BatchDuplicate, ID: items[0].ID, Error: "same song as item 0"}, items[3])
+		assert.Equal(t, models.BatchFailed, items[4].Status)
+		assert.Equal(t, models.BatchCreated, items[5].Status)
+		assert.NotEqual(t, items[0].ID, items[5].ID)
+	}
+
+	count, err := songStorage.Count(ctx, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+}
+
+func TestSongService_AddSongs_ConcurrentDuplicate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	songStorage := memory.NewMemStorage()
+	mockMusicAPIClient := mock_musicapi.NewMockMusicAPI(ctrl)
+	// The song is added by someone else while its details are fetched.
+	mockMusicAPIClient.EXPECT().GetSongDetailsFromAPI(gomock.Any(), "Muse", "Uprising").DoAndReturn(func(_ context.Context, groupName, songName string) (*models.SongDetailFromAPI, error) {
+		_, err := songStorage.Create(ctx, &models.Song{GroupName: groupName, SongName: songName, GroupKey: "muse", SongKey: "uprising"})
+		return &models.SongDetailFromAPI{}, err
+	})
+	mockMusicAPIClient.EXPECT().GetSongDetailsFromAPI(gomock.Any(), "Muse", "Resistance").Return(&models.SongDetailFromAPI{}, nil)
+
+	serviceInstance := service.NewSongService(songStorage, mockMusicAPIClient, normalize.New(normalize.DefaultArticles), service.Config{BatchWorkers: 1})
+	result, err := serviceInstance.AddSongs(ctx, []models.AddSongRequest{
+		{GroupName: "Muse", SongName: "Uprising"},
+		{GroupName: "Muse", SongName: "Resistance"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, models.BatchItemResult{Index: 0, Status: models.BatchDuplicate, ID: 1}, result.Items[0])
+	assert.Equal(t, models.BatchCreated, result.Items[1].Status)
+}
+
+func TestSongService_BatchWorkersShared(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	songStorage := memory.NewMemStorage()
+	for _, name := range []string{"Starlight", "Uprising", "Resistance", "Hysteria"} {
+		_, err := songStorage.Create(ctx, &models.Song{GroupName: "Muse", SongName: name, GroupKey: "muse", SongKey: strings.ToLower(name)})
+		assert.NoError(t, err)
+	}
+
+	var mu sync.Mutex
+	calls, maxCalls := 0, 0
+	mockMusicAPIClient := mock_musicapi.NewMockMusicAPI(ctrl)
+	mockMusicAPIClient.EXPECT().GetSongDetailsFromAPI(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(context.Context, string, string) (*models.SongDetailFromAPI, error) {
+		mu.Lock()
+		calls++
+		maxCalls = max(maxCalls, calls)
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		calls--
+		mu.Unlock()
+		return &models.SongDetailFromAPI{}, nil
+	}).Times(8)
+
+	// AddSongs and RefreshSongs running together share the BatchWorkers calls.
+	serviceInstance := service.NewSongService(songStorage, mockMusicAPIClient, normalize.New(normalize.DefaultArticles), service.Config{BatchWorkers: 2})
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		result, err := serviceInstance.AddSongs(ctx, []models.AddSongRequest{
+			{GroupName: "Queen", SongName: "Innuendo"},
+			{GroupName: "Queen", SongName: "Bohemian Rhapsody"},
+			{GroupName: "Queen", SongName: "Radio Ga Ga"},
+			{GroupName: "Queen", SongName: "Under Pressure"},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 4, result.Created)
+	}()
+	go func() {
+		defer wg.Done()
+		result, err := serviceInstance.RefreshSongs(ctx, &models.SongFilter{GroupName: stringPointer("Muse")}, models.RefreshAll)
+		assert.NoError(t, err)
+		assert.Len(t, result.Items, 4)
+	}()
+	wg.Wait()
+	assert.Equal(t, 2, maxCalls)
+}
+
+func TestSongService_AsyncEnrichment(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	songStorage := memory.NewMemStorage()
+	queue := songStorage.(storage.EnrichmentQueue)
+	mockMusicAPIClient := mock_musicapi.NewMockMusicAPI(ctrl)
+	serviceInstance := service.NewSongService(songStorage, mockMusicAPIClient, normalize.New(normalize.DefaultArticles), service.Config{Enrichment: models.EnrichAsync})
+
+	// The API is not called before the song is added.
+	added, created, err := serviceInstance.AddSong(ctx, &models.AddSongRequest{GroupName: "Muse", SongName: "Starlight"}, models.ConflictFail)
+	assert.NoError(t, err)
+	assert.True(t, created)
+	assert.Equal(t, models.EnrichmentPending, added.EnrichmentStatus)
+	assert.False(t, added.Text.Valid)
+
+	result, err := serviceInstance.AddSongs(ctx, []models.AddSongRequest{{GroupName: "Muse", SongName: "Uprising"}})
+	assert.NoError(t, err)
+	assert.Equal(t, models.EnrichmentPending, result.Items[0].Song.EnrichmentStatus)
+
+	job, err := serviceInstance.GetEnrichmentJob(ctx, added.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.JobPending, job.Status)
+
+	mockMusicAPIClient.EXPECT().GetSongDetailsFromAPI(gomock.Any(), "Muse", "Starlight").Return(&models.SongDetailFromAPI{ReleaseDate: "04.09.2006", Text: "Far away"}, nil)
+	mockMusicAPIClient.EXPECT().GetSongDetailsFromAPI(gomock.Any(), "Muse", "Uprising").Return(nil, errors.New("API error")).Times(2)
+	worker := service.NewEnrichmentWorker(songStorage, queue, mockMusicAPIClient, service.EnrichmentWorkerConfig{MaxAttempts: 2, Backoff: time.Nanosecond})
+	processed, err := worker.ProcessJobs(ctx, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, processed)
+
+	enriched, err := songStorage.GetByID(ctx, added.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.EnrichmentComplete, enriched.EnrichmentStatus)
+	assert.Equal(t, "2006-09-04", enriched.ReleaseDate.String)
+	assert.Equal(t, "Far away", enriched.Text.String)
+
+	// The failed job is retried, then dead once out of attempts.
+	job, err = serviceInstance.GetEnrichmentJob(ctx, result.Items[0].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.JobPending, job.Status)
+	assert.Equal(t, 1, job.Attempts)
+	assert.Contains(t, job.LastError, "external API error")
+	processed, err = worker.ProcessJobs(ctx, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, processed)
+	job, err = serviceInstance.GetEnrichmentJob(ctx, result.Items[0].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.JobDead, job.Status)
+	failed, err := songStorage.GetByID(ctx, result.Items[0].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.EnrichmentFailed, failed.EnrichmentStatus)
+
+	job, err = serviceInstance.RetryEnrichment(ctx, result.Items[0].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.JobPending, job.Status)
+	assert.Zero(t, job.Attempts)
+	_, err = serviceInstance.RetryEnrichment(ctx, 999)
+	assert.ErrorIs(t, err, storage.ErrSongNotFound)
+}
+
+func TestSongService_FallbackEnrichment(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockMusicAPIClient := mock_musicapi.NewMockMusicAPI(ctrl)
+	mockMusicAPIClient.EXPECT().GetSongDetailsFromAPI(gomock.Any(), "Muse", "Starlight").Return(&models.SongDetailFromAPI{Text: "Far away"}, nil)
+	mockMusicAPIClient.EXPECT().GetSongDetailsFromAPI(gomock.Any(), "Muse", "Uprising").Return(nil, errors.New("API error"))
+	serviceInstance := service.NewSongService(memory.NewMemStorage(), mockMusicAPIClient, normalize.New(normalize.DefaultArticles), service.Config{Enrichment: models.EnrichFallback})
+
+	added, _, err := serviceInstance.AddSong(ctx, &models.AddSongRequest{GroupName: "Muse", SongName: "Starlight"}, models.ConflictFail)
+	assert.NoError(t, err)
+	assert.Equal(t, models.EnrichmentComplete, added.EnrichmentStatus)
+	assert.Equal(t, "Far away", added.Text.String)
+	_, err = serviceInstance.GetEnrichmentJob(ctx, added.ID)
+	assert.ErrorIs(t, err, storage.ErrJobNotFound)
+
+	pending, _, err := serviceInstance.AddSong(ctx, &models.AddSongRequest{GroupName: "Muse", SongName: "Uprising"}, models.ConflictFail)
+	assert.NoError(t, err)
+	assert.Equal(t, models.EnrichmentPending, pending.EnrichmentStatus)
+	_, err = serviceInstance.GetEnrichmentJob(ctx, pending.ID)
+	assert.NoError(t, err)
+}
+
+func TestSongService_ExportSongs(t *testing.T) {
+	ctx := context.Background()
+	songStorage := memory.NewMemStorage()
+	for i := range 1201 {
+		groupName := "Muse"
+		if i%2 == 1 {
+			groupName = "Queen"
+		}
+		_, err := songStorage.Create(ctx, &models.Song{GroupName: groupName, SongName: fmt.Sprintf("Song %d", i)})
+		assert.NoError(t, err)
+	}
+	serviceInstance := service.NewSongService(songStorage, nil, normalize.New(normalize.DefaultArticles), service.Config{BatchWorkers: 4})
+
+	var ids []int
+	err := serviceInstance.ExportSongs(ctx, nil, func(song *models.Song) error {
+		ids = append(ids, song.ID)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Len(t, ids, 1201)
+	assert.IsIncreasing(t, ids)
+
+	exported := 0
+	filter := &models.SongFilter{GroupName: stringPointer("muse"), Sort: models.Sort{{Field: models.SortBySong, Desc: true}}}
+	err = serviceInstance.ExportSongs(ctx, filter, func(song *models.Song) error {
+		assert.Equal(t, "Muse", song.GroupName)
+		exported++
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 601, exported)
+
+	errStop := errors.New("stop")
+	err = serviceInstance.ExportSongs(ctx, nil, func(song *models.Song) error {
+		return errStop
+	})
+	assert.ErrorIs(t, err, errStop)
+}
+
+func TestSongService_ImportSongs(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	songStorage := memory.NewMemStorage()
+	_, err := songStorage.Create(ctx, &models.Song{GroupName: "Muse", SongName: "Starlight", GroupKey: "muse", SongKey: "starlight"})
+	assert.NoError(t, err)
+
+	mockMusicAPIClient := mock_musicapi.NewMockMusicAPI(ctrl)
+	mockMusicAPIClient.EXPECT().GetSongDetailsFromAPI(gomock.Any(), "Queen", "Innuendo").Return(&models.SongDetailFromAPI{Text: "API text", Link: "https://api.example.com"}, nil)
+	mockMusicAPIClient.EXPECT().GetSongDetailsFromAPI(gomock.Any(), "Queen", "Bohemian Rhapsody").Return(nil, errors.New("API error"))
+
+	file := `{"group": "Muse", "song": "Uprising", "text": "Given text"}
+{"group": "muse", "song": "starlight"}
+{"group": "Queen", "song": "Innuendo", "link": "https://example.com"}
+{"group": "Queen"}
+{"group": "Queen", "song": "Bohemian Rhapsody"}
+not json
+{"group": "Queen", "song": "Show Must Go On", "releaseDate": "1991-10-14"}
+{"group": "Queen", "song": "Mustapha", "releaseDate": "14.10.1991"}
+`
+	reader, err := songfile.NewReader(strings.NewReader(file), songfile.NDJSON)
+	assert.NoError(t, err)
+
+	serviceInstance := service.NewSongService(songStorage, mockMusicAPIClient, normalize.New(normalize.DefaultArticles), service.Config{BatchWorkers: 4})
+	// Innuendo gives a link but no text: with skipEnrichment it is stored as given,
+	// so the API is only called for it below, without skipEnrichment.
+	result, err := serviceInstance.ImportSongs(ctx, reader, true)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, result.Created)
+	assert.Equal(t, 1, result.Duplicates)
+	assert.Equal(t, 4, result.Failed)
+	assert.Equal(t, []models.ImportError{
+		{Row: 4, Error: "invalid song: song is required"},
+		{Row: 5, Error: "failed to fetch song details"},
+		{Row: 6, Error: "invalid character 'o' in literal null (expecting 'u')"},
+		{Row: 8, Error: "invalid song: releaseDate must be a YYYY-MM-DD date"},
+	}, result.Errors)
+
+	song, err := songStorage.GetByKey(ctx, "queen", "innuendo")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "https://example.com", song.Link.String)
+		assert.False(t, song.Text.Valid)
+	}
+
+	_, err = serviceInstance.ImportSongs(ctx, mustReader(t, `{"group": "Queen", "song": "Innuendo II"}`), false)
+	assert.ErrorIs(t, err, songfile.ErrInvalidFile)
+
+	reader, err = songfile.NewReader(strings.NewReader(`[{"group": "Queen", "song": "Innuendo", "link": "https://example.com"}]`), songfile.JSON)
+	assert.NoError(t, err)
+	assert.NoError(t, songStorage.Delete(ctx, song.ID, 0))
+	result, err = serviceInstance.ImportSongs(ctx, reader, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.Created)
+	song, err = songStorage.GetByKey(ctx, "queen", "innuendo")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "https://example.com", song.Link.String, "Expected given link to override the API")
+		assert.Equal(t, "API text", song.Text.String)
+	}
+}
+
+func TestSongService_ImportSongs_ManyErrors(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockMusicAPIClient := mock_musicapi.NewMockMusicAPI(ctrl)
+	mockMusicAPIClient.EXPECT().GetSongDetailsFromAPI(gomock.Any(), "Queen", "Innuendo").Return(nil, errors.New("API error"))
+
+	// The first row fails only once its batch is added, after the rows failing validation.
+	var file strings.Builder
+	file.WriteString(`{"group": "Queen", "song": "Innuendo"}` + "\n")
+	for range models.MaxImportErrors + 10 {
+		file.WriteString(`{"group": "Queen"}` + "\n")
+	}
+	reader, err := songfile.NewReader(strings.NewReader(file.String()), songfile.NDJSON)
+	assert.NoError(t, err)
+
+	serviceInstance := service.NewSongService(memory.NewMemStorage(), mockMusicAPIClient, normalize.New(normalize.DefaultArticles), service.Config{})
+	result, err := serviceInstance.ImportSongs(context.Background(), reader, false)
+	assert.NoError(t, err)
+	assert.Equal(t, models.MaxImportErrors+11, result.Failed)
+	assert.Len(t, result.Errors, models.MaxImportErrors)
+	assert.Equal(t, models.ImportError{Row: 1, Error: "failed to fetch song details"}, result.Errors[0])
+	assert.Equal(t, models.MaxImportErrors, result.Errors[len(result.Errors)-1].Row)
+}
+
+func mustReader(t *testing.T, file string) songfile.Reader {
+	reader, err := songfile.NewReader(strings.NewReader(file), songfile.JSON)
+	assert.NoError(t, err)
+	return reader
+}
+
+func TestBackfillKeys(t *testing.T) {
+	ctx := context.Background()
+	songStorage := memory.NewMemStorage()
+	for _, song := range []models.Song{
+		{GroupName: "The Beatles", SongName: "Help!"},
+		{GroupName: "Muse", SongName: "Starlight"},
+		{GroupName: "Beatles, The", SongName: "Help!"},
+		{GroupName: "the beatles ", SongName: "help!", GroupKey: "beatles", SongKey: "help!"},
+	} {
+		_, err := songStorage.Create(ctx, &song)
+		assert.NoError(t, err)
+	}
+	normalizer := normalize.New(normalize.DefaultArticles)
+
+	report, err := service.BackfillKeys(ctx, songStorage, normalizer, true)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, report.Songs)
+	assert.Equal(t, 1, report.Updated)
+	if assert.Len(t, report.Collisions, 1) {
+		collision := report.Collisions[0]
+		assert.Equal(t, "beatles", collision.GroupKey)
+		assert.Equal(t, "help!", collision.SongKey)
+		var ids []int
+		for _, song := range collision.Songs {
+			ids = append(ids, song.ID)
+		}
+		assert.Equal(t, []int{4, 1, 3}, ids, "the song already holding the keys comes first")
+	}
+	_, err = songStorage.GetByKey(ctx, "muse", "starlight")
+	assert.ErrorIs(t, err, storage.ErrSongNotFound, "dry run must not write")
+
+	_, err = service.BackfillKeys(ctx, songStorage, normalizer, false)
+	assert.NoError(t, err)
+	muse, err := songStorage.GetByKey(ctx, "muse", "starlight")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, muse.ID)
+	beatles, err := songStorage.GetByKey(ctx, "beatles", "help!")
+	assert.NoError(t, err)
+	assert.Equal(t, 4, beatles.ID)
+
+	report, err = service.BackfillKeys(ctx, songStorage, normalizer, false)
+	assert.NoError(t, err)
+	assert.Zero(t, report.Updated)
+}
+
 func TestSongService_GetSongs(t *testing.T) {
 	testCases := []struct {
 		name          string
 		filter        *models.SongFilter
 		pagination    *models.Pagination
 		mockStorageFn func(s *mock_storage.MockSongStorage)
+		expected      *models.SongList
 		expectError   bool
 	}{
 		{
@@ -132,6 +669,14 @@ func TestSongService_GetSongs(t *testing.T) {
 			pagination: models.NewPagination(1, 10),
 			mockStorageFn: func(s *mock_storage.MockSongStorage) {
 				s.EXPECT().List(gomock.Any(), nil, gomock.Any()).Return([]models.Song{{ID: 1, GroupName: "Test Group", SongName: "Test Song"}}, nil)
+				s.EXPECT().Count(gomock.Any(), nil).Return(1, nil)
+			},
+			expected: &models.SongList{
+				Items:      []models.Song{{ID: 1, GroupName: "Test Group", SongName: "Test Song"}},
+				Page:       1,
+				PageSize:   10,
+				TotalItems: 1,
+				TotalPages: 1,
 			},
 			expectError: false,
 		},
@@ -140,13 +685,116 @@ func TestSongService_GetSongs(t *testing.T) {
 			filter: &models.SongFilter{
 				GroupName: stringPointer("Test Group"),
 			},
-			pagination: models.NewPagination(1, 10),
+			pagination: models.NewPagination(2, 10),
 			mockStorageFn: func(s *mock_storage.MockSongStorage) {
 				filter := &models.SongFilter{GroupName: stringPointer("Test Group")}
-				s.EXPECT().List(gomock.Any(), filter, gomock.Any()).Return([]models.Song{{ID: 1, GroupName: "Test Group", SongName: "Test Song"}}, nil)
+				s.EXPECT().List(gomock.Any(), filter, gomock.Any()).Return([]models.Song{{ID: 11, GroupName: "Test Group", SongName: "Test Song"}}, nil)
+				s.EXPECT().Count(gomock.Any(), filter).Return(21, nil)
+			},
+			expected: &models.SongList{
+				Items:      []models.Song{{ID: 11, GroupName: "Test Group", SongName: "Test Song"}},
+				Page:       2,
+				PageSize:   10,
+				TotalItems: 21,
+				TotalPages: 3,
+				NextCursor: models.CursorAfter(models.Song{ID: 11}, nil).Encode(),
+			},
+			expectError: false,
+		},
+		{
+			name:       "Cursor with more songs",
+			filter:     nil,
+			pagination: &models.Pagination{Page: 1, PageSize: 2, After: &models.Cursor{ID: 3}},
+			mockStorageFn: func(s *mock_storage.MockSongStorage) {
+				s.EXPECT().List(gomock.Any(), nil, gomock.Eq(&models.Pagination{Page: 1, PageSize: 3, After: &models.Cursor{ID: 3}})).Return([]models.Song{{ID: 4}, {ID: 6}, {ID: 7}}, nil)
+				s.EXPECT().Count(gomock.Any(), nil).Return(7, nil)
+			},
+			expected: &models.SongList{
+				Items:      []models.Song{{ID: 4}, {ID: 6}},
+				PageSize:   2,
+				TotalItems: 7,
+				TotalPages: 4,
+				NextCursor: models.CursorAfter(models.Song{ID: 6}, nil).Encode(),
+			},
+			expectError: false,
+		},
+		{
+			name:       "Cursor on the last page",
+			filter:     nil,
+			pagination: &models.Pagination{Page: 1, PageSize: 2, After: &models.Cursor{ID: 6}},
+			mockStorageFn: func(s *mock_storage.MockSongStorage) {
+				s.EXPECT().List(gomock.Any(), nil, gomock.Any()).Return([]models.Song{{ID: 7}}, nil)
+				s.EXPECT().Count(gomock.Any(), nil).Return(7, nil)
+			},
+			expected: &models.SongList{
+				Items:      []models.Song{{ID: 7}},
+				PageSize:   2,
+				TotalItems: 7,
+				TotalPages: 4,
+			},
+			expectError: false,
+		},
+		{
+			name:       "Empty page",
+			filter:     nil,
+			pagination: models.NewPagination(1, 10),
+			mockStorageFn: func(s *mock_storage.MockSongStorage) {
+				s.EXPECT().List(gomock.Any(), nil, gomock.Any()).Return(nil, nil)
+				s.EXPECT().Count(gomock.Any(), nil).Return(0, nil)
+			},
+			expected: &models.SongList{
+				Items:    []models.Song{},
+				Page:     1,
+				PageSize: 10,
+			},
+			expectError: false,
+		},
+		{
+			name:       "Fuzzy match without cursor",
+			filter:     &models.SongFilter{GroupName: stringPointer("Metalica"), Match: models.MatchFuzzy},
+			pagination: models.NewPagination(1, 1),
+			mockStorageFn: func(s *mock_storage.MockSongStorage) {
+				s.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any()).Return([]models.Song{{ID: 1, GroupName: "Metallica"}}, nil)
+				s.EXPECT().Count(gomock.Any(), gomock.Any()).Return(2, nil)
+			},
+			expected: &models.SongList{
+				Items:      []models.Song{{ID: 1, GroupName: "Metallica"}},
+				Page:       1,
+				PageSize:   1,
+				TotalItems: 2,
+				TotalPages: 2,
+			},
+			expectError: false,
+		},
+		{
+			name:       "No match with suggestions",
+			filter:     &models.SongFilter{GroupName: stringPointer("Metalica")},
+			pagination: models.NewPagination(1, 10),
+			mockStorageFn: func(s *mock_storage.MockSongStorage) {
+				filter := &models.SongFilter{GroupName: stringPointer("Metalica")}
+				s.EXPECT().List(gomock.Any(), filter, gomock.Any()).Return(nil, nil)
+				s.EXPECT().Count(gomock.Any(), filter).Return(0, nil)
+				s.EXPECT().Suggest(gomock.Any(), filter).Return([]models.NameSuggestion{{Field: "group", Value: "Metallica", Similarity: 0.73}}, nil)
+			},
+			expected: &models.SongList{
+				Items:       []models.Song{},
+				Page:        1,
+				PageSize:    10,
+				Suggestions: []models.NameSuggestion{{Field: "group", Value: "Metallica", Similarity: 0.73}},
 			},
 			expectError: false,
 		},
+		{
+			name:       "Suggest error",
+			filter:     &models.SongFilter{SongName: stringPointer("Sandmen")},
+			pagination: models.NewPagination(1, 10),
+			mockStorageFn: func(s *mock_storage.MockSongStorage) {
+				s.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil)
+				s.EXPECT().Count(gomock.Any(), gomock.Any()).Return(0, nil)
+				s.EXPECT().Suggest(gomock.Any(), gomock.Any()).Return(nil, errors.New("storage error"))
+			},
+			expectError: true,
+		},
 		{
 			name:       "Storage error",
 			filter:     nil,
@@ -156,6 +804,80 @@ func TestSongService_GetSongs(t *testing.T) {
 			},
 			expectError: true,
 		},
+		{
+			name:       "Count error",
+			filter:     nil,
+			pagination: models.NewPagination(1, 10),
+			mockStorageFn: func(s *mock_storage.MockSongStorage) {
+				s.EXPECT().List(gomock.Any(), nil, gomock.Any()).Return([]models.Song{}, nil)
+				s.EXPECT().Count(gomock.Any(), nil).Return(0, errors.New("storage error"))
+			},
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockStorage := mock_storage.NewMockSongStorage(ctrl)
+			tc.mockStorageFn(mockStorage)
+
+			serviceInstance := service.NewSongService(mockStorage, nil, normalize.New(normalize.DefaultArticles), service.Config{BatchWorkers: 4})
+
+			songs, err := serviceInstance.GetSongs(context.Background(), tc.filter, tc.pagination)
+
+			if tc.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expected, songs)
+			}
+		})
+	}
+}
+
+func TestSongService_SearchSongs(t *testing.T) {
+	testCases := []struct {
+		name          string
+		pagination    *models.Pagination
+		mockStorageFn func(s *mock_storage.MockSongStorage)
+		expected      *models.SongSearchList
+		expectError   bool
+	}{
+		{
+			name:       "Found",
+			pagination: models.NewPagination(2, 1),
+			mockStorageFn: func(s *mock_storage.MockSongStorage) {
+				s.EXPECT().Search(gomock.Any(), "far away", gomock.Eq(models.NewPagination(2, 1))).Return(
+					[]models.SongSearchResult{{Song: models.Song{ID: 1}, Rank: 0.5, Snippet: "<mark>far</mark>"}}, 3, nil,
+				)
+			},
+			expected: &models.SongSearchList{
+				Items:      []models.SongSearchResult{{Song: models.Song{ID: 1}, Rank: 0.5, Snippet: "<mark>far</mark>"}},
+				Page:       2,
+				PageSize:   1,
+				TotalItems: 3,
+				TotalPages: 3,
+			},
+		},
+		{
+			name:       "Nothing found",
+			pagination: models.NewPagination(1, 10),
+			mockStorageFn: func(s *mock_storage.MockSongStorage) {
+				s.EXPECT().Search(gomock.Any(), "far away", gomock.Any()).Return(nil, 0, nil)
+			},
+			expected: &models.SongSearchList{Items: []models.SongSearchResult{}, Page: 1, PageSize: 10},
+		},
+		{
+			name:       "Storage error",
+			pagination: models.NewPagination(1, 10),
+			mockStorageFn: func(s *mock_storage.MockSongStorage) {
+				s.EXPECT().Search(gomock.Any(), "far away", gomock.Any()).Return(nil, 0, errors.New("storage error"))
+			},
+			expectError: true,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -166,14 +888,15 @@ func TestSongService_GetSongs(t *testing.T) {
 			mockStorage := mock_storage.NewMockSongStorage(ctrl)
 			tc.mockStorageFn(mockStorage)
 
-			serviceInstance := service.NewSongService(mockStorage, nil)
+			serviceInstance := service.NewSongService(mockStorage, nil, normalize.New(normalize.DefaultArticles), service.Config{BatchWorkers: 4})
 
-			_, err := serviceInstance.GetSongs(context.Background(), tc.filter, tc.pagination)
+			results, err := serviceInstance.SearchSongs(context.Background(), "far away", tc.pagination)
 
 			if tc.expectError {
 				assert.Error(t, err)
 			} else {
 				assert.NoError(t, err)
+				assert.Equal(t, tc.expected, results)
 			}
 		})
 	}
@@ -248,7 +971,7 @@ func TestSongService_GetSongText(t *testing.T) {
 			mockStorage := mock_storage.NewMockSongStorage(ctrl)
 			tc.mockStorageFn(mockStorage)
 
-			serviceInstance := service.NewSongService(mockStorage, nil)
+			serviceInstance := service.NewSongService(mockStorage, nil, normalize.New(normalize.DefaultArticles), service.Config{BatchWorkers: 4})
 
 			song, err := serviceInstance.GetSongText(context.Background(), tc.songID, tc.pagination)
 
@@ -277,6 +1000,7 @@ func TestSongService_UpdateSong(t *testing.T) {
 				SongName:  "Updated Song",
 			},
 			mockStorageFn: func(s *mock_storage.MockSongStorage) {
+				s.EXPECT().GetByID(gomock.Any(), 1).Return(&models.Song{ID: 1, GroupName: "Group", SongName: "Song"}, nil)
 				s.EXPECT().Update(gomock.Any(), gomock.Any()).Return(&models.Song{ID: 1, GroupName: "Updated Group", SongName: "Updated Song"}, nil)
 			},
 			expectError: false,
@@ -289,7 +1013,7 @@ func TestSongService_UpdateSong(t *testing.T) {
 				SongName:  "Updated Song",
 			},
 			mockStorageFn: func(s *mock_storage.MockSongStorage) {
-				s.EXPECT().Update(gomock.Any(), gomock.Any()).Return(nil, storage.ErrSongNotFound)
+				s.EXPECT().GetByID(gomock.Any(), 1).Return(nil, storage.ErrSongNotFound)
 			},
 			expectError: true,
 		},
@@ -301,6 +1025,7 @@ func TestSongService_UpdateSong(t *testing.T) {
 				SongName:  "Updated Song",
 			},
 			mockStorageFn: func(s *mock_storage.MockSongStorage) {
+				s.EXPECT().GetByID(gomock.Any(), 1).Return(&models.Song{ID: 1, GroupName: "Group", SongName: "Song"}, nil)
 				s.EXPECT().Update(gomock.Any(), gomock.Any()).Return(nil, errors.New("storage error"))
 			},
 			expectError: true,
@@ -313,9 +1038,12 @@ func TestSongService_UpdateSong(t *testing.T) {
 			defer ctrl.Finish()
 
 			mockStorage := mock_storage.NewMockSongStorage(ctrl)
+			mockStorage.EXPECT().WithTx(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, fn func(tx storage.SongStorage) error) error {
+				return fn(mockStorage)
+			})
 			tc.mockStorageFn(mockStorage)
 
-			serviceInstance := service.NewSongService(mockStorage, nil)
+			serviceInstance := service.NewSongService(mockStorage, nil, normalize.New(normalize.DefaultArticles), service.Config{BatchWorkers: 4})
 
 			_, err := serviceInstance.UpdateSong(context.Background(), tc.songToUpdate)
 
@@ -339,7 +1067,7 @@ func TestSongService_DeleteSong(t *testing.T) {
 			name:   "Valid request",
 			songID: 1,
 			mockStorageFn: func(s *mock_storage.MockSongStorage) {
-				s.EXPECT().Delete(gomock.Any(), 1).Return(nil)
+				s.EXPECT().Delete(gomock.Any(), 1, 0).Return(nil)
 			},
 			expectError: false,
 		},
@@ -347,7 +1075,7 @@ func TestSongService_DeleteSong(t *testing.T) {
 			name:   "Song not found",
 			songID: 1,
 			mockStorageFn: func(s *mock_storage.MockSongStorage) {
-				s.EXPECT().Delete(gomock.Any(), 1).Return(storage.ErrSongNotFound)
+				s.EXPECT().Delete(gomock.Any(), 1, 0).Return(storage.ErrSongNotFound)
 			},
 			expectError: true,
 		},
@@ -355,7 +1083,7 @@ func TestSongService_DeleteSong(t *testing.T) {
 			name:   "Storage error",
 			songID: 1,
 			mockStorageFn: func(s *mock_storage.MockSongStorage) {
-				s.EXPECT().Delete(gomock.Any(), 1).Return(errors.New("storage error"))
+				s.EXPECT().Delete(gomock.Any(), 1, 0).Return(errors.New("storage error"))
 			},
 			expectError: true,
 		},
@@ -369,9 +1097,9 @@ func TestSongService_DeleteSong(t *testing.T) {
 			mockStorage := mock_storage.NewMockSongStorage(ctrl)
 			tc.mockStorageFn(mockStorage)
 
-			serviceInstance := service.NewSongService(mockStorage, nil)
+			serviceInstance := service.NewSongService(mockStorage, nil, normalize.New(normalize.DefaultArticles), service.Config{BatchWorkers: 4})
 
-			err := serviceInstance.DeleteSong(context.Background(), tc.songID)
+			err := serviceInstance.DeleteSong(context.Background(), tc.songID, 0)
 
 			if tc.expectError {
 				assert.Error(t, err)
@@ -382,6 +1110,321 @@ func TestSongService_DeleteSong(t *testing.T) {
 	}
 }
 
+func TestSongService_PatchSong(t *testing.T) {
+	ctx := context.Background()
+	testCases := []struct {
+		name        string
+		format      models.PatchFormat
+		patch       string
+		expected    models.SongDocument
+		expectedErr error
+	}{
+		{
+			name:     "Merge patch changes one field",
+			format:   models.MergePatch,
+			patch:    `{"song": "Hysteria"}`,
+			expected: models.SongDocument{GroupName: "Muse", SongName: "Hysteria", ReleaseDate: stringPointer("2006-07-16"), Text: stringPointer("Far away"), Link: stringPointer("http://test.link")},
+		},
+		{
+			name:     "Merge patch removes the text",
+			format:   models.MergePatch,
+			patch:    `{"text": null, "releaseDate": "2006-06-19"}`,
+			expected: models.SongDocument{GroupName: "Muse", SongName: "Starlight", ReleaseDate: stringPointer("2006-06-19"), Link: stringPointer("http://test.link")},
+		},
+		{
+			name:     "JSON Patch",
+			format:   models.JSONPatch,
+			patch:    `[{"op": "test", "path": "/song", "value": "Starlight"}, {"op": "remove", "path": "/link"}]`,
+			expected: models.SongDocument{GroupName: "Muse", SongName: "Starlight", ReleaseDate: stringPointer("2006-07-16"), Text: stringPointer("Far away")},
+		},
+		{
+			name:        "Failed test operation",
+			format:      models.JSONPatch,
+			patch:       `[{"op": "test", "path": "/song", "value": "Hysteria"}, {"op": "remove", "path": "/link"}]`,
+			expectedErr: jsonpatch.ErrConflict,
+		},
+		{
+			name:        "Malformed patch",
+			format:      models.MergePatch,
+			patch:       `{"song":`,
+			expectedErr: jsonpatch.ErrInvalidPatch,
+		},
+		{
+			name:        "Required field removed",
+			format:      models.MergePatch,
+			patch:       `{"group": null}`,
+			expectedErr: models.ErrInvalidSong,
+		},
+		{
+			name:        "Read-only field",
+			format:      models.MergePatch,
+			patch:       `{"id": 2}`,
+			expectedErr: models.ErrInvalidSong,
+		},
+		{
+			name:        "Invalid release date",
+			format:      models.MergePatch,
+			patch:       `{"releaseDate": "16.07.2006"}`,
+			expectedErr: models.ErrInvalidSong,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			songStorage := memory.NewMemStorage()
+			original := &models.Song{
+				GroupName:   "Muse",
+				SongName:    "Starlight",
+				ReleaseDate: sqlStringPointer("2006-07-16"),
+				Text:        sqlStringPointer("Far away"),
+				Link:        sqlStringPointer("http://test.link"),
+			}
+			added, err := songStorage.Create(ctx, original)
+			assert.NoError(t, err)
+
+			serviceInstance := service.NewSongService(songStorage, nil, normalize.New(normalize.DefaultArticles), service.Config{BatchWorkers: 4})
+			patched, err := serviceInstance.PatchSong(ctx, added.ID, 0, tc.format, []byte(tc.patch))
+
+			stored, getErr := songStorage.GetByID(ctx, added.ID)
+			assert.NoError(t, getErr)
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+				assert.Equal(t, models.NewSongDocument(added), models.NewSongDocument(stored), "Expected the song to be unchanged")
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, models.NewSongDocument(patched))
+			assert.Equal(t, tc.expected, models.NewSongDocument(stored))
+		})
+	}
+
+	_, err := service.NewSongService(memory.NewMemStorage(), nil, normalize.New(normalize.DefaultArticles), service.Config{BatchWorkers: 4}).PatchSong(ctx, 1, 0, models.MergePatch, []byte(`{}`))
+	assert.ErrorIs(t, err, storage.ErrSongNotFound)
+
+	songStorage := memory.NewMemStorage()
+	added, err := songStorage.Create(ctx, &models.Song{GroupName: "Muse", SongName: "Starlight"})
+	assert.NoError(t, err)
+	serviceInstance := service.NewSongService(songStorage, nil, normalize.New(normalize.DefaultArticles), service.Config{BatchWorkers: 4})
+	_, err = serviceInstance.PatchSong(ctx, added.ID, added.Version+1, models.MergePatch, []byte(`{"song": "Hysteria"}`))
+	assert.ErrorIs(t, err, storage.ErrVersionMismatch)
+	patched, err := serviceInstance.PatchSong(ctx, added.ID, added.Version, models.MergePatch, []byte(`{"song": "Hysteria"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, added.Version+1, patched.Version)
+}
+
+func TestSongService_RefreshSong(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	details := map[string]*models.SongDetailFromAPI{
+		"Starlight": {ReleaseDate: "04.09.2006", Text: "Far away"},
+		"Uprising":  {ReleaseDate: "07.09.2009", Text: "Paranoia"},
+	}
+	mockMusicAPIClient := mock_musicapi.NewMockMusicAPI(ctrl)
+	mockMusicAPIClient.EXPECT().GetSongDetailsFromAPI(gomock.Any(), "Muse", gomock.Any()).DoAndReturn(func(_ context.Context, group, song string) (*models.SongDetailFromAPI, error) {
+		if details[song] == nil {
+			return nil, errors.New("music api error")
+		}
+		return details[song], nil
+	}).AnyTimes()
+
+	serviceInstance := service.NewSongService(memory.NewMemStorage(), mockMusicAPIClient, normalize.New(normalize.DefaultArticles), service.Config{BatchWorkers: 4})
+	added, _, err := serviceInstance.AddSong(ctx, &models.AddSongRequest{GroupName: "Muse", SongName: "Starlight"}, models.ConflictFail)
+	assert.NoError(t, err)
+	patched, err := serviceInstance.PatchSong(ctx, added.ID, 0, models.MergePatch, []byte(`{"text": "Far away, edited"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{models.FieldText}, patched.EditedFields)
+
+	result, err := serviceInstance.RefreshSong(ctx, added.ID, models.RefreshUnedited)
+	assert.NoError(t, err)
+	assert.Equal(t, models.RefreshSkipped, result.Status)
+	assert.Equal(t, []models.FieldChange{{Field: models.FieldText, Old: stringPointer("Far away, edited"), New: stringPointer("Far away"), Edited: true}}, result.Changes)
+	assert.Equal(t, patched.Version, result.Song.Version)
+
+	details["Starlight"] = &models.SongDetailFromAPI{ReleaseDate: "04.09.2006", Text: "Far away, fixed", Link: "http://test.link"}
+	result, err = serviceInstance.RefreshSong(ctx, added.ID, models.RefreshNone)
+	assert.NoError(t, err)
+	assert.Equal(t, models.RefreshSkipped, result.Status)
+	assert.Len(t, result.Changes, 2)
+	assert.Equal(t, patched.Version, result.Song.Version)
+
+	result, err = serviceInstance.RefreshSong(ctx, added.ID, models.RefreshUnedited)
+	assert.NoError(t, err)
+	assert.Equal(t, models.RefreshUpdated, result.Status)
+	assert.Equal(t, []models.FieldChange{
+		{Field: models.FieldText, Old: stringPointer("Far away, edited"), New: stringPointer("Far away, fixed"), Edited: true},
+		{Field: models.FieldLink, New: stringPointer("http://test.link"), Applied: true},
+	}, result.Changes)
+	assert.Equal(t, "Far away, edited", result.Song.Text.String)
+	assert.Equal(t, "http://test.link", result.Song.Link.String)
+	assert.Equal(t, []string{models.FieldText}, result.Song.EditedFields)
+
+	result, err = serviceInstance.RefreshSong(ctx, added.ID, models.RefreshAll)
+	assert.NoError(t, err)
+	assert.Equal(t, models.RefreshUpdated, result.Status)
+	assert.Equal(t, "Far away, fixed", result.Song.Text.String)
+	assert.Empty(t, result.Song.EditedFields)
+
+	// Details the external API stops returning are kept.
+	details["Starlight"] = &models.SongDetailFromAPI{Text: "Far away, fixed"}
+	result, err = serviceInstance.RefreshSong(ctx, added.ID, models.RefreshAll)
+	assert.NoError(t, err)
+	assert.Equal(t, models.RefreshUnchanged, result.Status)
+	assert.Empty(t, result.Changes)
+	assert.Equal(t, "2006-09-04", result.Song.ReleaseDate.String)
+
+	_, err = serviceInstance.RefreshSong(ctx, added.ID+100, models.RefreshAll)
+	assert.ErrorIs(t, err, storage.ErrSongNotFound)
+
+	uprising, _, err := serviceInstance.AddSong(ctx, &models.AddSongRequest{GroupName: "Muse", SongName: "Uprising"}, models.ConflictFail)
+	assert.NoError(t, err)
+	details["Uprising"] = nil
+	_, err = serviceInstance.RefreshSong(ctx, uprising.ID, models.RefreshAll)
+	assert.ErrorIs(t, err, service.ErrExternalAPI)
+
+	details["Starlight"] = &models.SongDetailFromAPI{Text: "Far away, again"}
+	batch, err := serviceInstance.RefreshSongs(ctx, &models.SongFilter{GroupName: stringPointer("muse")}, models.RefreshUnedited)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, batch.Updated)
+	assert.Equal(t, 1, batch.Failed)
+	if assert.Len(t, batch.Items, 2) {
+		assert.Equal(t, added.ID, batch.Items[0].ID)
+		assert.Equal(t, "Far away, again", batch.Items[0].Song.Text.String)
+		assert.Equal(t, models.RefreshFailed, batch.Items[1].Status)
+		assert.Equal(t, "failed to fetch song details", batch.Items[1].Error)
+	}
+}
+
+func TestSongService_Sources(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	details := &models.SongDetailFromAPI{
+		ReleaseDate: "04.09.2006",
+		Text:        "Far away",
+		Sources:     map[string]string{models.FieldReleaseDate: "api", models.FieldText: "lyrics"},
+	}
+	mockMusicAPIClient := mock_musicapi.NewMockMusicAPI(ctrl)
+	mockMusicAPIClient.EXPECT().GetSongDetailsFromAPI(gomock.Any(), "Muse", "Starlight").DoAndReturn(func(context.Context, string, string) (*models.SongDetailFromAPI, error) {
+		return details, nil
+	}).AnyTimes()
+
+	serviceInstance := service.NewSongService(memory.NewMemStorage(), mockMusicAPIClient, normalize.New(normalize.DefaultArticles), service.Config{})
+	added, _, err := serviceInstance.AddSong(ctx, &models.AddSongRequest{GroupName: "Muse", SongName: "Starlight"}, models.ConflictFail)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{models.FieldReleaseDate: "api", models.FieldText: "lyrics"}, added.Sources)
+
+	// Edited details lose their source.
+	patched, err := serviceInstance.PatchSong(ctx, added.ID, 0, models.MergePatch, []byte(`{"text": "Far away, edited"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{models.FieldReleaseDate: "api"}, patched.Sources)
+
+	details = &models.SongDetailFromAPI{
+		ReleaseDate: "04.09.2006",
+		Text:        "Far away",
+		Link:        "https://example.com",
+		Sources:     map[string]string{models.FieldReleaseDate: "catalog", models.FieldText: "lyrics", models.FieldLink: "catalog"},
+	}
+	result, err := serviceInstance.RefreshSong(ctx, added.ID, models.RefreshAll)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{models.FieldReleaseDate: "api", models.FieldText: "lyrics", models.FieldLink: "catalog"}, result.Song.Sources,
+		"Expected the sources of the changes applied, and only them, to be updated")
+}
+
+func TestSongService_PrimaryProviderDown(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// The API is down, the catalog still knows the songs: lookups are partial.
+	ctx := context.Background()
+	api := mock_musicapi.NewMockMusicAPI(ctrl)
+	api.EXPECT().GetSongDetailsFromAPI(gomock.Any(), "Muse", gomock.Any()).Return(nil, musicapi.ErrCircuitOpen).AnyTimes()
+	catalog := mock_musicapi.NewMockMusicAPI(ctrl)
+	catalog.EXPECT().GetSongDetailsFromAPI(gomock.Any(), "Muse", gomock.Any()).Return(&models.SongDetailFromAPI{Link: "https://example.com"}, nil).AnyTimes()
+	chain := musicapi.NewChain(musicapi.Provider{Name: musicapi.ProviderAPI, MusicAPI: api}, musicapi.Provider{Name: musicapi.ProviderCatalog, MusicAPI: catalog})
+
+	t.Run("Fallback", func(t *testing.T) {
+		serviceInstance := service.NewSongService(memory.NewMemStorage(), chain, normalize.New(normalize.DefaultArticles), service.Config{Enrichment: models.EnrichFallback})
+		added, _, err := serviceInstance.AddSong(ctx, &models.AddSongRequest{GroupName: "Muse", SongName: "Starlight"}, models.ConflictFail)
+		assert.NoError(t, err)
+		assert.Equal(t, models.EnrichmentPending, added.EnrichmentStatus)
+		assert.False(t, added.Link.Valid)
+		_, err = serviceInstance.GetEnrichmentJob(ctx, added.ID)
+		assert.NoError(t, err)
+
+		_, err = serviceInstance.RefreshSong(ctx, added.ID, models.RefreshAll)
+		assert.ErrorIs(t, err, service.ErrExternalAPI)
+	})
+
+	t.Run("Async", func(t *testing.T) {
+		songStorage := memory.NewMemStorage()
+		serviceInstance := service.NewSongService(songStorage, chain, normalize.New(normalize.DefaultArticles), service.Config{Enrichment: models.EnrichAsync})
+		added, _, err := serviceInstance.AddSong(ctx, &models.AddSongRequest{GroupName: "Muse", SongName: "Uprising"}, models.ConflictFail)
+		assert.NoError(t, err)
+
+		worker := service.NewEnrichmentWorker(songStorage, songStorage.(storage.EnrichmentQueue), chain, service.EnrichmentWorkerConfig{MaxAttempts: 2, Backoff: time.Nanosecond})
+		processed, err := worker.ProcessJobs(ctx, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, processed)
+
+		job, err := serviceInstance.GetEnrichmentJob(ctx, added.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, models.JobPending, job.Status, "Expected the partial lookup to be retried")
+		assert.Equal(t, 1, job.Attempts)
+		pending, err := songStorage.GetByID(ctx, added.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, models.EnrichmentPending, pending.EnrichmentStatus)
+		assert.False(t, pending.Link.Valid)
+	})
+}
+
+func TestSongService_MemoryStorage(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockMusicAPIClient := mock_musicapi.NewMockMusicAPI(ctrl)
+	mockMusicAPIClient.EXPECT().GetSongDetailsFromAPI(gomock.Any(), gomock.Any(), gomock.Any()).Return(&models.SongDetailFromAPI{Text: "Verse1\n\nVerse2", ReleaseDate: "16.07.2006", Link: "http://test.link"}, nil).AnyTimes()
+
+	serviceInstance := service.NewSongService(memory.NewMemStorage(), mockMusicAPIClient, normalize.New(normalize.DefaultArticles), service.Config{BatchWorkers: 4})
+
+	added, created, err := serviceInstance.AddSong(ctx, &models.AddSongRequest{GroupName: "Muse", SongName: "Supermassive Black Hole"}, models.ConflictFail)
+	assert.NoError(t, err)
+	assert.True(t, created)
+	assert.Equal(t, "2006-07-16", added.ReleaseDate.String)
+
+	_, _, err = serviceInstance.AddSong(ctx, &models.AddSongRequest{GroupName: "Muse", SongName: "Supermassive Black Hole"}, models.ConflictFail)
+	var existsErr *service.SongExistsError
+	if assert.ErrorAs(t, err, &existsErr, "Expected duplicate song to be rejected") {
+		assert.Equal(t, added.ID, existsErr.Song.ID)
+	}
+	assert.ErrorIs(t, err, storage.ErrSongAlreadyExists)
+
+	existing, created, err := serviceInstance.AddSong(ctx, &models.AddSongRequest{GroupName: "Muse", SongName: "Supermassive Black Hole"}, models.ConflictReturn)
+	assert.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, added.ID, existing.ID)
+
+	songs, err := serviceInstance.GetSongs(ctx, &models.SongFilter{GroupName: stringPointer("muse")}, models.NewPagination(1, 10))
+	assert.NoError(t, err)
+	assert.Len(t, songs.Items, 1)
+	assert.Equal(t, 1, songs.TotalItems)
+
+	song, err := serviceInstance.GetSongText(ctx, added.ID, models.NewPagination(2, 1))
+	assert.NoError(t, err)
+	assert.Equal(t, "Verse2", song.Text.String)
+
+	added.SongName = "Starlight"
+	updated, err := serviceInstance.UpdateSong(ctx, added)
+	assert.NoError(t, err)
+	assert.Equal(t, "Starlight", updated.SongName)
+
+	assert.NoError(t, serviceInstance.DeleteSong(ctx, added.ID, 0))
+	assert.ErrorIs(t, serviceInstance.DeleteSong(ctx, added.ID, 0), storage.ErrSongNotFound)
+}
+
 func stringPointer(s string) *string {
 	return &s
 }
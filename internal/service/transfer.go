@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"go.uber.org/zap"
+
+	"songlibrary/internal/lib/logger/utils"
+	"songlibrary/internal/lib/songfile"
+	"songlibrary/internal/models"
+)
+
+// exportPageSize is the number of songs read from storage at a time by ExportSongs.
+const exportPageSize = 500
+
+func (s *songService) ExportSongs(ctx context.Context, filter *models.SongFilter, fn func(song *models.Song) error) error {
+	utils.Logger.Debug("SongService.ExportSongs", zap.Any("filter", filter))
+
+	// Songs are paged by id, so that songs added during the export neither shift nor repeat others.
+	pageFilter := models.SongFilter{}
+	if filter != nil {
+		pageFilter = *filter
+		pageFilter.Sort = nil
+	}
+	filter = &pageFilter
+	pagination := &models.Pagination{Page: 1, PageSize: exportPageSize}
+	exported := 0
+	for {
+		songs, err := s.storage.List(ctx, filter, pagination)
+		if err != nil {
+			utils.Logger.Error("SongService.ExportSongs - storage.List failed", zap.Error(err), zap.Int("exported", exported))
+			return fmt.Errorf("SongService.ExportSongs - storage.List failed: %w", err)
+		}
+		for i := range songs {
+			if err := fn(&songs[i]); err != nil {
+				return err
+			}
+		}
+		exported += len(songs)
+		if len(songs) < exportPageSize {
+			break
+		}
+		pagination.After = models.CursorAfter(songs[len(songs)-1], filter.Sort)
+	}
+	utils.Logger.Info("SongService.ExportSongs - songs exported", zap.Int("exported", exported))
+	return nil
+}
+
+func (s *songService) ImportSongs(ctx context.Context, reader songfile.Reader, skipEnrichment bool) (*models.ImportResult, error) {
+	utils.Logger.Debug("SongService.ImportSongs", zap.Bool("skipEnrichment", skipEnrichment))
+
+	result := &models.ImportResult{Errors: []models.ImportError{}}
+	var docs []models.SongDocument
+	var rows []int
+	addRows := func() error {
+		if len(docs) == 0 {
+			return nil
+		}
+		batch, err := s.addBatch(ctx, docs, skipEnrichment)
+		if err != nil {
+			return err
+		}
+		for i, item := range batch.Items {
+			switch item.Status {
+			case models.BatchCreated:
+				result.Created++
+			case models.BatchDuplicate:
+				result.Duplicates++
+			case models.BatchFailed:
+				result.AddError(rows[i], item.Error)
+			}
+		}
+		docs, rows = docs[:0], rows[:0]
+		return nil
+	}
+
+	read := 0
+	for {
+		doc, row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		var rowErr *songfile.RowError
+		if errors.As(err, &rowErr) {
+			read++
+			result.AddError(rowErr.Row, rowErr.Err.Error())
+			continue
+		}
+		if err != nil {
+			if errors.Is(err, songfile.ErrInvalidFile) {
+				if read == 0 {
+					return nil, err
+				}
+				result.Stopped = err.Error()
+				break
+			}
+			utils.Logger.Error("SongService.ImportSongs - read failed", zap.Error(err), zap.Int("read", read))
+			return nil, fmt.Errorf("SongService.ImportSongs - read failed: %w", err)
+		}
+		read++
+
+		if err := doc.Validate(); err != nil {
+			result.AddError(row, err.Error())
+			continue
+		}
+		docs = append(docs, doc)
+		rows = append(rows, row)
+		if len(docs) == models.MaxBatchSize {
+			if err := addRows(); err != nil {
+				utils.Logger.Error("SongService.ImportSongs - storage.Create failed", zap.Error(err), zap.Int("read", read))
+				return nil, fmt.Errorf("SongService.ImportSongs - storage.Create failed: %w", err)
+			}
+		}
+	}
+	if err := addRows(); err != nil {
+		utils.Logger.Error("SongService.ImportSongs - storage.Create failed", zap.Error(err), zap.Int("read", read))
+		return nil, fmt.Errorf("SongService.ImportSongs - storage.Create failed: %w", err)
+	}
+
+	// Rows failing validation are reported before the rows of their batch.
+	result.SortErrors()
+	utils.Logger.Info("SongService.ImportSongs - songs imported", zap.Int("created", result.Created), zap.Int("duplicates", result.Duplicates), zap.Int("failed", result.Failed), zap.String("stopped", result.Stopped))
+	return result, nil
+}
@@ -0,0 +1,30 @@
+package service
+
+import (
+	"context"
+	"sync"
+)
+
+// forEach calls fn with 0..n-1, each call holding one of the slots of workers, so
+// that all the callers sharing workers make at most cap(workers) calls at a time.
+// It returns once all the calls returned. The indexes whose slot is not taken yet
+// when ctx is done are skipped.
+func forEach(ctx context.Context, workers chan struct{}, n int, fn func(i int)) {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for i := range n {
+		select {
+		case workers <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-workers
+				wg.Done()
+			}()
+			fn(i)
+		}()
+	}
+}
@@ -0,0 +1,143 @@
+package memory
+
+import (
+	"context"
+	"maps"
+	"sort"
+	"time"
+
+	"songlibrary/internal/models"
+	"songlibrary/internal/storage"
+)
+
+func (s *MemStorage) EnqueueEnrichment(ctx context.Context, songID int) error {
+	unlock := s.lock()
+	defer unlock()
+
+	song, ok := s.state.songs[songID]
+	if !ok {
+		return storage.ErrSongNotFound
+	}
+	now := time.Now()
+	if song.EnrichmentStatus != models.EnrichmentPending {
+		song.EnrichmentStatus = models.EnrichmentPending
+		song.UpdatedAt = now
+		song.Version++
+		s.state.songs[songID] = song
+	}
+
+	job, ok := s.state.jobs[songID]
+	switch {
+	case !ok:
+		job = models.EnrichmentJob{SongID: songID, CreatedAt: now}
+	case job.Status != models.JobDone && job.Status != models.JobDead:
+		return nil
+	}
+	job.Status = models.JobPending
+	job.Attempts = 0
+	job.RunAt = now
+	job.LastError = ""
+	job.UpdatedAt = now
+	s.state.jobs[songID] = job
+	return nil
+}
+
+func (s *MemStorage) ClaimEnrichmentJobs(ctx context.Context, limit int, lease time.Duration) ([]models.EnrichmentJob, error) {
+	unlock := s.lock()
+	defer unlock()
+
+	now := time.Now()
+	var due []models.EnrichmentJob
+	for _, job := range s.state.jobs {
+		if (job.Status == models.JobPending || job.Status == models.JobRunning) && !job.RunAt.After(now) {
+			due = append(due, job)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].RunAt.Before(due[j].RunAt) })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	for i := range due {
+		due[i].Status = models.JobRunning
+		due[i].Attempts++
+		due[i].RunAt = now.Add(lease)
+		due[i].UpdatedAt = now
+		s.state.jobs[due[i].SongID] = due[i]
+	}
+	return due, nil
+}
+
+func (s *MemStorage) CompleteEnrichmentJob(ctx context.Context, songID, attempts int, details *models.Song) error {
+	unlock := s.lock()
+	defer unlock()
+
+	if !s.finishJob(songID, attempts, models.JobDone, "") {
+		return nil
+	}
+	song := s.state.songs[songID]
+	// Sources is shared with the copies of the song returned before.
+	song.Sources = maps.Clone(song.Sources)
+	song.Enrich(details)
+	song.EnrichmentStatus = models.EnrichmentComplete
+	song.UpdatedAt = time.Now()
+	song.Version++
+	s.state.songs[songID] = song
+	return nil
+}
+
+func (s *MemStorage) RetryEnrichmentJob(ctx context.Context, songID, attempts int, delay time.Duration, lastError string) error {
+	unlock := s.lock()
+	defer unlock()
+
+	job, ok := s.state.jobs[songID]
+	if !ok || job.Status != models.JobRunning || job.Attempts != attempts {
+		return nil
+	}
+	now := time.Now()
+	job.Status = models.JobPending
+	job.RunAt = now.Add(delay)
+	job.LastError = lastError
+	job.UpdatedAt = now
+	s.state.jobs[songID] = job
+	return nil
+}
+
+func (s *MemStorage) FailEnrichmentJob(ctx context.Context, songID, attempts int, lastError string) error {
+	unlock := s.lock()
+	defer unlock()
+
+	if !s.finishJob(songID, attempts, models.JobDead, lastError) {
+		return nil
+	}
+	song := s.state.songs[songID]
+	song.EnrichmentStatus = models.EnrichmentFailed
+	song.UpdatedAt = time.Now()
+	song.Version++
+	s.state.songs[songID] = song
+	return nil
+}
+
+func (s *MemStorage) GetEnrichmentJob(ctx context.Context, songID int) (*models.EnrichmentJob, error) {
+	unlock := s.rlock()
+	defer unlock()
+
+	job, ok := s.state.jobs[songID]
+	if !ok {
+		return nil, storage.ErrJobNotFound
+	}
+	return &job, nil
+}
+
+// finishJob sets the status of a running job and reports whether there was one.
+func (s *MemStorage) finishJob(songID, attempts int, status models.JobStatus, lastError string) bool {
+	job, ok := s.state.jobs[songID]
+	if !ok || job.Status != models.JobRunning || job.Attempts != attempts {
+		return false
+	}
+	job.Status = status
+	job.LastError = lastError
+	job.UpdatedAt = time.Now()
+	s.state.jobs[songID] = job
+	return true
+}
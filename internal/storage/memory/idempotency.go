@@ -0,0 +1,69 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"songlibrary/internal/models"
+)
+
+// idempotencyKeys is shared by a MemStorage and the storages its transactions hand
+// out, as the records are not part of the transactional state.
+type idempotencyKeys struct {
+	mu      sync.Mutex
+	records map[string]models.IdempotencyRecord
+}
+
+func (s *MemStorage) ReserveIdempotencyKey(ctx context.Context, key, fingerprint string, ttl time.Duration) (*models.IdempotencyRecord, error) {
+	s.idempotency.mu.Lock()
+	defer s.idempotency.mu.Unlock()
+
+	now := time.Now()
+	if record, ok := s.idempotency.records[key]; ok && record.ExpiresAt.After(now) {
+		return &record, nil
+	}
+	s.idempotency.records[key] = models.IdempotencyRecord{Key: key, Fingerprint: fingerprint, ExpiresAt: now.Add(ttl)}
+	return nil, nil
+}
+
+func (s *MemStorage) CompleteIdempotencyKey(ctx context.Context, record *models.IdempotencyRecord) error {
+	s.idempotency.mu.Lock()
+	defer s.idempotency.mu.Unlock()
+
+	stored, ok := s.idempotency.records[record.Key]
+	if !ok {
+		return nil
+	}
+	stored.Fingerprint = record.Fingerprint
+	stored.StatusCode = record.StatusCode
+	stored.Header = record.Header
+	stored.Body = record.Body
+	s.idempotency.records[record.Key] = stored
+	return nil
+}
+
+func (s *MemStorage) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	s.idempotency.mu.Lock()
+	defer s.idempotency.mu.Unlock()
+
+	if record, ok := s.idempotency.records[key]; ok && record.StatusCode == 0 {
+		delete(s.idempotency.records, key)
+	}
+	return nil
+}
+
+func (s *MemStorage) DeleteExpiredIdempotencyKeys(ctx context.Context) (int, error) {
+	s.idempotency.mu.Lock()
+	defer s.idempotency.mu.Unlock()
+
+	now := time.Now()
+	deleted := 0
+	for key, record := range s.idempotency.records {
+		if !record.ExpiresAt.After(now) {
+			delete(s.idempotency.records, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
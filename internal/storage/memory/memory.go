@@ -0,0 +1,423 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"songlibrary/internal/lib/textsearch"
+	"songlibrary/internal/lib/trigram"
+	"songlibrary/internal/models"
+	"songlibrary/internal/storage"
+)
+
+type state struct {
+	songs map[int]models.Song
+	// jobs holds the enrichment jobs by song id.
+	jobs   map[int]models.EnrichmentJob
+	nextID int
+}
+
+func (st *state) clone() *state {
+	songs := make(map[int]models.Song, len(st.songs))
+	for id, song := range st.songs {
+		songs[id] = song
+	}
+	jobs := make(map[int]models.EnrichmentJob, len(st.jobs))
+	for id, job := range st.jobs {
+		jobs[id] = job
+	}
+	return &state{songs: songs, jobs: jobs, nextID: st.nextID}
+}
+
+// MemStorage keeps songs in process memory. It mirrors the semantics of the
+// Postgres schema (serial ids, the unique_song_group constraint, ILIKE filters, ordering)
+// so it can stand in for PgStorage in local development and tests.
+type MemStorage struct {
+	// mu is nil for storages handed out by WithTx: the owning storage holds
+	// the write lock for the whole lifetime of the transaction.
+	mu          *sync.RWMutex
+	state       *state
+	idempotency *idempotencyKeys
+}
+
+func NewMemStorage() storage.SongStorage {
+	return &MemStorage{
+		mu:          &sync.RWMutex{},
+		state:       &state{songs: make(map[int]models.Song), jobs: make(map[int]models.EnrichmentJob), nextID: 1},
+		idempotency: &idempotencyKeys{records: make(map[string]models.IdempotencyRecord)},
+	}
+}
+
+func (s *MemStorage) lock() func() {
+	if s.mu == nil {
+		return func() {}
+	}
+	s.mu.Lock()
+	return s.mu.Unlock
+}
+
+func (s *MemStorage) rlock() func() {
+	if s.mu == nil {
+		return func() {}
+	}
+	s.mu.RLock()
+	return s.mu.RUnlock
+}
+
+func (s *MemStorage) WithTx(ctx context.Context, fn func(tx storage.SongStorage) error) error {
+	unlock := s.lock()
+	defer unlock()
+
+	snapshot := s.state.clone()
+	if err := fn(&MemStorage{state: snapshot, idempotency: s.idempotency}); err != nil {
+		return err
+	}
+	*s.state = *snapshot
+	return nil
+}
+
+func (s *MemStorage) Create(ctx context.Context, song *models.Song) (*models.Song, error) {
+	unlock := s.lock()
+	defer unlock()
+
+	if s.conflicts(song, 0) {
+		return nil, storage.ErrSongAlreadyExists
+	}
+
+	now := time.Now()
+	addedSong := models.Song{
+		ID:               s.state.nextID,
+		GroupName:        song.GroupName,
+		SongName:         song.SongName,
+		ReleaseDate:      song.ReleaseDate,
+		Text:             song.Text,
+		Link:             song.Link,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+		Version:          1,
+		EnrichmentStatus: song.EnrichmentStatus,
+		EditedFields:     slices.Clone(song.EditedFields),
+		Sources:          maps.Clone(song.Sources),
+		GroupKey:         song.GroupKey,
+		SongKey:          song.SongKey,
+	}
+	if addedSong.EnrichmentStatus == "" {
+		addedSong.EnrichmentStatus = models.EnrichmentComplete
+	}
+	s.state.songs[addedSong.ID] = addedSong
+	s.state.nextID++
+
+	return &addedSong, nil
+}
+
+func (s *MemStorage) GetByID(ctx context.Context, id int) (*models.Song, error) {
+	unlock := s.rlock()
+	defer unlock()
+
+	song, ok := s.state.songs[id]
+	if !ok {
+		return nil, storage.ErrSongNotFound
+	}
+	return &song, nil
+}
+
+func (s *MemStorage) GetByKey(ctx context.Context, groupKey, songKey string) (*models.Song, error) {
+	unlock := s.rlock()
+	defer unlock()
+
+	for _, song := range s.state.songs {
+		if song.GroupKey != "" && song.GroupKey == groupKey && song.SongKey == songKey {
+			return &song, nil
+		}
+	}
+	return nil, storage.ErrSongNotFound
+}
+
+func (s *MemStorage) List(ctx context.Context, filter *models.SongFilter, pagination *models.Pagination) ([]models.Song, error) {
+	unlock := s.rlock()
+	defer unlock()
+
+	var order models.Sort
+	if filter != nil {
+		order = filter.Sort
+	}
+	keys := order.WithTieBreaker()
+
+	songs := s.filter(filter)
+	if filter.IsFuzzy() {
+		if pagination.After != nil {
+			return nil, fmt.Errorf("MemStorage.List - keyset pagination is not supported with fuzzy matching")
+		}
+		sort.Slice(songs, func(i, j int) bool {
+			if *songs[i].Similarity != *songs[j].Similarity {
+				return *songs[i].Similarity > *songs[j].Similarity
+			}
+			return compareSongs(songs[i], songs[j], keys) < 0
+		})
+	} else {
+		sort.Slice(songs, func(i, j int) bool { return compareSongs(songs[i], songs[j], keys) < 0 })
+	}
+	if pagination.After != nil {
+		after, err := cursorSong(keys, pagination.After)
+		if err != nil {
+			return nil, fmt.Errorf("MemStorage.List - invalid cursor: %w", err)
+		}
+		start := sort.Search(len(songs), func(i int) bool { return compareSongs(songs[i], after, keys) > 0 })
+		songs = songs[start:]
+	}
+
+	offset := pagination.GetOffset()
+	if offset >= len(songs) {
+		return nil, nil
+	}
+	end := offset + pagination.GetLimit()
+	if end > len(songs) {
+		end = len(songs)
+	}
+	return songs[offset:end], nil
+}
+
+func (s *MemStorage) Count(ctx context.Context, filter *models.SongFilter) (int, error) {
+	unlock := s.rlock()
+	defer unlock()
+
+	return len(s.filter(filter)), nil
+}
+
+func (s *MemStorage) Suggest(ctx context.Context, filter *models.SongFilter) ([]models.NameSuggestion, error) {
+	unlock := s.rlock()
+	defer unlock()
+
+	var groupNames, songNames []string
+	for _, song := range s.state.songs {
+		groupNames = append(groupNames, song.GroupName)
+		songNames = append(songNames, song.SongName)
+	}
+
+	var suggestions []models.NameSuggestion
+	if filter.GroupName != nil && *filter.GroupName != "" {
+		suggestions = append(suggestions, trigram.Suggest("group", groupNames, *filter.GroupName)...)
+	}
+	if filter.SongName != nil && *filter.SongName != "" {
+		suggestions = append(suggestions, trigram.Suggest("song", songNames, *filter.SongName)...)
+	}
+	return suggestions, nil
+}
+
+func (s *MemStorage) Search(ctx context.Context, query string, pagination *models.Pagination) ([]models.SongSearchResult, int, error) {
+	unlock := s.rlock()
+	defer unlock()
+
+	results, total := textsearch.Search(s.filter(nil), query, pagination)
+	return results, total, nil
+}
+
+// filter returns the songs matching filter in no particular order.
+func (s *MemStorage) filter(filter *models.SongFilter) []models.Song {
+	var songs []models.Song
+	if filter == nil {
+		for _, song := range s.state.songs {
+			songs = append(songs, song)
+		}
+		return songs
+	}
+
+	if filter.IsFuzzy() {
+		for _, song := range s.state.songs {
+			similarity, ok := trigram.Match(song, filter)
+			if !ok || !matchesRanges(song, filter) {
+				continue
+			}
+			song.Similarity = &similarity
+			songs = append(songs, song)
+		}
+		return songs
+	}
+
+	var groupPattern, songPattern *regexp.Regexp
+	if filter.GroupName != nil && *filter.GroupName != "" {
+		groupPattern = ilikePattern(filter.Match.LikePattern(*filter.GroupName))
+	}
+	if filter.SongName != nil && *filter.SongName != "" {
+		songPattern = ilikePattern(filter.Match.LikePattern(*filter.SongName))
+	}
+
+	for _, song := range s.state.songs {
+		if groupPattern != nil && !groupPattern.MatchString(song.GroupName) {
+			continue
+		}
+		if songPattern != nil && !songPattern.MatchString(song.SongName) {
+			continue
+		}
+		if !matchesRanges(song, filter) {
+			continue
+		}
+		songs = append(songs, song)
+	}
+	return songs
+}
+
+// matchesRanges checks the non-name conditions of filter, with the SQL
+// semantics of NULL never satisfying a comparison.
+func matchesRanges(song models.Song, filter *models.SongFilter) bool {
+	if filter.ReleaseDateFrom != nil && (!song.ReleaseDate.Valid || song.ReleaseDate.String < *filter.ReleaseDateFrom) {
+		return false
+	}
+	if filter.ReleaseDateTo != nil && (!song.ReleaseDate.Valid || song.ReleaseDate.String > *filter.ReleaseDateTo) {
+		return false
+	}
+	if filter.HasText != nil && *filter.HasText != (song.Text.String != "") {
+		return false
+	}
+	if filter.HasLink != nil && *filter.HasLink != (song.Link.String != "") {
+		return false
+	}
+	if filter.CreatedFrom != nil && song.CreatedAt.Before(*filter.CreatedFrom) {
+		return false
+	}
+	if filter.CreatedTo != nil && song.CreatedAt.After(*filter.CreatedTo) {
+		return false
+	}
+	if filter.UpdatedFrom != nil && song.UpdatedAt.Before(*filter.UpdatedFrom) {
+		return false
+	}
+	if filter.UpdatedTo != nil && song.UpdatedAt.After(*filter.UpdatedTo) {
+		return false
+	}
+	return true
+}
+
+func (s *MemStorage) Update(ctx context.Context, song *models.Song) (*models.Song, error) {
+	unlock := s.lock()
+	defer unlock()
+
+	existing, ok := s.state.songs[song.ID]
+	if !ok {
+		return nil, storage.ErrSongNotFound
+	}
+	if song.Version != 0 && song.Version != existing.Version {
+		return nil, storage.ErrVersionMismatch
+	}
+	keyed := *song
+	keyed.GroupKey = cmp.Or(song.GroupKey, existing.GroupKey)
+	keyed.SongKey = cmp.Or(song.SongKey, existing.SongKey)
+	if s.conflicts(&keyed, song.ID) {
+		return nil, storage.ErrSongAlreadyExists
+	}
+
+	existing.GroupName = song.GroupName
+	existing.SongName = song.SongName
+	existing.ReleaseDate = song.ReleaseDate
+	existing.Text = song.Text
+	existing.Link = song.Link
+	existing.GroupKey = keyed.GroupKey
+	existing.SongKey = keyed.SongKey
+	existing.EditedFields = slices.Clone(song.EditedFields)
+	existing.Sources = maps.Clone(song.Sources)
+	existing.UpdatedAt = time.Now()
+	existing.Version++
+	s.state.songs[song.ID] = existing
+
+	return &existing, nil
+}
+
+func (s *MemStorage) Delete(ctx context.Context, id int, version int) error {
+	unlock := s.lock()
+	defer unlock()
+
+	existing, ok := s.state.songs[id]
+	if !ok {
+		return storage.ErrSongNotFound
+	}
+	if version != 0 && version != existing.Version {
+		return storage.ErrVersionMismatch
+	}
+	delete(s.state.songs, id)
+	delete(s.state.jobs, id)
+	return nil
+}
+
+func (s *MemStorage) Identities(ctx context.Context) ([]models.SongIdentity, error) {
+	unlock := s.rlock()
+	defer unlock()
+
+	identities := make([]models.SongIdentity, 0, len(s.state.songs))
+	for _, song := range s.state.songs {
+		identities = append(identities, models.SongIdentity{
+			ID:        song.ID,
+			GroupName: song.GroupName,
+			SongName:  song.SongName,
+			GroupKey:  song.GroupKey,
+			SongKey:   song.SongKey,
+		})
+	}
+	sort.Slice(identities, func(i, j int) bool { return identities[i].ID < identities[j].ID })
+	return identities, nil
+}
+
+func (s *MemStorage) SetKeys(ctx context.Context, id int, groupKey, songKey string) error {
+	unlock := s.lock()
+	defer unlock()
+
+	song, ok := s.state.songs[id]
+	if !ok {
+		return storage.ErrSongNotFound
+	}
+	song.GroupKey = groupKey
+	song.SongKey = songKey
+	if s.conflicts(&song, id) {
+		return storage.ErrSongAlreadyExists
+	}
+	s.state.songs[id] = song
+	return nil
+}
+
+// conflicts reports whether a song other than exceptID has the names of song or,
+// as NULL keys never conflict, its non-empty keys.
+func (s *MemStorage) conflicts(song *models.Song, exceptID int) bool {
+	for id, other := range s.state.songs {
+		if id == exceptID {
+			continue
+		}
+		if other.GroupName == song.GroupName && other.SongName == song.SongName {
+			return true
+		}
+		if song.GroupKey != "" && other.GroupKey == song.GroupKey && other.SongKey == song.SongKey {
+			return true
+		}
+	}
+	return false
+}
+
+// ilikePattern compiles a SQL ILIKE pattern: '%' matches any sequence,
+// '_' matches a single character and '\' escapes the next character.
+func ilikePattern(pattern string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("(?is)^")
+	escaped := false
+	for _, r := range pattern {
+		switch {
+		case escaped:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '%':
+			b.WriteString(".*")
+		case r == '_':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
@@ -0,0 +1,15 @@
+package memory_test
+
+import (
+	"testing"
+
+	"songlibrary/internal/storage"
+	"songlibrary/internal/storage/memory"
+	"songlibrary/internal/storage/storagetest"
+)
+
+func TestMemStorage(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.SongStorage {
+		return memory.NewMemStorage()
+	})
+}
@@ -0,0 +1,71 @@
+package memory
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"songlibrary/internal/models"
+)
+
+// compareSongs orders songs the way the SQL storages do: by the sort keys and
+// then by id, with NULL release dates first.
+func compareSongs(a, b models.Song, keys models.Sort) int {
+	for _, key := range keys {
+		c := compareField(a, b, key.Field)
+		if key.Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+func compareField(a, b models.Song, field models.SortField) int {
+	switch field {
+	case models.SortByID:
+		return a.ID - b.ID
+	case models.SortByGroup:
+		return models.CompareNames(a.GroupName, b.GroupName)
+	case models.SortBySong:
+		return models.CompareNames(a.SongName, b.SongName)
+	case models.SortByReleaseDate:
+		return strings.Compare(a.ReleaseDate.String, b.ReleaseDate.String)
+	case models.SortByCreatedAt:
+		return a.CreatedAt.Compare(b.CreatedAt)
+	case models.SortByUpdatedAt:
+		return a.UpdatedAt.Compare(b.UpdatedAt)
+	}
+	return 0
+}
+
+// cursorSong returns a song holding the cursor position, to be compared with compareSongs.
+func cursorSong(keys models.Sort, cursor *models.Cursor) (models.Song, error) {
+	song := models.Song{ID: cursor.ID}
+	for i, key := range keys {
+		if key.Field == models.SortByID {
+			continue
+		}
+		value, err := key.Field.ParseValue(cursor.Values[i])
+		if err != nil {
+			return song, err
+		}
+		switch key.Field {
+		case models.SortByGroup:
+			song.GroupName = value.(string)
+		case models.SortBySong:
+			song.SongName = value.(string)
+		case models.SortByReleaseDate:
+			if value != nil {
+				song.ReleaseDate = sql.NullString{String: value.(string), Valid: true}
+			}
+		case models.SortByCreatedAt:
+			song.CreatedAt = value.(time.Time)
+		case models.SortByUpdatedAt:
+			song.UpdatedAt = value.(time.Time)
+		}
+	}
+	return song, nil
+}
@@ -6,9 +6,9 @@ package mocks
 
 import (
 	context "context"
-	sql "database/sql"
 	reflect "reflect"
 	models "songlibrary/internal/models"
+	storage "songlibrary/internal/storage"
 
 	gomock "github.com/golang/mock/gomock"
 )
@@ -36,48 +36,48 @@ func (m *MockSongStorage) EXPECT() *MockSongStorageMockRecorder {
 	return m.recorder
 }
 
-// BeginTx mocks base method.
-func (m *MockSongStorage) BeginTx(arg0 context.Context) (*sql.Tx, error) {
+// Count mocks base method.
+func (m *MockSongStorage) Count(arg0 context.Context, arg1 *models.SongFilter) (int, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "BeginTx", arg0)
-	ret0, _ := ret[0].(*sql.Tx)
+	ret := m.ctrl.Call(m, "Count", arg0, arg1)
+	ret0, _ := ret[0].(int)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// BeginTx indicates an expected call of BeginTx.
-func (mr *MockSongStorageMockRecorder) BeginTx(arg0 interface{}) *gomock.Call {
+// Count indicates an expected call of Count.
+func (mr *MockSongStorageMockRecorder) Count(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BeginTx", reflect.TypeOf((*MockSongStorage)(nil).BeginTx), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Count", reflect.TypeOf((*MockSongStorage)(nil).Count), arg0, arg1)
 }
 
 // Create mocks base method.
-func (m *MockSongStorage) Create(arg0 context.Context, arg1 *models.Song, arg2 *sql.Tx) (*models.Song, error) {
+func (m *MockSongStorage) Create(arg0 context.Context, arg1 *models.Song) (*models.Song, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Create", arg0, arg1, arg2)
+	ret := m.ctrl.Call(m, "Create", arg0, arg1)
 	ret0, _ := ret[0].(*models.Song)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Create indicates an expected call of Create.
-func (mr *MockSongStorageMockRecorder) Create(arg0, arg1, arg2 interface{}) *gomock.Call {
+func (mr *MockSongStorageMockRecorder) Create(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockSongStorage)(nil).Create), arg0, arg1, arg2)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockSongStorage)(nil).Create), arg0, arg1)
 }
 
 // Delete mocks base method.
-func (m *MockSongStorage) Delete(arg0 context.Context, arg1 int) error {
+func (m *MockSongStorage) Delete(arg0 context.Context, arg1, arg2 int) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Delete", arg0, arg1)
+	ret := m.ctrl.Call(m, "Delete", arg0, arg1, arg2)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Delete indicates an expected call of Delete.
-func (mr *MockSongStorageMockRecorder) Delete(arg0, arg1 interface{}) *gomock.Call {
+func (mr *MockSongStorageMockRecorder) Delete(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockSongStorage)(nil).Delete), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockSongStorage)(nil).Delete), arg0, arg1, arg2)
 }
 
 // GetByID mocks base method.
@@ -95,6 +95,21 @@ func (mr *MockSongStorageMockRecorder) GetByID(arg0, arg1 interface{}) *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByID", reflect.TypeOf((*MockSongStorage)(nil).GetByID), arg0, arg1)
 }
 
+// GetByKey mocks base method.
+func (m *MockSongStorage) GetByKey(arg0 context.Context, arg1, arg2 string) (*models.Song, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetByKey", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*models.Song)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetByKey indicates an expected call of GetByKey.
+func (mr *MockSongStorageMockRecorder) GetByKey(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByKey", reflect.TypeOf((*MockSongStorage)(nil).GetByKey), arg0, arg1, arg2)
+}
+
 // List mocks base method.
 func (m *MockSongStorage) List(arg0 context.Context, arg1 *models.SongFilter, arg2 *models.Pagination) ([]models.Song, error) {
 	m.ctrl.T.Helper()
@@ -110,6 +125,37 @@ func (mr *MockSongStorageMockRecorder) List(arg0, arg1, arg2 interface{}) *gomoc
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockSongStorage)(nil).List), arg0, arg1, arg2)
 }
 
+// Search mocks base method.
+func (m *MockSongStorage) Search(arg0 context.Context, arg1 string, arg2 *models.Pagination) ([]models.SongSearchResult, int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Search", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]models.SongSearchResult)
+	ret1, _ := ret[1].(int)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// Search indicates an expected call of Search.
+func (mr *MockSongStorageMockRecorder) Search(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Search", reflect.TypeOf((*MockSongStorage)(nil).Search), arg0, arg1, arg2)
+}
+
+// Suggest mocks base method.
+func (m *MockSongStorage) Suggest(arg0 context.Context, arg1 *models.SongFilter) ([]models.NameSuggestion, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Suggest", arg0, arg1)
+	ret0, _ := ret[0].([]models.NameSuggestion)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Suggest indicates an expected call of Suggest.
+func (mr *MockSongStorageMockRecorder) Suggest(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Suggest", reflect.TypeOf((*MockSongStorage)(nil).Suggest), arg0, arg1)
+}
+
 // Update mocks base method.
 func (m *MockSongStorage) Update(arg0 context.Context, arg1 *models.Song) (*models.Song, error) {
 	m.ctrl.T.Helper()
@@ -124,3 +170,17 @@ func (mr *MockSongStorageMockRecorder) Update(arg0, arg1 interface{}) *gomock.Ca
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockSongStorage)(nil).Update), arg0, arg1)
 }
+
+// WithTx mocks base method.
+func (m *MockSongStorage) WithTx(arg0 context.Context, arg1 func(storage.SongStorage) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WithTx", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WithTx indicates an expected call of WithTx.
+func (mr *MockSongStorageMockRecorder) WithTx(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WithTx", reflect.TypeOf((*MockSongStorage)(nil).WithTx), arg0, arg1)
+}
@@ -0,0 +1,151 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+
+	"songlibrary/internal/lib/logger/utils"
+	"songlibrary/internal/models"
+	"songlibrary/internal/storage"
+)
+
+const jobColumns = `song_id, status, attempts, run_at, COALESCE(last_error, ''), created_at, updated_at`
+
+func (s *PgStorage) EnqueueEnrichment(ctx context.Context, songID int) error {
+	// The version only moves when the status does, so that a song created pending keeps its ETag.
+	query := `
+        UPDATE songs SET enrichment_status = 'pending',
+            updated_at = CASE WHEN enrichment_status = 'pending' THEN updated_at ELSE CURRENT_TIMESTAMP END,
+            version = CASE WHEN enrichment_status = 'pending' THEN version ELSE version + 1 END
+        WHERE id = $1
+    `
+	result, err := s.db.Exec(ctx, query, songID)
+	if err != nil {
+		utils.Logger.Error("PgStorage.EnqueueEnrichment - update failed", zap.Error(err), zap.Int("song_id", songID))
+		return fmt.Errorf("PgStorage.EnqueueEnrichment - update failed: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return storage.ErrSongNotFound
+	}
+
+	query = `
+        INSERT INTO enrichment_jobs (song_id)
+        SELECT id FROM songs WHERE id = $1
+        ON CONFLICT (song_id) DO UPDATE
+        SET status = 'pending', attempts = 0, run_at = CURRENT_TIMESTAMP, last_error = NULL, updated_at = CURRENT_TIMESTAMP
+        WHERE enrichment_jobs.status IN ('done', 'dead')
+    `
+	if _, err := s.db.Exec(ctx, query, songID); err != nil {
+		utils.Logger.Error("PgStorage.EnqueueEnrichment - insert failed", zap.Error(err), zap.Int("song_id", songID))
+		return fmt.Errorf("PgStorage.EnqueueEnrichment - insert failed: %w", err)
+	}
+	return nil
+}
+
+func (s *PgStorage) ClaimEnrichmentJobs(ctx context.Context, limit int, lease time.Duration) ([]models.EnrichmentJob, error) {
+	query := `
+        UPDATE enrichment_jobs
+        SET status = 'running', attempts = attempts + 1, run_at = CURRENT_TIMESTAMP + $2 * INTERVAL '1 second', updated_at = CURRENT_TIMESTAMP
+        WHERE song_id IN (
+            SELECT song_id FROM enrichment_jobs
+            WHERE status IN ('pending', 'running') AND run_at <= CURRENT_TIMESTAMP
+            ORDER BY run_at
+            LIMIT $1
+            FOR UPDATE SKIP LOCKED
+        )
+        RETURNING ` + jobColumns
+	rows, err := s.db.Query(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		utils.Logger.Error("PgStorage.ClaimEnrichmentJobs - query failed", zap.Error(err))
+		return nil, fmt.Errorf("PgStorage.ClaimEnrichmentJobs - query failed: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []models.EnrichmentJob
+	for rows.Next() {
+		var job models.EnrichmentJob
+		if err := rows.Scan(&job.SongID, &job.Status, &job.Attempts, &job.RunAt, &job.LastError, &job.CreatedAt, &job.UpdatedAt); err != nil {
+			utils.Logger.Error("PgStorage.ClaimEnrichmentJobs - rows.Scan failed", zap.Error(err))
+			return nil, fmt.Errorf("PgStorage.ClaimEnrichmentJobs - rows.Scan failed: %w", err)
+		}
+		jobs = append(jobs, job)
+	}
+	if err := rows.Err(); err != nil {
+		utils.Logger.Error("PgStorage.ClaimEnrichmentJobs - rows.Err failed", zap.Error(err))
+		return nil, fmt.Errorf("PgStorage.ClaimEnrichmentJobs - rows.Err failed: %w", err)
+	}
+	return jobs, nil
+}
+
+func (s *PgStorage) CompleteEnrichmentJob(ctx context.Context, songID, attempts int, details *models.Song) error {
+	query := `
+        WITH job AS (
+            UPDATE enrichment_jobs SET status = 'done', last_error = NULL, updated_at = CURRENT_TIMESTAMP
+            WHERE song_id = $1 AND status = 'running' AND attempts = $6
+            RETURNING song_id
+        )
+        UPDATE songs
+        SET release_date = COALESCE(release_date, $2), text = COALESCE(text, $3), link = COALESCE(link, $4),
+            detail_sources = detail_sources || jsonb_strip_nulls(jsonb_build_object(
+                'releaseDate', CASE WHEN release_date IS NULL AND $2::date IS NOT NULL THEN $5::jsonb->>'releaseDate' END,
+                'text', CASE WHEN text IS NULL AND $3::text IS NOT NULL THEN $5::jsonb->>'text' END,
+                'link', CASE WHEN link IS NULL AND $4::text IS NOT NULL THEN $5::jsonb->>'link' END
+            )),
+            enrichment_status = 'complete', updated_at = CURRENT_TIMESTAMP, version = version + 1
+        WHERE id IN (SELECT song_id FROM job)
+    `
+	if _, err := s.db.Exec(ctx, query, songID, details.ReleaseDate, details.Text, details.Link, details.Sources, attempts); err != nil {
+		utils.Logger.Error("PgStorage.CompleteEnrichmentJob - exec failed", zap.Error(err), zap.Int("song_id", songID))
+		return fmt.Errorf("PgStorage.CompleteEnrichmentJob - exec failed: %w", err)
+	}
+	return nil
+}
+
+func (s *PgStorage) RetryEnrichmentJob(ctx context.Context, songID, attempts int, delay time.Duration, lastError string) error {
+	query := `
+        UPDATE enrichment_jobs
+        SET status = 'pending', run_at = CURRENT_TIMESTAMP + $2 * INTERVAL '1 second', last_error = $3, updated_at = CURRENT_TIMESTAMP
+        WHERE song_id = $1 AND status = 'running' AND attempts = $4
+    `
+	if _, err := s.db.Exec(ctx, query, songID, delay.Seconds(), lastError, attempts); err != nil {
+		utils.Logger.Error("PgStorage.RetryEnrichmentJob - exec failed", zap.Error(err), zap.Int("song_id", songID))
+		return fmt.Errorf("PgStorage.RetryEnrichmentJob - exec failed: %w", err)
+	}
+	return nil
+}
+
+func (s *PgStorage) FailEnrichmentJob(ctx context.Context, songID, attempts int, lastError string) error {
+	query := `
+        WITH job AS (
+            UPDATE enrichment_jobs SET status = 'dead', last_error = $2, updated_at = CURRENT_TIMESTAMP
+            WHERE song_id = $1 AND status = 'running' AND attempts = $3
+            RETURNING song_id
+        )
+        UPDATE songs SET enrichment_status = 'failed', updated_at = CURRENT_TIMESTAMP, version = version + 1
+        WHERE id IN (SELECT song_id FROM job)
+    `
+	if _, err := s.db.Exec(ctx, query, songID, lastError, attempts); err != nil {
+		utils.Logger.Error("PgStorage.FailEnrichmentJob - exec failed", zap.Error(err), zap.Int("song_id", songID))
+		return fmt.Errorf("PgStorage.FailEnrichmentJob - exec failed: %w", err)
+	}
+	return nil
+}
+
+func (s *PgStorage) GetEnrichmentJob(ctx context.Context, songID int) (*models.EnrichmentJob, error) {
+	var job models.EnrichmentJob
+	err := s.db.QueryRow(ctx, `SELECT `+jobColumns+` FROM enrichment_jobs WHERE song_id = $1`, songID).
+		Scan(&job.SongID, &job.Status, &job.Attempts, &job.RunAt, &job.LastError, &job.CreatedAt, &job.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, storage.ErrJobNotFound
+		}
+		utils.Logger.Error("PgStorage.GetEnrichmentJob - queryRow failed", zap.Error(err), zap.Int("song_id", songID))
+		return nil, fmt.Errorf("PgStorage.GetEnrichmentJob - queryRow failed: %w", err)
+	}
+	return &job, nil
+}
@@ -0,0 +1,78 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+
+	"songlibrary/internal/lib/logger/utils"
+	"songlibrary/internal/models"
+)
+
+func (s *PgStorage) ReserveIdempotencyKey(ctx context.Context, key, fingerprint string, ttl time.Duration) (*models.IdempotencyRecord, error) {
+	// An expired record is taken over as if it did not exist.
+	query := `
+        INSERT INTO idempotency_keys (key, fingerprint, expires_at)
+        VALUES ($1, $2, CURRENT_TIMESTAMP + $3 * INTERVAL '1 second')
+        ON CONFLICT (key) DO UPDATE
+        SET fingerprint = EXCLUDED.fingerprint, status_code = NULL, headers = NULL, body = NULL,
+            created_at = CURRENT_TIMESTAMP, expires_at = EXCLUDED.expires_at
+        WHERE idempotency_keys.expires_at <= CURRENT_TIMESTAMP
+        RETURNING key
+    `
+	err := s.db.QueryRow(ctx, query, key, fingerprint, ttl.Seconds()).Scan(&key)
+	if err == nil {
+		return nil, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		utils.Logger.Error("PgStorage.ReserveIdempotencyKey - queryRow failed", zap.Error(err), zap.String("key", key))
+		return nil, fmt.Errorf("PgStorage.ReserveIdempotencyKey - queryRow failed: %w", err)
+	}
+
+	record := models.IdempotencyRecord{Key: key}
+	var statusCode *int
+	err = s.db.QueryRow(ctx, `
+        SELECT fingerprint, status_code, COALESCE(headers, '{}'), body, expires_at
+        FROM idempotency_keys WHERE key = $1
+    `, key).Scan(&record.Fingerprint, &statusCode, &record.Header, &record.Body, &record.ExpiresAt)
+	if err != nil {
+		utils.Logger.Error("PgStorage.ReserveIdempotencyKey - select failed", zap.Error(err), zap.String("key", key))
+		return nil, fmt.Errorf("PgStorage.ReserveIdempotencyKey - select failed: %w", err)
+	}
+	if statusCode != nil {
+		record.StatusCode = *statusCode
+	}
+	return &record, nil
+}
+
+func (s *PgStorage) CompleteIdempotencyKey(ctx context.Context, record *models.IdempotencyRecord) error {
+	_, err := s.db.Exec(ctx, "UPDATE idempotency_keys SET fingerprint = $2, status_code = $3, headers = $4, body = $5 WHERE key = $1",
+		record.Key, record.Fingerprint, record.StatusCode, record.Header, record.Body)
+	if err != nil {
+		utils.Logger.Error("PgStorage.CompleteIdempotencyKey - exec failed", zap.Error(err), zap.String("key", record.Key))
+		return fmt.Errorf("PgStorage.CompleteIdempotencyKey - exec failed: %w", err)
+	}
+	return nil
+}
+
+func (s *PgStorage) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	_, err := s.db.Exec(ctx, "DELETE FROM idempotency_keys WHERE key = $1 AND status_code IS NULL", key)
+	if err != nil {
+		utils.Logger.Error("PgStorage.ReleaseIdempotencyKey - exec failed", zap.Error(err), zap.String("key", key))
+		return fmt.Errorf("PgStorage.ReleaseIdempotencyKey - exec failed: %w", err)
+	}
+	return nil
+}
+
+func (s *PgStorage) DeleteExpiredIdempotencyKeys(ctx context.Context) (int, error) {
+	result, err := s.db.Exec(ctx, "DELETE FROM idempotency_keys WHERE expires_at <= CURRENT_TIMESTAMP")
+	if err != nil {
+		utils.Logger.Error("PgStorage.DeleteExpiredIdempotencyKeys - exec failed", zap.Error(err))
+		return 0, fmt.Errorf("PgStorage.DeleteExpiredIdempotencyKeys - exec failed: %w", err)
+	}
+	return int(result.RowsAffected()), nil
+}
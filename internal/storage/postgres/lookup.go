@@ -0,0 +1,68 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+
+	"songlibrary/internal/lib/logger/utils"
+	"songlibrary/internal/models"
+)
+
+func (s *PgStorage) GetCachedLookup(ctx context.Context, group, song string) (*models.CachedLookup, error) {
+	lookup := models.CachedLookup{GroupName: group, SongName: song}
+	var details []byte
+	err := s.db.QueryRow(ctx, `
+        SELECT details, expires_at FROM api_lookups
+        WHERE group_name = $1 AND song_name = $2 AND expires_at > CURRENT_TIMESTAMP
+    `, group, song).Scan(&details, &lookup.ExpiresAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		utils.Logger.Error("PgStorage.GetCachedLookup - queryRow failed", zap.Error(err), zap.String("group", group), zap.String("song", song))
+		return nil, fmt.Errorf("PgStorage.GetCachedLookup - queryRow failed: %w", err)
+	}
+	if details != nil {
+		if err := json.Unmarshal(details, &lookup.Details); err != nil {
+			utils.Logger.Error("PgStorage.GetCachedLookup - unmarshal failed", zap.Error(err), zap.String("group", group), zap.String("song", song))
+			return nil, fmt.Errorf("PgStorage.GetCachedLookup - unmarshal failed: %w", err)
+		}
+	}
+	return &lookup, nil
+}
+
+func (s *PgStorage) PutCachedLookup(ctx context.Context, lookup *models.CachedLookup) error {
+	var details []byte
+	if lookup.Details != nil {
+		var err error
+		if details, err = json.Marshal(lookup.Details); err != nil {
+			utils.Logger.Error("PgStorage.PutCachedLookup - marshal failed", zap.Error(err))
+			return fmt.Errorf("PgStorage.PutCachedLookup - marshal failed: %w", err)
+		}
+	}
+	_, err := s.db.Exec(ctx, `
+        INSERT INTO api_lookups (group_name, song_name, details, expires_at)
+        VALUES ($1, $2, $3, $4)
+        ON CONFLICT (group_name, song_name) DO UPDATE
+        SET details = EXCLUDED.details, expires_at = EXCLUDED.expires_at
+    `, lookup.GroupName, lookup.SongName, details, lookup.ExpiresAt)
+	if err != nil {
+		utils.Logger.Error("PgStorage.PutCachedLookup - exec failed", zap.Error(err), zap.String("group", lookup.GroupName), zap.String("song", lookup.SongName))
+		return fmt.Errorf("PgStorage.PutCachedLookup - exec failed: %w", err)
+	}
+	return nil
+}
+
+func (s *PgStorage) DeleteExpiredLookups(ctx context.Context) (int, error) {
+	result, err := s.db.Exec(ctx, "DELETE FROM api_lookups WHERE expires_at <= CURRENT_TIMESTAMP")
+	if err != nil {
+		utils.Logger.Error("PgStorage.DeleteExpiredLookups - exec failed", zap.Error(err))
+		return 0, fmt.Errorf("PgStorage.DeleteExpiredLookups - exec failed: %w", err)
+	}
+	return int(result.RowsAffected()), nil
+}
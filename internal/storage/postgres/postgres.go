@@ -2,64 +2,137 @@ package postgres
 
 import (
 	"context"
-	"database/sql"
 	"errors"
 	"fmt"
+	"html"
+	"strings"
 
+	"songlibrary/config"
 	"songlibrary/internal/lib/logger/utils"
 	"songlibrary/internal/models"
 	"songlibrary/internal/storage"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"go.uber.org/zap"
 )
 
+// querier is the subset of pgx API shared by the pool and a transaction,
+// so that every storage method runs the same way inside and outside WithTx.
+type querier interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// songColumns are the columns scanned into models.Song. release_date is formatted
+// explicitly, as pgx would otherwise scan a DATE into a string as a timestamp.
+const songColumns = `id, group_name, song_name, to_char(release_date, 'YYYY-MM-DD'), text, link, created_at, updated_at, version, enrichment_status, edited_fields, detail_sources`
+
 type PgStorage struct {
-	conn *pgx.Conn
+	pool *pgxpool.Pool
+	db   querier
+	// searchLanguage is the text search configuration new songs are indexed with
+	// and search queries are parsed with.
+	searchLanguage string
 }
 
-func NewPgStorage(conn *pgx.Conn) storage.SongStorage {
-	return &PgStorage{conn: conn}
+func NewPgStorage(pool *pgxpool.Pool, searchLanguage string) storage.SongStorage {
+	return &PgStorage{pool: pool, db: pool, searchLanguage: searchLanguage}
 }
 
-func (s *PgStorage) BeginTx(ctx context.Context) (*sql.Tx, error) {
-	db, err := sql.Open("pgx", s.conn.Config().ConnString())
+// NewPool opens a connection pool sized and tuned by cfg and verifies it with a ping.
+func NewPool(ctx context.Context, cfg *config.Config) (*pgxpool.Pool, error) {
+	poolConfig, err := pgxpool.ParseConfig(cfg.DBURL)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open sql connection: %w", err)
+		return nil, fmt.Errorf("failed to parse database URL: %w", err)
 	}
-	tx, err := db.BeginTx(ctx, nil)
+
+	poolConfig.MaxConns = cfg.DBMaxConns
+	poolConfig.MinConns = cfg.DBMinConns
+	poolConfig.MaxConnLifetime = cfg.DBMaxConnLifetime
+	poolConfig.MaxConnIdleTime = cfg.DBMaxConnIdleTime
+	poolConfig.HealthCheckPeriod = cfg.DBHealthCheckPeriod
+
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
 	if err != nil {
-		return nil, fmt.Errorf("failed to start transaction: %w", err)
+		return nil, fmt.Errorf("failed to create connection pool: %w", err)
 	}
-	return tx, nil
+
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	return pool, nil
 }
 
-func (s *PgStorage) Create(ctx context.Context, song *models.Song, tx *sql.Tx) (*models.Song, error) {
-	var query string
-	var addedSong models.Song
-	var err error
-
-	if tx != nil {
-		query = `
-            INSERT INTO songs (group_name, song_name, release_date, text, link)
-            VALUES ($1, $2, $3, $4, $5)
-            RETURNING id, group_name, song_name, release_date, text, link, created_at, updated_at
-        `
-		err = tx.QueryRowContext(ctx, query, song.GroupName, song.SongName, song.ReleaseDate, song.Text, song.Link).Scan(
-			&addedSong.ID, &addedSong.GroupName, &addedSong.SongName, &addedSong.ReleaseDate, &addedSong.Text, &addedSong.Link, &addedSong.CreatedAt, &addedSong.UpdatedAt,
-		)
-	} else {
-		query = `
-            INSERT INTO songs (group_name, song_name, release_date, text, link)
-            VALUES ($1, $2, $3, $4, $5)
-            RETURNING id, group_name, song_name, release_date, text, link, created_at, updated_at
-        `
-		err = s.conn.QueryRow(ctx, query, song.GroupName, song.SongName, song.ReleaseDate, song.Text, song.Link).Scan(
-			&addedSong.ID, &addedSong.GroupName, &addedSong.SongName, &addedSong.ReleaseDate, &addedSong.Text, &addedSong.Link, &addedSong.CreatedAt, &addedSong.UpdatedAt,
-		)
+// CheckSearchLanguage verifies that language is a text search configuration of the
+// database, so that a misconfigured one fails at startup rather than on every search.
+func CheckSearchLanguage(ctx context.Context, pool *pgxpool.Pool, language string) error {
+	var exists bool
+	if err := pool.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM pg_ts_config WHERE cfgname = $1)", language).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to look up text search configurations: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("SEARCH_LANGUAGE %q is not a text search configuration of the database", language)
+	}
+	return nil
+}
+
+func (s *PgStorage) Stats() storage.PoolStats {
+	stat := s.pool.Stat()
+	return storage.PoolStats{
+		TotalConns:           stat.TotalConns(),
+		IdleConns:            stat.IdleConns(),
+		AcquiredConns:        stat.AcquiredConns(),
+		ConstructingConns:    stat.ConstructingConns(),
+		MaxConns:             stat.MaxConns(),
+		AcquireCount:         stat.AcquireCount(),
+		EmptyAcquireCount:    stat.EmptyAcquireCount(),
+		CanceledAcquireCount: stat.CanceledAcquireCount(),
+		AcquireDuration:      stat.AcquireDuration(),
+		NewConnsCount:        stat.NewConnsCount(),
+		MaxLifetimeDestroys:  stat.MaxLifetimeDestroyCount(),
+		MaxIdleDestroys:      stat.MaxIdleDestroyCount(),
+	}
+}
+
+func (s *PgStorage) WithTx(ctx context.Context, fn func(tx storage.SongStorage) error) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		utils.Logger.Error("PgStorage.WithTx - begin failed", zap.Error(err))
+		return fmt.Errorf("PgStorage.WithTx - begin failed: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := fn(&PgStorage{pool: s.pool, db: tx, searchLanguage: s.searchLanguage}); err != nil {
+		return err
 	}
 
+	if err := tx.Commit(ctx); err != nil {
+		utils.Logger.Error("PgStorage.WithTx - commit failed", zap.Error(err))
+		return fmt.Errorf("PgStorage.WithTx - commit failed: %w", err)
+	}
+	return nil
+}
+
+func (s *PgStorage) Create(ctx context.Context, song *models.Song) (*models.Song, error) {
+	query := `
+        INSERT INTO songs (group_name, song_name, release_date, text, link, search_language, group_key, song_key, enrichment_status, edited_fields, detail_sources)
+        VALUES ($1, $2, $3, $4, $5, $6::regconfig, NULLIF($7, ''), NULLIF($8, ''), COALESCE(NULLIF($9, ''), 'complete'), COALESCE($10::text[], '{}'), COALESCE($11::jsonb, '{}'))
+        RETURNING ` + songColumns + `
+    `
+	var addedSong models.Song
+	err := s.db.QueryRow(ctx, query, song.GroupName, song.SongName, song.ReleaseDate, song.Text, song.Link, s.searchLanguage, song.GroupKey, song.SongKey, string(song.EnrichmentStatus), song.EditedFields, song.Sources).Scan(
+		&addedSong.ID, &addedSong.GroupName, &addedSong.SongName, &addedSong.ReleaseDate, &addedSong.Text, &addedSong.Link, &addedSong.CreatedAt, &addedSong.UpdatedAt, &addedSong.Version, &addedSong.EnrichmentStatus, &addedSong.EditedFields, &addedSong.Sources,
+	)
 	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, storage.ErrSongAlreadyExists
+		}
 		utils.Logger.Error("PgStorage.Create - queryRow failed", zap.Error(err))
 		return nil, fmt.Errorf("PgStorage.Create - queryRow failed: %w", err)
 	}
@@ -67,10 +140,10 @@ func (s *PgStorage) Create(ctx context.Context, song *models.Song, tx *sql.Tx) (
 }
 
 func (s *PgStorage) GetByID(ctx context.Context, id int) (*models.Song, error) {
-	query := `SELECT id, group_name, song_name, release_date, text, link, created_at, updated_at FROM songs WHERE id = $1`
+	query := `SELECT ` + songColumns + ` FROM songs WHERE id = $1`
 	var song models.Song
-	err := s.conn.QueryRow(ctx, query, id).Scan(
-		&song.ID, &song.GroupName, &song.SongName, &song.ReleaseDate, &song.Text, &song.Link, &song.CreatedAt, &song.UpdatedAt,
+	err := s.db.QueryRow(ctx, query, id).Scan(
+		&song.ID, &song.GroupName, &song.SongName, &song.ReleaseDate, &song.Text, &song.Link, &song.CreatedAt, &song.UpdatedAt, &song.Version, &song.EnrichmentStatus, &song.EditedFields, &song.Sources,
 	)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -82,27 +155,48 @@ func (s *PgStorage) GetByID(ctx context.Context, id int) (*models.Song, error) {
 	return &song, nil
 }
 
-func (s *PgStorage) List(ctx context.Context, filter *models.SongFilter, pagination *models.Pagination) ([]models.Song, error) {
-	query := `SELECT id, group_name, song_name, release_date, text, link, created_at, updated_at FROM songs WHERE 1=1`
-	var params []interface{}
-	paramCount := 0
+func (s *PgStorage) GetByKey(ctx context.Context, groupKey, songKey string) (*models.Song, error) {
+	query := `SELECT ` + songColumns + ` FROM songs WHERE group_key = $1 AND song_key = $2`
+	var song models.Song
+	err := s.db.QueryRow(ctx, query, groupKey, songKey).Scan(
+		&song.ID, &song.GroupName, &song.SongName, &song.ReleaseDate, &song.Text, &song.Link, &song.CreatedAt, &song.UpdatedAt, &song.Version, &song.EnrichmentStatus, &song.EditedFields, &song.Sources,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, storage.ErrSongNotFound
+		}
+		utils.Logger.Error("PgStorage.GetByKey - queryRow failed", zap.Error(err), zap.String("groupKey", groupKey), zap.String("songKey", songKey))
+		return nil, fmt.Errorf("PgStorage.GetByKey - queryRow failed: %w", err)
+	}
+	return &song, nil
+}
 
+func (s *PgStorage) List(ctx context.Context, filter *models.SongFilter, pagination *models.Pagination) ([]models.Song, error) {
+	var sort models.Sort
 	if filter != nil {
-		if filter.GroupName != nil && *filter.GroupName != "" {
-			paramCount++
-			query += fmt.Sprintf(" AND group_name ILIKE $%d", paramCount)
-			params = append(params, "%"+*filter.GroupName+"%")
+		sort = filter.Sort
+	}
+
+	where, params, similarity := buildFilter(filter)
+	order := orderBy(sort)
+	if filter.IsFuzzy() {
+		if pagination.After != nil {
+			return nil, fmt.Errorf("PgStorage.List - keyset pagination is not supported with fuzzy matching")
 		}
-		if filter.SongName != nil && *filter.SongName != "" {
-			paramCount++
-			query += fmt.Sprintf(" AND song_name ILIKE $%d", paramCount)
-			params = append(params, "%"+*filter.SongName+"%")
+		order = "similarity DESC, " + order
+	}
+	if pagination.After != nil {
+		condition, keysetParams, err := keysetCondition(sort, pagination.After, params)
+		if err != nil {
+			return nil, fmt.Errorf("PgStorage.List - invalid cursor: %w", err)
 		}
+		where += " AND " + condition
+		params = keysetParams
 	}
+	query := `SELECT ` + songColumns + `, ` + similarity + ` AS similarity FROM songs WHERE ` + where
+	query += fmt.Sprintf(" ORDER BY %s LIMIT %d OFFSET %d", order, pagination.GetLimit(), pagination.GetOffset())
 
-	query += fmt.Sprintf(" LIMIT %d OFFSET %d", pagination.GetLimit(), pagination.GetOffset())
-
-	rows, err := s.conn.Query(ctx, query, params...)
+	rows, err := s.db.Query(ctx, query, params...)
 	if err != nil {
 		utils.Logger.Error("PgStorage.List - query failed", zap.Error(err), zap.Any("filter", filter), zap.Any("pagination", pagination))
 		return nil, fmt.Errorf("PgStorage.List - query failed: %w", err)
@@ -113,7 +207,7 @@ func (s *PgStorage) List(ctx context.Context, filter *models.SongFilter, paginat
 	for rows.Next() {
 		var song models.Song
 		err := rows.Scan(
-			&song.ID, &song.GroupName, &song.SongName, &song.ReleaseDate, &song.Text, &song.Link, &song.CreatedAt, &song.UpdatedAt,
+			&song.ID, &song.GroupName, &song.SongName, &song.ReleaseDate, &song.Text, &song.Link, &song.CreatedAt, &song.UpdatedAt, &song.Version, &song.EnrichmentStatus, &song.EditedFields, &song.Sources, &song.Similarity,
 		)
 		if err != nil {
 			utils.Logger.Error("PgStorage.List - rows.Scan failed", zap.Error(err))
@@ -130,24 +224,226 @@ func (s *PgStorage) List(ctx context.Context, filter *models.SongFilter, paginat
 	return songs, nil
 }
 
+func (s *PgStorage) Count(ctx context.Context, filter *models.SongFilter) (int, error) {
+	where, params, _ := buildFilter(filter)
+	query := `SELECT COUNT(*) FROM songs WHERE ` + where
+
+	var count int
+	if err := s.db.QueryRow(ctx, query, params...).Scan(&count); err != nil {
+		utils.Logger.Error("PgStorage.Count - queryRow failed", zap.Error(err), zap.Any("filter", filter))
+		return 0, fmt.Errorf("PgStorage.Count - queryRow failed: %w", err)
+	}
+	return count, nil
+}
+
+func (s *PgStorage) Suggest(ctx context.Context, filter *models.SongFilter) ([]models.NameSuggestion, error) {
+	var suggestions []models.NameSuggestion
+	for _, name := range []struct {
+		field  string
+		column string
+		value  *string
+	}{{"group", "group_name", filter.GroupName}, {"song", "song_name", filter.SongName}} {
+		if name.value == nil || *name.value == "" {
+			continue
+		}
+		query := fmt.Sprintf(`
+            SELECT %[1]s, similarity(%[1]s, $1) AS similarity
+            FROM songs
+            WHERE similarity(%[1]s, $1) >= $2
+            GROUP BY %[1]s
+            ORDER BY similarity DESC, %[1]s
+            LIMIT $3`, name.column)
+		rows, err := s.db.Query(ctx, query, *name.value, models.SuggestionThreshold, models.MaxSuggestions)
+		if err != nil {
+			utils.Logger.Error("PgStorage.Suggest - query failed", zap.Error(err), zap.String("field", name.field))
+			return nil, fmt.Errorf("PgStorage.Suggest - query failed: %w", err)
+		}
+		for rows.Next() {
+			suggestion := models.NameSuggestion{Field: name.field}
+			if err := rows.Scan(&suggestion.Value, &suggestion.Similarity); err != nil {
+				rows.Close()
+				utils.Logger.Error("PgStorage.Suggest - rows.Scan failed", zap.Error(err))
+				return nil, fmt.Errorf("PgStorage.Suggest - rows.Scan failed: %w", err)
+			}
+			suggestions = append(suggestions, suggestion)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			utils.Logger.Error("PgStorage.Suggest - rows.Err failed", zap.Error(err))
+			return nil, fmt.Errorf("PgStorage.Suggest - rows.Err failed: %w", err)
+		}
+	}
+	return suggestions, nil
+}
+
+// searchQuery parses the query both with the configured language, to match
+// stemmed lyrics, and with 'simple', to match names as they are written.
+const searchQuery = `websearch_to_tsquery($1::regconfig, $2) || websearch_to_tsquery('simple', $2)`
+
+// Matched words are delimited in snippets by characters of the private use area,
+// removed from the text, and only turned into <mark></mark> once the snippet is escaped.
+const (
+	markStart = "\uE000"
+	markStop  = "\uE001"
+)
+
+// snippetOptions select up to two fragments of matching verses.
+const snippetOptions = `StartSel=` + markStart + `, StopSel=` + markStop + `, MaxFragments=2, MaxWords=20, MinWords=5, FragmentDelimiter=" … "`
+
+var snippetMarks = strings.NewReplacer(markStart, "<mark>", markStop, "</mark>")
+
+func (s *PgStorage) Search(ctx context.Context, query string, pagination *models.Pagination) ([]models.SongSearchResult, int, error) {
+	sqlQuery := `
+        WITH q AS (SELECT ` + searchQuery + ` AS query)
+        SELECT ` + songColumns + `,
+            ts_rank_cd(search_vector, q.query) AS rank,
+            CASE WHEN to_tsvector(search_language, COALESCE(text, '')) @@ q.query
+                THEN ts_headline(search_language, translate(text, '` + markStart + markStop + `', ''), q.query, '` + snippetOptions + `')
+                ELSE ''
+            END,
+            COUNT(*) OVER ()
+        FROM songs, q
+        WHERE search_vector @@ q.query
+        ORDER BY rank DESC, id
+    ` + fmt.Sprintf("LIMIT %d OFFSET %d", pagination.GetLimit(), pagination.GetOffset())
+
+	rows, err := s.db.Query(ctx, sqlQuery, s.searchLanguage, query)
+	if err != nil {
+		utils.Logger.Error("PgStorage.Search - query failed", zap.Error(err), zap.String("query", query), zap.Any("pagination", pagination))
+		return nil, 0, fmt.Errorf("PgStorage.Search - query failed: %w", err)
+	}
+	defer rows.Close()
+
+	var results []models.SongSearchResult
+	var total int
+	for rows.Next() {
+		var result models.SongSearchResult
+		err := rows.Scan(
+			&result.ID, &result.GroupName, &result.SongName, &result.ReleaseDate, &result.Text, &result.Link, &result.CreatedAt, &result.UpdatedAt, &result.Version, &result.EnrichmentStatus, &result.EditedFields, &result.Sources,
+			&result.Rank, &result.Snippet, &total,
+		)
+		if err != nil {
+			utils.Logger.Error("PgStorage.Search - rows.Scan failed", zap.Error(err))
+			return nil, 0, fmt.Errorf("PgStorage.Search - rows.Scan failed: %w", err)
+		}
+		result.Snippet = snippetMarks.Replace(html.EscapeString(result.Snippet))
+		results = append(results, result)
+	}
+
+	if err := rows.Err(); err != nil {
+		utils.Logger.Error("PgStorage.Search - rows.Err failed", zap.Error(err))
+		return nil, 0, fmt.Errorf("PgStorage.Search - rows.Err failed: %w", err)
+	}
+
+	if len(results) == 0 && pagination.GetOffset() > 0 {
+		// The window count is not available past the last match.
+		if err := s.db.QueryRow(ctx, `SELECT COUNT(*) FROM songs WHERE search_vector @@ (`+searchQuery+`)`, s.searchLanguage, query).Scan(&total); err != nil {
+			utils.Logger.Error("PgStorage.Search - count failed", zap.Error(err), zap.String("query", query))
+			return nil, 0, fmt.Errorf("PgStorage.Search - count failed: %w", err)
+		}
+	}
+
+	return results, total, nil
+}
+
+// buildFilter renders filter as a WHERE condition with positional parameters,
+// along with the expression of the names similarity for fuzzy filters.
+func buildFilter(filter *models.SongFilter) (string, []interface{}, string) {
+	where := "1=1"
+	var params []interface{}
+	add := func(condition string, value interface{}) {
+		params = append(params, value)
+		where += " AND " + strings.ReplaceAll(condition, "$?", fmt.Sprintf("$%d", len(params)))
+	}
+
+	similarity := "NULL::float8"
+	if filter == nil {
+		return where, params, similarity
+	}
+
+	if filter.IsFuzzy() {
+		// The threshold is explicit rather than the % operator, whose
+		// pg_trgm.similarity_threshold setting can be changed on the server.
+		var similarities []string
+		for _, name := range []struct {
+			column string
+			value  *string
+		}{{"group_name", filter.GroupName}, {"song_name", filter.SongName}} {
+			if name.value != nil && *name.value != "" {
+				params = append(params, *name.value)
+				nameSimilarity := fmt.Sprintf("similarity(%s, $%d)", name.column, len(params))
+				add(nameSimilarity+" >= $?", models.SimilarityThreshold)
+				similarities = append(similarities, nameSimilarity)
+			}
+		}
+		similarity = fmt.Sprintf("(%s) / %d", strings.Join(similarities, " + "), len(similarities))
+	} else {
+		if filter.GroupName != nil && *filter.GroupName != "" {
+			add("group_name ILIKE $?", filter.Match.LikePattern(*filter.GroupName))
+		}
+		if filter.SongName != nil && *filter.SongName != "" {
+			add("song_name ILIKE $?", filter.Match.LikePattern(*filter.SongName))
+		}
+	}
+	if filter.ReleaseDateFrom != nil {
+		add("release_date >= $?", *filter.ReleaseDateFrom)
+	}
+	if filter.ReleaseDateTo != nil {
+		add("release_date <= $?", *filter.ReleaseDateTo)
+	}
+	if filter.HasText != nil {
+		where += " AND " + presence("text", *filter.HasText)
+	}
+	if filter.HasLink != nil {
+		where += " AND " + presence("link", *filter.HasLink)
+	}
+	if filter.CreatedFrom != nil {
+		add("created_at >= $?", *filter.CreatedFrom)
+	}
+	if filter.CreatedTo != nil {
+		add("created_at <= $?", *filter.CreatedTo)
+	}
+	if filter.UpdatedFrom != nil {
+		add("updated_at >= $?", *filter.UpdatedFrom)
+	}
+	if filter.UpdatedTo != nil {
+		add("updated_at <= $?", *filter.UpdatedTo)
+	}
+
+	return where, params, similarity
+}
+
+// presence renders a condition on column having a non-empty value.
+func presence(column string, present bool) string {
+	if present {
+		return fmt.Sprintf("COALESCE(%s, '') <> ''", column)
+	}
+	return fmt.Sprintf("COALESCE(%s, '') = ''", column)
+}
+
 func (s *PgStorage) Update(ctx context.Context, song *models.Song) (*models.Song, error) {
 	query := `
         UPDATE songs
-        SET group_name = $1, song_name = $2, release_date = $3, text = $4, link = $5, updated_at = CURRENT_TIMESTAMP
-        WHERE id = $6
-        RETURNING id, group_name, song_name, release_date, text, link, created_at, updated_at
+        SET group_name = $1, song_name = $2, release_date = $3, text = $4, link = $5, updated_at = CURRENT_TIMESTAMP, version = version + 1,
+            group_key = COALESCE(NULLIF($8, ''), group_key), song_key = COALESCE(NULLIF($9, ''), song_key), edited_fields = COALESCE($10::text[], '{}'),
+            detail_sources = COALESCE($11::jsonb, '{}')
+        WHERE id = $6 AND ($7::integer = 0 OR version = $7)
+        RETURNING ` + songColumns + `
     `
 	var updatedSong models.Song
-	err := s.conn.QueryRow(
+	err := s.db.QueryRow(
 		ctx,
 		query,
-		&song.GroupName, &song.SongName, song.ReleaseDate, song.Text, song.Link, song.ID,
+		&song.GroupName, &song.SongName, song.ReleaseDate, song.Text, song.Link, song.ID, song.Version, song.GroupKey, song.SongKey, song.EditedFields, song.Sources,
 	).Scan(
-		&updatedSong.ID, &updatedSong.GroupName, &updatedSong.SongName, &updatedSong.ReleaseDate, &updatedSong.Text, &updatedSong.Link, &updatedSong.CreatedAt, &updatedSong.UpdatedAt,
+		&updatedSong.ID, &updatedSong.GroupName, &updatedSong.SongName, &updatedSong.ReleaseDate, &updatedSong.Text, &updatedSong.Link, &updatedSong.CreatedAt, &updatedSong.UpdatedAt, &updatedSong.Version, &updatedSong.EnrichmentStatus, &updatedSong.EditedFields, &updatedSong.Sources,
 	)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			return nil, storage.ErrSongNotFound
+			return nil, s.unchanged(ctx, song.ID, song.Version)
+		}
+		if isUniqueViolation(err) {
+			return nil, storage.ErrSongAlreadyExists
 		}
 		utils.Logger.Error("PgStorage.Update - queryRow failed", zap.Error(err), zap.Int("id", song.ID))
 		return nil, fmt.Errorf("PgStorage.Update - queryRow failed: %w", err)
@@ -155,15 +451,79 @@ func (s *PgStorage) Update(ctx context.Context, song *models.Song) (*models.Song
 	return &updatedSong, nil
 }
 
-func (s *PgStorage) Delete(ctx context.Context, id int) error {
-	result, err := s.conn.Exec(ctx, "DELETE FROM songs WHERE id = $1", id)
+func (s *PgStorage) Delete(ctx context.Context, id int, version int) error {
+	result, err := s.db.Exec(ctx, "DELETE FROM songs WHERE id = $1 AND ($2::integer = 0 OR version = $2)", id, version)
 	if err != nil {
 		utils.Logger.Error("PgStorage.Delete - exec failed", zap.Error(err), zap.Int("id", id))
 		return fmt.Errorf("PgStorage.Delete - exec failed: %w", err)
 	}
 	rowsAffected := result.RowsAffected()
 	if rowsAffected == 0 {
+		return s.unchanged(ctx, id, version)
+	}
+	return nil
+}
+
+func (s *PgStorage) Identities(ctx context.Context) ([]models.SongIdentity, error) {
+	rows, err := s.db.Query(ctx, `SELECT id, group_name, song_name, COALESCE(group_key, ''), COALESCE(song_key, '') FROM songs ORDER BY id`)
+	if err != nil {
+		utils.Logger.Error("PgStorage.Identities - query failed", zap.Error(err))
+		return nil, fmt.Errorf("PgStorage.Identities - query failed: %w", err)
+	}
+	defer rows.Close()
+
+	var identities []models.SongIdentity
+	for rows.Next() {
+		var identity models.SongIdentity
+		if err := rows.Scan(&identity.ID, &identity.GroupName, &identity.SongName, &identity.GroupKey, &identity.SongKey); err != nil {
+			utils.Logger.Error("PgStorage.Identities - rows.Scan failed", zap.Error(err))
+			return nil, fmt.Errorf("PgStorage.Identities - rows.Scan failed: %w", err)
+		}
+		identities = append(identities, identity)
+	}
+	if err := rows.Err(); err != nil {
+		utils.Logger.Error("PgStorage.Identities - rows.Err failed", zap.Error(err))
+		return nil, fmt.Errorf("PgStorage.Identities - rows.Err failed: %w", err)
+	}
+	return identities, nil
+}
+
+func (s *PgStorage) SetKeys(ctx context.Context, id int, groupKey, songKey string) error {
+	result, err := s.db.Exec(ctx, "UPDATE songs SET group_key = NULLIF($2, ''), song_key = NULLIF($3, '') WHERE id = $1", id, groupKey, songKey)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return storage.ErrSongAlreadyExists
+		}
+		utils.Logger.Error("PgStorage.SetKeys - exec failed", zap.Error(err), zap.Int("id", id))
+		return fmt.Errorf("PgStorage.SetKeys - exec failed: %w", err)
+	}
+	if result.RowsAffected() == 0 {
 		return storage.ErrSongNotFound
 	}
 	return nil
 }
+
+// unchanged explains why a conditional write of the song matched no row: either
+// the song does not exist or its version is not the expected one.
+func (s *PgStorage) unchanged(ctx context.Context, id int, version int) error {
+	if version == 0 {
+		return storage.ErrSongNotFound
+	}
+	var exists bool
+	if err := s.db.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM songs WHERE id = $1)", id).Scan(&exists); err != nil {
+		utils.Logger.Error("PgStorage.unchanged - queryRow failed", zap.Error(err), zap.Int("id", id))
+		return fmt.Errorf("PgStorage.unchanged - queryRow failed: %w", err)
+	}
+	if exists {
+		return storage.ErrVersionMismatch
+	}
+	return storage.ErrSongNotFound
+}
+
+// uniqueViolation is the SQLSTATE of unique_violation.
+const uniqueViolation = "23505"
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == uniqueViolation
+}
@@ -0,0 +1,74 @@
+package postgres
+
+import (
+	"fmt"
+	"strings"
+
+	"songlibrary/internal/models"
+)
+
+// sortColumns maps the whitelisted sort fields to SQL. NULL release dates are
+// ordered as the earliest ones so that keyset comparisons never meet a NULL. Names
+// are ordered by their lower case bytes, as models.CompareNames does, whatever the
+// collation of the database.
+var sortColumns = map[models.SortField]string{
+	models.SortByID:          "id",
+	models.SortByGroup:       `lower(group_name) COLLATE "C"`,
+	models.SortBySong:        `lower(song_name) COLLATE "C"`,
+	models.SortByReleaseDate: "COALESCE(release_date, '-infinity'::date)",
+	models.SortByCreatedAt:   "created_at",
+	models.SortByUpdatedAt:   "updated_at",
+}
+
+func orderBy(sort models.Sort) string {
+	keys := sort.WithTieBreaker()
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = sortColumns[key.Field]
+		if key.Desc {
+			parts[i] += " DESC"
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// keysetCondition renders the condition selecting the songs that follow cursor
+// in sort order, appending its parameters to params.
+func keysetCondition(sort models.Sort, cursor *models.Cursor, params []interface{}) (string, []interface{}, error) {
+	keys := sort.WithTieBreaker()
+	placeholders := make([]string, len(keys))
+	for i, key := range keys {
+		var value interface{} = cursor.ID
+		if key.Field != models.SortByID {
+			var err error
+			value, err = key.Field.ParseValue(cursor.Values[i])
+			if err != nil {
+				return "", nil, err
+			}
+			if value == nil {
+				value = "-infinity"
+			}
+		}
+		params = append(params, value)
+		placeholders[i] = fmt.Sprintf("$%d", len(params))
+		if key.Field == models.SortByGroup || key.Field == models.SortBySong {
+			placeholders[i] = "lower(" + placeholders[i] + ")"
+		}
+	}
+
+	// (k1 > v1) OR (k1 = v1 AND k2 > v2) OR ..., with < for descending keys.
+	var alternatives []string
+	for i, key := range keys {
+		var terms []string
+		for j := 0; j < i; j++ {
+			terms = append(terms, fmt.Sprintf("%s = %s", sortColumns[keys[j].Field], placeholders[j]))
+		}
+		op := ">"
+		if key.Desc {
+			op = "<"
+		}
+		terms = append(terms, fmt.Sprintf("%s %s %s", sortColumns[key.Field], op, placeholders[i]))
+		alternatives = append(alternatives, "("+strings.Join(terms, " AND ")+")")
+	}
+	return "(" + strings.Join(alternatives, " OR ") + ")", params, nil
+}
@@ -0,0 +1,174 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"songlibrary/internal/lib/logger/utils"
+	"songlibrary/internal/models"
+	"songlibrary/internal/storage"
+)
+
+const jobColumns = `song_id, status, attempts, run_at, COALESCE(last_error, ''), created_at, updated_at`
+
+func (s *SqliteStorage) EnqueueEnrichment(ctx context.Context, songID int) error {
+	now := time.Now().UTC()
+	// The version only moves when the status does, so that a song created pending keeps its ETag.
+	query := `
+        UPDATE songs SET enrichment_status = 'pending',
+            updated_at = CASE WHEN enrichment_status = 'pending' THEN updated_at ELSE ? END,
+            version = CASE WHEN enrichment_status = 'pending' THEN version ELSE version + 1 END
+        WHERE id = ?
+    `
+	result, err := s.q.ExecContext(ctx, query, now, songID)
+	if err != nil {
+		utils.Logger.Error("SqliteStorage.EnqueueEnrichment - update failed", zap.Error(err), zap.Int("song_id", songID))
+		return fmt.Errorf("SqliteStorage.EnqueueEnrichment - update failed: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		utils.Logger.Error("SqliteStorage.EnqueueEnrichment - rowsAffected failed", zap.Error(err), zap.Int("song_id", songID))
+		return fmt.Errorf("SqliteStorage.EnqueueEnrichment - rowsAffected failed: %w", err)
+	}
+	if rowsAffected == 0 {
+		return storage.ErrSongNotFound
+	}
+
+	query = `
+        INSERT INTO enrichment_jobs (song_id, run_at, created_at, updated_at)
+        VALUES (?, ?, ?, ?)
+        ON CONFLICT (song_id) DO UPDATE
+        SET status = 'pending', attempts = 0, run_at = excluded.run_at, last_error = NULL, updated_at = excluded.updated_at
+        WHERE enrichment_jobs.status IN ('done', 'dead')
+    `
+	if _, err := s.q.ExecContext(ctx, query, songID, now, now, now); err != nil {
+		utils.Logger.Error("SqliteStorage.EnqueueEnrichment - insert failed", zap.Error(err), zap.Int("song_id", songID))
+		return fmt.Errorf("SqliteStorage.EnqueueEnrichment - insert failed: %w", err)
+	}
+	return nil
+}
+
+// ClaimEnrichmentJobs relies on SQLite running a single writer at a time, which
+// makes the UPDATE atomic without row locks.
+func (s *SqliteStorage) ClaimEnrichmentJobs(ctx context.Context, limit int, lease time.Duration) ([]models.EnrichmentJob, error) {
+	query := `
+        UPDATE enrichment_jobs
+        SET status = 'running', attempts = attempts + 1, run_at = ?, updated_at = ?
+        WHERE song_id IN (
+            SELECT song_id FROM enrichment_jobs
+            WHERE status IN ('pending', 'running') AND run_at <= ?
+            ORDER BY run_at
+            LIMIT ?
+        )
+        RETURNING ` + jobColumns
+	now := time.Now().UTC()
+	rows, err := s.q.QueryContext(ctx, query, now.Add(lease), now, now, limit)
+	if err != nil {
+		utils.Logger.Error("SqliteStorage.ClaimEnrichmentJobs - query failed", zap.Error(err))
+		return nil, fmt.Errorf("SqliteStorage.ClaimEnrichmentJobs - query failed: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []models.EnrichmentJob
+	for rows.Next() {
+		var job models.EnrichmentJob
+		if err := rows.Scan(&job.SongID, &job.Status, &job.Attempts, &job.RunAt, &job.LastError, &job.CreatedAt, &job.UpdatedAt); err != nil {
+			utils.Logger.Error("SqliteStorage.ClaimEnrichmentJobs - rows.Scan failed", zap.Error(err))
+			return nil, fmt.Errorf("SqliteStorage.ClaimEnrichmentJobs - rows.Scan failed: %w", err)
+		}
+		jobs = append(jobs, job)
+	}
+	if err := rows.Err(); err != nil {
+		utils.Logger.Error("SqliteStorage.ClaimEnrichmentJobs - rows.Err failed", zap.Error(err))
+		return nil, fmt.Errorf("SqliteStorage.ClaimEnrichmentJobs - rows.Err failed: %w", err)
+	}
+	return jobs, nil
+}
+
+func (s *SqliteStorage) CompleteEnrichmentJob(ctx context.Context, songID, attempts int, details *models.Song) error {
+	err := s.finishJob(ctx, songID, attempts, models.JobDone, "", func(tx *SqliteStorage) error {
+		// The song is read in the transaction to merge the sources of the details filled in.
+		song, err := tx.GetByID(ctx, songID)
+		if err != nil {
+			return err
+		}
+		song.Enrich(details)
+		query := `
+            UPDATE songs
+            SET release_date = ?, text = ?, link = ?, detail_sources = ?,
+                enrichment_status = 'complete', updated_at = ?, version = version + 1
+            WHERE id = ?
+        `
+		_, err = tx.q.ExecContext(ctx, query, song.ReleaseDate, song.Text, song.Link, encodeSources(song.Sources), time.Now().UTC(), songID)
+		return err
+	})
+	if err != nil {
+		utils.Logger.Error("SqliteStorage.CompleteEnrichmentJob - exec failed", zap.Error(err), zap.Int("song_id", songID))
+		return fmt.Errorf("SqliteStorage.CompleteEnrichmentJob - exec failed: %w", err)
+	}
+	return nil
+}
+
+func (s *SqliteStorage) RetryEnrichmentJob(ctx context.Context, songID, attempts int, delay time.Duration, lastError string) error {
+	query := `
+        UPDATE enrichment_jobs SET status = 'pending', run_at = ?, last_error = ?, updated_at = ?
+        WHERE song_id = ? AND status = 'running' AND attempts = ?
+    `
+	now := time.Now().UTC()
+	if _, err := s.q.ExecContext(ctx, query, now.Add(delay), lastError, now, songID, attempts); err != nil {
+		utils.Logger.Error("SqliteStorage.RetryEnrichmentJob - exec failed", zap.Error(err), zap.Int("song_id", songID))
+		return fmt.Errorf("SqliteStorage.RetryEnrichmentJob - exec failed: %w", err)
+	}
+	return nil
+}
+
+func (s *SqliteStorage) FailEnrichmentJob(ctx context.Context, songID, attempts int, lastError string) error {
+	err := s.finishJob(ctx, songID, attempts, models.JobDead, lastError, func(tx *SqliteStorage) error {
+		_, err := tx.q.ExecContext(ctx, "UPDATE songs SET enrichment_status = 'failed', updated_at = ?, version = version + 1 WHERE id = ?", time.Now().UTC(), songID)
+		return err
+	})
+	if err != nil {
+		utils.Logger.Error("SqliteStorage.FailEnrichmentJob - exec failed", zap.Error(err), zap.Int("song_id", songID))
+		return fmt.Errorf("SqliteStorage.FailEnrichmentJob - exec failed: %w", err)
+	}
+	return nil
+}
+
+// finishJob sets the status of a running job and, when there is one, updates its
+// song with updateSong in the same transaction.
+func (s *SqliteStorage) finishJob(ctx context.Context, songID, attempts int, status models.JobStatus, lastError string, updateSong func(tx *SqliteStorage) error) error {
+	return s.WithTx(ctx, func(tx storage.SongStorage) error {
+		sqliteTx := tx.(*SqliteStorage)
+		result, err := sqliteTx.q.ExecContext(ctx, `
+            UPDATE enrichment_jobs SET status = ?, last_error = NULLIF(?, ''), updated_at = ?
+            WHERE song_id = ? AND status = 'running' AND attempts = ?
+        `, string(status), lastError, time.Now().UTC(), songID, attempts)
+		if err != nil {
+			return err
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil || rowsAffected == 0 {
+			return err
+		}
+		return updateSong(sqliteTx)
+	})
+}
+
+func (s *SqliteStorage) GetEnrichmentJob(ctx context.Context, songID int) (*models.EnrichmentJob, error) {
+	var job models.EnrichmentJob
+	err := s.q.QueryRowContext(ctx, `SELECT `+jobColumns+` FROM enrichment_jobs WHERE song_id = ?`, songID).
+		Scan(&job.SongID, &job.Status, &job.Attempts, &job.RunAt, &job.LastError, &job.CreatedAt, &job.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, storage.ErrJobNotFound
+		}
+		utils.Logger.Error("SqliteStorage.GetEnrichmentJob - queryRow failed", zap.Error(err), zap.Int("song_id", songID))
+		return nil, fmt.Errorf("SqliteStorage.GetEnrichmentJob - queryRow failed: %w", err)
+	}
+	return &job, nil
+}
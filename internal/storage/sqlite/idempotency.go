@@ -0,0 +1,90 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"songlibrary/internal/lib/logger/utils"
+	"songlibrary/internal/models"
+)
+
+func (s *SqliteStorage) ReserveIdempotencyKey(ctx context.Context, key, fingerprint string, ttl time.Duration) (*models.IdempotencyRecord, error) {
+	now := time.Now().UTC()
+	// An expired record is taken over as if it did not exist.
+	query := `
+        INSERT INTO idempotency_keys (key, fingerprint, created_at, expires_at)
+        VALUES (?, ?, ?, ?)
+        ON CONFLICT (key) DO UPDATE
+        SET fingerprint = excluded.fingerprint, status_code = NULL, headers = NULL, body = NULL,
+            created_at = excluded.created_at, expires_at = excluded.expires_at
+        WHERE idempotency_keys.expires_at <= excluded.created_at
+        RETURNING key
+    `
+	err := s.q.QueryRowContext(ctx, query, key, fingerprint, now, now.Add(ttl)).Scan(&key)
+	if err == nil {
+		return nil, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		utils.Logger.Error("SqliteStorage.ReserveIdempotencyKey - queryRow failed", zap.Error(err), zap.String("key", key))
+		return nil, fmt.Errorf("SqliteStorage.ReserveIdempotencyKey - queryRow failed: %w", err)
+	}
+
+	record := models.IdempotencyRecord{Key: key}
+	var statusCode sql.NullInt64
+	var header sql.NullString
+	err = s.q.QueryRowContext(ctx, "SELECT fingerprint, status_code, headers, body, expires_at FROM idempotency_keys WHERE key = ?", key).
+		Scan(&record.Fingerprint, &statusCode, &header, &record.Body, &record.ExpiresAt)
+	if err != nil {
+		utils.Logger.Error("SqliteStorage.ReserveIdempotencyKey - select failed", zap.Error(err), zap.String("key", key))
+		return nil, fmt.Errorf("SqliteStorage.ReserveIdempotencyKey - select failed: %w", err)
+	}
+	record.StatusCode = int(statusCode.Int64)
+	if header.Valid {
+		if err := json.Unmarshal([]byte(header.String), &record.Header); err != nil {
+			return nil, fmt.Errorf("SqliteStorage.ReserveIdempotencyKey - invalid headers: %w", err)
+		}
+	}
+	return &record, nil
+}
+
+func (s *SqliteStorage) CompleteIdempotencyKey(ctx context.Context, record *models.IdempotencyRecord) error {
+	header, err := json.Marshal(record.Header)
+	if err != nil {
+		return fmt.Errorf("SqliteStorage.CompleteIdempotencyKey - json.Marshal failed: %w", err)
+	}
+	_, err = s.q.ExecContext(ctx, "UPDATE idempotency_keys SET fingerprint = ?, status_code = ?, headers = ?, body = ? WHERE key = ?",
+		record.Fingerprint, record.StatusCode, string(header), record.Body, record.Key)
+	if err != nil {
+		utils.Logger.Error("SqliteStorage.CompleteIdempotencyKey - exec failed", zap.Error(err), zap.String("key", record.Key))
+		return fmt.Errorf("SqliteStorage.CompleteIdempotencyKey - exec failed: %w", err)
+	}
+	return nil
+}
+
+func (s *SqliteStorage) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	_, err := s.q.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE key = ? AND status_code IS NULL", key)
+	if err != nil {
+		utils.Logger.Error("SqliteStorage.ReleaseIdempotencyKey - exec failed", zap.Error(err), zap.String("key", key))
+		return fmt.Errorf("SqliteStorage.ReleaseIdempotencyKey - exec failed: %w", err)
+	}
+	return nil
+}
+
+func (s *SqliteStorage) DeleteExpiredIdempotencyKeys(ctx context.Context) (int, error) {
+	result, err := s.q.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE expires_at <= ?", time.Now().UTC())
+	if err != nil {
+		utils.Logger.Error("SqliteStorage.DeleteExpiredIdempotencyKeys - exec failed", zap.Error(err))
+		return 0, fmt.Errorf("SqliteStorage.DeleteExpiredIdempotencyKeys - exec failed: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("SqliteStorage.DeleteExpiredIdempotencyKeys - rowsAffected failed: %w", err)
+	}
+	return int(rowsAffected), nil
+}
@@ -0,0 +1,78 @@
+package sqlite
+
+import (
+	"strings"
+
+	"songlibrary/internal/models"
+
+	modernc "modernc.org/sqlite"
+)
+
+// sortColumns maps the whitelisted sort fields to SQL. NULL release dates are
+// ordered as the earliest ones so that keyset comparisons never meet a NULL. Names
+// are ordered with the casefold collation, see init.
+var sortColumns = map[models.SortField]string{
+	models.SortByID:          "id",
+	models.SortByGroup:       "group_name COLLATE casefold",
+	models.SortBySong:        "song_name COLLATE casefold",
+	models.SortByReleaseDate: "COALESCE(release_date, '')",
+	models.SortByCreatedAt:   "created_at",
+	models.SortByUpdatedAt:   "updated_at",
+}
+
+// init registers the casefold collation, which orders names as models.CompareNames.
+// SQLite's own NOCASE collation only folds ASCII letters.
+func init() {
+	modernc.MustRegisterCollationUtf8("casefold", models.CompareNames)
+}
+
+func orderBy(sort models.Sort) string {
+	keys := sort.WithTieBreaker()
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = sortColumns[key.Field]
+		if key.Desc {
+			parts[i] += " DESC"
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// keysetCondition renders the condition selecting the songs that follow cursor
+// in sort order, appending its parameters to params.
+func keysetCondition(sort models.Sort, cursor *models.Cursor, params []interface{}) (string, []interface{}, error) {
+	keys := sort.WithTieBreaker()
+	values := make([]interface{}, len(keys))
+	for i, key := range keys {
+		if key.Field == models.SortByID {
+			values[i] = cursor.ID
+			continue
+		}
+		value, err := key.Field.ParseValue(cursor.Values[i])
+		if err != nil {
+			return "", nil, err
+		}
+		if value == nil {
+			value = ""
+		}
+		values[i] = value
+	}
+
+	// (k1 > v1) OR (k1 = v1 AND k2 > v2) OR ..., with < for descending keys.
+	var alternatives []string
+	for i, key := range keys {
+		var terms []string
+		for j := 0; j < i; j++ {
+			terms = append(terms, sortColumns[keys[j].Field]+" = ?")
+			params = append(params, values[j])
+		}
+		op := " > ?"
+		if key.Desc {
+			op = " < ?"
+		}
+		terms = append(terms, sortColumns[key.Field]+op)
+		params = append(params, values[i])
+		alternatives = append(alternatives, "("+strings.Join(terms, " AND ")+")")
+	}
+	return "(" + strings.Join(alternatives, " OR ") + ")", params, nil
+}
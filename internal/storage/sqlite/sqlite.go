@@ -0,0 +1,619 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"songlibrary/internal/lib/logger/utils"
+	"songlibrary/internal/lib/textsearch"
+	"songlibrary/internal/lib/trigram"
+	"songlibrary/internal/models"
+	"songlibrary/internal/storage"
+
+	"go.uber.org/zap"
+	modernc "modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// querier is the subset of database/sql API shared by *sql.DB and *sql.Tx.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+type SqliteStorage struct {
+	db *sql.DB
+	q  querier
+	tx *sql.Tx
+	// depth is the number of savepoints opened on top of tx.
+	depth int
+}
+
+func NewSqliteStorage(db *sql.DB) storage.SongStorage {
+	return &SqliteStorage{db: db, q: db}
+}
+
+// NewDB opens the SQLite database at dsn. SQLite allows a single writer, so the
+// pool is limited to one connection; this also keeps ":memory:" databases shared.
+func NewDB(ctx context.Context, dsn string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sqlite database: %w", err)
+	}
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.ExecContext(ctx, "PRAGMA busy_timeout = 5000"); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to configure sqlite database: %w", err)
+	}
+
+	return db, nil
+}
+
+func (s *SqliteStorage) Stats() storage.PoolStats {
+	stat := s.db.Stats()
+	return storage.PoolStats{
+		TotalConns:          int32(stat.OpenConnections),
+		IdleConns:           int32(stat.Idle),
+		AcquiredConns:       int32(stat.InUse),
+		MaxConns:            int32(stat.MaxOpenConnections),
+		AcquireDuration:     stat.WaitDuration,
+		MaxLifetimeDestroys: stat.MaxLifetimeClosed,
+		MaxIdleDestroys:     stat.MaxIdleTimeClosed,
+		WaitCount:           stat.WaitCount,
+	}
+}
+
+func (s *SqliteStorage) WithTx(ctx context.Context, fn func(tx storage.SongStorage) error) error {
+	if s.tx != nil {
+		return s.withSavepoint(ctx, fn)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		utils.Logger.Error("SqliteStorage.WithTx - begin failed", zap.Error(err))
+		return fmt.Errorf("SqliteStorage.WithTx - begin failed: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(&SqliteStorage{db: s.db, q: tx, tx: tx}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		utils.Logger.Error("SqliteStorage.WithTx - commit failed", zap.Error(err))
+		return fmt.Errorf("SqliteStorage.WithTx - commit failed: %w", err)
+	}
+	return nil
+}
+
+func (s *SqliteStorage) withSavepoint(ctx context.Context, fn func(tx storage.SongStorage) error) error {
+	name := fmt.Sprintf("sp_%d", s.depth+1)
+	if _, err := s.tx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		utils.Logger.Error("SqliteStorage.WithTx - savepoint failed", zap.Error(err))
+		return fmt.Errorf("SqliteStorage.WithTx - savepoint failed: %w", err)
+	}
+
+	if err := fn(&SqliteStorage{db: s.db, q: s.tx, tx: s.tx, depth: s.depth + 1}); err != nil {
+		if _, rbErr := s.tx.ExecContext(ctx, "ROLLBACK TO "+name+"; RELEASE "+name); rbErr != nil {
+			utils.Logger.Error("SqliteStorage.WithTx - rollback to savepoint failed", zap.Error(rbErr))
+		}
+		return err
+	}
+
+	if _, err := s.tx.ExecContext(ctx, "RELEASE "+name); err != nil {
+		utils.Logger.Error("SqliteStorage.WithTx - release savepoint failed", zap.Error(err))
+		return fmt.Errorf("SqliteStorage.WithTx - release savepoint failed: %w", err)
+	}
+	return nil
+}
+
+func (s *SqliteStorage) Create(ctx context.Context, song *models.Song) (*models.Song, error) {
+	query := `
+        INSERT INTO songs (group_name, song_name, release_date, text, link, created_at, updated_at, group_key, song_key, enrichment_status, edited_fields, detail_sources)
+        VALUES (?, ?, ?, ?, ?, ?, ?, NULLIF(?, ''), NULLIF(?, ''), COALESCE(NULLIF(?, ''), 'complete'), ?, ?)
+        RETURNING id, group_name, song_name, release_date, text, link, created_at, updated_at, version, enrichment_status, edited_fields, detail_sources
+    `
+	now := time.Now().UTC()
+	var addedSong models.Song
+	err := s.q.QueryRowContext(ctx, query, song.GroupName, song.SongName, song.ReleaseDate, song.Text, song.Link, now, now, song.GroupKey, song.SongKey, string(song.EnrichmentStatus), strings.Join(song.EditedFields, ","), encodeSources(song.Sources)).Scan(
+		&addedSong.ID, &addedSong.GroupName, &addedSong.SongName, &addedSong.ReleaseDate, &addedSong.Text, &addedSong.Link, &addedSong.CreatedAt, &addedSong.UpdatedAt, &addedSong.Version, &addedSong.EnrichmentStatus, fieldList{&addedSong.EditedFields}, sourceMap{&addedSong.Sources},
+	)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, storage.ErrSongAlreadyExists
+		}
+		utils.Logger.Error("SqliteStorage.Create - queryRow failed", zap.Error(err))
+		return nil, fmt.Errorf("SqliteStorage.Create - queryRow failed: %w", err)
+	}
+	return &addedSong, nil
+}
+
+func (s *SqliteStorage) GetByID(ctx context.Context, id int) (*models.Song, error) {
+	query := `SELECT id, group_name, song_name, release_date, text, link, created_at, updated_at, version, enrichment_status, edited_fields, detail_sources FROM songs WHERE id = ?`
+	var song models.Song
+	err := s.q.QueryRowContext(ctx, query, id).Scan(
+		&song.ID, &song.GroupName, &song.SongName, &song.ReleaseDate, &song.Text, &song.Link, &song.CreatedAt, &song.UpdatedAt, &song.Version, &song.EnrichmentStatus, fieldList{&song.EditedFields}, sourceMap{&song.Sources},
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, storage.ErrSongNotFound
+		}
+		utils.Logger.Error("SqliteStorage.GetByID - queryRow failed", zap.Error(err), zap.Int("id", id))
+		return nil, fmt.Errorf("SqliteStorage.GetByID - queryRow failed: %w", err)
+	}
+	return &song, nil
+}
+
+func (s *SqliteStorage) GetByKey(ctx context.Context, groupKey, songKey string) (*models.Song, error) {
+	query := `SELECT id, group_name, song_name, release_date, text, link, created_at, updated_at, version, enrichment_status, edited_fields, detail_sources FROM songs WHERE group_key = ? AND song_key = ?`
+	var song models.Song
+	err := s.q.QueryRowContext(ctx, query, groupKey, songKey).Scan(
+		&song.ID, &song.GroupName, &song.SongName, &song.ReleaseDate, &song.Text, &song.Link, &song.CreatedAt, &song.UpdatedAt, &song.Version, &song.EnrichmentStatus, fieldList{&song.EditedFields}, sourceMap{&song.Sources},
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, storage.ErrSongNotFound
+		}
+		utils.Logger.Error("SqliteStorage.GetByKey - queryRow failed", zap.Error(err), zap.String("groupKey", groupKey), zap.String("songKey", songKey))
+		return nil, fmt.Errorf("SqliteStorage.GetByKey - queryRow failed: %w", err)
+	}
+	return &song, nil
+}
+
+func (s *SqliteStorage) List(ctx context.Context, filter *models.SongFilter, pagination *models.Pagination) ([]models.Song, error) {
+	var sort models.Sort
+	if filter != nil {
+		sort = filter.Sort
+	}
+
+	if filter.IsFuzzy() {
+		if pagination.After != nil {
+			return nil, fmt.Errorf("SqliteStorage.List - keyset pagination is not supported with fuzzy matching")
+		}
+		songs, err := s.fuzzyMatches(ctx, filter)
+		if err != nil {
+			return nil, fmt.Errorf("SqliteStorage.List - fuzzyMatches failed: %w", err)
+		}
+		offset := pagination.GetOffset()
+		if offset >= len(songs) {
+			return nil, nil
+		}
+		return songs[offset:min(offset+pagination.GetLimit(), len(songs))], nil
+	}
+
+	where, params := buildFilter(filter)
+	if pagination.After != nil {
+		condition, keysetParams, err := keysetCondition(sort, pagination.After, params)
+		if err != nil {
+			return nil, fmt.Errorf("SqliteStorage.List - invalid cursor: %w", err)
+		}
+		where += " AND " + condition
+		params = keysetParams
+	}
+	query := `SELECT id, group_name, song_name, release_date, text, link, created_at, updated_at, version, enrichment_status, edited_fields, detail_sources FROM songs WHERE ` + where
+	query += fmt.Sprintf(" ORDER BY %s LIMIT %d OFFSET %d", orderBy(sort), pagination.GetLimit(), pagination.GetOffset())
+
+	rows, err := s.q.QueryContext(ctx, query, params...)
+	if err != nil {
+		utils.Logger.Error("SqliteStorage.List - query failed", zap.Error(err), zap.Any("filter", filter), zap.Any("pagination", pagination))
+		return nil, fmt.Errorf("SqliteStorage.List - query failed: %w", err)
+	}
+	defer rows.Close()
+
+	var songs []models.Song
+	for rows.Next() {
+		var song models.Song
+		err := rows.Scan(
+			&song.ID, &song.GroupName, &song.SongName, &song.ReleaseDate, &song.Text, &song.Link, &song.CreatedAt, &song.UpdatedAt, &song.Version, &song.EnrichmentStatus, fieldList{&song.EditedFields}, sourceMap{&song.Sources},
+		)
+		if err != nil {
+			utils.Logger.Error("SqliteStorage.List - rows.Scan failed", zap.Error(err))
+			return nil, fmt.Errorf("SqliteStorage.List - rows.Scan failed: %w", err)
+		}
+		songs = append(songs, song)
+	}
+
+	if err := rows.Err(); err != nil {
+		utils.Logger.Error("SqliteStorage.List - rows.Err failed", zap.Error(err))
+		return nil, fmt.Errorf("SqliteStorage.List - rows.Err failed: %w", err)
+	}
+
+	return songs, nil
+}
+
+// Search preselects the songs containing the query words with LIKE and ranks
+// them in process, as SQLite has no tsvector. LIKE only folds ASCII case, so
+// other words are left to the in-process match.
+func (s *SqliteStorage) Search(ctx context.Context, query string, pagination *models.Pagination) ([]models.SongSearchResult, int, error) {
+	terms := textsearch.Terms(query)
+	if len(terms) == 0 {
+		return nil, 0, nil
+	}
+
+	where := "1=1"
+	var params []interface{}
+	for _, term := range terms {
+		if !isASCII(term) {
+			continue
+		}
+		where += ` AND (group_name || ' ' || song_name || ' ' || COALESCE(text, '')) LIKE ?`
+		params = append(params, "%"+term+"%")
+	}
+	sqlQuery := `SELECT id, group_name, song_name, release_date, text, link, created_at, updated_at, version, enrichment_status, edited_fields, detail_sources FROM songs WHERE ` + where
+
+	rows, err := s.q.QueryContext(ctx, sqlQuery, params...)
+	if err != nil {
+		utils.Logger.Error("SqliteStorage.Search - query failed", zap.Error(err), zap.String("query", query))
+		return nil, 0, fmt.Errorf("SqliteStorage.Search - query failed: %w", err)
+	}
+	defer rows.Close()
+
+	var songs []models.Song
+	for rows.Next() {
+		var song models.Song
+		err := rows.Scan(
+			&song.ID, &song.GroupName, &song.SongName, &song.ReleaseDate, &song.Text, &song.Link, &song.CreatedAt, &song.UpdatedAt, &song.Version, &song.EnrichmentStatus, fieldList{&song.EditedFields}, sourceMap{&song.Sources},
+		)
+		if err != nil {
+			utils.Logger.Error("SqliteStorage.Search - rows.Scan failed", zap.Error(err))
+			return nil, 0, fmt.Errorf("SqliteStorage.Search - rows.Scan failed: %w", err)
+		}
+		songs = append(songs, song)
+	}
+
+	if err := rows.Err(); err != nil {
+		utils.Logger.Error("SqliteStorage.Search - rows.Err failed", zap.Error(err))
+		return nil, 0, fmt.Errorf("SqliteStorage.Search - rows.Err failed: %w", err)
+	}
+
+	results, total := textsearch.Search(songs, query, pagination)
+	return results, total, nil
+}
+
+// fuzzyMatches returns the songs matching a fuzzy filter, ordered by similarity
+// and then by filter.Sort. SQLite has no trigram support, so the names are
+// compared in Go with the songs matching the rest of the filter.
+func (s *SqliteStorage) fuzzyMatches(ctx context.Context, filter *models.SongFilter) ([]models.Song, error) {
+	where, params := buildFilter(filter)
+	query := `SELECT id, group_name, song_name, release_date, text, link, created_at, updated_at, version, enrichment_status, edited_fields, detail_sources FROM songs WHERE ` + where
+	query += " ORDER BY " + orderBy(filter.Sort)
+
+	rows, err := s.q.QueryContext(ctx, query, params...)
+	if err != nil {
+		utils.Logger.Error("SqliteStorage.fuzzyMatches - query failed", zap.Error(err), zap.Any("filter", filter))
+		return nil, fmt.Errorf("SqliteStorage.fuzzyMatches - query failed: %w", err)
+	}
+	defer rows.Close()
+
+	var songs []models.Song
+	for rows.Next() {
+		var song models.Song
+		err := rows.Scan(
+			&song.ID, &song.GroupName, &song.SongName, &song.ReleaseDate, &song.Text, &song.Link, &song.CreatedAt, &song.UpdatedAt, &song.Version, &song.EnrichmentStatus, fieldList{&song.EditedFields}, sourceMap{&song.Sources},
+		)
+		if err != nil {
+			utils.Logger.Error("SqliteStorage.fuzzyMatches - rows.Scan failed", zap.Error(err))
+			return nil, fmt.Errorf("SqliteStorage.fuzzyMatches - rows.Scan failed: %w", err)
+		}
+		if similarity, ok := trigram.Match(song, filter); ok {
+			song.Similarity = &similarity
+			songs = append(songs, song)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		utils.Logger.Error("SqliteStorage.fuzzyMatches - rows.Err failed", zap.Error(err))
+		return nil, fmt.Errorf("SqliteStorage.fuzzyMatches - rows.Err failed: %w", err)
+	}
+
+	sort.SliceStable(songs, func(i, j int) bool { return *songs[i].Similarity > *songs[j].Similarity })
+	return songs, nil
+}
+
+func (s *SqliteStorage) Suggest(ctx context.Context, filter *models.SongFilter) ([]models.NameSuggestion, error) {
+	var suggestions []models.NameSuggestion
+	for _, name := range []struct {
+		field  string
+		column string
+		value  *string
+	}{{"group", "group_name", filter.GroupName}, {"song", "song_name", filter.SongName}} {
+		if name.value == nil || *name.value == "" {
+			continue
+		}
+		rows, err := s.q.QueryContext(ctx, `SELECT DISTINCT `+name.column+` FROM songs`)
+		if err != nil {
+			utils.Logger.Error("SqliteStorage.Suggest - query failed", zap.Error(err), zap.String("field", name.field))
+			return nil, fmt.Errorf("SqliteStorage.Suggest - query failed: %w", err)
+		}
+		var names []string
+		for rows.Next() {
+			var value string
+			if err := rows.Scan(&value); err != nil {
+				rows.Close()
+				utils.Logger.Error("SqliteStorage.Suggest - rows.Scan failed", zap.Error(err))
+				return nil, fmt.Errorf("SqliteStorage.Suggest - rows.Scan failed: %w", err)
+			}
+			names = append(names, value)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			utils.Logger.Error("SqliteStorage.Suggest - rows.Err failed", zap.Error(err))
+			return nil, fmt.Errorf("SqliteStorage.Suggest - rows.Err failed: %w", err)
+		}
+		suggestions = append(suggestions, trigram.Suggest(name.field, names, *name.value)...)
+	}
+	return suggestions, nil
+}
+
+func (s *SqliteStorage) Count(ctx context.Context, filter *models.SongFilter) (int, error) {
+	if filter.IsFuzzy() {
+		songs, err := s.fuzzyMatches(ctx, filter)
+		if err != nil {
+			return 0, fmt.Errorf("SqliteStorage.Count - fuzzyMatches failed: %w", err)
+		}
+		return len(songs), nil
+	}
+
+	where, params := buildFilter(filter)
+	query := `SELECT COUNT(*) FROM songs WHERE ` + where
+
+	var count int
+	if err := s.q.QueryRowContext(ctx, query, params...).Scan(&count); err != nil {
+		utils.Logger.Error("SqliteStorage.Count - queryRow failed", zap.Error(err), zap.Any("filter", filter))
+		return 0, fmt.Errorf("SqliteStorage.Count - queryRow failed: %w", err)
+	}
+	return count, nil
+}
+
+// buildFilter renders filter as a WHERE condition. It uses LIKE, which SQLite
+// evaluates case-insensitively for ASCII characters only.
+func buildFilter(filter *models.SongFilter) (string, []interface{}) {
+	where := "1=1"
+	var params []interface{}
+	add := func(condition string, value interface{}) {
+		where += " AND " + condition
+		params = append(params, value)
+	}
+
+	if filter == nil {
+		return where, params
+	}
+
+	// Fuzzy name conditions are checked in Go, see fuzzyMatches.
+	if !filter.IsFuzzy() {
+		if filter.GroupName != nil && *filter.GroupName != "" {
+			add(`group_name LIKE ? ESCAPE '\'`, filter.Match.LikePattern(*filter.GroupName))
+		}
+		if filter.SongName != nil && *filter.SongName != "" {
+			add(`song_name LIKE ? ESCAPE '\'`, filter.Match.LikePattern(*filter.SongName))
+		}
+	}
+	if filter.ReleaseDateFrom != nil {
+		add("release_date >= ?", *filter.ReleaseDateFrom)
+	}
+	if filter.ReleaseDateTo != nil {
+		add("release_date <= ?", *filter.ReleaseDateTo)
+	}
+	if filter.HasText != nil {
+		where += " AND " + presence("text", *filter.HasText)
+	}
+	if filter.HasLink != nil {
+		where += " AND " + presence("link", *filter.HasLink)
+	}
+	// Timestamps are stored as UTC text, so bounds are converted to UTC to compare as text.
+	if filter.CreatedFrom != nil {
+		add("created_at >= ?", filter.CreatedFrom.UTC())
+	}
+	if filter.CreatedTo != nil {
+		add("created_at <= ?", filter.CreatedTo.UTC())
+	}
+	if filter.UpdatedFrom != nil {
+		add("updated_at >= ?", filter.UpdatedFrom.UTC())
+	}
+	if filter.UpdatedTo != nil {
+		add("updated_at <= ?", filter.UpdatedTo.UTC())
+	}
+
+	return where, params
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// presence renders a condition on column having a non-empty value.
+func presence(column string, present bool) string {
+	if present {
+		return fmt.Sprintf("COALESCE(%s, '') <> ''", column)
+	}
+	return fmt.Sprintf("COALESCE(%s, '') = ''", column)
+}
+
+func (s *SqliteStorage) Update(ctx context.Context, song *models.Song) (*models.Song, error) {
+	query := `
+        UPDATE songs
+        SET group_name = ?, song_name = ?, release_date = ?, text = ?, link = ?, updated_at = ?, version = version + 1,
+            group_key = COALESCE(NULLIF(?, ''), group_key), song_key = COALESCE(NULLIF(?, ''), song_key), edited_fields = ?,
+            detail_sources = ?
+        WHERE id = ? AND (? = 0 OR version = ?)
+        RETURNING id, group_name, song_name, release_date, text, link, created_at, updated_at, version, enrichment_status, edited_fields, detail_sources
+    `
+	var updatedSong models.Song
+	err := s.q.QueryRowContext(
+		ctx,
+		query,
+		song.GroupName, song.SongName, song.ReleaseDate, song.Text, song.Link, time.Now().UTC(), song.GroupKey, song.SongKey, strings.Join(song.EditedFields, ","), encodeSources(song.Sources), song.ID, song.Version, song.Version,
+	).Scan(
+		&updatedSong.ID, &updatedSong.GroupName, &updatedSong.SongName, &updatedSong.ReleaseDate, &updatedSong.Text, &updatedSong.Link, &updatedSong.CreatedAt, &updatedSong.UpdatedAt, &updatedSong.Version, &updatedSong.EnrichmentStatus, fieldList{&updatedSong.EditedFields}, sourceMap{&updatedSong.Sources},
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, s.unchanged(ctx, song.ID, song.Version)
+		}
+		if isUniqueViolation(err) {
+			return nil, storage.ErrSongAlreadyExists
+		}
+		utils.Logger.Error("SqliteStorage.Update - queryRow failed", zap.Error(err), zap.Int("id", song.ID))
+		return nil, fmt.Errorf("SqliteStorage.Update - queryRow failed: %w", err)
+	}
+	return &updatedSong, nil
+}
+
+func (s *SqliteStorage) Delete(ctx context.Context, id int, version int) error {
+	result, err := s.q.ExecContext(ctx, "DELETE FROM songs WHERE id = ? AND (? = 0 OR version = ?)", id, version, version)
+	if err != nil {
+		utils.Logger.Error("SqliteStorage.Delete - exec failed", zap.Error(err), zap.Int("id", id))
+		return fmt.Errorf("SqliteStorage.Delete - exec failed: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		utils.Logger.Error("SqliteStorage.Delete - rowsAffected failed", zap.Error(err), zap.Int("id", id))
+		return fmt.Errorf("SqliteStorage.Delete - rowsAffected failed: %w", err)
+	}
+	if rowsAffected == 0 {
+		return s.unchanged(ctx, id, version)
+	}
+	return nil
+}
+
+func (s *SqliteStorage) Identities(ctx context.Context) ([]models.SongIdentity, error) {
+	rows, err := s.q.QueryContext(ctx, `SELECT id, group_name, song_name, COALESCE(group_key, ''), COALESCE(song_key, '') FROM songs ORDER BY id`)
+	if err != nil {
+		utils.Logger.Error("SqliteStorage.Identities - query failed", zap.Error(err))
+		return nil, fmt.Errorf("SqliteStorage.Identities - query failed: %w", err)
+	}
+	defer rows.Close()
+
+	var identities []models.SongIdentity
+	for rows.Next() {
+		var identity models.SongIdentity
+		if err := rows.Scan(&identity.ID, &identity.GroupName, &identity.SongName, &identity.GroupKey, &identity.SongKey); err != nil {
+			utils.Logger.Error("SqliteStorage.Identities - rows.Scan failed", zap.Error(err))
+			return nil, fmt.Errorf("SqliteStorage.Identities - rows.Scan failed: %w", err)
+		}
+		identities = append(identities, identity)
+	}
+	if err := rows.Err(); err != nil {
+		utils.Logger.Error("SqliteStorage.Identities - rows.Err failed", zap.Error(err))
+		return nil, fmt.Errorf("SqliteStorage.Identities - rows.Err failed: %w", err)
+	}
+	return identities, nil
+}
+
+func (s *SqliteStorage) SetKeys(ctx context.Context, id int, groupKey, songKey string) error {
+	result, err := s.q.ExecContext(ctx, "UPDATE songs SET group_key = NULLIF(?, ''), song_key = NULLIF(?, '') WHERE id = ?", groupKey, songKey, id)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return storage.ErrSongAlreadyExists
+		}
+		utils.Logger.Error("SqliteStorage.SetKeys - exec failed", zap.Error(err), zap.Int("id", id))
+		return fmt.Errorf("SqliteStorage.SetKeys - exec failed: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		utils.Logger.Error("SqliteStorage.SetKeys - rowsAffected failed", zap.Error(err), zap.Int("id", id))
+		return fmt.Errorf("SqliteStorage.SetKeys - rowsAffected failed: %w", err)
+	}
+	if rowsAffected == 0 {
+		return storage.ErrSongNotFound
+	}
+	return nil
+}
+
+// unchanged explains why a conditional write of the song matched no row: either
+// the song does not exist or its version is not the expected one.
+func (s *SqliteStorage) unchanged(ctx context.Context, id int, version int) error {
+	if version == 0 {
+		return storage.ErrSongNotFound
+	}
+	var exists bool
+	if err := s.q.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM songs WHERE id = ?)", id).Scan(&exists); err != nil {
+		utils.Logger.Error("SqliteStorage.unchanged - queryRow failed", zap.Error(err), zap.Int("id", id))
+		return fmt.Errorf("SqliteStorage.unchanged - queryRow failed: %w", err)
+	}
+	if exists {
+		return storage.ErrVersionMismatch
+	}
+	return storage.ErrSongNotFound
+}
+
+// isUniqueViolation reports whether err is a UNIQUE constraint failure.
+func isUniqueViolation(err error) bool {
+	var sqliteErr *modernc.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE
+}
+
+// fieldList scans the comma-separated edited_fields column into a slice.
+type fieldList struct {
+	fields *[]string
+}
+
+func (l fieldList) Scan(src any) error {
+	var value string
+	switch src := src.(type) {
+	case string:
+		value = src
+	case []byte:
+		value = string(src)
+	case nil:
+	default:
+		return fmt.Errorf("unsupported edited_fields type %T", src)
+	}
+	*l.fields = nil
+	if value != "" {
+		*l.fields = strings.Split(value, ",")
+	}
+	return nil
+}
+
+// sourceMap scans the detail_sources JSON object into a map, nil when empty.
+type sourceMap struct {
+	sources *map[string]string
+}
+
+func (m sourceMap) Scan(src any) error {
+	var value []byte
+	switch src := src.(type) {
+	case string:
+		value = []byte(src)
+	case []byte:
+		value = src
+	case nil:
+	default:
+		return fmt.Errorf("unsupported detail_sources type %T", src)
+	}
+	*m.sources = nil
+	if len(value) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(value, m.sources); err != nil {
+		return fmt.Errorf("invalid detail_sources: %w", err)
+	}
+	if len(*m.sources) == 0 {
+		*m.sources = nil
+	}
+	return nil
+}
+
+// encodeSources encodes sources for the detail_sources column.
+func encodeSources(sources map[string]string) string {
+	if len(sources) == 0 {
+		return "{}"
+	}
+	value, _ := json.Marshal(sources)
+	return string(value)
+}
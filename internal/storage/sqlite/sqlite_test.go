@@ -0,0 +1,40 @@
+package sqlite_test
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"songlibrary/internal/lib/logger/utils"
+	"songlibrary/internal/migrator"
+	"songlibrary/internal/storage"
+	"songlibrary/internal/storage/sqlite"
+	"songlibrary/internal/storage/storagetest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	if err := utils.InitLogger(); err != nil {
+		log.Fatalf("Failed to initialize logger: %v", err)
+	}
+	exitCode := m.Run()
+	utils.Logger.Sync()
+	os.Exit(exitCode)
+}
+
+func TestSqliteStorage(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.SongStorage {
+		db, err := sqlite.NewDB(context.Background(), filepath.Join(t.TempDir(), "songlibrary.db"))
+		require.NoError(t, err)
+		t.Cleanup(func() { db.Close() })
+
+		m, err := migrator.NewSQLite(db, 0)
+		require.NoError(t, err)
+		require.NoError(t, m.Up())
+		require.NoError(t, m.Close())
+		return sqlite.NewSqliteStorage(db)
+	})
+}
@@ -2,20 +2,136 @@ package storage
 
 import (
 	"context"
-	"database/sql"
 	"errors"
 	"songlibrary/internal/models"
+	"time"
 )
 
 var ErrSongNotFound = errors.New("song not found")
 
+// ErrVersionMismatch is returned when a song is changed with an expected version
+// that is not its current version, i.e. someone else changed it in between.
+var ErrVersionMismatch = errors.New("song version mismatch")
+
+// ErrSongAlreadyExists is returned by Create and Update when another song has the
+// same group and song names, or the same non-empty GroupKey and SongKey.
+var ErrSongAlreadyExists = errors.New("song already exists")
+
+// ErrJobNotFound is returned for songs that have no enrichment job.
+var ErrJobNotFound = errors.New("enrichment job not found")
+
 //go:generate mockgen -destination=mocks/mock_storage.go -package=mocks songlibrary/internal/storage SongStorage
 
 type SongStorage interface {
-	Create(ctx context.Context, song *models.Song, tx *sql.Tx) (*models.Song, error)
+	Create(ctx context.Context, song *models.Song) (*models.Song, error)
 	GetByID(ctx context.Context, id int) (*models.Song, error)
+	// GetByKey returns the song whose GroupKey and SongKey are the given keys.
+	GetByKey(ctx context.Context, groupKey, songKey string) (*models.Song, error)
+	// List returns songs ordered by filter.Sort, ties broken by id. When pagination.After
+	// is set it returns the songs following the cursor (keyset pagination) instead of
+	// using an offset; the cursor must have been issued for the same sort.
+	// Fuzzy filters order songs by their Similarity first and do not support cursors.
 	List(ctx context.Context, filter *models.SongFilter, pagination *models.Pagination) ([]models.Song, error)
+	// Count returns the number of songs matching filter, ignoring pagination.
+	Count(ctx context.Context, filter *models.SongFilter) (int, error)
+	// Suggest returns, for each name condition of filter, up to models.MaxSuggestions
+	// existing names at least models.SuggestionThreshold similar to its value, most similar first.
+	Suggest(ctx context.Context, filter *models.SongFilter) ([]models.NameSuggestion, error)
+	// Search finds songs whose names or text match the words of query, most relevant
+	// first, and returns a page of them along with the total number of matches.
+	Search(ctx context.Context, query string, pagination *models.Pagination) ([]models.SongSearchResult, int, error)
+	// Update stores song and increments its version. When song.Version is not zero the
+	// update only happens if it is the current version, otherwise it fails with ErrVersionMismatch.
+	// Empty keys keep the stored ones.
 	Update(ctx context.Context, song *models.Song) (*models.Song, error)
-	Delete(ctx context.Context, id int) error
-	BeginTx(ctx context.Context) (*sql.Tx, error)
+	// Delete removes the song. A non-zero version must be its current version, as for Update.
+	Delete(ctx context.Context, id int, version int) error
+	// WithTx runs fn as a single unit of work. The SongStorage passed to fn is bound to
+	// the transaction: the work is committed when fn returns nil and rolled back otherwise.
+	// Calling WithTx on a transactional storage opens a nested unit (savepoint).
+	WithTx(ctx context.Context, fn func(tx SongStorage) error) error
+}
+
+// KeyStore is implemented by the persistent storages, to fill in the identity keys
+// of songs stored before keys existed.
+type KeyStore interface {
+	// Identities returns the identities of all songs, ordered by id.
+	Identities(ctx context.Context) ([]models.SongIdentity, error)
+	// SetKeys stores the identity keys of a song without changing its version.
+	// Empty keys are stored as NULL.
+	SetKeys(ctx context.Context, id int, groupKey, songKey string) error
+}
+
+// IdempotencyStore is implemented by storages that keep the responses of write
+// requests made with an Idempotency-Key.
+type IdempotencyStore interface {
+	// ReserveIdempotencyKey records key as in progress for ttl and returns nil. When
+	// an unexpired record of key exists it is returned instead and nothing is stored.
+	ReserveIdempotencyKey(ctx context.Context, key, fingerprint string, ttl time.Duration) (*models.IdempotencyRecord, error)
+	// CompleteIdempotencyKey stores the response and the fingerprint of the request
+	// holding record.Key.
+	CompleteIdempotencyKey(ctx context.Context, record *models.IdempotencyRecord) error
+	// ReleaseIdempotencyKey forgets a key still in progress, so that the request can be retried.
+	ReleaseIdempotencyKey(ctx context.Context, key string) error
+	// DeleteExpiredIdempotencyKeys removes the expired records and returns their number.
+	DeleteExpiredIdempotencyKeys(ctx context.Context) (int, error)
+}
+
+// LookupCache is implemented by storages that keep the results of external API
+// lookups across restarts and instances.
+type LookupCache interface {
+	// GetCachedLookup returns the unexpired result of looking up the song of group, or nil.
+	GetCachedLookup(ctx context.Context, group, song string) (*models.CachedLookup, error)
+	// PutCachedLookup stores lookup, replacing the previous result for the song.
+	PutCachedLookup(ctx context.Context, lookup *models.CachedLookup) error
+	// DeleteExpiredLookups removes the expired results and returns their number.
+	DeleteExpiredLookups(ctx context.Context) (int, error)
+}
+
+// EnrichmentQueue is implemented by storages that keep the jobs fetching the details
+// of songs added without them. Jobs are part of the transactional state, so that a
+// song and its job are added in the same transaction.
+type EnrichmentQueue interface {
+	// EnqueueEnrichment marks the song pending and schedules a job for it at once. A
+	// pending or running job is kept as is, a done or dead one is reset. It fails with
+	// ErrSongNotFound when the song does not exist.
+	EnqueueEnrichment(ctx context.Context, songID int) error
+	// ClaimEnrichmentJobs marks up to limit due jobs running for lease and returns them
+	// with the attempt counted. Running jobs whose lease expired are due
+	// again, as their worker stopped. Concurrent claims never return the same job.
+	ClaimEnrichmentJobs(ctx context.Context, limit int, lease time.Duration) ([]models.EnrichmentJob, error)
+	// CompleteEnrichmentJob marks a running job done and its song complete, filling in
+	// the release date, text and link of the song that are still NULL from details.
+	// Like RetryEnrichmentJob and FailEnrichmentJob, it does nothing unless the job is
+	// running the attempt claimed, so that a worker whose lease expired cannot finish
+	// the job claimed again by another.
+	CompleteEnrichmentJob(ctx context.Context, songID, attempts int, details *models.Song) error
+	// RetryEnrichmentJob returns a running job to pending, due after delay.
+	RetryEnrichmentJob(ctx context.Context, songID, attempts int, delay time.Duration, lastError string) error
+	// FailEnrichmentJob marks a running job dead and its song failed.
+	FailEnrichmentJob(ctx context.Context, songID, attempts int, lastError string) error
+	// GetEnrichmentJob returns the job of the song, or ErrJobNotFound.
+	GetEnrichmentJob(ctx context.Context, songID int) (*models.EnrichmentJob, error)
+}
+
+// StatsReporter is implemented by storages backed by a connection pool.
+type StatsReporter interface {
+	Stats() PoolStats
+}
+
+type PoolStats struct {
+	TotalConns           int32         `json:"totalConns"`
+	IdleConns            int32         `json:"idleConns"`
+	AcquiredConns        int32         `json:"acquiredConns"`
+	ConstructingConns    int32         `json:"constructingConns"`
+	MaxConns             int32         `json:"maxConns"`
+	AcquireCount         int64         `json:"acquireCount"`
+	EmptyAcquireCount    int64         `json:"emptyAcquireCount"`
+	CanceledAcquireCount int64         `json:"canceledAcquireCount"`
+	AcquireDuration      time.Duration `json:"acquireDurationNs" swaggertype:"integer"`
+	NewConnsCount        int64         `json:"newConnsCount"`
+	MaxLifetimeDestroys  int64         `json:"maxLifetimeDestroyCount"`
+	MaxIdleDestroys      int64         `json:"maxIdleDestroyCount"`
+	// WaitCount is the number of connections waited for, reported by database/sql pools only.
+	WaitCount int64 `json:"waitCount,omitempty"`
 }
@@ -0,0 +1,980 @@
+// Package storagetest is a conformance suite shared by all storage.SongStorage
+// implementations, so that every backend behaves the same way for the service.
+package storagetest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"songlibrary/internal/models"
+	"songlibrary/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Run executes the suite. newStorage must return an empty storage on every call.
+func Run(t *testing.T, newStorage func(t *testing.T) storage.SongStorage) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s storage.SongStorage)
+	}{
+		{"CRUD", testCRUD},
+		{"Unique", testUnique},
+		{"Keys", testKeys},
+		{"Version", testVersion},
+		{"List", testList},
+		{"Sort", testSort},
+		{"SortCase", testSortCase},
+		{"Filter", testFilter},
+		{"Fuzzy", testFuzzy},
+		{"Search", testSearch},
+		{"WithTx", testWithTx},
+		{"Concurrent", testConcurrent},
+		{"Idempotency", testIdempotency},
+		{"Enrichment", testEnrichment},
+		{"LookupCache", testLookupCache},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.fn(t, newStorage(t))
+		})
+	}
+}
+
+func testCRUD(t *testing.T, s storage.SongStorage) {
+	ctx := context.Background()
+
+	created, err := s.Create(ctx, &models.Song{
+		GroupName:    "Muse",
+		SongName:     "Starlight",
+		ReleaseDate:  sql.NullString{String: "2006-09-04", Valid: true},
+		Text:         sql.NullString{String: "Far away", Valid: true},
+		EditedFields: []string{models.FieldText},
+		Sources:      map[string]string{models.FieldReleaseDate: "api"},
+	})
+	require.NoError(t, err)
+	assert.NotZero(t, created.ID)
+	assert.Equal(t, "2006-09-04", created.ReleaseDate.String)
+	assert.False(t, created.Link.Valid)
+	assert.False(t, created.CreatedAt.IsZero())
+	assert.Equal(t, models.EnrichmentComplete, created.EnrichmentStatus)
+
+	fetched, err := s.GetByID(ctx, created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, created.GroupName, fetched.GroupName)
+	assert.Equal(t, created.SongName, fetched.SongName)
+	assert.Equal(t, created.ReleaseDate, fetched.ReleaseDate)
+	assert.Equal(t, created.Text, fetched.Text)
+	assert.Equal(t, []string{models.FieldText}, fetched.EditedFields)
+	assert.Equal(t, map[string]string{models.FieldReleaseDate: "api"}, fetched.Sources)
+	assert.True(t, created.CreatedAt.Equal(fetched.CreatedAt))
+
+	fetched.SongName = "Uprising"
+	fetched.Text = sql.NullString{}
+	fetched.EditedFields = []string{models.FieldReleaseDate, models.FieldText}
+	updated, err := s.Update(ctx, fetched)
+	require.NoError(t, err)
+	assert.Equal(t, "Uprising", updated.SongName)
+	assert.False(t, updated.Text.Valid)
+	assert.Equal(t, []string{models.FieldReleaseDate, models.FieldText}, updated.EditedFields)
+
+	updated.EditedFields = nil
+	updated.Sources = nil
+	updated, err = s.Update(ctx, updated)
+	require.NoError(t, err)
+	assert.Empty(t, updated.EditedFields)
+	assert.Empty(t, updated.Sources)
+	assert.True(t, created.CreatedAt.Equal(updated.CreatedAt))
+
+	require.NoError(t, s.Delete(ctx, created.ID, 0))
+	_, err = s.GetByID(ctx, created.ID)
+	assert.ErrorIs(t, err, storage.ErrSongNotFound)
+	assert.ErrorIs(t, s.Delete(ctx, created.ID, 0), storage.ErrSongNotFound)
+	_, err = s.Update(ctx, created)
+	assert.ErrorIs(t, err, storage.ErrSongNotFound)
+}
+
+func testUnique(t *testing.T, s storage.SongStorage) {
+	ctx := context.Background()
+
+	first, err := s.Create(ctx, &models.Song{GroupName: "Muse", SongName: "Starlight"})
+	require.NoError(t, err)
+	_, err = s.Create(ctx, &models.Song{GroupName: "Muse", SongName: "Starlight"})
+	assert.ErrorIs(t, err, storage.ErrSongAlreadyExists)
+
+	second, err := s.Create(ctx, &models.Song{GroupName: "Muse", SongName: "Uprising"})
+	require.NoError(t, err)
+	second.SongName = first.SongName
+	_, err = s.Update(ctx, second)
+	assert.ErrorIs(t, err, storage.ErrSongAlreadyExists)
+}
+
+func testKeys(t *testing.T, s storage.SongStorage) {
+	ctx := context.Background()
+
+	beatles, err := s.Create(ctx, &models.Song{GroupName: "The Beatles", SongName: "Help!", GroupKey: "beatles", SongKey: "help!"})
+	require.NoError(t, err)
+	_, err = s.Create(ctx, &models.Song{GroupName: "Beatles, The", SongName: "help!", GroupKey: "beatles", SongKey: "help!"})
+	assert.ErrorIs(t, err, storage.ErrSongAlreadyExists)
+
+	existing, err := s.GetByKey(ctx, "beatles", "help!")
+	require.NoError(t, err)
+	assert.Equal(t, beatles.ID, existing.ID)
+	_, err = s.GetByKey(ctx, "beatles", "yesterday")
+	assert.ErrorIs(t, err, storage.ErrSongNotFound)
+
+	// Songs without keys never conflict on them.
+	unkeyed, err := s.Create(ctx, &models.Song{GroupName: "the beatles", SongName: "Help!"})
+	require.NoError(t, err)
+	_, err = s.Create(ctx, &models.Song{GroupName: "the beatles ", SongName: "Help!"})
+	require.NoError(t, err)
+
+	existing.SongName, existing.SongKey, existing.GroupKey = "Yesterday", "yesterday", "beatles"
+	_, err = s.Update(ctx, existing)
+	require.NoError(t, err)
+	_, err = s.GetByKey(ctx, "beatles", "help!")
+	assert.ErrorIs(t, err, storage.ErrSongNotFound)
+
+	// An update without keys keeps the stored ones.
+	existing.SongKey, existing.GroupKey, existing.Version = "", "", 0
+	existing.Text = sql.NullString{String: "Yesterday, all my troubles seemed so far away", Valid: true}
+	_, err = s.Update(ctx, existing)
+	require.NoError(t, err)
+	fetched, err := s.GetByKey(ctx, "beatles", "yesterday")
+	require.NoError(t, err)
+	assert.Equal(t, existing.Text, fetched.Text)
+	_, err = s.Create(ctx, &models.Song{GroupName: "Beatles", SongName: "Yesterday", GroupKey: "beatles", SongKey: "yesterday"})
+	assert.ErrorIs(t, err, storage.ErrSongAlreadyExists)
+
+	keyStore, ok := s.(storage.KeyStore)
+	if !ok {
+		return
+	}
+	require.NoError(t, keyStore.SetKeys(ctx, unkeyed.ID, "beatles", "help!"))
+	assert.ErrorIs(t, keyStore.SetKeys(ctx, unkeyed.ID, "beatles", "yesterday"), storage.ErrSongAlreadyExists)
+	assert.ErrorIs(t, keyStore.SetKeys(ctx, 0, "beatles", "let it be"), storage.ErrSongNotFound)
+
+	identities, err := keyStore.Identities(ctx)
+	require.NoError(t, err)
+	require.Len(t, identities, 3)
+	assert.Equal(t, models.SongIdentity{ID: beatles.ID, GroupName: "The Beatles", SongName: "Yesterday", GroupKey: "beatles", SongKey: "yesterday"}, identities[0])
+	assert.Equal(t, models.SongIdentity{ID: unkeyed.ID, GroupName: "the beatles", SongName: "Help!", GroupKey: "beatles", SongKey: "help!"}, identities[1])
+	assert.Empty(t, identities[2].GroupKey)
+
+	fetched, err = s.GetByID(ctx, unkeyed.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, fetched.Version, "SetKeys must not change the version")
+}
+
+func testVersion(t *testing.T, s storage.SongStorage) {
+	ctx := context.Background()
+
+	created, err := s.Create(ctx, &models.Song{GroupName: "Muse", SongName: "Starlight"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, created.Version)
+
+	first := *created
+	first.SongName = "Uprising"
+	updated, err := s.Update(ctx, &first)
+	require.NoError(t, err)
+	assert.Equal(t, 2, updated.Version)
+
+	stale := *created
+	stale.SongName = "Hysteria"
+	_, err = s.Update(ctx, &stale)
+	assert.ErrorIs(t, err, storage.ErrVersionMismatch, "Expected an update of a stale version to fail")
+	fetched, err := s.GetByID(ctx, created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Uprising", fetched.SongName)
+	assert.Equal(t, 2, fetched.Version)
+
+	unconditional := *created
+	unconditional.Version = 0
+	unconditional.SongName = "Hysteria"
+	updated, err = s.Update(ctx, &unconditional)
+	require.NoError(t, err)
+	assert.Equal(t, 3, updated.Version)
+
+	assert.ErrorIs(t, s.Delete(ctx, created.ID, 2), storage.ErrVersionMismatch)
+	assert.ErrorIs(t, s.Delete(ctx, created.ID+100, 2), storage.ErrSongNotFound)
+	require.NoError(t, s.Delete(ctx, created.ID, 3))
+	_, err = s.Update(ctx, updated)
+	assert.ErrorIs(t, err, storage.ErrSongNotFound)
+}
+
+func testList(t *testing.T, s storage.SongStorage) {
+	ctx := context.Background()
+	for _, song := range []models.Song{
+		{GroupName: "Muse", SongName: "Starlight"},
+		{GroupName: "Muse", SongName: "Uprising"},
+		{GroupName: "Metallica", SongName: "One"},
+		{GroupName: "100% Pure", SongName: "Percent"},
+	} {
+		_, err := s.Create(ctx, &song)
+		require.NoError(t, err)
+	}
+
+	testCases := []struct {
+		name       string
+		filter     *models.SongFilter
+		pagination *models.Pagination
+		expected   []string
+	}{
+		{
+			name:       "No filter",
+			pagination: models.NewPagination(1, 10),
+			expected:   []string{"Starlight", "Uprising", "One", "Percent"},
+		},
+		{
+			name:       "Case-insensitive substring",
+			filter:     &models.SongFilter{GroupName: stringPointer("mUs")},
+			pagination: models.NewPagination(1, 10),
+			expected:   []string{"Starlight", "Uprising"},
+		},
+		{
+			name:       "Wildcards",
+			filter:     &models.SongFilter{GroupName: stringPointer("M_t%ca")},
+			pagination: models.NewPagination(1, 10),
+			expected:   []string{"One"},
+		},
+		{
+			name:       "Escaped wildcard",
+			filter:     &models.SongFilter{GroupName: stringPointer(`0\%`)},
+			pagination: models.NewPagination(1, 10),
+			expected:   []string{"Percent"},
+		},
+		{
+			name:       "Both filters",
+			filter:     &models.SongFilter{GroupName: stringPointer("muse"), SongName: stringPointer("RISING")},
+			pagination: models.NewPagination(1, 10),
+			expected:   []string{"Uprising"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			songs, err := s.List(ctx, tc.filter, tc.pagination)
+			require.NoError(t, err)
+			assert.ElementsMatch(t, tc.expected, songNames(songs))
+
+			count, err := s.Count(ctx, tc.filter)
+			require.NoError(t, err)
+			assert.Equal(t, len(tc.expected), count)
+		})
+	}
+
+	t.Run("Pages", func(t *testing.T) {
+		firstPage, err := s.List(ctx, nil, models.NewPagination(1, 3))
+		require.NoError(t, err)
+		assert.Len(t, firstPage, 3)
+
+		secondPage, err := s.List(ctx, nil, models.NewPagination(2, 3))
+		require.NoError(t, err)
+		assert.Len(t, secondPage, 1)
+
+		pastTheEnd, err := s.List(ctx, nil, models.NewPagination(3, 3))
+		require.NoError(t, err)
+		assert.Empty(t, pastTheEnd)
+	})
+
+	t.Run("Keyset", func(t *testing.T) {
+		all, err := s.List(ctx, nil, models.NewPagination(1, 10))
+		require.NoError(t, err)
+		require.Len(t, all, 4)
+
+		firstPage, err := s.List(ctx, nil, models.NewPagination(1, 2))
+		require.NoError(t, err)
+		assert.Equal(t, songNames(all[:2]), songNames(firstPage))
+
+		// Songs inserted between page fetches must neither shift nor duplicate the next page.
+		_, err = s.Create(ctx, &models.Song{GroupName: "Muse", SongName: "Hysteria"})
+		require.NoError(t, err)
+
+		pagination := &models.Pagination{Page: 1, PageSize: 2, After: models.CursorAfter(firstPage[1], nil)}
+		secondPage, err := s.List(ctx, nil, pagination)
+		require.NoError(t, err)
+		assert.Equal(t, songNames(all[2:]), songNames(secondPage))
+
+		pagination.After = models.CursorAfter(secondPage[1], nil)
+		pagination.PageSize = 10
+		lastPage, err := s.List(ctx, &models.SongFilter{GroupName: stringPointer("muse")}, pagination)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Hysteria"}, songNames(lastPage))
+	})
+}
+
+func testSort(t *testing.T, s storage.SongStorage) {
+	ctx := context.Background()
+	for _, song := range []models.Song{
+		{GroupName: "Muse", SongName: "Starlight", ReleaseDate: sql.NullString{String: "2006-09-04", Valid: true}},
+		{GroupName: "Muse", SongName: "Uprising", ReleaseDate: sql.NullString{String: "2009-09-07", Valid: true}},
+		{GroupName: "Metallica", SongName: "One"},
+		{GroupName: "Metallica", SongName: "Fuel", ReleaseDate: sql.NullString{String: "2009-09-07", Valid: true}},
+	} {
+		_, err := s.Create(ctx, &song)
+		require.NoError(t, err)
+	}
+
+	testCases := []struct {
+		sort     string
+		expected []string
+	}{
+		{sort: "", expected: []string{"Starlight", "Uprising", "One", "Fuel"}},
+		{sort: "-id", expected: []string{"Fuel", "One", "Uprising", "Starlight"}},
+		{sort: "releaseDate", expected: []string{"One", "Starlight", "Uprising", "Fuel"}},
+		{sort: "-releaseDate,group", expected: []string{"Fuel", "Uprising", "Starlight", "One"}},
+		{sort: "group,-song", expected: []string{"One", "Fuel", "Uprising", "Starlight"}},
+		{sort: "-createdAt"},
+		{sort: "updatedAt,-group"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.sort, func(t *testing.T) {
+			sort, err := models.ParseSort(tc.sort)
+			require.NoError(t, err)
+			filter := &models.SongFilter{Sort: sort}
+
+			all, err := s.List(ctx, filter, models.NewPagination(1, 10))
+			require.NoError(t, err)
+			require.Len(t, all, 4)
+			if tc.expected != nil {
+				assert.Equal(t, tc.expected, songNames(all))
+			}
+
+			// Walking the list with cursors must visit the songs in the same order.
+			var walked []models.Song
+			pagination := models.NewPagination(1, 1)
+			for {
+				page, err := s.List(ctx, filter, pagination)
+				require.NoError(t, err)
+				if len(page) == 0 {
+					break
+				}
+				walked = append(walked, page...)
+				require.LessOrEqual(t, len(walked), len(all), "Expected the walk to end")
+
+				cursor, err := models.DecodeCursor(models.CursorAfter(page[0], sort).Encode())
+				require.NoError(t, err)
+				require.NoError(t, cursor.Validate(sort))
+				pagination.After = cursor
+			}
+			assert.Equal(t, songNames(all), songNames(walked))
+		})
+	}
+}
+
+func testSortCase(t *testing.T, s storage.SongStorage) {
+	ctx := context.Background()
+	for _, song := range []models.Song{
+		{GroupName: "muse", SongName: "Starlight"},
+		{GroupName: "Metallica", SongName: "one"},
+		{GroupName: "MUSE", SongName: "Uprising"},
+		{GroupName: "abba", SongName: "Fernando"},
+		{GroupName: "Ärzte", SongName: "Schrei nach Liebe"},
+	} {
+		_, err := s.Create(ctx, &song)
+		require.NoError(t, err)
+	}
+
+	// Names differing in case only are ordered by id.
+	for sortValue, expected := range map[string][]string{
+		"group":       {"Fernando", "one", "Starlight", "Uprising", "Schrei nach Liebe"},
+		"-group":      {"Schrei nach Liebe", "Starlight", "Uprising", "one", "Fernando"},
+		"song,-group": {"Fernando", "one", "Schrei nach Liebe", "Starlight", "Uprising"},
+	} {
+		t.Run(sortValue, func(t *testing.T) {
+			sort, err := models.ParseSort(sortValue)
+			require.NoError(t, err)
+			filter := &models.SongFilter{Sort: sort}
+
+			all, err := s.List(ctx, filter, models.NewPagination(1, 10))
+			require.NoError(t, err)
+			assert.Equal(t, expected, songNames(all))
+
+			var walked []models.Song
+			pagination := models.NewPagination(1, 2)
+			for {
+				page, err := s.List(ctx, filter, pagination)
+				require.NoError(t, err)
+				if len(page) == 0 {
+					break
+				}
+				walked = append(walked, page...)
+				require.LessOrEqual(t, len(walked), len(all), "Expected the walk to end")
+				pagination.After = models.CursorAfter(page[len(page)-1], sort)
+			}
+			assert.Equal(t, expected, songNames(walked))
+		})
+	}
+}
+
+func testFilter(t *testing.T, s storage.SongStorage) {
+	ctx := context.Background()
+	before := time.Now().Add(-time.Minute)
+	for _, song := range []models.Song{
+		{GroupName: "Muse", SongName: "Starlight", ReleaseDate: sql.NullString{String: "2006-09-04", Valid: true}, Text: sql.NullString{String: "Far away", Valid: true}},
+		{GroupName: "Muse", SongName: "Uprising", ReleaseDate: sql.NullString{String: "2009-09-07", Valid: true}, Link: sql.NullString{String: "https://muse.mu", Valid: true}},
+		{GroupName: "Museum", SongName: "Star", Text: sql.NullString{String: "", Valid: true}},
+		{GroupName: "The Muse", SongName: "Stars_"},
+	} {
+		_, err := s.Create(ctx, &song)
+		require.NoError(t, err)
+	}
+	after := time.Now().Add(time.Minute)
+
+	testCases := []struct {
+		name     string
+		filter   *models.SongFilter
+		expected []string
+	}{
+		{
+			name:     "Prefix",
+			filter:   &models.SongFilter{GroupName: stringPointer("mus"), Match: models.MatchPrefix},
+			expected: []string{"Starlight", "Uprising", "Star"},
+		},
+		{
+			name:     "Exact",
+			filter:   &models.SongFilter{GroupName: stringPointer("muse"), Match: models.MatchExact},
+			expected: []string{"Starlight", "Uprising"},
+		},
+		{
+			name:     "Exact takes wildcards literally",
+			filter:   &models.SongFilter{SongName: stringPointer("Star%"), Match: models.MatchExact},
+			expected: nil,
+		},
+		{
+			name:     "Exact with a wildcard character",
+			filter:   &models.SongFilter{SongName: stringPointer("stars_"), Match: models.MatchExact},
+			expected: []string{"Stars_"},
+		},
+		{
+			name:     "Release date range",
+			filter:   &models.SongFilter{ReleaseDateFrom: stringPointer("2006-09-04"), ReleaseDateTo: stringPointer("2008-12-31")},
+			expected: []string{"Starlight"},
+		},
+		{
+			name:     "Release date lower bound",
+			filter:   &models.SongFilter{ReleaseDateFrom: stringPointer("2007-01-01")},
+			expected: []string{"Uprising"},
+		},
+		{
+			name:     "Has text",
+			filter:   &models.SongFilter{HasText: boolPointer(true)},
+			expected: []string{"Starlight"},
+		},
+		{
+			name:     "Has no text",
+			filter:   &models.SongFilter{HasText: boolPointer(false)},
+			expected: []string{"Uprising", "Star", "Stars_"},
+		},
+		{
+			name:     "Has link and name",
+			filter:   &models.SongFilter{HasLink: boolPointer(true), GroupName: stringPointer("muse")},
+			expected: []string{"Uprising"},
+		},
+		{
+			name:     "Created and updated within range",
+			filter:   &models.SongFilter{CreatedFrom: &before, CreatedTo: &after, UpdatedFrom: &before, UpdatedTo: &after},
+			expected: []string{"Starlight", "Uprising", "Star", "Stars_"},
+		},
+		{
+			name:     "Created later",
+			filter:   &models.SongFilter{CreatedFrom: &after},
+			expected: nil,
+		},
+		{
+			name:     "Updated earlier",
+			filter:   &models.SongFilter{UpdatedTo: &before},
+			expected: nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			songs, err := s.List(ctx, tc.filter, models.NewPagination(1, 10))
+			require.NoError(t, err)
+			assert.ElementsMatch(t, tc.expected, songNames(songs))
+
+			count, err := s.Count(ctx, tc.filter)
+			require.NoError(t, err)
+			assert.Equal(t, len(tc.expected), count)
+		})
+	}
+}
+
+func testFuzzy(t *testing.T, s storage.SongStorage) {
+	ctx := context.Background()
+	for _, song := range []models.Song{
+		{GroupName: "Metallica", SongName: "Enter Sandman"},
+		{GroupName: "Metallica", SongName: "Nothing Else Matters"},
+		{GroupName: "Metal Church", SongName: "Watch the Children Pray"},
+		{GroupName: "Megadeth", SongName: "Symphony of Destruction"},
+	} {
+		_, err := s.Create(ctx, &song)
+		require.NoError(t, err)
+	}
+
+	testCases := []struct {
+		name     string
+		filter   *models.SongFilter
+		expected []string
+	}{
+		{
+			name:     "Typo",
+			filter:   &models.SongFilter{GroupName: stringPointer("Metalica"), Match: models.MatchFuzzy},
+			expected: []string{"Enter Sandman", "Nothing Else Matters"},
+		},
+		{
+			name:     "Most similar first",
+			filter:   &models.SongFilter{GroupName: stringPointer("metal"), Match: models.MatchFuzzy},
+			expected: []string{"Watch the Children Pray", "Enter Sandman", "Nothing Else Matters"},
+		},
+		{
+			name:     "Group and song",
+			filter:   &models.SongFilter{GroupName: stringPointer("Metalica"), SongName: stringPointer("enter sandmen"), Match: models.MatchFuzzy},
+			expected: []string{"Enter Sandman"},
+		},
+		{
+			name:     "No match",
+			filter:   &models.SongFilter{GroupName: stringPointer("Scorpions"), Match: models.MatchFuzzy},
+			expected: []string{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			songs, err := s.List(ctx, tc.filter, models.NewPagination(1, 10))
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, songNames(songs))
+			for _, song := range songs {
+				require.NotNil(t, song.Similarity, "Expected similarity of %q", song.SongName)
+				assert.GreaterOrEqual(t, *song.Similarity, models.SimilarityThreshold)
+			}
+
+			count, err := s.Count(ctx, tc.filter)
+			require.NoError(t, err)
+			assert.Equal(t, len(tc.expected), count)
+		})
+	}
+
+	t.Run("Similarity", func(t *testing.T) {
+		songs, err := s.List(ctx, &models.SongFilter{GroupName: stringPointer("Metalica"), Match: models.MatchFuzzy}, models.NewPagination(1, 1))
+		require.NoError(t, err)
+		require.Len(t, songs, 1)
+		assert.InDelta(t, 8.0/11, *songs[0].Similarity, 0.001)
+
+		songs, err = s.List(ctx, &models.SongFilter{GroupName: stringPointer("Metallica")}, models.NewPagination(1, 1))
+		require.NoError(t, err)
+		require.Len(t, songs, 1)
+		assert.Nil(t, songs[0].Similarity, "Expected no similarity outside fuzzy mode")
+	})
+
+	t.Run("Suggest", func(t *testing.T) {
+		suggestions, err := s.Suggest(ctx, &models.SongFilter{GroupName: stringPointer("Metalica"), SongName: stringPointer("Enter Sandmen")})
+		require.NoError(t, err)
+		require.Len(t, suggestions, 3)
+		assert.Equal(t, "group", suggestions[0].Field)
+		assert.Equal(t, "Metallica", suggestions[0].Value)
+		assert.InDelta(t, 8.0/11, suggestions[0].Similarity, 0.001)
+		assert.Equal(t, "group", suggestions[1].Field)
+		assert.Equal(t, "Metal Church", suggestions[1].Value)
+		assert.Equal(t, "song", suggestions[2].Field)
+		assert.Equal(t, "Enter Sandman", suggestions[2].Value)
+
+		suggestions, err = s.Suggest(ctx, &models.SongFilter{GroupName: stringPointer("Scorpions")})
+		require.NoError(t, err)
+		assert.Empty(t, suggestions)
+	})
+}
+
+func testSearch(t *testing.T, s storage.SongStorage) {
+	ctx := context.Background()
+	for _, song := range []models.Song{
+		{GroupName: "Muse", SongName: "Starlight", Text: sql.NullString{String: "This ship is taking me far away\n\nFrom the memories", Valid: true}},
+		{GroupName: "Pink Floyd", SongName: "Wish You Were Here", Text: sql.NullString{String: "So, so you think you can tell\n\nHow I wish, how I wish you were here", Valid: true}},
+		{GroupName: "Far Away Band", SongName: "Echo"},
+	} {
+		_, err := s.Create(ctx, &song)
+		require.NoError(t, err)
+	}
+
+	results, total, err := s.Search(ctx, "AWAY", models.NewPagination(1, 10))
+	require.NoError(t, err)
+	assert.Equal(t, 2, total)
+	require.Len(t, results, 2)
+	assert.Equal(t, "Echo", results[0].SongName, "Expected name matches to rank first")
+	assert.Empty(t, results[0].Snippet)
+	assert.Equal(t, "Starlight", results[1].SongName)
+	assert.Greater(t, results[0].Rank, results[1].Rank)
+	assert.Contains(t, results[1].Snippet, "<mark>away</mark>")
+
+	results, total, err = s.Search(ctx, "wish here", models.NewPagination(1, 10))
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+	require.Len(t, results, 1)
+	assert.Equal(t, "Wish You Were Here", results[0].SongName)
+	assert.Contains(t, results[0].Snippet, "<mark>wish</mark>")
+	assert.Contains(t, results[0].Snippet, "<mark>here</mark>")
+
+	results, total, err = s.Search(ctx, "away", models.NewPagination(2, 1))
+	require.NoError(t, err)
+	assert.Equal(t, 2, total)
+	require.Len(t, results, 1)
+	assert.Equal(t, "Starlight", results[0].SongName)
+
+	results, total, err = s.Search(ctx, "away", models.NewPagination(3, 1))
+	require.NoError(t, err)
+	assert.Equal(t, 2, total)
+	assert.Empty(t, results)
+
+	results, total, err = s.Search(ctx, "nothing", models.NewPagination(1, 10))
+	require.NoError(t, err)
+	assert.Zero(t, total)
+	assert.Empty(t, results)
+
+	// Snippets escape the text, <mark> being their only tag.
+	_, err = s.Create(ctx, &models.Song{GroupName: "Muse", SongName: "Markup", Text: sql.NullString{String: `<img src=x onerror="alert(1)"> & loud`, Valid: true}})
+	require.NoError(t, err)
+	results, _, err = s.Search(ctx, "loud", models.NewPagination(1, 10))
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Contains(t, results[0].Snippet, "<mark>loud</mark>")
+	assert.NotContains(t, results[0].Snippet, "<img")
+}
+
+func testWithTx(t *testing.T, s storage.SongStorage) {
+	ctx := context.Background()
+	existing, err := s.Create(ctx, &models.Song{GroupName: "Muse", SongName: "Starlight"})
+	require.NoError(t, err)
+
+	var committed *models.Song
+	err = s.WithTx(ctx, func(tx storage.SongStorage) error {
+		var err error
+		committed, err = tx.Create(ctx, &models.Song{GroupName: "Muse", SongName: "Hysteria"})
+		return err
+	})
+	require.NoError(t, err, "Expected transaction to commit")
+	_, err = s.GetByID(ctx, committed.ID)
+	assert.NoError(t, err, "Expected committed song to be visible")
+
+	errAbort := errors.New("abort")
+	var rolledBack *models.Song
+	err = s.WithTx(ctx, func(tx storage.SongStorage) error {
+		var err error
+		rolledBack, err = tx.Create(ctx, &models.Song{GroupName: "Muse", SongName: "Uprising"})
+		if err != nil {
+			return err
+		}
+		if err := tx.Delete(ctx, existing.ID, 0); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	assert.ErrorIs(t, err, errAbort)
+	_, err = s.GetByID(ctx, rolledBack.ID)
+	assert.ErrorIs(t, err, storage.ErrSongNotFound, "Expected rolled back insert to be discarded")
+	_, err = s.GetByID(ctx, existing.ID)
+	assert.NoError(t, err, "Expected rolled back delete to be discarded")
+
+	err = s.WithTx(ctx, func(tx storage.SongStorage) error {
+		if _, err := tx.Create(ctx, &models.Song{GroupName: "Muse", SongName: "Knights of Cydonia"}); err != nil {
+			return err
+		}
+		nestedErr := tx.WithTx(ctx, func(nested storage.SongStorage) error {
+			if _, err := nested.Create(ctx, &models.Song{GroupName: "Muse", SongName: "Madness"}); err != nil {
+				return err
+			}
+			return errAbort
+		})
+		assert.ErrorIs(t, nestedErr, errAbort)
+
+		// A failed statement inside a nested unit leaves the transaction usable.
+		duplicateErr := tx.WithTx(ctx, func(nested storage.SongStorage) error {
+			_, err := nested.Create(ctx, &models.Song{GroupName: "Muse", SongName: "Starlight"})
+			return err
+		})
+		assert.ErrorIs(t, duplicateErr, storage.ErrSongAlreadyExists)
+		_, err := tx.Create(ctx, &models.Song{GroupName: "Muse", SongName: "Dead Inside"})
+		return err
+	})
+	require.NoError(t, err)
+
+	songs, err := s.List(ctx, nil, models.NewPagination(1, 10))
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"Starlight", "Hysteria", "Knights of Cydonia", "Dead Inside"}, songNames(songs))
+}
+
+func testConcurrent(t *testing.T, s storage.SongStorage) {
+	ctx := context.Background()
+
+	const workers = 20
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := s.Create(ctx, &models.Song{GroupName: "Group", SongName: fmt.Sprintf("Song %d", i)})
+			assert.NoError(t, err)
+			_, err = s.List(ctx, nil, models.NewPagination(1, 10))
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+
+	songs, err := s.List(ctx, nil, models.NewPagination(1, workers*2))
+	require.NoError(t, err)
+	assert.Len(t, songs, workers)
+}
+
+func songNames(songs []models.Song) []string {
+	names := make([]string, 0, len(songs))
+	for _, song := range songs {
+		names = append(names, song.SongName)
+	}
+	return names
+}
+
+func stringPointer(s string) *string {
+	return &s
+}
+
+func boolPointer(b bool) *bool {
+	return &b
+}
+
+func testIdempotency(t *testing.T, s storage.SongStorage) {
+	store, ok := s.(storage.IdempotencyStore)
+	if !ok {
+		t.Skip("storage does not keep idempotency keys")
+	}
+	ctx := context.Background()
+
+	existing, err := store.ReserveIdempotencyKey(ctx, "key-1", "fingerprint", time.Hour)
+	require.NoError(t, err)
+	assert.Nil(t, existing)
+
+	existing, err = store.ReserveIdempotencyKey(ctx, "key-1", "other", time.Hour)
+	require.NoError(t, err)
+	require.NotNil(t, existing)
+	assert.Equal(t, "fingerprint", existing.Fingerprint)
+	assert.Zero(t, existing.StatusCode, "the first request is still in progress")
+
+	require.NoError(t, store.CompleteIdempotencyKey(ctx, &models.IdempotencyRecord{
+		Key:         "key-1",
+		Fingerprint: "completed",
+		StatusCode:  201,
+		Header:      map[string]string{"Content-Type": "application/json"},
+		Body:        []byte(`{"id":1}`),
+	}))
+	existing, err = store.ReserveIdempotencyKey(ctx, "key-1", "fingerprint", time.Hour)
+	require.NoError(t, err)
+	require.NotNil(t, existing)
+	assert.Equal(t, "completed", existing.Fingerprint)
+	assert.Equal(t, 201, existing.StatusCode)
+	assert.Equal(t, map[string]string{"Content-Type": "application/json"}, existing.Header)
+	assert.Equal(t, `{"id":1}`, string(existing.Body))
+
+	// Completed records are not released, in-progress ones are.
+	require.NoError(t, store.ReleaseIdempotencyKey(ctx, "key-1"))
+	existing, err = store.ReserveIdempotencyKey(ctx, "key-1", "fingerprint", time.Hour)
+	require.NoError(t, err)
+	assert.NotNil(t, existing)
+
+	_, err = store.ReserveIdempotencyKey(ctx, "key-2", "fingerprint", time.Hour)
+	require.NoError(t, err)
+	require.NoError(t, store.ReleaseIdempotencyKey(ctx, "key-2"))
+	existing, err = store.ReserveIdempotencyKey(ctx, "key-2", "other", time.Hour)
+	require.NoError(t, err)
+	assert.Nil(t, existing)
+
+	// Expired records are taken over and purged.
+	_, err = store.ReserveIdempotencyKey(ctx, "key-3", "fingerprint", -time.Second)
+	require.NoError(t, err)
+	existing, err = store.ReserveIdempotencyKey(ctx, "key-3", "other", -time.Second)
+	require.NoError(t, err)
+	assert.Nil(t, existing)
+	deleted, err := store.DeleteExpiredIdempotencyKeys(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+}
+
+func testEnrichment(t *testing.T, s storage.SongStorage) {
+	queue, ok := s.(storage.EnrichmentQueue)
+	if !ok {
+		t.Skip("storage does not keep enrichment jobs")
+	}
+	ctx := context.Background()
+
+	assert.ErrorIs(t, queue.EnqueueEnrichment(ctx, 999), storage.ErrSongNotFound)
+
+	// The song and its job are added in the same transaction.
+	var song *models.Song
+	err := s.WithTx(ctx, func(tx storage.SongStorage) error {
+		var err error
+		song, err = tx.Create(ctx, &models.Song{GroupName: "Muse", SongName: "Starlight", EnrichmentStatus: models.EnrichmentPending})
+		if err != nil {
+			return err
+		}
+		return tx.(storage.EnrichmentQueue).EnqueueEnrichment(ctx, song.ID)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, models.EnrichmentPending, song.EnrichmentStatus)
+
+	job, err := queue.GetEnrichmentJob(ctx, song.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.JobPending, job.Status)
+	assert.Zero(t, job.Attempts)
+
+	jobs, err := queue.ClaimEnrichmentJobs(ctx, 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	assert.Equal(t, song.ID, jobs[0].SongID)
+	assert.Equal(t, models.JobRunning, jobs[0].Status)
+	assert.Equal(t, 1, jobs[0].Attempts)
+	jobs, err = queue.ClaimEnrichmentJobs(ctx, 10, time.Minute)
+	require.NoError(t, err)
+	assert.Empty(t, jobs, "a running job is not claimed again before its lease expires")
+
+	require.NoError(t, queue.RetryEnrichmentJob(ctx, song.ID, 1, time.Hour, "external API error"))
+	job, err = queue.GetEnrichmentJob(ctx, song.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.JobPending, job.Status)
+	assert.Equal(t, "external API error", job.LastError)
+	jobs, err = queue.ClaimEnrichmentJobs(ctx, 10, time.Minute)
+	require.NoError(t, err)
+	assert.Empty(t, jobs, "a retried job is not due before its delay")
+
+	require.NoError(t, queue.RetryEnrichmentJob(ctx, song.ID, 1, 0, "ignored, the job is not running"))
+
+	// Jobs whose lease expired are claimed again.
+	other, err := s.Create(ctx, &models.Song{
+		GroupName:        "Muse",
+		SongName:         "Uprising",
+		Text:             sql.NullString{String: "Given text", Valid: true},
+		EnrichmentStatus: models.EnrichmentPending,
+		Sources:          map[string]string{models.FieldText: "catalog"},
+	})
+	require.NoError(t, err)
+	require.NoError(t, queue.EnqueueEnrichment(ctx, other.ID))
+	jobs, err = queue.ClaimEnrichmentJobs(ctx, 10, -time.Second)
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	jobs, err = queue.ClaimEnrichmentJobs(ctx, 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	assert.Equal(t, other.ID, jobs[0].SongID)
+	assert.Equal(t, 2, jobs[0].Attempts)
+
+	// The worker of the expired lease no longer holds the job.
+	require.NoError(t, queue.FailEnrichmentJob(ctx, other.ID, 1, "ignored, the attempt is not the one running"))
+	require.NoError(t, queue.RetryEnrichmentJob(ctx, other.ID, 1, 0, "ignored, the attempt is not the one running"))
+	require.NoError(t, queue.CompleteEnrichmentJob(ctx, other.ID, 1, &models.Song{Link: sql.NullString{String: "https://example.com", Valid: true}}))
+	job, err = queue.GetEnrichmentJob(ctx, other.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.JobRunning, job.Status)
+	assert.Empty(t, job.LastError)
+
+	require.NoError(t, queue.CompleteEnrichmentJob(ctx, other.ID, 2, &models.Song{
+		ReleaseDate: sql.NullString{String: "2009-09-07", Valid: true},
+		Text:        sql.NullString{String: "Paranoia is in bloom", Valid: true},
+		Sources:     map[string]string{models.FieldReleaseDate: "api", models.FieldText: "lyrics"},
+	}))
+	enriched, err := s.GetByID(ctx, other.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.EnrichmentComplete, enriched.EnrichmentStatus)
+	assert.Equal(t, "2009-09-07", enriched.ReleaseDate.String)
+	assert.Equal(t, "Given text", enriched.Text.String, "details the song has are kept")
+	assert.Equal(t, map[string]string{models.FieldReleaseDate: "api", models.FieldText: "catalog"}, enriched.Sources)
+	assert.False(t, enriched.Link.Valid)
+	assert.Equal(t, other.Version+1, enriched.Version)
+	job, err = queue.GetEnrichmentJob(ctx, other.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.JobDone, job.Status)
+
+	// Enqueueing keeps a pending job and resets a finished one.
+	require.NoError(t, queue.EnqueueEnrichment(ctx, song.ID))
+	job, err = queue.GetEnrichmentJob(ctx, song.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.JobPending, job.Status)
+	assert.Equal(t, "external API error", job.LastError)
+	require.NoError(t, queue.FailEnrichmentJob(ctx, song.ID, 1, "ignored, the job is not running"))
+
+	unchanged, err := s.GetByID(ctx, song.ID)
+	require.NoError(t, err)
+	assert.Equal(t, song.Version, unchanged.Version, "enqueueing a pending song keeps its version")
+
+	require.NoError(t, queue.EnqueueEnrichment(ctx, other.ID))
+	requeued, err := s.GetByID(ctx, other.ID)
+	require.NoError(t, err)
+	assert.Equal(t, enriched.Version+1, requeued.Version)
+	jobs, err = queue.ClaimEnrichmentJobs(ctx, 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	assert.Equal(t, 1, jobs[0].Attempts)
+	require.NoError(t, queue.FailEnrichmentJob(ctx, other.ID, 1, "text too long"))
+	failed, err := s.GetByID(ctx, other.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.EnrichmentFailed, failed.EnrichmentStatus)
+	assert.Equal(t, requeued.Version+1, failed.Version)
+	job, err = queue.GetEnrichmentJob(ctx, other.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.JobDead, job.Status)
+	assert.Equal(t, "text too long", job.LastError)
+
+	require.NoError(t, queue.EnqueueEnrichment(ctx, other.ID))
+	job, err = queue.GetEnrichmentJob(ctx, other.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.JobPending, job.Status)
+	assert.Zero(t, job.Attempts)
+	assert.Empty(t, job.LastError)
+	pending, err := s.GetByID(ctx, other.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.EnrichmentPending, pending.EnrichmentStatus)
+	assert.Equal(t, failed.Version+1, pending.Version)
+
+	// Jobs are deleted along with their song.
+	require.NoError(t, s.Delete(ctx, song.ID, 0))
+	_, err = queue.GetEnrichmentJob(ctx, song.ID)
+	assert.ErrorIs(t, err, storage.ErrJobNotFound)
+}
+
+func testLookupCache(t *testing.T, s storage.SongStorage) {
+	cache, ok := s.(storage.LookupCache)
+	if !ok {
+		t.Skip("storage does not cache lookups")
+	}
+	ctx := context.Background()
+
+	lookup, err := cache.GetCachedLookup(ctx, "Muse", "Starlight")
+	require.NoError(t, err)
+	assert.Nil(t, lookup)
+
+	details := &models.SongDetailFromAPI{ReleaseDate: "04.09.2006", Text: "Far away", Link: "https://example.com"}
+	require.NoError(t, cache.PutCachedLookup(ctx, &models.CachedLookup{GroupName: "Muse", SongName: "Starlight", Details: details, ExpiresAt: time.Now().Add(time.Hour)}))
+	require.NoError(t, cache.PutCachedLookup(ctx, &models.CachedLookup{GroupName: "Muse", SongName: "Unknown", ExpiresAt: time.Now().Add(time.Hour)}))
+
+	lookup, err = cache.GetCachedLookup(ctx, "Muse", "Starlight")
+	require.NoError(t, err)
+	require.NotNil(t, lookup)
+	assert.Equal(t, details, lookup.Details)
+	lookup, err = cache.GetCachedLookup(ctx, "Muse", "Unknown")
+	require.NoError(t, err)
+	require.NotNil(t, lookup)
+	assert.Nil(t, lookup.Details, "the song is not known")
+
+	// A new result replaces the previous one, expired results are not returned and are purged.
+	require.NoError(t, cache.PutCachedLookup(ctx, &models.CachedLookup{GroupName: "Muse", SongName: "Starlight", Details: details, ExpiresAt: time.Now().Add(-time.Second)}))
+	lookup, err = cache.GetCachedLookup(ctx, "Muse", "Starlight")
+	require.NoError(t, err)
+	assert.Nil(t, lookup)
+	deleted, err := cache.DeleteExpiredLookups(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+}
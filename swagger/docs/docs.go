@@ -35,9 +35,29 @@ const docTemplate = `{
                 }
             }
         },
+        "/health/stats": {
+            "get": {
+                "description": "Get connection pool statistics of the storage backend and the circuit breaker state of the external music API.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "root"
+                ],
+                "summary": "Show runtime statistics",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/health.StatsResponse"
+                        }
+                    }
+                }
+            }
+        },
         "/songs": {
             "get": {
-                "description": "Get songs with optional filters for group and song name, and pagination.",
+                "description": "Get songs with optional filters for group and song name, and pagination.\nPages are addressed either by number (page) or, for stable iteration over large lists, by the nextCursor of the previous page (cursor).\nWhen no song matches a group or song filter, suggestions lists similar existing names (\"did you mean\").",
                 "produces": [
                     "application/json"
                 ],
@@ -59,6 +79,74 @@ const docTemplate = `{
                         "in": "query"
                     },
                     {
+                        "enum": [
+                            "substring",
+                            "prefix",
+                            "exact",
+                            "fuzzy"
+                        ],
+                        "type": "string",
+                        "default": "substring",
+                        "description": "How group and song are matched, case-insensitively. fuzzy tolerates typos and orders songs by similarity",
+                        "name": "match",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "format": "date",
+                        "description": "Earliest release date, inclusive",
+                        "name": "releaseDateFrom",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "format": "date",
+                        "description": "Latest release date, inclusive",
+                        "name": "releaseDateTo",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only songs with (true) or without (false) text",
+                        "name": "hasText",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only songs with (true) or without (false) a link",
+                        "name": "hasLink",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "format": "date-time",
+                        "description": "Earliest creation time, inclusive, RFC 3339",
+                        "name": "createdFrom",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "format": "date-time",
+                        "description": "Latest creation time, inclusive, RFC 3339",
+                        "name": "createdTo",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "format": "date-time",
+                        "description": "Earliest update time, inclusive, RFC 3339",
+                        "name": "updatedFrom",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "format": "date-time",
+                        "description": "Latest update time, inclusive, RFC 3339",
+                        "name": "updatedTo",
+                        "in": "query"
+                    },
+                    {
+                        "maximum": 21474836,
                         "type": "integer",
                         "default": 1,
                         "description": "Page number for pagination",
@@ -66,27 +154,66 @@ const docTemplate = `{
                         "in": "query"
                     },
                     {
+                        "maximum": 100,
                         "type": "integer",
                         "default": 10,
                         "description": "Number of songs per page",
                         "name": "pageSize",
                         "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Cursor returned as nextCursor by the previous page, excludes page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "default": "id",
+                        "example": "-releaseDate,group",
+                        "description": "Comma-separated sort fields, '-' prefix for descending order. Fields: id, group, song, releaseDate, createdAt, updatedAt",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of a cached response",
+                        "name": "If-None-Match",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/models.Song"
+                            "$ref": "#/definitions/models.SongList"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Weak ETag of the response"
                             }
                         }
+                    },
+                    "304": {
+                        "description": "Not Modified"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
                     }
                 }
             },
             "post": {
-                "description": "Add a new song to the library, fetching details from external API.",
+                "description": "Add a new song to the library, fetching details from external API.\nWith ENRICHMENT_MODE async, or fallback when the API fails, the song is added at once with enrichmentStatus pending\nand its details are filled in later by a job, see GET /songs/{id}/enrichment.\nA song with the same group and song names is a conflict, answered with 409 and the existing song's location\nunless onConflict is set: return answers 200 with the existing song, update refreshes its details first, keeping the ones edited by hand.",
                 "consumes": [
                     "application/json"
                 ],
@@ -106,13 +233,51 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/models.AddSongRequest"
                         }
+                    },
+                    {
+                        "enum": [
+                            "return",
+                            "update"
+                        ],
+                        "type": "string",
+                        "description": "What to do when the song already exists",
+                        "name": "onConflict",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Unique key making retries of the request safe, see README",
+                        "name": "Idempotency-Key",
+                        "in": "header"
                     }
                 ],
                 "responses": {
+                    "200": {
+                        "description": "The song already existed",
+                        "schema": {
+                            "$ref": "#/definitions/models.Song"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Song version"
+                            }
+                        }
+                    },
                     "201": {
                         "description": "Created",
                         "schema": {
                             "$ref": "#/definitions/models.Song"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Song version"
+                            },
+                            "Location": {
+                                "type": "string",
+                                "description": "URL of the song"
+                            }
                         }
                     },
                     "400": {
@@ -121,6 +286,18 @@ const docTemplate = `{
                             "type": "string"
                         }
                     },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/models.SongConflict"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -130,9 +307,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/songs/{id}": {
-            "put": {
-                "description": "Update an existing song's details.",
+        "/songs/batch": {
+            "post": {
+                "description": "Add up to 1000 songs in a single transaction, fetching their details from the external API concurrently.\nThe outcome of each item is reported separately: created, duplicate (the song exists, or repeats an earlier item) or failed with the reason.\nDuplicates and failures do not prevent the other songs from being added.",
                 "consumes": [
                     "application/json"
                 ],
@@ -142,30 +319,29 @@ const docTemplate = `{
                 "tags": [
                     "songs"
                 ],
-                "summary": "Update song by ID",
+                "summary": "Add songs in bulk",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "Song ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "description": "Song details to update",
+                        "description": "Songs to add",
                         "name": "body",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/models.Song"
+                            "$ref": "#/definitions/models.AddSongsRequest"
                         }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Unique key making retries of the request safe, see README",
+                        "name": "Idempotency-Key",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.Song"
+                            "$ref": "#/definitions/models.BatchResult"
                         }
                     },
                     "400": {
@@ -174,8 +350,14 @@ const docTemplate = `{
                             "type": "string"
                         }
                     },
-                    "404": {
-                        "description": "Not Found",
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
                         "schema": {
                             "type": "string"
                         }
@@ -187,31 +369,130 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "delete": {
-                "description": "Delete a song from the library.",
+            }
+        },
+        "/songs/export": {
+            "get": {
+                "description": "Stream all songs, or those matching the filters, in id order as CSV, NDJSON or a JSON array.\nEach song is exported as its group, song, releaseDate, text and link; CSV files have a header row and leave missing values empty.\nExported files can be imported with POST /songs/import.",
                 "produces": [
-                    "application/json"
+                    "application/json",
+                    "text/csv",
+                    "application/x-ndjson"
                 ],
                 "tags": [
                     "songs"
                 ],
-                "summary": "Delete song by ID",
+                "summary": "Export songs",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "Song ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "enum": [
+                            "csv",
+                            "ndjson",
+                            "json"
+                        ],
+                        "type": "string",
+                        "default": "json",
+                        "description": "File format",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by group name",
+                        "name": "group",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by song name",
+                        "name": "song",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "substring",
+                            "prefix",
+                            "exact"
+                        ],
+                        "type": "string",
+                        "default": "substring",
+                        "description": "How group and song are matched, case-insensitively",
+                        "name": "match",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "format": "date",
+                        "description": "Earliest release date, inclusive",
+                        "name": "releaseDateFrom",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "format": "date",
+                        "description": "Latest release date, inclusive",
+                        "name": "releaseDateTo",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only songs with (true) or without (false) text",
+                        "name": "hasText",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only songs with (true) or without (false) a link",
+                        "name": "hasLink",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "format": "date-time",
+                        "description": "Earliest creation time, inclusive, RFC 3339",
+                        "name": "createdFrom",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "format": "date-time",
+                        "description": "Latest creation time, inclusive, RFC 3339",
+                        "name": "createdTo",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "format": "date-time",
+                        "description": "Earliest update time, inclusive, RFC 3339",
+                        "name": "updatedFrom",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "format": "date-time",
+                        "description": "Latest update time, inclusive, RFC 3339",
+                        "name": "updatedTo",
+                        "in": "query"
                     }
                 ],
                 "responses": {
-                    "204": {
-                        "description": "No Content"
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.SongDocument"
+                            }
+                        },
+                        "headers": {
+                            "Content-Disposition": {
+                                "type": "string",
+                                "description": "attachment; filename=songs.\u003cformat\u003e"
+                            }
+                        }
                     },
-                    "404": {
-                        "description": "Not Found",
+                    "400": {
+                        "description": "Bad Request",
                         "schema": {
                             "type": "string"
                         }
@@ -225,48 +506,79 @@ const docTemplate = `{
                 }
             }
         },
-        "/songs/{id}/text": {
-            "get": {
-                "description": "Get the text of a song by its ID, with pagination for verses.",
+        "/songs/import": {
+            "post": {
+                "description": "Add the songs of a file in the format of GET /songs/export: CSV with a header row naming at least the group and song columns,\nNDJSON or a JSON array. Rows are added in batches of 1000, each in its own transaction, and validated one by one:\ninvalid rows, songs that cannot be added and duplicates do not prevent the others from being imported.\nThe response counts the outcomes and lists the failed rows, up to 1000 of them.",
+                "consumes": [
+                    "application/json",
+                    "text/csv",
+                    "application/x-ndjson"
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
                     "songs"
                 ],
-                "summary": "Get song text by ID with pagination",
+                "summary": "Import songs",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "Song ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "enum": [
+                            "csv",
+                            "ndjson",
+                            "json"
+                        ],
+                        "type": "string",
+                        "description": "File format, taken from the Content-Type by default",
+                        "name": "format",
+                        "in": "query"
                     },
                     {
-                        "type": "integer",
-                        "default": 1,
-                        "description": "Page number for verses",
-                        "name": "page",
+                        "type": "boolean",
+                        "description": "Store rows giving a release date, text or link as given, without calling the external API",
+                        "name": "skipEnrichment",
                         "in": "query"
                     },
                     {
-                        "type": "integer",
-                        "default": 1,
-                        "description": "Number of verses per page",
-                        "name": "pageSize",
-                        "in": "query"
+                        "description": "Songs to import",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.SongDocument"
+                            }
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Unique key making retries of the request safe, see README",
+                        "name": "Idempotency-Key",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.Song"
+                            "$ref": "#/definitions/models.ImportResult"
                         }
                     },
-                    "404": {
-                        "description": "Not Found",
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
                         "schema": {
                             "type": "string"
                         }
@@ -279,26 +591,1229 @@ const docTemplate = `{
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "models.AddSongRequest": {
-            "type": "object",
-            "properties": {
-                "group": {
-                    "type": "string"
-                },
-                "song": {
-                    "type": "string"
-                }
-            }
         },
-        "models.Song": {
+        "/songs/refresh": {
+            "post": {
+                "description": "Refresh all songs, or those matching the filters, as POST /songs/{id}/refresh does, fetching their details concurrently.\nAt most 1000 songs can be refreshed at once. The outcome of each song is reported separately: updated, unchanged,\nskipped (there are changes but apply stored none of them) or failed with the reason.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "songs"
+                ],
+                "summary": "Refresh the details of songs from the external API",
+                "parameters": [
+                    {
+                        "enum": [
+                            "unedited",
+                            "all",
+                            "none"
+                        ],
+                        "type": "string",
+                        "default": "unedited",
+                        "description": "Which changes to store",
+                        "name": "apply",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by group name",
+                        "name": "group",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by song name",
+                        "name": "song",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "substring",
+                            "prefix",
+                            "exact"
+                        ],
+                        "type": "string",
+                        "default": "substring",
+                        "description": "How group and song are matched, case-insensitively",
+                        "name": "match",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "format": "date",
+                        "description": "Earliest release date, inclusive",
+                        "name": "releaseDateFrom",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "format": "date",
+                        "description": "Latest release date, inclusive",
+                        "name": "releaseDateTo",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only songs with (true) or without (false) text",
+                        "name": "hasText",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only songs with (true) or without (false) a link",
+                        "name": "hasLink",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "format": "date-time",
+                        "description": "Earliest creation time, inclusive, RFC 3339",
+                        "name": "createdFrom",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "format": "date-time",
+                        "description": "Latest creation time, inclusive, RFC 3339",
+                        "name": "createdTo",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "format": "date-time",
+                        "description": "Earliest update time, inclusive, RFC 3339",
+                        "name": "updatedFrom",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "format": "date-time",
+                        "description": "Latest update time, inclusive, RFC 3339",
+                        "name": "updatedTo",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Unique key making retries of the request safe, see README",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.RefreshBatchResult"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/songs/search": {
+            "get": {
+                "description": "Search songs by words of their group name, song name and text. Results are ranked by relevance,\nname matches rank higher than text matches. The snippet holds the matching verses with the matched\nwords wrapped in \u003cmark\u003e\u003c/mark\u003e, the text being HTML-escaped. The query supports quoted phrases, \"or\" and \"-\" exclusions.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "songs"
+                ],
+                "summary": "Full-text search of songs",
+                "parameters": [
+                    {
+                        "maxLength": 256,
+                        "type": "string",
+                        "description": "Search query",
+                        "name": "q",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "maximum": 21474836,
+                        "type": "integer",
+                        "default": 1,
+                        "description": "Page number for pagination",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "maximum": 100,
+                        "type": "integer",
+                        "default": 10,
+                        "description": "Number of songs per page",
+                        "name": "pageSize",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.SongSearchList"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/songs/{id}": {
+            "put": {
+                "description": "Update an existing song's details. Send the ETag of the song as If-Match to make sure\nnobody changed it since it was read; the version field of the body is ignored.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "songs"
+                ],
+                "summary": "Update song by ID",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Song ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Song details to update",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.Song"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the song version being changed, e.g. \\",
+                        "name": "If-Match",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Unique key making retries of the request safe, see README",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Song"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Song version"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Delete a song from the library.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "songs"
+                ],
+                "summary": "Delete song by ID",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Song ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the song version being changed, e.g. \\",
+                        "name": "If-Match",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Unique key making retries of the request safe, see README",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "description": "Change some fields of a song, leaving the others as they are. The patch applies to the document\n{\"group\", \"song\", \"releaseDate\", \"text\", \"link\"}, where releaseDate is YYYY-MM-DD and a missing\nreleaseDate, text or link is null. Send a JSON Merge Patch (RFC 7396) as application/merge-patch+json\nor application/json, e.g. {\"text\": null} removes the text, or a JSON Patch (RFC 6902) as application/json-patch+json.",
+                "consumes": [
+                    "application/merge-patch+json",
+                    "application/json-patch+json",
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "songs"
+                ],
+                "summary": "Partially update song by ID",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Song ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Merge patch or JSON Patch of the song document",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.SongDocument"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the song version being changed, e.g. \\",
+                        "name": "If-Match",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Unique key making retries of the request safe, see README",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Song"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Song version"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/songs/{id}/enrichment": {
+            "get": {
+                "description": "Get the job fetching the release date, text and link of a song added with ENRICHMENT_MODE async or fallback.\nFailed attempts are retried with exponential backoff until the job is dead, which marks the song's enrichmentStatus failed.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "songs"
+                ],
+                "summary": "Get the enrichment job of a song",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Song ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.EnrichmentJob"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Schedule a new job fetching the details a song is missing, e.g. after its job is dead. A pending or running job is returned as is.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "songs"
+                ],
+                "summary": "Retry the enrichment of a song",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Song ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Unique key making retries of the request safe, see README",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/models.EnrichmentJob"
+                        },
+                        "headers": {
+                            "Location": {
+                                "type": "string",
+                                "description": "URL of the enrichment job"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "501": {
+                        "description": "Not Implemented",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/songs/{id}/refresh": {
+            "post": {
+                "description": "Fetch the release date, text and link of a song from the external API again and report the fields that differ from the stored ones.\nWith apply=unedited (the default) only the changes of fields never edited with PUT or PATCH are stored, with apply=all all of them,\nwith apply=none none. Fields the external API does not return are kept.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "songs"
+                ],
+                "summary": "Refresh song details from the external API",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Song ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "unedited",
+                            "all",
+                            "none"
+                        ],
+                        "type": "string",
+                        "default": "unedited",
+                        "description": "Which changes to store",
+                        "name": "apply",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Unique key making retries of the request safe, see README",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.RefreshResult"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Song version"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/songs/{id}/text": {
+            "get": {
+                "description": "Get the text of a song by its ID, with pagination for verses.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "songs"
+                ],
+                "summary": "Get song text by ID with pagination",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Song ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "default": 1,
+                        "description": "Page number for verses",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 1,
+                        "description": "Number of verses per page",
+                        "name": "pageSize",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of a cached response",
+                        "name": "If-None-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Song"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Song version"
+                            }
+                        }
+                    },
+                    "304": {
+                        "description": "Not Modified"
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "health.StatsResponse": {
+            "type": "object",
+            "properties": {
+                "database": {
+                    "$ref": "#/definitions/storage.PoolStats"
+                },
+                "musicApi": {
+                    "$ref": "#/definitions/musicapi.BreakerStats"
+                }
+            }
+        },
+        "models.AddSongRequest": {
+            "type": "object",
+            "properties": {
+                "group": {
+                    "type": "string"
+                },
+                "song": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.AddSongsRequest": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.AddSongRequest"
+                    }
+                }
+            }
+        },
+        "models.BatchItemResult": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "description": "Error is the reason the item failed, or names the earlier item a duplicate repeats.",
+                    "type": "string"
+                },
+                "id": {
+                    "description": "ID is the id of the created song, or of the existing song a duplicate matches.\nIt is absent for failures and for duplicates of a failed item.",
+                    "type": "integer"
+                },
+                "index": {
+                    "description": "Index is the position of the item in the request.",
+                    "type": "integer"
+                },
+                "location": {
+                    "type": "string"
+                },
+                "song": {
+                    "$ref": "#/definitions/models.Song"
+                },
+                "status": {
+                    "$ref": "#/definitions/models.BatchItemStatus"
+                }
+            }
+        },
+        "models.BatchItemStatus": {
+            "type": "string",
+            "enum": [
+                "created",
+                "duplicate",
+                "failed"
+            ],
+            "x-enum-varnames": [
+                "BatchCreated",
+                "BatchDuplicate",
+                "BatchFailed"
+            ]
+        },
+        "models.BatchResult": {
+            "type": "object",
+            "properties": {
+                "created": {
+                    "type": "integer"
+                },
+                "duplicates": {
+                    "type": "integer"
+                },
+                "failed": {
+                    "type": "integer"
+                },
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.BatchItemResult"
+                    }
+                }
+            }
+        },
+        "models.EnrichmentJob": {
+            "type": "object",
+            "properties": {
+                "attempts": {
+                    "description": "Attempts counts the calls to the external API made so far.",
+                    "type": "integer"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "lastError": {
+                    "description": "LastError is the reason the last attempt failed.",
+                    "type": "string"
+                },
+                "runAt": {
+                    "description": "RunAt is when a pending job is due and when the lease of a running job expires.",
+                    "type": "string"
+                },
+                "songId": {
+                    "type": "integer"
+                },
+                "status": {
+                    "$ref": "#/definitions/models.JobStatus"
+                },
+                "updatedAt": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.EnrichmentStatus": {
+            "type": "string",
+            "enum": [
+                "complete",
+                "pending",
+                "failed"
+            ],
+            "x-enum-varnames": [
+                "EnrichmentComplete",
+                "EnrichmentPending",
+                "EnrichmentFailed"
+            ]
+        },
+        "models.FieldChange": {
+            "type": "object",
+            "properties": {
+                "applied": {
+                    "type": "boolean"
+                },
+                "edited": {
+                    "description": "Edited reports that the stored value was set by hand.",
+                    "type": "boolean"
+                },
+                "field": {
+                    "type": "string"
+                },
+                "new": {
+                    "type": "string"
+                },
+                "old": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.ImportError": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "row": {
+                    "description": "Row is the line of the row for CSV and NDJSON, counting the CSV header, and\nthe position of the element, from 1, for a JSON array.",
+                    "type": "integer"
+                }
+            }
+        },
+        "models.ImportResult": {
+            "type": "object",
+            "properties": {
+                "created": {
+                    "type": "integer"
+                },
+                "duplicates": {
+                    "type": "integer"
+                },
+                "errors": {
+                    "description": "Errors lists the rows that failed, up to MaxImportErrors of them, ordered by row.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ImportError"
+                    }
+                },
+                "failed": {
+                    "type": "integer"
+                },
+                "stopped": {
+                    "description": "Stopped is why reading stopped before the end of the file, the rows after it\nwere not imported.",
+                    "type": "string"
+                }
+            }
+        },
+        "models.JobStatus": {
+            "type": "string",
+            "enum": [
+                "pending",
+                "running",
+                "done",
+                "dead"
+            ],
+            "x-enum-varnames": [
+                "JobPending",
+                "JobRunning",
+                "JobDone",
+                "JobDead"
+            ]
+        },
+        "models.NameSuggestion": {
+            "type": "object",
+            "properties": {
+                "field": {
+                    "description": "Field is the filter the suggestion is for, \"group\" or \"song\".",
+                    "type": "string"
+                },
+                "similarity": {
+                    "type": "number"
+                },
+                "value": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.RefreshBatchResult": {
+            "type": "object",
+            "properties": {
+                "failed": {
+                    "type": "integer"
+                },
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.RefreshResult"
+                    }
+                },
+                "skipped": {
+                    "type": "integer"
+                },
+                "unchanged": {
+                    "type": "integer"
+                },
+                "updated": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.RefreshResult": {
+            "type": "object",
+            "properties": {
+                "changes": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.FieldChange"
+                    }
+                },
+                "error": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "location": {
+                    "type": "string"
+                },
+                "song": {
+                    "description": "Song is the song after the refresh. It is absent for failures.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.Song"
+                        }
+                    ]
+                },
+                "status": {
+                    "$ref": "#/definitions/models.RefreshStatus"
+                }
+            }
+        },
+        "models.RefreshStatus": {
+            "type": "string",
+            "enum": [
+                "updated",
+                "unchanged",
+                "skipped",
+                "failed"
+            ],
+            "x-enum-varnames": [
+                "RefreshUpdated",
+                "RefreshUnchanged",
+                "RefreshSkipped",
+                "RefreshFailed"
+            ]
+        },
+        "models.Song": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "editedFields": {
+                    "description": "EditedFields lists the DetailFields changed by hand since the song was added,\nwhich RefreshUnedited keeps. Update stores it as given.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "enrichmentStatus": {
+                    "description": "EnrichmentStatus is pending while a job fetches the details of the song and\nfailed when it gave up. Create stores an empty status as complete.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.EnrichmentStatus"
+                        }
+                    ]
+                },
+                "group": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "link": {
+                    "description": "swagger:strfmt uri",
+                    "type": "string"
+                },
+                "releaseDate": {
+                    "description": "swagger:strfmt date-time",
+                    "type": "string"
+                },
+                "similarity": {
+                    "description": "Similarity is how close the names are to a fuzzy filter, set only by match=fuzzy listings.",
+                    "type": "number"
+                },
+                "song": {
+                    "type": "string"
+                },
+                "sources": {
+                    "description": "Sources maps the DetailFields fetched from the external providers to the name\nof the provider that supplied them. Update stores it as given.",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "text": {
+                    "description": "swagger:strfmt string",
+                    "type": "string"
+                },
+                "updatedAt": {
+                    "type": "string"
+                },
+                "version": {
+                    "description": "Version starts at 1 and is incremented by every update. It is the song's ETag.",
+                    "type": "integer"
+                }
+            }
+        },
+        "models.SongConflict": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "location": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.SongDocument": {
+            "type": "object",
+            "properties": {
+                "group": {
+                    "type": "string"
+                },
+                "link": {
+                    "type": "string"
+                },
+                "releaseDate": {
+                    "type": "string"
+                },
+                "song": {
+                    "type": "string"
+                },
+                "text": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.SongList": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Song"
+                    }
+                },
+                "next": {
+                    "type": "string"
+                },
+                "nextCursor": {
+                    "type": "string"
+                },
+                "page": {
+                    "description": "Page is omitted for keyset pages, which have no page number.",
+                    "type": "integer"
+                },
+                "pageSize": {
+                    "type": "integer"
+                },
+                "prev": {
+                    "type": "string"
+                },
+                "suggestions": {
+                    "description": "Suggestions lists close existing names when the name filters matched nothing.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.NameSuggestion"
+                    }
+                },
+                "totalItems": {
+                    "type": "integer"
+                },
+                "totalPages": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.SongSearchList": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.SongSearchResult"
+                    }
+                },
+                "page": {
+                    "type": "integer"
+                },
+                "pageSize": {
+                    "type": "integer"
+                },
+                "totalItems": {
+                    "type": "integer"
+                },
+                "totalPages": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.SongSearchResult": {
             "type": "object",
             "properties": {
                 "createdAt": {
                     "type": "string"
                 },
+                "editedFields": {
+                    "description": "EditedFields lists the DetailFields changed by hand since the song was added,\nwhich RefreshUnedited keeps. Update stores it as given.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "enrichmentStatus": {
+                    "description": "EnrichmentStatus is pending while a job fetches the details of the song and\nfailed when it gave up. Create stores an empty status as complete.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.EnrichmentStatus"
+                        }
+                    ]
+                },
                 "group": {
                     "type": "string"
                 },
@@ -309,19 +1824,116 @@ const docTemplate = `{
                     "description": "swagger:strfmt uri",
                     "type": "string"
                 },
+                "rank": {
+                    "description": "Rank orders results, higher is more relevant. It is only comparable within one search.",
+                    "type": "number"
+                },
                 "releaseDate": {
                     "description": "swagger:strfmt date-time",
                     "type": "string"
                 },
+                "similarity": {
+                    "description": "Similarity is how close the names are to a fuzzy filter, set only by match=fuzzy listings.",
+                    "type": "number"
+                },
+                "snippet": {
+                    "description": "Snippet holds the matching verses of the text, HTML-escaped, with the matched words\nwrapped in \u003cmark\u003e\u003c/mark\u003e, the only tags. Empty when only the names match.",
+                    "type": "string"
+                },
                 "song": {
                     "type": "string"
                 },
+                "sources": {
+                    "description": "Sources maps the DetailFields fetched from the external providers to the name\nof the provider that supplied them. Update stores it as given.",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
                 "text": {
                     "description": "swagger:strfmt string",
                     "type": "string"
                 },
                 "updatedAt": {
                     "type": "string"
+                },
+                "version": {
+                    "description": "Version starts at 1 and is incremented by every update. It is the song's ETag.",
+                    "type": "integer"
+                }
+            }
+        },
+        "musicapi.BreakerState": {
+            "type": "string",
+            "enum": [
+                "closed",
+                "open",
+                "half-open"
+            ],
+            "x-enum-varnames": [
+                "BreakerClosed",
+                "BreakerOpen",
+                "BreakerHalfOpen"
+            ]
+        },
+        "musicapi.BreakerStats": {
+            "type": "object",
+            "properties": {
+                "consecutiveFailures": {
+                    "description": "ConsecutiveFailures is the number of failed calls since the last successful one.",
+                    "type": "integer"
+                },
+                "openUntil": {
+                    "description": "OpenUntil is when an open breaker lets a trial call through.",
+                    "type": "string"
+                },
+                "state": {
+                    "$ref": "#/definitions/musicapi.BreakerState"
+                }
+            }
+        },
+        "storage.PoolStats": {
+            "type": "object",
+            "properties": {
+                "acquireCount": {
+                    "type": "integer"
+                },
+                "acquireDurationNs": {
+                    "type": "integer"
+                },
+                "acquiredConns": {
+                    "type": "integer"
+                },
+                "canceledAcquireCount": {
+                    "type": "integer"
+                },
+                "constructingConns": {
+                    "type": "integer"
+                },
+                "emptyAcquireCount": {
+                    "type": "integer"
+                },
+                "idleConns": {
+                    "type": "integer"
+                },
+                "maxConns": {
+                    "type": "integer"
+                },
+                "maxIdleDestroyCount": {
+                    "type": "integer"
+                },
+                "maxLifetimeDestroyCount": {
+                    "type": "integer"
+                },
+                "newConnsCount": {
+                    "type": "integer"
+                },
+                "totalConns": {
+                    "type": "integer"
+                },
+                "waitCount": {
+                    "description": "WaitCount is the number of connections waited for, reported by database/sql pools only.",
+                    "type": "integer"
                 }
             }
         }
@@ -7,17 +7,17 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"strconv"
+	"sync"
 	"testing"
+	"time"
 
-	"github.com/golang-migrate/migrate/v4"
-	_ "github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
 	"github.com/gorilla/mux"
 	"github.com/jackc/pgx/v5"
 	"github.com/joho/godotenv"
@@ -27,18 +27,23 @@ import (
 
 	"songlibrary/config"
 	"songlibrary/internal/api/handlers/songs"
+	"songlibrary/internal/api/middleware"
 	"songlibrary/internal/lib/logger/utils"
+	"songlibrary/internal/lib/normalize"
+	"songlibrary/internal/migrator"
 	"songlibrary/internal/models"
 	"songlibrary/internal/musicapi"
 	"songlibrary/internal/service"
 	"songlibrary/internal/storage"
 	"songlibrary/internal/storage/postgres"
+	"songlibrary/internal/storage/storagetest"
 	integration "songlibrary/tests/integration_test"
 )
 
 var (
 	testDBConnStr         string
 	testServer            *httptest.Server
+	fakeAPIServer         *httptest.Server
 	testRouter            *mux.Router
 	pgStorage             storage.SongStorage
 	musicAPIClient        *musicapi.MusicAPIClient
@@ -64,7 +69,8 @@ func setupTestEnvironment(t *testing.T) func() {
 	testDBConnStr = testPostgresContainer.ConnectionString()
 	utils.Logger.Info("Test database connection string", zap.String("conn", testDBConnStr))
 
-	conn, err := pgx.Connect(context.Background(), testDBConnStr)
+	cfg.DBURL = testDBConnStr
+	pool, err := postgres.NewPool(context.Background(), cfg)
 	require.NoError(t, err, "Failed to connect to test database")
 
 	if err := runMigrations(testDBConnStr); err != nil {
@@ -73,25 +79,42 @@ func setupTestEnvironment(t *testing.T) func() {
 	}
 	utils.Logger.Info("Database migrations completed successfully for test DB")
 
-	pgStorage = postgres.NewPgStorage(conn)
-	musicAPIClient = musicapi.NewMusicAPIClient(cfg.APIURL)
-	songService = service.NewSongService(pgStorage, musicAPIClient)
+	pgStorage = postgres.NewPgStorage(pool, cfg.SearchLanguage)
+	fixtures, err := musicapi.LoadFixtures("")
+	require.NoError(t, err, "Failed to load the fixtures of the fake music API")
+	fakeAPIServer = httptest.NewServer(musicapi.NewFake(fixtures, musicapi.FakeConfig{}))
+	musicAPIClient = musicapi.NewMusicAPIClient(fakeAPIServer.URL, musicapi.Config{
+		Timeout:         cfg.APITimeout,
+		ConnectTimeout:  cfg.APIConnectTimeout,
+		MaxResponseSize: cfg.APIMaxResponseSize,
+	})
+	songService = service.NewSongService(pgStorage, musicAPIClient, normalize.New(normalize.DefaultArticles), service.Config{BatchWorkers: 4})
 	songHandlers = songs.NewSongHandlers(songService)
 
 	testRouter = mux.NewRouter()
+	testRouter.Use(middleware.Idempotency(pgStorage.(storage.IdempotencyStore), time.Hour))
 	testRouter.HandleFunc("/health", songHandlers.HealthCheckHandler).Methods("GET")
 	testRouter.HandleFunc("/songs", songHandlers.GetSongsHandler).Methods("GET")
 	testRouter.HandleFunc("/songs", songHandlers.AddSongHandler).Methods("POST")
+	testRouter.HandleFunc("/songs/batch", songHandlers.AddSongsHandler).Methods("POST")
+	testRouter.HandleFunc("/songs/export", songHandlers.ExportSongsHandler).Methods("GET")
+	testRouter.HandleFunc("/songs/import", songHandlers.ImportSongsHandler).Methods("POST")
+	testRouter.HandleFunc("/songs/refresh", songHandlers.RefreshSongsHandler).Methods("POST")
 	testRouter.HandleFunc("/songs/{id}/text", songHandlers.GetSongTextHandler).Methods("GET")
+	testRouter.HandleFunc("/songs/{id}/enrichment", songHandlers.GetEnrichmentJobHandler).Methods("GET")
+	testRouter.HandleFunc("/songs/{id}/enrichment", songHandlers.RetryEnrichmentHandler).Methods("POST")
+	testRouter.HandleFunc("/songs/{id}/refresh", songHandlers.RefreshSongHandler).Methods("POST")
 	testRouter.HandleFunc("/songs/{id}", songHandlers.UpdateSongHandler).Methods("PUT")
+	testRouter.HandleFunc("/songs/{id}", songHandlers.PatchSongHandler).Methods("PATCH")
 	testRouter.HandleFunc("/songs/{id}", songHandlers.DeleteSongHandler).Methods("DELETE")
 
 	testServer = httptest.NewServer(testRouter)
 
 	return func() {
-		conn.Close(context.Background())
 		cleanupTestData(t)
+		pool.Close()
 		testServer.Close()
+		fakeAPIServer.Close()
 		if testPostgresContainer != nil {
 			if err := testPostgresContainer.Terminate(context.Background()); err != nil {
 				utils.Logger.Error("Failed to terminate container", zap.Error(err))
@@ -105,7 +128,7 @@ func cleanupTestData(t *testing.T) {
 	require.NoError(t, err, "Failed to connect to test database for cleanup")
 	defer conn.Close(context.Background())
 
-	_, err = conn.Exec(context.Background(), "DELETE FROM songs")
+	_, err = conn.Exec(context.Background(), "TRUNCATE songs, enrichment_jobs, idempotency_keys RESTART IDENTITY")
 	require.NoError(t, err, "Failed to cleanup test data")
 }
 
@@ -135,10 +158,11 @@ func TestGetSongsHandler_Integration(t *testing.T) {
 	recorder := executeRequest(t, "GET", "/songs", "")
 	assert.Equal(t, http.StatusOK, recorder.Code)
 
-	var songs []models.Song
+	var songs models.SongList
 	err := json.Unmarshal(recorder.Body.Bytes(), &songs)
 	require.NoError(t, err, "Failed to unmarshal response body")
-	assert.NotEmpty(t, songs, "Expected songs in response")
+	assert.NotEmpty(t, songs.Items, "Expected songs in response")
+	assert.Equal(t, len(songs.Items), songs.TotalItems)
 }
 
 func TestAddSongHandler_Integration(t *testing.T) {
@@ -159,6 +183,120 @@ func TestAddSongHandler_Integration(t *testing.T) {
 	require.NoError(t, err, "Failed to fetch song from DB")
 	assert.Equal(t, song.GroupName, fetchedSong.GroupName)
 	assert.Equal(t, song.SongName, fetchedSong.SongName)
+
+	recorder = executeRequest(t, "POST", "/songs", requestBody)
+	assert.Equal(t, http.StatusConflict, recorder.Code)
+	assert.Equal(t, "/songs/"+strconv.Itoa(song.ID), recorder.Header().Get("Location"))
+
+	recorder = executeRequest(t, "POST", "/songs?onConflict=return", requestBody)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	var existing models.Song
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &existing))
+	assert.Equal(t, song.ID, existing.ID)
+}
+
+func TestAddSongsHandler_Integration(t *testing.T) {
+	teardown := setupTestEnvironment(t)
+	defer teardown()
+
+	existing, err := pgStorage.Create(context.Background(), &models.Song{GroupName: "Batch Group", SongName: "Old Song", GroupKey: "batch group", SongKey: "old song"})
+	require.NoError(t, err)
+
+	requestBody := `{"items": [
+		{"group": "Batch Group", "song": "First Song"},
+		{"group": "Batch Group", "song": "Old Song"},
+		{"group": "batch group", "song": "first song"},
+		{"group": "Batch Group", "song": "Second Song"}
+	]}`
+	recorder := executeRequest(t, "POST", "/songs/batch", requestBody)
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+
+	var result models.BatchResult
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &result))
+	assert.Equal(t, 2, result.Created)
+	assert.Equal(t, 2, result.Duplicates)
+	require.Len(t, result.Items, 4)
+	assert.Equal(t, models.BatchDuplicate, result.Items[1].Status)
+	assert.Equal(t, existing.ID, result.Items[1].ID)
+	assert.Equal(t, result.Items[0].ID, result.Items[2].ID)
+
+	for _, item := range []models.BatchItemResult{result.Items[0], result.Items[3]} {
+		assert.Equal(t, models.BatchCreated, item.Status)
+		_, err := pgStorage.GetByID(context.Background(), item.ID)
+		assert.NoError(t, err, "Expected created song to be stored")
+	}
+}
+
+func TestAsyncEnrichment_Integration(t *testing.T) {
+	teardown := setupTestEnvironment(t)
+	defer teardown()
+
+	ctx := context.Background()
+	asyncService := service.NewSongService(pgStorage, musicAPIClient, normalize.New(normalize.DefaultArticles), service.Config{Enrichment: models.EnrichAsync})
+	added, created, err := asyncService.AddSong(ctx, &models.AddSongRequest{GroupName: "Async Group", SongName: "Async Song"}, models.ConflictFail)
+	require.NoError(t, err)
+	assert.True(t, created)
+	assert.Equal(t, models.EnrichmentPending, added.EnrichmentStatus)
+	assert.False(t, added.Text.Valid)
+
+	path := fmt.Sprintf("/songs/%d/enrichment", added.ID)
+	recorder := executeRequest(t, "GET", path, "")
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	var job models.EnrichmentJob
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &job))
+	assert.Equal(t, models.JobPending, job.Status)
+
+	queue := pgStorage.(storage.EnrichmentQueue)
+	worker := service.NewEnrichmentWorker(pgStorage, queue, musicAPIClient, service.EnrichmentWorkerConfig{})
+	processed, err := worker.ProcessJobs(ctx, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, processed)
+
+	enriched, err := pgStorage.GetByID(ctx, added.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.EnrichmentComplete, enriched.EnrichmentStatus)
+	assert.True(t, enriched.Text.Valid, "Expected the text to be filled in by the job")
+
+	recorder = executeRequest(t, "POST", path, "")
+	require.Equal(t, http.StatusAccepted, recorder.Code, recorder.Body.String())
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &job))
+	assert.Equal(t, models.JobPending, job.Status)
+
+	recorder = executeRequest(t, "GET", "/songs/999/enrichment", "")
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+}
+
+func TestExportImport_Integration(t *testing.T) {
+	teardown := setupTestEnvironment(t)
+	defer teardown()
+
+	addTestData(t)
+	addTestDataWithText(t)
+
+	recorder := executeRequest(t, "GET", "/songs/export?format=csv", "")
+	require.Equal(t, http.StatusOK, recorder.Code)
+	exported := recorder.Body.String()
+	assert.Equal(t, "group,song,releaseDate,text,link\n"+
+		"Test Group 1,Test Song 1,,,\n"+
+		"Test Group 2,Test Song 2,,,\n"+
+		"Text Group,Text Song,,Test Song Text,\n", exported)
+
+	cleanupTestData(t)
+	req, err := http.NewRequest("POST", testServer.URL+"/songs/import?skipEnrichment=true", bytes.NewBufferString(exported+"Broken Group,,,,\n"))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "text/csv")
+	recorder = httptest.NewRecorder()
+	testRouter.ServeHTTP(recorder, req)
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+
+	var result models.ImportResult
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &result))
+	assert.Equal(t, 3, result.Created)
+	assert.Equal(t, []models.ImportError{{Row: 5, Error: "invalid song: song is required"}}, result.Errors)
+
+	// Only the song giving details skips the external API.
+	recorder = executeRequest(t, "GET", "/songs/export?format=csv", "")
+	assert.Contains(t, recorder.Body.String(), "\nText Group,Text Song,,Test Song Text,\n")
 }
 
 func TestGetSongTextHandler_Integration(t *testing.T) {
@@ -200,6 +338,72 @@ func TestUpdateSongHandler_Integration(t *testing.T) {
 	assert.Equal(t, updatedSongName, fetchedSong.SongName)
 }
 
+func TestPatchSongHandler_Integration(t *testing.T) {
+	teardown := setupTestEnvironment(t)
+	defer teardown()
+
+	testSong := addTestDataWithText(t)
+	req, err := http.NewRequest("PATCH", testServer.URL+"/songs/"+strconv.Itoa(testSong.ID), bytes.NewBufferString(`{"song": "Patched Song", "releaseDate": "2006-07-16"}`))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	recorder := httptest.NewRecorder()
+	testRouter.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	fetchedSong, err := pgStorage.GetByID(context.Background(), testSong.ID)
+	require.NoError(t, err, "Failed to fetch song from DB")
+	assert.Equal(t, "Patched Song", fetchedSong.SongName)
+	assert.Equal(t, "2006-07-16", fetchedSong.ReleaseDate.String)
+	assert.Equal(t, testSong.GroupName, fetchedSong.GroupName, "Expected group to be kept")
+	assert.Equal(t, testSong.Text, fetchedSong.Text, "Expected text to be kept")
+	assert.Equal(t, testSong.Version+1, fetchedSong.Version)
+	assert.Equal(t, []string{models.FieldReleaseDate}, fetchedSong.EditedFields)
+
+	req, err = http.NewRequest("PATCH", testServer.URL+"/songs/"+strconv.Itoa(testSong.ID), bytes.NewBufferString(`{"link": "https://example.com"}`))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	req.Header.Set("If-Match", fmt.Sprintf(`"%d"`, testSong.Version))
+	recorder = httptest.NewRecorder()
+	testRouter.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusPreconditionFailed, recorder.Code, "Expected a stale If-Match to be rejected")
+}
+
+func TestRefreshSongHandler_Integration(t *testing.T) {
+	teardown := setupTestEnvironment(t)
+	defer teardown()
+
+	testSong := addTestDataWithText(t)
+	req, err := http.NewRequest("PATCH", testServer.URL+"/songs/"+strconv.Itoa(testSong.ID), bytes.NewBufferString(`{"text": "Edited Text"}`))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	recorder := httptest.NewRecorder()
+	testRouter.ServeHTTP(recorder, req)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	recorder = executeRequest(t, "POST", "/songs/"+strconv.Itoa(testSong.ID)+"/refresh", "")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	var result models.RefreshResult
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &result))
+	assert.Equal(t, models.RefreshUpdated, result.Status)
+
+	fetchedSong, err := pgStorage.GetByID(context.Background(), testSong.ID)
+	require.NoError(t, err, "Failed to fetch song from DB")
+	assert.Equal(t, "Edited Text", fetchedSong.Text.String, "Expected the edited text to be kept")
+	assert.True(t, fetchedSong.Link.Valid, "Expected the link to be refreshed")
+	assert.Equal(t, []string{models.FieldText}, fetchedSong.EditedFields)
+
+	recorder = executeRequest(t, "POST", "/songs/refresh?apply=all&group=Text+Group", "")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	var batch models.RefreshBatchResult
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &batch))
+	assert.Equal(t, 1, batch.Updated)
+
+	fetchedSong, err = pgStorage.GetByID(context.Background(), testSong.ID)
+	require.NoError(t, err, "Failed to fetch song from DB")
+	assert.NotEqual(t, "Edited Text", fetchedSong.Text.String, "Expected the edited text to be overwritten")
+	assert.Empty(t, fetchedSong.EditedFields)
+}
+
 func TestDeleteSongHandler_Integration(t *testing.T) {
 	teardown := setupTestEnvironment(t)
 	defer teardown()
@@ -213,6 +417,128 @@ func TestDeleteSongHandler_Integration(t *testing.T) {
 	assert.ErrorIs(t, err, storage.ErrSongNotFound, "Expected song to be deleted")
 }
 
+func TestIdempotencyKey_Integration(t *testing.T) {
+	teardown := setupTestEnvironment(t)
+	defer teardown()
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("POST", testServer.URL+"/songs", bytes.NewBufferString(body))
+		require.NoError(t, err)
+		req.Header.Set(middleware.IdempotencyKeyHeader, "integration-key")
+		recorder := httptest.NewRecorder()
+		testRouter.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	body := `{"group": "Idempotent Group", "song": "Idempotent Song"}`
+	first := post(body)
+	require.Equal(t, http.StatusCreated, first.Code, first.Body.String())
+
+	retry := post(body)
+	assert.Equal(t, http.StatusCreated, retry.Code)
+	assert.Equal(t, "true", retry.Header().Get(middleware.IdempotentReplayedHeader))
+	assert.JSONEq(t, first.Body.String(), retry.Body.String())
+	assert.Equal(t, first.Header().Get("Location"), retry.Header().Get("Location"))
+
+	other := post(`{"group": "Other Group", "song": "Other Song"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, other.Code)
+}
+
+func TestConcurrentRequests_Integration(t *testing.T) {
+	teardown := setupTestEnvironment(t)
+	defer teardown()
+
+	testSong := addTestData(t)[0]
+
+	const workers = 32
+	var wg sync.WaitGroup
+	codes := make(chan int, workers*2)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes <- executeRequest(t, "GET", "/songs", "").Code
+			codes <- executeRequest(t, "GET", "/songs/"+strconv.Itoa(testSong.ID)+"/text", "").Code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+
+	for code := range codes {
+		assert.Equal(t, http.StatusOK, code)
+	}
+}
+
+func TestPgStorageConformance_Integration(t *testing.T) {
+	teardown := setupTestEnvironment(t)
+	defer teardown()
+
+	storagetest.Run(t, func(t *testing.T) storage.SongStorage {
+		cleanupTestData(t)
+		return pgStorage
+	})
+}
+
+func TestWithTx_Integration(t *testing.T) {
+	teardown := setupTestEnvironment(t)
+	defer teardown()
+
+	ctx := context.Background()
+
+	var committed *models.Song
+	err := pgStorage.WithTx(ctx, func(tx storage.SongStorage) error {
+		var err error
+		committed, err = tx.Create(ctx, &models.Song{GroupName: "Tx Group", SongName: "Committed Song"})
+		return err
+	})
+	require.NoError(t, err, "Expected transaction to commit")
+	_, err = pgStorage.GetByID(ctx, committed.ID)
+	assert.NoError(t, err, "Expected committed song to be visible")
+
+	errAbort := errors.New("abort")
+	var rolledBack *models.Song
+	err = pgStorage.WithTx(ctx, func(tx storage.SongStorage) error {
+		var err error
+		rolledBack, err = tx.Create(ctx, &models.Song{GroupName: "Tx Group", SongName: "Rolled Back Song"})
+		if err != nil {
+			return err
+		}
+		if err := tx.Delete(ctx, committed.ID, 0); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	assert.ErrorIs(t, err, errAbort)
+	_, err = pgStorage.GetByID(ctx, rolledBack.ID)
+	assert.ErrorIs(t, err, storage.ErrSongNotFound, "Expected rolled back insert to be discarded")
+	_, err = pgStorage.GetByID(ctx, committed.ID)
+	assert.NoError(t, err, "Expected rolled back delete to be discarded")
+
+	err = pgStorage.WithTx(ctx, func(tx storage.SongStorage) error {
+		if _, err := tx.Create(ctx, &models.Song{GroupName: "Tx Group", SongName: "Outer Song"}); err != nil {
+			return err
+		}
+		nestedErr := tx.WithTx(ctx, func(nested storage.SongStorage) error {
+			if _, err := nested.Create(ctx, &models.Song{GroupName: "Tx Group", SongName: "Inner Song"}); err != nil {
+				return err
+			}
+			return errAbort
+		})
+		assert.ErrorIs(t, nestedErr, errAbort)
+		return nil
+	})
+	require.NoError(t, err)
+
+	group := "Tx Group"
+	songs, err := pgStorage.List(ctx, &models.SongFilter{GroupName: &group}, models.NewPagination(1, 10))
+	require.NoError(t, err)
+	var names []string
+	for _, song := range songs {
+		names = append(names, song.SongName)
+	}
+	assert.ElementsMatch(t, []string{"Committed Song", "Outer Song"}, names)
+}
+
 func addTestData(t *testing.T) []models.Song {
 	songsToAdd := []models.Song{
 		{GroupName: "Test Group 1", SongName: "Test Song 1"},
@@ -221,7 +547,7 @@ func addTestData(t *testing.T) []models.Song {
 	addedSongs := make([]models.Song, len(songsToAdd))
 
 	for i, song := range songsToAdd {
-		addedSong, err := pgStorage.Create(context.Background(), &song, nil)
+		addedSong, err := pgStorage.Create(context.Background(), &song)
 		require.NoError(t, err, "Failed to add test song to DB")
 		addedSongs[i] = *addedSong
 	}
@@ -234,7 +560,7 @@ func addTestDataWithText(t *testing.T) models.Song {
 		SongName:  "Text Song",
 		Text:      sql.NullString{String: "Test Song Text", Valid: true},
 	}
-	addedSong, err := pgStorage.Create(context.Background(), &songToAdd, nil)
+	addedSong, err := pgStorage.Create(context.Background(), &songToAdd)
 	require.NoError(t, err, "Failed to add test song with text to DB")
 	return *addedSong
 }
@@ -251,15 +577,10 @@ func TestMain(m *testing.M) {
 }
 
 func runMigrations(dbURL string) error {
-	migrationSourceURL := "file://../internal/migrations"
-	m, err := migrate.New(migrationSourceURL, dbURL)
+	m, err := migrator.NewPostgres(dbURL, 0)
 	if err != nil {
-		return fmt.Errorf("failed to initialize migration: %w", err)
+		return err
 	}
-
-	if err := m.Up(); err != nil && err != migrate.ErrNoChange {
-		return fmt.Errorf("failed to run migrations: %w", err)
-	}
-
-	return nil
+	defer m.Close()
+	return m.Up()
 }